@@ -80,3 +80,14 @@ func (eh *ExecutionHeader) BlockHash() common.Hash {
 		panic(fmt.Errorf("unsupported ExecutionPayloadHeader type %T", obj))
 	}
 }
+
+func (eh *ExecutionHeader) BlockNumber() uint64 {
+	switch obj := eh.obj.(type) {
+	case *capella.ExecutionPayloadHeader:
+		return uint64(obj.BlockNumber)
+	case *deneb.ExecutionPayloadHeader:
+		return uint64(obj.BlockNumber)
+	default:
+		panic(fmt.Errorf("unsupported ExecutionPayloadHeader type %T", obj))
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/eth/protocols/snap"
 	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/rlpx"
 	"github.com/ethereum/go-ethereum/rlp"
 )
@@ -53,12 +54,18 @@ func (s *Suite) dial() (*Conn, error) {
 // dialAs attempts to dial a given node and perform a handshake using the given
 // private key.
 func (s *Suite) dialAs(key *ecdsa.PrivateKey) (*Conn, error) {
-	tcpEndpoint, _ := s.Dest.TCPEndpoint()
+	return dialNode(s.Dest, key)
+}
+
+// dialNode dials a node and performs the RLPx handshake using the given
+// private key.
+func dialNode(dest *enode.Node, key *ecdsa.PrivateKey) (*Conn, error) {
+	tcpEndpoint, _ := dest.TCPEndpoint()
 	fd, err := net.Dial("tcp", tcpEndpoint.String())
 	if err != nil {
 		return nil, err
 	}
-	conn := Conn{Conn: rlpx.NewConn(fd, s.Dest.Pubkey())}
+	conn := Conn{Conn: rlpx.NewConn(fd, dest.Pubkey())}
 	conn.ourKey = key
 	_, err = conn.Handshake(conn.ourKey)
 	if err != nil {
@@ -0,0 +1,169 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethtest
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/eth/relay"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ReplayStats counts the messages of a replay.
+type ReplayStats struct {
+	Sent     int // captured messages sent to the node
+	Received int // messages received from the node meanwhile
+}
+
+// DialPeer connects to a node and peers with it on eth/69. The status message
+// of the node is sent back as our own, so the node accepts the connection
+// whatever chain it is on.
+func DialPeer(dest *enode.Node) (*Conn, error) {
+	key, _ := crypto.GenerateKey()
+	c, err := dialNode(dest, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.handshake(); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("handshake failed: %v", err)
+	}
+	if err := c.echoStatus(); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("status exchange failed: %v", err)
+	}
+	return c, nil
+}
+
+// echoStatus waits for the status message of the node and sends it back.
+func (c *Conn) echoStatus() error {
+	for {
+		code, data, err := c.Read()
+		if err != nil {
+			return fmt.Errorf("failed to read from connection: %w", err)
+		}
+		switch code {
+		case eth.StatusMsg + protoOffset(ethProto):
+			c.SetWriteDeadline(time.Now().Add(timeout))
+			_, err := c.Conn.Write(code, data)
+			return err
+		case discMsg:
+			return fmt.Errorf("disconnect received: %v", decodeDisconnect(data))
+		case pingMsg:
+			c.Write(baseProto, pongMsg, []any{})
+		default:
+			return fmt.Errorf("bad status message: code %d", code)
+		}
+	}
+}
+
+// Replay sends the eth messages of a capture to the node, keeping the recorded
+// gaps between them divided by speed. With zero speed, messages are sent
+// without delay. Status messages are skipped, the connection is peered
+// already. Messages of the node are counted and dropped meanwhile, and for the
+// linger time after the last message was sent. Replay returns early if the node
+// disconnects.
+func (c *Conn) Replay(records []*relay.CaptureRecord, speed float64, linger time.Duration) (*ReplayStats, error) {
+	var (
+		lock     sync.Mutex // writes of the replay and the pong replies
+		received atomic.Int64
+		done     = make(chan error, 1)
+	)
+	write := func(code uint64, data []byte) error {
+		lock.Lock()
+		defer lock.Unlock()
+		c.SetWriteDeadline(time.Now().Add(timeout))
+		_, err := c.Conn.Write(code, data)
+		return err
+	}
+	go func() {
+		c.SetReadDeadline(time.Time{})
+		for {
+			code, data, _, err := c.Conn.Read()
+			switch {
+			case err != nil:
+				done <- err
+				return
+			case code == discMsg:
+				done <- fmt.Errorf("disconnect received: %v", decodeDisconnect(data))
+				return
+			case code == pingMsg:
+				pong, _ := rlp.EncodeToBytes([]any{})
+				write(pongMsg, pong)
+			default:
+				received.Add(1)
+			}
+		}
+	}()
+
+	var (
+		stats ReplayStats
+		last  time.Time
+	)
+	for _, rec := range records {
+		if rec.Proto != eth.ProtocolName || rec.Code == eth.StatusMsg {
+			continue
+		}
+		var wait <-chan time.Time
+		if speed > 0 && !last.IsZero() {
+			wait = time.After(time.Duration(float64(rec.Time.Sub(last)) / speed))
+		} else {
+			wait = time.After(0)
+		}
+		last = rec.Time
+		select {
+		case <-wait:
+		case err := <-done:
+			stats.Received = int(received.Load())
+			return &stats, err
+		}
+		if err := write(protoOffset(ethProto)+rec.Code, rec.Data); err != nil {
+			stats.Received = int(received.Load())
+			return &stats, err
+		}
+		stats.Sent++
+	}
+	var err error
+	select {
+	case <-time.After(linger):
+	case err = <-done:
+	}
+	stats.Received = int(received.Load())
+	return &stats, err
+}
+
+// decodeDisconnect returns the reason of a disconnect message. The reason is
+// sent in a list, or as is by some implementations.
+func decodeDisconnect(data []byte) error {
+	var reason p2p.DiscReason
+	s := rlp.NewStream(bytes.NewReader(data), uint64(len(data)))
+	if kind, _, err := s.Kind(); err == nil && kind == rlp.List {
+		s.List()
+	}
+	if err := s.Decode(&reason); err != nil {
+		return fmt.Errorf("invalid disconnect message %x", data)
+	}
+	return reason
+}
@@ -0,0 +1,108 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethtest
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/eth/relay"
+	"github.com/ethereum/go-ethereum/p2p/rlpx"
+)
+
+type wireMsg struct {
+	code uint64
+	data []byte
+}
+
+func TestReplay(t *testing.T) {
+	key1, _ := crypto.GenerateKey()
+	key2, _ := crypto.GenerateKey()
+	fd1, fd2 := net.Pipe()
+	c := &Conn{Conn: rlpx.NewConn(fd1, &key2.PublicKey), ourKey: key1}
+	defer c.Close()
+	node := rlpx.NewConn(fd2, nil)
+	defer node.Close()
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := node.Handshake(key2)
+		errc <- err
+	}()
+	if _, err := c.Handshake(key1); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	// The node pings and reads the replayed messages and the pong.
+	msgs := make(chan wireMsg, 3)
+	go func() {
+		if _, err := node.Write(pingMsg, []byte{0xc0}); err != nil {
+			errc <- err
+			return
+		}
+		for i := 0; i < 3; i++ {
+			code, data, _, err := node.Read()
+			if err != nil {
+				errc <- err
+				return
+			}
+			msgs <- wireMsg{code, bytes.Clone(data)} // data is reused by the next read
+		}
+		errc <- nil
+	}()
+
+	start := time.Now()
+	records := []*relay.CaptureRecord{
+		{Time: start, Proto: "eth", Code: eth.StatusMsg, Data: []byte{0xc0}},
+		{Time: start, Proto: "eth", Code: eth.TransactionsMsg, Data: []byte{0xc1, 0x01}},
+		{Time: start, Proto: "snap", Code: 0x00, Data: []byte{0xc0}},
+		{Time: start.Add(20 * time.Millisecond), Proto: "eth", Code: eth.GetBlockHeadersMsg, Data: []byte{0xc1, 0x02}},
+	}
+	stats, err := c.Replay(records, 2, 0)
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("replay took %v, want the gap halved", elapsed)
+	}
+	if stats.Sent != 2 {
+		t.Errorf("sent %d messages, want 2", stats.Sent)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[uint64][]byte{
+		pongMsg: {0xc0},
+		protoOffset(ethProto) + eth.TransactionsMsg:    {0xc1, 0x01},
+		protoOffset(ethProto) + eth.GetBlockHeadersMsg: {0xc1, 0x02},
+	}
+	for i := 0; i < 3; i++ {
+		msg := <-msgs
+		if !bytes.Equal(want[msg.code], msg.data) {
+			t.Errorf("message code %d: data %x, want %x", msg.code, msg.data, want[msg.code])
+		}
+		delete(want, msg.code)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/devp2p/internal/ethtest"
+	"github.com/ethereum/go-ethereum/eth/relay"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	relayCommand = &cli.Command{
+		Name:  "relay",
+		Usage: "Relay Commands",
+		Subcommands: []*cli.Command{
+			relayReplayCommand,
+		},
+	}
+	relayReplayCommand = &cli.Command{
+		Name:      "replay",
+		Usage:     "Replays the eth messages a peer sent to a relay from a capture",
+		ArgsUsage: "<node> <capture file>...",
+		Action:    relayReplay,
+		Flags: []cli.Flag{
+			replayPeerFlag,
+			replaySpeedFlag,
+			replayLingerFlag,
+		},
+	}
+)
+
+var (
+	replayPeerFlag = &cli.StringFlag{
+		Name:  "peer",
+		Usage: "Node ID of the captured peer to replay (default = first peer of the capture)",
+	}
+	replaySpeedFlag = &cli.Float64Flag{
+		Name:  "speed",
+		Usage: "Replay speed relative to the capture, 0 sends without delay",
+		Value: 1,
+	}
+	replayLingerFlag = &cli.DurationFlag{
+		Name:  "linger",
+		Usage: "How long to wait for messages of the node after the replay",
+		Value: 2 * time.Second,
+	}
+)
+
+// relayReplay peers with a node and sends it the messages one peer sent to the
+// relay, as recorded in the capture files.
+func relayReplay(ctx *cli.Context) error {
+	n := getNodeArg(ctx)
+	if ctx.NArg() < 2 {
+		return errors.New("missing capture file as command-line argument")
+	}
+	var peer enode.ID
+	if ctx.IsSet(replayPeerFlag.Name) {
+		id, err := enode.ParseID(ctx.String(replayPeerFlag.Name))
+		if err != nil {
+			return fmt.Errorf("invalid -%s: %v", replayPeerFlag.Name, err)
+		}
+		peer = id
+	}
+	var records []*relay.CaptureRecord
+	for _, file := range ctx.Args().Slice()[1:] {
+		recs, err := loadCapture(file, &peer)
+		if err != nil {
+			return err
+		}
+		records = append(records, recs...)
+	}
+	if len(records) == 0 {
+		return errors.New("no messages of the peer in the capture")
+	}
+
+	conn, err := ethtest.DialPeer(n)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stats, err := conn.Replay(records, ctx.Float64(replaySpeedFlag.Name), ctx.Duration(replayLingerFlag.Name))
+	fmt.Printf("Replayed %d messages of peer %v, received %d\n", stats.Sent, peer, stats.Received)
+	return err
+}
+
+// loadCapture reads the messages received from peer in a capture file. With a
+// zero peer, the first peer of the capture is selected.
+func loadCapture(file string, peer *enode.ID) ([]*relay.CaptureRecord, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		records []*relay.CaptureRecord
+		r       = relay.NewCaptureReader(f)
+	)
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid capture %s: %v", file, err)
+		}
+		if *peer == (enode.ID{}) {
+			*peer = rec.Peer
+		}
+		if rec.Peer == *peer && rec.Dir == relay.CaptureIn {
+			records = append(records, rec)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+)
+
+// Private transactions.
+//
+// eth_sendPrivateTransaction and eth_sendBundle never reach the public
+// mempool: they are not gossiped to peers and not sent to the upstream
+// endpoint, but forwarded to every configured block builder. Requests carry
+// the X-Flashbots-Signature header, a signature over the body by the relay's
+// builder key, which builders use to identify the sender.
+
+const (
+	builderTimeout         = 10 * time.Second
+	builderSignatureHeader = "X-Flashbots-Signature"
+	datadirBuilderKey      = "builderkey" // Path within the datadir to the builder signing key
+)
+
+var errNoBuilders = errors.New("no builder endpoints configured")
+
+// builderAPI provides the eth_sendPrivateTransaction and eth_sendBundle
+// methods.
+type builderAPI struct {
+	builders   []string
+	key        *ecdsa.PrivateKey // signs builder requests, nil if there are no builders
+	httpClient *http.Client
+	log        log.Logger
+}
+
+// builderReport is the outcome of forwarding a request to one builder.
+type builderReport struct {
+	Builder string          `json:"builder"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// builderError is returned when no builder accepted a request. The reports of
+// all builders are sent as error data.
+type builderError struct {
+	reports []builderReport
+}
+
+func (e *builderError) Error() string          { return "rejected by all builders" }
+func (e *builderError) ErrorData() interface{} { return e.reports }
+
+// bundleResult is the result of eth_sendBundle.
+type bundleResult struct {
+	BundleHash common.Hash     `json:"bundleHash"`
+	Builders   []builderReport `json:"builders"`
+}
+
+func newBuilderAPI(builders []string, key *ecdsa.PrivateKey) *builderAPI {
+	return &builderAPI{
+		builders:   builders,
+		key:        key,
+		httpClient: &http.Client{Timeout: builderTimeout},
+		log:        log.New("module", "builders"),
+	}
+}
+
+// loadBuilderKey loads the key signing builder requests from file, or from the
+// datadir if file is empty. A missing key in the datadir is generated.
+func loadBuilderKey(stack *node.Node, file string) (*ecdsa.PrivateKey, error) {
+	if file != "" {
+		key, err := crypto.LoadECDSA(file)
+		if err != nil {
+			return nil, fmt.Errorf("invalid builder key: %v", err)
+		}
+		return key, nil
+	}
+	if stack.DataDir() == "" {
+		return crypto.GenerateKey()
+	}
+	file = stack.ResolvePath(datadirBuilderKey)
+	if key, err := crypto.LoadECDSA(file); err == nil {
+		return key, nil
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := crypto.SaveECDSA(file, key); err != nil {
+		return nil, fmt.Errorf("failed to persist builder key: %v", err)
+	}
+	return key, nil
+}
+
+// SendPrivateTransaction handles eth_sendPrivateTransaction requests. The
+// arguments are forwarded to the builders unchanged, the transaction hash is
+// returned if at least one builder accepted it.
+func (api *builderAPI) SendPrivateTransaction(ctx context.Context, args json.RawMessage) (common.Hash, error) {
+	var req struct {
+		Tx hexutil.Bytes `json:"tx"`
+	}
+	if err := json.Unmarshal(args, &req); err != nil {
+		return common.Hash{}, fmt.Errorf("invalid arguments: %v", err)
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(req.Tx); err != nil {
+		return common.Hash{}, fmt.Errorf("invalid transaction: %v", err)
+	}
+	api.log.Info("Received private transaction", "hash", tx.Hash())
+
+	if _, err := api.forward(ctx, "eth_sendPrivateTransaction", args); err != nil {
+		return common.Hash{}, err
+	}
+	return tx.Hash(), nil
+}
+
+// SendBundle handles eth_sendBundle requests. The arguments are forwarded to
+// the builders unchanged. The result has the bundle hash, the hash of the
+// concatenated transaction hashes, and the outcome for every builder.
+func (api *builderAPI) SendBundle(ctx context.Context, args json.RawMessage) (*bundleResult, error) {
+	var req struct {
+		Txs         []hexutil.Bytes `json:"txs"`
+		BlockNumber hexutil.Uint64  `json:"blockNumber"`
+	}
+	if err := json.Unmarshal(args, &req); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %v", err)
+	}
+	if len(req.Txs) == 0 {
+		return nil, errors.New("bundle has no transactions")
+	}
+	if req.BlockNumber == 0 {
+		return nil, errors.New("bundle has no target block number")
+	}
+	hashes := make([]byte, 0, len(req.Txs)*common.HashLength)
+	for i, enc := range req.Txs {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(enc); err != nil {
+			return nil, fmt.Errorf("invalid transaction %d: %v", i, err)
+		}
+		hashes = append(hashes, tx.Hash().Bytes()...)
+	}
+	result := &bundleResult{BundleHash: crypto.Keccak256Hash(hashes)}
+	api.log.Info("Received bundle", "hash", result.BundleHash, "txs", len(req.Txs), "block", uint64(req.BlockNumber))
+
+	reports, err := api.forward(ctx, "eth_sendBundle", args)
+	if err != nil {
+		return nil, err
+	}
+	result.Builders = reports
+	return result, nil
+}
+
+// forward sends a request to all builders at once and returns their reports.
+// It fails if no builder accepted the request.
+func (api *builderAPI) forward(ctx context.Context, method string, args json.RawMessage) ([]builderReport, error) {
+	if len(api.builders) == 0 {
+		return nil, errNoBuilders
+	}
+	body, err := json.Marshal(&jsonrpcMessage{
+		Version: "2.0",
+		ID:      json.RawMessage("1"),
+		Method:  method,
+		Params:  json.RawMessage("[" + string(args) + "]"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	signature, err := api.sign(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		reports  = make([]builderReport, len(api.builders))
+		wg       sync.WaitGroup
+		accepted bool
+	)
+	for i, builder := range api.builders {
+		reports[i].Builder = builderName(builder)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := api.send(ctx, builder, body, signature)
+			if err != nil {
+				reports[i].Error = err.Error()
+				return
+			}
+			reports[i].Result = result
+		}()
+	}
+	wg.Wait()
+
+	for _, report := range reports {
+		if report.Error != "" {
+			api.log.Warn("Builder rejected request", "method", method, "builder", report.Builder, "err", report.Error)
+			continue
+		}
+		api.log.Debug("Builder accepted request", "method", method, "builder", report.Builder)
+		accepted = true
+	}
+	if !accepted {
+		return nil, &builderError{reports}
+	}
+	return reports, nil
+}
+
+// send posts a signed request to a builder and returns the result.
+func (api *builderAPI) send(ctx context.Context, builder string, body []byte, signature string) (json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, builder, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(builderSignatureHeader, signature)
+
+	resp, err := api.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return nil, err
+	}
+	var msg jsonrpcMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("HTTP status %s", resp.Status)
+		}
+		return nil, fmt.Errorf("invalid response: %v", err)
+	}
+	if msg.Error != nil {
+		return nil, fmt.Errorf("%s (code: %d)", msg.Error.Message, msg.Error.Code)
+	}
+	if len(msg.Result) == 0 {
+		return nil, errors.New("no result")
+	}
+	return msg.Result, nil
+}
+
+// sign returns the signature header of a request body: the address of the
+// builder key and its signature over the hex encoded hash of the body.
+func (api *builderAPI) sign(body []byte) (string, error) {
+	hash := accounts.TextHash([]byte(hexutil.Encode(crypto.Keccak256(body))))
+	sig, err := crypto.Sign(hash, api.key)
+	if err != nil {
+		return "", err
+	}
+	return crypto.PubkeyToAddress(api.key.PublicKey).Hex() + ":" + hexutil.Encode(sig), nil
+}
+
+// builderName returns the host of a builder endpoint, so credentials in the
+// URL are not reported.
+func builderName(builder string) string {
+	if u, err := url.Parse(builder); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return builder
+}
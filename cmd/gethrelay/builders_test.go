@@ -0,0 +1,276 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// testBuilder is a stand-in block builder endpoint. It records the requests
+// with a valid signature and answers with result, or with an error if result
+// is empty.
+type testBuilder struct {
+	t      *testing.T
+	signer common.Address
+	result string
+
+	mu       sync.Mutex
+	requests []jsonrpcMessage
+}
+
+func newTestBuilder(t *testing.T, signer common.Address, result string) (*testBuilder, string) {
+	b := &testBuilder{t: t, signer: signer, result: result}
+	srv := httptest.NewServer(b)
+	t.Cleanup(srv.Close)
+	return b, srv.URL
+}
+
+func (b *testBuilder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	addr, sig, ok := strings.Cut(r.Header.Get(builderSignatureHeader), ":")
+	if !ok {
+		http.Error(w, "missing signature", http.StatusUnauthorized)
+		return
+	}
+	hash := accounts.TextHash([]byte(hexutil.Encode(crypto.Keccak256(body))))
+	pub, err := crypto.SigToPub(hash, hexutil.MustDecode(sig))
+	if err != nil || crypto.PubkeyToAddress(*pub) != b.signer || common.HexToAddress(addr) != b.signer {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	var msg jsonrpcMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		b.t.Errorf("invalid builder request: %v", err)
+	}
+	b.mu.Lock()
+	b.requests = append(b.requests, msg)
+	b.mu.Unlock()
+
+	if b.result == "" {
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":{"code":-32000,"message":"bundle rejected"}}`, msg.ID)
+		return
+	}
+	fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":%s}`, msg.ID, b.result)
+}
+
+func (b *testBuilder) received() []jsonrpcMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.requests
+}
+
+// newTestBuilderProxy creates an RPC proxy forwarding to the given builders.
+// Its upstream fails the test when called.
+func newTestBuilderProxy(t *testing.T, key *ecdsa.PrivateKey, builders ...string) *rpcProxy {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		t.Errorf("upstream called: %s", body)
+	}))
+	t.Cleanup(upstream.Close)
+
+	localServer := rpc.NewServer()
+	if err := localServer.RegisterName("eth", &ethAPI{upstreamURL: upstream.URL, httpClient: &http.Client{}, log: testLogger}); err != nil {
+		t.Fatal(err)
+	}
+	if err := localServer.RegisterName("eth", newBuilderAPI(builders, key)); err != nil {
+		t.Fatal(err)
+	}
+	return newRPCProxy(upstream.URL, localServer)
+}
+
+func callProxy(t *testing.T, proxy *rpcProxy, method, params string) jsonrpcMessage {
+	t.Helper()
+	body := fmt.Sprintf(`{"jsonrpc":"2.0","method":"%s","params":[%s],"id":1}`, method, params)
+	req := httptest.NewRequest("POST", "/", bytes.NewReader([]byte(body)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+
+	var resp jsonrpcMessage
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return resp
+}
+
+func testSignedTx(t *testing.T, nonce uint64) (*types.Transaction, string) {
+	key, _ := crypto.GenerateKey()
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(common.Big1), &types.LegacyTx{Nonce: nonce, Gas: 21000, To: &common.Address{1}, GasPrice: common.Big1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, _ := tx.MarshalBinary()
+	return tx, hexutil.Encode(enc)
+}
+
+func TestSendPrivateTransaction(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := crypto.PubkeyToAddress(key.PublicKey)
+	good, goodURL := newTestBuilder(t, signer, `"0x01"`)
+	bad, badURL := newTestBuilder(t, signer, "")
+	proxy := newTestBuilderProxy(t, key, goodURL, badURL)
+
+	// The arguments reach all builders unchanged, the transaction hash is
+	// returned if one of them accepted it.
+	tx, enc := testSignedTx(t, 1)
+	args := fmt.Sprintf(`{"tx":"%s","maxBlockNumber":"0x10","preferences":{"fast":true}}`, enc)
+	resp := callProxy(t, proxy, "eth_sendPrivateTransaction", args)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error.Message)
+	}
+	var hash common.Hash
+	if err := json.Unmarshal(resp.Result, &hash); err != nil || hash != tx.Hash() {
+		t.Fatalf("wrong result %s, want %v", resp.Result, tx.Hash())
+	}
+	for _, b := range []*testBuilder{good, bad} {
+		reqs := b.received()
+		if len(reqs) != 1 || reqs[0].Method != "eth_sendPrivateTransaction" {
+			t.Fatalf("builder got wrong requests: %v", reqs)
+		}
+		var params []json.RawMessage
+		if err := json.Unmarshal(reqs[0].Params, &params); err != nil || len(params) != 1 || string(params[0]) != args {
+			t.Fatalf("builder got wrong params: %s", reqs[0].Params)
+		}
+	}
+
+	// Invalid transactions are not forwarded.
+	if resp := callProxy(t, proxy, "eth_sendPrivateTransaction", `{"tx":"0x1234"}`); resp.Error == nil {
+		t.Fatal("invalid transaction accepted")
+	}
+	if len(good.received()) != 1 {
+		t.Fatal("invalid transaction forwarded")
+	}
+}
+
+func TestSendBundle(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := crypto.PubkeyToAddress(key.PublicKey)
+	_, goodURL := newTestBuilder(t, signer, `{"bundleHash":"0x01"}`)
+	_, badURL := newTestBuilder(t, signer, "")
+	_, wrongKeyURL := newTestBuilder(t, common.Address{1}, `{"bundleHash":"0x01"}`)
+	proxy := newTestBuilderProxy(t, key, goodURL, badURL, wrongKeyURL)
+
+	tx1, enc1 := testSignedTx(t, 1)
+	tx2, enc2 := testSignedTx(t, 2)
+	resp := callProxy(t, proxy, "eth_sendBundle", fmt.Sprintf(`{"txs":["%s","%s"],"blockNumber":"0x100"}`, enc1, enc2))
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error.Message)
+	}
+	var result bundleResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	if want := crypto.Keccak256Hash(tx1.Hash().Bytes(), tx2.Hash().Bytes()); result.BundleHash != want {
+		t.Errorf("wrong bundle hash %v, want %v", result.BundleHash, want)
+	}
+
+	// Every builder's outcome is reported.
+	if len(result.Builders) != 3 {
+		t.Fatalf("wrong number of builder reports: %d", len(result.Builders))
+	}
+	for i, url := range []string{goodURL, badURL, wrongKeyURL} {
+		if report := result.Builders[i]; report.Builder != builderName(url) {
+			t.Errorf("report %d: wrong builder %s", i, report.Builder)
+		}
+	}
+	if r := result.Builders[0]; r.Error != "" || string(r.Result) != `{"bundleHash":"0x01"}` {
+		t.Errorf("accepting builder: wrong report %+v", r)
+	}
+	if r := result.Builders[1]; !strings.Contains(r.Error, "bundle rejected") {
+		t.Errorf("rejecting builder: wrong report %+v", r)
+	}
+	if r := result.Builders[2]; !strings.Contains(r.Error, "401") {
+		t.Errorf("builder with another key: wrong report %+v", r)
+	}
+
+	// Bundles need transactions and a target block.
+	for _, args := range []string{`{"txs":[],"blockNumber":"0x100"}`, fmt.Sprintf(`{"txs":["%s"]}`, enc1)} {
+		if resp := callProxy(t, proxy, "eth_sendBundle", args); resp.Error == nil {
+			t.Errorf("invalid bundle %s accepted", args)
+		}
+	}
+}
+
+func TestSendBundleRejected(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	_, badURL := newTestBuilder(t, crypto.PubkeyToAddress(key.PublicKey), "")
+	proxy := newTestBuilderProxy(t, key, badURL)
+
+	// The reports are sent as error data if no builder accepted the bundle.
+	_, enc := testSignedTx(t, 1)
+	resp := callProxy(t, proxy, "eth_sendBundle", fmt.Sprintf(`{"txs":["%s"],"blockNumber":"0x100"}`, enc))
+	if resp.Error == nil {
+		t.Fatal("rejected bundle reported as accepted")
+	}
+	data, _ := json.Marshal(resp.Error.Data)
+	var reports []builderReport
+	if err := json.Unmarshal(data, &reports); err != nil || len(reports) != 1 || !strings.Contains(reports[0].Error, "bundle rejected") {
+		t.Fatalf("wrong error data: %s", data)
+	}
+}
+
+func TestSendPrivateTransactionNoBuilders(t *testing.T) {
+	proxy := newTestBuilderProxy(t, nil)
+
+	// Without builders, private transactions fail instead of going upstream.
+	_, enc := testSignedTx(t, 1)
+	resp := callProxy(t, proxy, "eth_sendPrivateTransaction", fmt.Sprintf(`{"tx":"%s"}`, enc))
+	if resp.Error == nil || resp.Error.Message != errNoBuilders.Error() {
+		t.Fatalf("wrong response: %+v", resp.Error)
+	}
+}
+
+func TestLoadBuilderKey(t *testing.T) {
+	stack, err := node.New(&node.Config{DataDir: t.TempDir(), Name: clientIdentifier})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stack.Close()
+
+	// The generated key is kept in the datadir.
+	key1, err := loadBuilderKey(stack, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key2, err := loadBuilderKey(stack, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key1.Equal(key2) {
+		t.Fatal("builder key not persisted")
+	}
+	if _, err := loadBuilderKey(stack, stack.ResolvePath("missing")); err == nil {
+		t.Fatal("missing key file accepted")
+	}
+}
@@ -0,0 +1,603 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"time"
+
+	bparams "github.com/ethereum/go-ethereum/beacon/params"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/eth/relay"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/nat"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/naoina/toml"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	configFileFlag = &cli.StringFlag{
+		Name:  "config",
+		Usage: "TOML configuration file; command line flags override its values",
+	}
+
+	dumpConfigCommand = &cli.Command{
+		Action:      dumpConfig,
+		Name:        "dumpconfig",
+		Usage:       "Export configuration values in a TOML format",
+		ArgsUsage:   "<dumpfile (optional)>",
+		Flags:       slices.Concat([]cli.Flag{configFileFlag}, relayFlags),
+		Description: `Export configuration values in TOML format (to stdout by default).`,
+	}
+)
+
+// These settings ensure that TOML keys use the same names as Go struct fields.
+var tomlSettings = toml.Config{
+	NormFieldName: func(rt reflect.Type, key string) string {
+		return key
+	},
+	FieldToKey: func(rt reflect.Type, field string) string {
+		return field
+	},
+	MissingField: func(rt reflect.Type, field string) error {
+		return fmt.Errorf("field '%s' is not defined in %s", field, rt.String())
+	},
+}
+
+// gethrelayConfig is the content of the TOML configuration file. Zero network
+// ID, genesis hash, latest block hash and bootstrap nodes select the defaults
+// of the chain preset.
+type gethrelayConfig struct {
+	Chain        string        // chain preset: mainnet, holesky or sepolia
+	DrainTimeout time.Duration // how long shutdown waits for requests and queues
+	Ethstats     string        // ethstats reporting URL, nodename:secret@host:port
+	Relay        relay.Config
+	Node         node.Config
+	RPC          rpcProxyConfig
+	Health       healthConfig
+	Networks     []networkConfig // further chains served by the same process
+}
+
+// rpcProxyConfig configures the JSON-RPC proxy.
+type rpcProxyConfig struct {
+	Upstream   string // upstream RPC endpoint for all methods except the transaction submissions
+	HTTPHost   string
+	HTTPPort   int
+	Builders   []string // builder endpoints for eth_sendPrivateTransaction and eth_sendBundle
+	BuilderKey string   // key file signing builder requests, <datadir>/gethrelay/builderkey if empty
+
+	P2PFallback bool // answer block and transaction reads from peers when the upstream is down
+}
+
+// chainPreset holds the parameters of a supported chain.
+type chainPreset struct {
+	config    *params.ChainConfig
+	genesis   func() *core.Genesis
+	hash      common.Hash
+	networkID uint64
+	bootnodes []string
+	beacon    *bparams.ChainConfig // beacon chain of the light client
+	upstream  string               // public RPC endpoint
+}
+
+var chainPresets = map[string]chainPreset{
+	"mainnet": {params.MainnetChainConfig, core.DefaultGenesisBlock, params.MainnetGenesisHash, 1, params.MainnetBootnodes, bparams.MainnetLightConfig, "https://ethereum-rpc.publicnode.com"},
+	"holesky": {params.HoleskyChainConfig, core.DefaultHoleskyGenesisBlock, params.HoleskyGenesisHash, 17000, params.HoleskyBootnodes, bparams.HoleskyLightConfig, "https://ethereum-holesky-rpc.publicnode.com"},
+	"sepolia": {params.SepoliaChainConfig, core.DefaultSepoliaGenesisBlock, params.SepoliaGenesisHash, 11155111, params.SepoliaBootnodes, bparams.SepoliaLightConfig, "https://ethereum-sepolia-rpc.publicnode.com"},
+}
+
+// defaultConfig returns the configuration used when neither the configuration
+// file nor the flags set a value. It matches the flag defaults.
+func defaultConfig() *gethrelayConfig {
+	return &gethrelayConfig{
+		Chain:        "mainnet",
+		DrainTimeout: 20 * time.Second,
+		Relay: relay.Config{
+			BanThreshold:    -100,
+			BanDuration:     time.Hour,
+			QueueLimit:      4 * 1024 * 1024,
+			QueuePolicy:     relay.DropGossip,
+			SlowPeerTimeout: 30 * time.Second,
+			Dandelion: relay.DandelionConfig{
+				FluffProbability: 0.1,
+				Embargo:          30 * time.Second,
+				Epoch:            10 * time.Minute,
+			},
+			LightClient: relay.LightClientConfig{
+				Threshold: bparams.SyncCommitteeSupermajority,
+			},
+			Overlay: relay.OverlayConfig{
+				Links: 4,
+			},
+			Capture: relay.CaptureConfig{
+				MaxSize:  100,
+				MaxFiles: 10,
+			},
+		},
+		Node: node.Config{
+			Name: clientIdentifier,
+			P2P: p2p.Config{
+				MaxPeers:   200,
+				ListenAddr: ":30303",
+			},
+			// The hidden service is off until a control port is configured.
+			Tor: node.TorConfig{
+				CookiePath:           node.DefaultTorCookiePath,
+				HiddenServiceDir:     node.DefaultTorServiceDir,
+				P2POnly:              true,
+				AuthorizedClientsDir: node.DefaultTorAuthorizedDir,
+				ClientAuthDir:        node.DefaultTorClientAuthDir,
+			},
+		},
+		RPC: rpcProxyConfig{
+			Upstream: "https://ethereum-rpc.publicnode.com",
+			HTTPHost: node.DefaultHTTPHost,
+			HTTPPort: node.DefaultHTTPPort,
+		},
+		Health: healthConfig{
+			MinPeers:      1,
+			MaxQueueDepth: 1000,
+		},
+	}
+}
+
+// loadConfig reads a TOML configuration file into cfg.
+func loadConfig(file string, cfg *gethrelayConfig) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = tomlSettings.NewDecoder(bufio.NewReader(f)).Decode(cfg)
+	// Add file name to errors that have a line number.
+	if _, ok := err.(*toml.LineError); ok {
+		err = errors.New(file + ", " + err.Error())
+	}
+	return err
+}
+
+// makeConfig assembles the configuration from the defaults, the configuration
+// file and the command line flags, in increasing order of precedence.
+func makeConfig(ctx *cli.Context) (*gethrelayConfig, error) {
+	cfg := defaultConfig()
+	if file := ctx.String(configFileFlag.Name); file != "" {
+		if err := loadConfig(file, cfg); err != nil {
+			return nil, fmt.Errorf("invalid config file: %v", err)
+		}
+	}
+	if err := applyFlags(ctx, cfg); err != nil {
+		return nil, err
+	}
+	// The node name is not part of the file.
+	cfg.Node.Name = clientIdentifier
+	if err := cfg.check(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// resolve fills in the defaults of the chain preset and the settings implied by
+// others.
+func (cfg *gethrelayConfig) resolve() {
+	preset := chainPresets[cfg.Chain]
+	p2pcfg := &cfg.Node.P2P
+
+	if cfg.Relay.NetworkID == 0 {
+		cfg.Relay.NetworkID = preset.networkID
+	}
+	if cfg.Relay.GenesisHash == (common.Hash{}) {
+		cfg.Relay.GenesisHash = preset.hash
+	}
+	if cfg.Relay.BlockRange.LatestBlockHash == (common.Hash{}) && cfg.Relay.BlockRange.LatestBlock == 0 {
+		// If no latest block specified, use genesis block hash for ETH69 compatibility
+		// ETH69 requires a non-empty LatestBlockHash in the status packet
+		cfg.Relay.BlockRange.LatestBlockHash = cfg.Relay.GenesisHash
+	}
+	if len(p2pcfg.BootstrapNodes) == 0 {
+		p2pcfg.BootstrapNodes = mustParseBootnodes(preset.bootnodes)
+	}
+
+	// UDP discovery is unavailable over Tor, peer exchange replaces it
+	if p2pcfg.OnlyOnion || p2pcfg.ClearnetViaTor {
+		cfg.Relay.PeerExchange = true
+	}
+	// Discovery traffic is UDP and cannot be proxied, it would reveal the
+	// relay's address when all peer traffic is meant to go through Tor.
+	if p2pcfg.ClearnetViaTor {
+		p2pcfg.NoDiscovery = true
+		p2pcfg.DiscoveryV4 = false
+		p2pcfg.DiscoveryV5 = false
+	}
+	for i := range cfg.Networks {
+		cfg.Networks[i].resolve()
+	}
+}
+
+// applyFlags overrides configuration values with the flags set on the command
+// line or through the environment.
+func applyFlags(ctx *cli.Context, cfg *gethrelayConfig) error {
+	// Network and chain
+	if ctx.IsSet("chain") {
+		cfg.Chain = ctx.String("chain")
+	}
+	if ctx.IsSet("networkid") {
+		cfg.Relay.NetworkID = ctx.Uint64("networkid")
+	}
+	if ctx.IsSet("genesis") {
+		cfg.Relay.GenesisHash = common.HexToHash(ctx.String("genesis"))
+	}
+	if ctx.IsSet("earliest-block") {
+		cfg.Relay.BlockRange.EarliestBlock = ctx.Uint64("earliest-block")
+	}
+	if ctx.IsSet("latest-block") {
+		cfg.Relay.BlockRange.LatestBlock = ctx.Uint64("latest-block")
+	}
+	if ctx.IsSet("latest-hash") {
+		cfg.Relay.BlockRange.LatestBlockHash = common.HexToHash(ctx.String("latest-hash"))
+	}
+
+	// Node and P2P
+	if ctx.IsSet("identity") {
+		cfg.Node.UserIdent = ctx.String("identity")
+	}
+	if ctx.IsSet("datadir") {
+		cfg.Node.DataDir = ctx.String("datadir")
+	}
+	if ctx.IsSet("port") {
+		cfg.Node.P2P.ListenAddr = fmt.Sprintf(":%d", ctx.Int("port"))
+	}
+	if ctx.IsSet("maxpeers") {
+		cfg.Node.P2P.MaxPeers = ctx.Int("maxpeers")
+	}
+	if ctx.IsSet("v4disc") {
+		cfg.Node.P2P.DiscoveryV4 = ctx.Bool("v4disc")
+	}
+	if ctx.IsSet("v5disc") {
+		cfg.Node.P2P.DiscoveryV5 = ctx.Bool("v5disc")
+	}
+	if ctx.IsSet("nodiscover") {
+		cfg.Node.P2P.NoDiscovery = ctx.Bool("nodiscover")
+	}
+	if ctx.IsSet("nat") {
+		natif, err := nat.Parse(ctx.String("nat"))
+		if err != nil {
+			return fmt.Errorf("invalid NAT option: %v", err)
+		}
+		cfg.Node.P2P.NAT = natif
+	}
+	if ctx.IsSet("netrestrict") {
+		list, err := netutil.ParseNetlist(ctx.String("netrestrict"))
+		if err != nil {
+			return fmt.Errorf("invalid --netrestrict: %v", err)
+		}
+		cfg.Node.P2P.NetRestrict = list
+	}
+	if ctx.IsSet("bootnodes") {
+		cfg.Node.P2P.BootstrapNodes = mustParseBootnodes(splitAndTrim(ctx.String("bootnodes")))
+	}
+	if ctx.IsSet("staticnodes") {
+		cfg.Node.P2P.StaticNodes = mustParseBootnodes(splitAndTrim(ctx.String("staticnodes")))
+	}
+
+	// Tor, I2P and outbound proxy
+	if ctx.IsSet("tor-proxy") {
+		cfg.Node.P2P.TorSOCKSProxy = ctx.String("tor-proxy")
+	}
+	if ctx.IsSet("prefer-tor") {
+		cfg.Node.P2P.PreferTor = ctx.Bool("prefer-tor")
+	}
+	if ctx.IsSet("only-onion") {
+		cfg.Node.P2P.OnlyOnion = ctx.Bool("only-onion")
+	}
+	if ctx.IsSet("tor-all") {
+		cfg.Node.P2P.ClearnetViaTor = ctx.Bool("tor-all")
+	}
+	if ctx.IsSet("min-onion-peers") {
+		cfg.Node.P2P.MinOnionPeers = ctx.Int("min-onion-peers")
+	}
+	if ctx.IsSet("max-onion-peers") {
+		cfg.Node.P2P.MaxOnionPeers = ctx.Int("max-onion-peers")
+	}
+	if ctx.IsSet("max-clearnet-inbound") {
+		cfg.Node.P2P.MaxClearnetInbound = ctx.Int("max-clearnet-inbound")
+	}
+	if ctx.IsSet("reserved-onion-slots") {
+		cfg.Node.P2P.ReservedOnionSlots = ctx.Int("reserved-onion-slots")
+	}
+	if ctx.IsSet("proxy") {
+		cfg.Node.P2P.ClearnetProxy = ctx.String("proxy")
+	}
+	if ctx.IsSet("i2p-sam") {
+		cfg.Node.P2P.I2PSAMAddress = ctx.String("i2p-sam")
+	}
+	if ctx.IsSet("prefer-i2p") {
+		cfg.Node.P2P.PreferI2P = ctx.Bool("prefer-i2p")
+	}
+	if ctx.IsSet("only-i2p") {
+		cfg.Node.P2P.OnlyI2P = ctx.Bool("only-i2p")
+	}
+	if ctx.IsSet("tor-control") {
+		cfg.Node.Tor.Enabled = true
+		cfg.Node.Tor.ControlAddress = ctx.String("tor-control")
+	}
+	if ctx.IsSet("tor-cookie") {
+		cfg.Node.Tor.CookiePath = ctx.String("tor-cookie")
+	}
+	if ctx.IsSet("pex") {
+		cfg.Relay.PeerExchange = ctx.Bool("pex")
+	}
+
+	// JSON-RPC proxy and admin API
+	if ctx.IsSet("rpc.upstream") {
+		cfg.RPC.Upstream = ctx.String("rpc.upstream")
+	}
+	if ctx.IsSet("rpc.builders") {
+		cfg.RPC.Builders = splitAndTrim(ctx.String("rpc.builders"))
+	}
+	if ctx.IsSet("rpc.builder-key") {
+		cfg.RPC.BuilderKey = ctx.String("rpc.builder-key")
+	}
+	if ctx.IsSet("rpc.p2p-fallback") {
+		cfg.RPC.P2PFallback = ctx.Bool("rpc.p2p-fallback")
+	}
+	if ctx.IsSet("http.addr") {
+		cfg.RPC.HTTPHost = ctx.String("http.addr")
+	}
+	if ctx.IsSet("http.port") {
+		cfg.RPC.HTTPPort = ctx.Int("http.port")
+	}
+	if ctx.Bool("admin") {
+		cfg.Node.HTTPHost = ctx.String("admin.addr")
+		cfg.Node.HTTPPort = ctx.Int("admin.port")
+		cfg.Node.HTTPModules = []string{"admin", "relay"}
+		// WebSocket on the same port carries relay_subscribe notifications
+		cfg.Node.WSHost = cfg.Node.HTTPHost
+		cfg.Node.WSPort = cfg.Node.HTTPPort
+		cfg.Node.WSModules = cfg.Node.HTTPModules
+	}
+
+	// Reputation, bandwidth and queues
+	if ctx.IsSet("ban-threshold") {
+		cfg.Relay.BanThreshold = ctx.Int("ban-threshold")
+	}
+	if ctx.IsSet("ban-duration") {
+		cfg.Relay.BanDuration = ctx.Duration("ban-duration")
+	}
+	if ctx.IsSet("bandwidth.egress") {
+		cfg.Relay.Bandwidth.Egress = ctx.Uint64("bandwidth.egress")
+	}
+	if ctx.IsSet("bandwidth.ingress") {
+		cfg.Relay.Bandwidth.Ingress = ctx.Uint64("bandwidth.ingress")
+	}
+	if ctx.IsSet("bandwidth.peer-egress") {
+		cfg.Relay.Bandwidth.PeerEgress = ctx.Uint64("bandwidth.peer-egress")
+	}
+	if ctx.IsSet("bandwidth.peer-ingress") {
+		cfg.Relay.Bandwidth.PeerIngress = ctx.Uint64("bandwidth.peer-ingress")
+	}
+	if ctx.IsSet("queue.limit") {
+		cfg.Relay.QueueLimit = ctx.Int("queue.limit")
+	}
+	if ctx.IsSet("queue.policy") {
+		policy, err := relay.ParseDropPolicy(ctx.String("queue.policy"))
+		if err != nil {
+			return err
+		}
+		cfg.Relay.QueuePolicy = policy
+	}
+	if ctx.IsSet("queue.slow-peer-timeout") {
+		cfg.Relay.SlowPeerTimeout = ctx.Duration("queue.slow-peer-timeout")
+	}
+	if ctx.IsSet("broadcast.delay") {
+		cfg.Relay.BroadcastDelay = ctx.Duration("broadcast.delay")
+	}
+	if ctx.IsSet("dandelion") {
+		cfg.Relay.Dandelion.Enabled = ctx.Bool("dandelion")
+	}
+	if ctx.IsSet("dandelion.fluff-probability") {
+		cfg.Relay.Dandelion.FluffProbability = ctx.Float64("dandelion.fluff-probability")
+	}
+	if ctx.IsSet("dandelion.embargo") {
+		cfg.Relay.Dandelion.Embargo = ctx.Duration("dandelion.embargo")
+	}
+	if ctx.IsSet("dandelion.epoch") {
+		cfg.Relay.Dandelion.Epoch = ctx.Duration("dandelion.epoch")
+	}
+	if ctx.IsSet("networks") {
+		networks, err := parseNetworks(ctx.String("networks"))
+		if err != nil {
+			return err
+		}
+		cfg.Networks = networks
+	}
+	if ctx.IsSet("beacon.api") {
+		cfg.Relay.LightClient.BeaconAPIs = splitAndTrim(ctx.String("beacon.api"))
+	}
+	if ctx.IsSet("beacon.checkpoint") {
+		checkpoint, err := hexutil.Decode(ctx.String("beacon.checkpoint"))
+		if err != nil || len(checkpoint) != common.HashLength {
+			return fmt.Errorf("invalid --beacon.checkpoint: %q", ctx.String("beacon.checkpoint"))
+		}
+		cfg.Relay.LightClient.Checkpoint = common.BytesToHash(checkpoint)
+	}
+	if ctx.IsSet("beacon.threshold") {
+		cfg.Relay.LightClient.Threshold = ctx.Int("beacon.threshold")
+	}
+	if ctx.IsSet("overlay.links") {
+		cfg.Relay.Overlay.Links = ctx.Int("overlay.links")
+	}
+	if ctx.IsSet("overlay.operator") {
+		operator, err := hexutil.Decode(ctx.String("overlay.operator"))
+		if err != nil {
+			return fmt.Errorf("invalid --overlay.operator: %q", ctx.String("overlay.operator"))
+		}
+		cfg.Relay.Overlay.Operator = operator
+	}
+	if ctx.IsSet("capture.file") {
+		cfg.Relay.Capture.File = ctx.String("capture.file")
+	}
+	if ctx.IsSet("capture.maxsize") {
+		cfg.Relay.Capture.MaxSize = ctx.Int("capture.maxsize")
+	}
+	if ctx.IsSet("capture.maxfiles") {
+		cfg.Relay.Capture.MaxFiles = ctx.Int("capture.maxfiles")
+	}
+
+	if ctx.IsSet("ethstats") {
+		cfg.Ethstats = ctx.String("ethstats")
+	}
+
+	// Readiness checks and shutdown
+	if ctx.IsSet("drain.timeout") {
+		cfg.DrainTimeout = ctx.Duration("drain.timeout")
+	}
+	if ctx.IsSet("health.min-peers") {
+		cfg.Health.MinPeers = ctx.Int("health.min-peers")
+	}
+	if ctx.IsSet("health.onion") {
+		cfg.Health.RequireOnion = ctx.Bool("health.onion")
+	}
+	if ctx.IsSet("health.upstream") {
+		cfg.Health.CheckUpstream = ctx.Bool("health.upstream")
+	}
+	if ctx.IsSet("health.max-queue") {
+		cfg.Health.MaxQueueDepth = ctx.Int("health.max-queue")
+	}
+	if ctx.IsSet("health.max-idle") {
+		cfg.Health.MaxIdle = ctx.Duration("health.max-idle")
+	}
+	return nil
+}
+
+// check validates the combined configuration.
+func (cfg *gethrelayConfig) check() error {
+	p2pcfg := &cfg.Node.P2P
+	if _, ok := chainPresets[cfg.Chain]; !ok {
+		return fmt.Errorf("unknown chain preset: %s", cfg.Chain)
+	}
+	if p2pcfg.OnlyOnion && p2pcfg.TorSOCKSProxy == "" {
+		return fmt.Errorf("--only-onion requires --tor-proxy to be set")
+	}
+	if p2pcfg.OnlyI2P && p2pcfg.I2PSAMAddress == "" {
+		return fmt.Errorf("--only-i2p requires --i2p-sam to be set")
+	}
+	if p2pcfg.ClearnetViaTor && p2pcfg.TorSOCKSProxy == "" {
+		return fmt.Errorf("--tor-all requires --tor-proxy to be set")
+	}
+	if p2pcfg.ClearnetViaTor && p2pcfg.ClearnetProxy != "" {
+		return fmt.Errorf("--tor-all and --proxy are mutually exclusive")
+	}
+	if cfg.Relay.BanThreshold >= 0 {
+		return fmt.Errorf("--ban-threshold must be negative")
+	}
+	if cfg.Relay.BanDuration <= 0 {
+		return fmt.Errorf("--ban-duration must be positive")
+	}
+	if cfg.Relay.QueueLimit <= 0 {
+		return fmt.Errorf("--queue.limit must be positive")
+	}
+	if cfg.Relay.SlowPeerTimeout <= 0 {
+		return fmt.Errorf("--queue.slow-peer-timeout must be positive")
+	}
+	if _, err := relay.ParseDropPolicy(string(cfg.Relay.QueuePolicy)); err != nil {
+		return err
+	}
+	for _, builder := range cfg.RPC.Builders {
+		if u, err := url.Parse(builder); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid builder endpoint %q", builder)
+		}
+	}
+	if cfg.Relay.BroadcastDelay < 0 {
+		return fmt.Errorf("--broadcast.delay must not be negative")
+	}
+	if p := cfg.Relay.Dandelion.FluffProbability; p <= 0 || p > 1 {
+		return fmt.Errorf("--dandelion.fluff-probability must be in (0, 1]")
+	}
+	if cfg.Relay.Dandelion.Embargo <= 0 || cfg.Relay.Dandelion.Epoch <= 0 {
+		return fmt.Errorf("--dandelion.embargo and --dandelion.epoch must be positive")
+	}
+	for _, api := range cfg.Relay.LightClient.BeaconAPIs {
+		if u, err := url.Parse(api); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid beacon API endpoint %q", api)
+		}
+	}
+	if t := cfg.Relay.LightClient.Threshold; t < 1 || t > bparams.SyncCommitteeSize {
+		return fmt.Errorf("--beacon.threshold must be between 1 and %d", bparams.SyncCommitteeSize)
+	}
+	if cfg.Relay.Overlay.Links < 0 {
+		return fmt.Errorf("--overlay.links must not be negative")
+	}
+	if len(cfg.Relay.Overlay.Operator) > 0 {
+		if _, err := relay.ParseOperatorKey(cfg.Relay.Overlay.Operator); err != nil {
+			return fmt.Errorf("--overlay.operator: %v", err)
+		}
+	}
+	if cfg.Relay.Capture.MaxSize <= 0 {
+		return fmt.Errorf("--capture.maxsize must be positive")
+	}
+	if cfg.Relay.Capture.MaxFiles < 0 {
+		return fmt.Errorf("--capture.maxfiles must not be negative")
+	}
+	if err := cfg.checkNetworks(); err != nil {
+		return err
+	}
+	if cfg.Health.MinPeers < 0 || cfg.Health.MaxQueueDepth < 0 || cfg.Health.MaxIdle < 0 {
+		return fmt.Errorf("--health.min-peers, --health.max-queue and --health.max-idle must not be negative")
+	}
+	if cfg.DrainTimeout < 0 {
+		return fmt.Errorf("--drain.timeout must not be negative")
+	}
+	if cfg.Health.RequireOnion && !cfg.Node.Tor.Enabled {
+		return fmt.Errorf("--health.onion requires --tor-control to be set")
+	}
+	return nil
+}
+
+// dumpConfig is the dumpconfig command.
+func dumpConfig(ctx *cli.Context) error {
+	cfg, err := makeConfig(ctx)
+	if err != nil {
+		return err
+	}
+	out, err := tomlSettings.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	dump := os.Stdout
+	if ctx.NArg() > 0 {
+		dump, err = os.OpenFile(ctx.Args().Get(0), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		defer dump.Close()
+	}
+	dump.Write(out)
+	return nil
+}
@@ -18,8 +18,16 @@ package main
 
 import (
 	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/eth/relay"
 	"github.com/urfave/cli/v2"
 )
 
@@ -301,3 +309,210 @@ func TestTorConfigMapping(t *testing.T) {
 		})
 	}
 }
+
+// runMakeConfig runs makeConfig with the given command line arguments.
+func runMakeConfig(t *testing.T, args ...string) (*gethrelayConfig, error) {
+	t.Helper()
+	var (
+		cfg *gethrelayConfig
+		err error
+	)
+	app := &cli.App{
+		Flags: slices.Concat([]cli.Flag{configFileFlag}, relayFlags),
+		Action: func(ctx *cli.Context) error {
+			cfg, err = makeConfig(ctx)
+			return nil
+		},
+	}
+	if runErr := app.Run(append([]string{"gethrelay"}, args...)); runErr != nil {
+		t.Fatalf("app.Run() error = %v", runErr)
+	}
+	return cfg, err
+}
+
+// TestConfigFile tests that the config file is loaded and flags override it
+func TestConfigFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "relay.toml")
+	content := `Chain = "sepolia"
+
+[Relay]
+BanThreshold = -50
+QueuePolicy = "drop-oldest"
+
+[Node.P2P]
+MaxPeers = 30
+TorSOCKSProxy = "127.0.0.1:9050"
+OnlyOnion = true
+
+[RPC]
+Upstream = "http://127.0.0.1:8545"
+`
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := runMakeConfig(t, "--config", file, "--maxpeers", "40", "--ban-duration", "2h")
+	if err != nil {
+		t.Fatalf("makeConfig() error = %v", err)
+	}
+	if cfg.Chain != "sepolia" {
+		t.Errorf("Chain = %q, want sepolia", cfg.Chain)
+	}
+	if cfg.Relay.BanThreshold != -50 || cfg.Relay.QueuePolicy != "drop-oldest" {
+		t.Errorf("relay settings not loaded: %+v", cfg.Relay)
+	}
+	if cfg.Node.P2P.MaxPeers != 40 {
+		t.Errorf("MaxPeers = %d, want flag value 40", cfg.Node.P2P.MaxPeers)
+	}
+	if cfg.Relay.BanDuration != 2*time.Hour {
+		t.Errorf("BanDuration = %v, want flag value 2h", cfg.Relay.BanDuration)
+	}
+	if !cfg.Node.P2P.OnlyOnion || cfg.Node.P2P.TorSOCKSProxy != "127.0.0.1:9050" {
+		t.Error("Tor settings not loaded")
+	}
+	if cfg.RPC.Upstream != "http://127.0.0.1:8545" {
+		t.Errorf("Upstream = %q", cfg.RPC.Upstream)
+	}
+	// Values neither in the file nor on the command line keep their defaults.
+	if cfg.Relay.SlowPeerTimeout != 30*time.Second || cfg.Node.P2P.ListenAddr != ":30303" {
+		t.Error("defaults not applied")
+	}
+
+	// Validation applies to the combined configuration.
+	if _, err := runMakeConfig(t, "--config", file, "--tor-all", "--proxy", "socks5://127.0.0.1:1080"); err == nil {
+		t.Error("expected --tor-all with --proxy to fail")
+	}
+}
+
+// TestConfigFileErrors tests that invalid config files are rejected
+func TestConfigFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"unknown field": "[Relay]\nQueueSize = 1\n",
+		"bad chain":     "Chain = \"goerli\"\n",
+		"bad policy":    "[Relay]\nQueuePolicy = \"drop-all\"\n",
+		"bad fluff":     "[Relay.Dandelion]\nFluffProbability = 1.5\n",
+		"bad delay":     "[Relay]\nBroadcastDelay = -1\n",
+		"bad beacon":    "[Relay.LightClient]\nBeaconAPIs = [\"localhost:5052\"]\n",
+		"bad threshold": "[Relay.LightClient]\nThreshold = 513\n",
+		"bad links":     "[Relay.Overlay]\nLinks = -1\n",
+		"bad operator":  "[Relay.Overlay]\nOperator = \"0x0102\"\n",
+		"bad capture":   "[Relay.Capture]\nMaxSize = 0\n",
+	} {
+		file := filepath.Join(dir, strings.ReplaceAll(name, " ", "-")+".toml")
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := runMakeConfig(t, "--config", file); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := runMakeConfig(t, "--config", filepath.Join(dir, "missing.toml")); err == nil {
+		t.Error("missing file: expected error")
+	}
+}
+
+// TestBeaconFlags tests the light client flags
+func TestBeaconFlags(t *testing.T) {
+	cfg, err := runMakeConfig(t,
+		"--beacon.api", "http://127.0.0.1:5052, https://beacon.example.com",
+		"--beacon.checkpoint", "0x0102030405060708091011121314151617181920212223242526272829303132",
+		"--beacon.threshold", "400",
+	)
+	if err != nil {
+		t.Fatalf("makeConfig() error = %v", err)
+	}
+	lc := cfg.Relay.LightClient
+	if !slices.Equal(lc.BeaconAPIs, []string{"http://127.0.0.1:5052", "https://beacon.example.com"}) {
+		t.Errorf("BeaconAPIs = %v", lc.BeaconAPIs)
+	}
+	if lc.Checkpoint != common.HexToHash("0x0102030405060708091011121314151617181920212223242526272829303132") {
+		t.Errorf("Checkpoint = %x", lc.Checkpoint)
+	}
+	if lc.Threshold != 400 {
+		t.Errorf("Threshold = %d, want 400", lc.Threshold)
+	}
+	if _, err := runMakeConfig(t, "--beacon.checkpoint", "0x0102"); err == nil {
+		t.Error("expected short checkpoint to fail")
+	}
+}
+
+func TestOverlayFlags(t *testing.T) {
+	cfg, err := runMakeConfig(t)
+	if err != nil {
+		t.Fatalf("makeConfig() error = %v", err)
+	}
+	if cfg.Relay.Overlay.Links != 4 || cfg.Relay.Overlay.Operator != nil {
+		t.Errorf("default overlay = %+v", cfg.Relay.Overlay)
+	}
+	cfg, err = runMakeConfig(t,
+		"--overlay.links", "8",
+		"--overlay.operator", "0x03ca634cae0d49acb401d8a4c6b6fe8c55b70d115bf400769cc1400f3258cd3138",
+	)
+	if err != nil {
+		t.Fatalf("makeConfig() error = %v", err)
+	}
+	if cfg.Relay.Overlay.Links != 8 {
+		t.Errorf("Links = %d, want 8", cfg.Relay.Overlay.Links)
+	}
+	if got := hexutil.Encode(cfg.Relay.Overlay.Operator); got != "0x03ca634cae0d49acb401d8a4c6b6fe8c55b70d115bf400769cc1400f3258cd3138" {
+		t.Errorf("Operator = %s", got)
+	}
+	if _, err := runMakeConfig(t, "--overlay.operator", "0x04ca634cae0d49acb401d8a4c6b6fe8c55b70d115bf400769cc1400f3258cd3138"); err == nil {
+		t.Error("expected invalid operator key to fail")
+	}
+}
+
+func TestCaptureFlags(t *testing.T) {
+	cfg, err := runMakeConfig(t, "--capture.file", "capture.jsonl", "--capture.maxsize", "50", "--capture.maxfiles", "0")
+	if err != nil {
+		t.Fatalf("makeConfig() error = %v", err)
+	}
+	want := relay.CaptureConfig{File: "capture.jsonl", MaxSize: 50, MaxFiles: 0}
+	if cfg.Relay.Capture != want {
+		t.Errorf("Capture = %+v, want %+v", cfg.Relay.Capture, want)
+	}
+	if _, err := runMakeConfig(t, "--capture.maxfiles", "-1"); err == nil {
+		t.Error("expected negative capture file count to fail")
+	}
+}
+
+// TestDumpConfigRoundTrip tests that dumped configs load back unchanged
+func TestDumpConfigRoundTrip(t *testing.T) {
+	cfg, err := runMakeConfig(t,
+		"--chain", "holesky",
+		"--staticnodes", "enode://d860a01f9722d78051619d1e2351aba3f43f943f6f00718d1b9baa4101932a1f5011f16bb2b1bb35db20d6fe28fa0bf09636d26a87d31de9ec6203eeedb1f666@127.0.0.1:30303",
+		"--netrestrict", "10.0.0.0/8",
+		"--tor-proxy", "127.0.0.1:9050",
+		"--tor-control", "127.0.0.1:9051",
+		"--bandwidth.egress", "1000000",
+		"--networks", "sepolia:30304",
+		"--overlay.operator", "0x03ca634cae0d49acb401d8a4c6b6fe8c55b70d115bf400769cc1400f3258cd3138",
+	)
+	if err != nil {
+		t.Fatalf("makeConfig() error = %v", err)
+	}
+	out, err := tomlSettings.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "dump.toml")
+	if err := os.WriteFile(file, out, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := runMakeConfig(t, "--config", file)
+	if err != nil {
+		t.Fatalf("loading dumped config: %v", err)
+	}
+	out2, err := tomlSettings.Marshal(loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != string(out2) {
+		t.Errorf("config changed after round trip:\n%s\n---\n%s", out, out2)
+	}
+	if !loaded.Node.Tor.Enabled || loaded.Relay.Bandwidth.Egress != 1000000 || len(loaded.Networks) != 1 || len(loaded.Relay.Overlay.Operator) != 33 {
+		t.Error("settings lost in round trip")
+	}
+}
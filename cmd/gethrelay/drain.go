@@ -0,0 +1,160 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/eth/relay"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Graceful shutdown.
+//
+// SIGINT, SIGTERM and relay_drain drain the relay before shutting it down. The
+// RPC proxy refuses new calls and finishes the ones in flight, the relay
+// refuses new peers, answers the requests in flight and flushes its outbound
+// queues, all up to the drain timeout. Readiness fails for the whole drain. A
+// second signal skips the rest of the drain.
+
+const drainPollInterval = 50 * time.Millisecond
+
+// relayDrainer is the part of the relay service used for draining.
+type relayDrainer interface {
+	Drain(timeout time.Duration) (*relay.DrainInfo, error)
+}
+
+// rpcDrainer is the part of the RPC proxy used for draining.
+type rpcDrainer interface {
+	drain(ctx context.Context) int
+}
+
+// drainResult is the outcome of a drain.
+type drainResult struct {
+	Relay    *relay.DrainInfo `json:"relay"`
+	RPCCalls int              `json:"rpcCalls"` // RPC calls still being served at the deadline
+}
+
+// drainer drains the relay and then shuts the node down.
+type drainer struct {
+	relay   relayDrainer
+	proxy   rpcDrainer
+	timeout time.Duration
+	close   func() error // shuts the node down
+
+	once   sync.Once
+	done   chan struct{} // closed when the drain is over
+	result *drainResult
+	err    error
+
+	quit chan struct{}
+}
+
+func newDrainer(relay relayDrainer, proxy rpcDrainer, timeout time.Duration, close func() error) *drainer {
+	return &drainer{
+		relay:   relay,
+		proxy:   proxy,
+		timeout: timeout,
+		close:   close,
+		done:    make(chan struct{}),
+		quit:    make(chan struct{}),
+	}
+}
+
+// Start implements node.Lifecycle.
+func (d *drainer) Start() error {
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	go d.loop(sigc)
+	return nil
+}
+
+// Stop implements node.Lifecycle. It does not wait for the signal loop, which
+// may be the one shutting the node down.
+func (d *drainer) Stop() error {
+	close(d.quit)
+	return nil
+}
+
+func (d *drainer) loop(sigc chan os.Signal) {
+	defer signal.Stop(sigc)
+
+	select {
+	case sig := <-sigc:
+		log.Info("Got signal, draining relay before shutdown", "signal", sig, "timeout", d.timeout)
+		go d.shutdown()
+	case <-d.quit:
+		return
+	}
+	select {
+	case <-sigc:
+		log.Warn("Already draining, shutting down now")
+		d.close()
+	case <-d.quit:
+	}
+}
+
+// shutdown drains the relay and shuts the node down.
+func (d *drainer) shutdown() {
+	d.drain()
+	d.close()
+}
+
+// drain drains the RPC proxy and the relay once. Later calls wait for the
+// first drain to finish and return its result.
+func (d *drainer) drain() (*drainResult, error) {
+	d.once.Do(func() {
+		defer close(d.done)
+
+		ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+		defer cancel()
+		var rpcCalls int
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rpcCalls = d.proxy.drain(ctx)
+		}()
+		info, err := d.relay.Drain(d.timeout)
+		wg.Wait()
+
+		d.result, d.err = &drainResult{Relay: info, RPCCalls: rpcCalls}, err
+		if rpcCalls > 0 {
+			log.Warn("RPC calls still in flight after drain", "calls", rpcCalls)
+		}
+	})
+	<-d.done
+	return d.result, d.err
+}
+
+// drainAPI adds relay_drain to the relay namespace.
+type drainAPI struct {
+	drainer *drainer
+}
+
+// Drain drains the relay and returns the outcome. The relay shuts down after
+// the call returns.
+func (api *drainAPI) Drain() (*drainResult, error) {
+	result, err := api.drainer.drain()
+	go api.drainer.close()
+	return result, err
+}
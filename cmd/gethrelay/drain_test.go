@@ -0,0 +1,129 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/eth/relay"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// testRelayDrainer records the drain timeout.
+type testRelayDrainer struct {
+	timeout time.Duration
+}
+
+func (d *testRelayDrainer) Drain(timeout time.Duration) (*relay.DrainInfo, error) {
+	d.timeout = timeout
+	return &relay.DrainInfo{Flushed: true}, nil
+}
+
+// newBlockingUpstream returns an upstream answering calls once release is
+// closed, and a channel receiving a value when a call arrives.
+func newBlockingUpstream(t *testing.T) (url string, arrived chan struct{}, release chan struct{}) {
+	arrived, release = make(chan struct{}, 10), make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	}))
+	t.Cleanup(upstream.Close)
+	return upstream.URL, arrived, release
+}
+
+func proxyCall(proxy *rpcProxy) int {
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(`{"jsonrpc":"2.0","method":"eth_chainId","params":[],"id":1}`)))
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestDrainer(t *testing.T) {
+	url, arrived, release := newBlockingUpstream(t)
+	proxy := newRPCProxy(url, rpc.NewServer())
+	relayDrainer := new(testRelayDrainer)
+	var closed atomic.Int32
+	d := newDrainer(relayDrainer, proxy, 5*time.Second, func() error { closed.Add(1); return nil })
+
+	// Start a call and the drain.
+	callDone := make(chan int, 1)
+	go func() { callDone <- proxyCall(proxy) }()
+	<-arrived
+	shutdownDone := make(chan struct{})
+	go func() {
+		d.shutdown()
+		close(shutdownDone)
+	}()
+
+	// New calls are refused, the one in flight completes.
+	for !d.proxyDraining() {
+		time.Sleep(time.Millisecond)
+	}
+	if code := proxyCall(proxy); code != http.StatusServiceUnavailable {
+		t.Errorf("call during drain: status %d, want %d", code, http.StatusServiceUnavailable)
+	}
+	close(release)
+	if code := <-callDone; code != http.StatusOK {
+		t.Errorf("call in flight: status %d, want %d", code, http.StatusOK)
+	}
+	<-shutdownDone
+
+	result, err := d.drain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RPCCalls != 0 || !result.Relay.Flushed {
+		t.Errorf("wrong drain result: %+v", result)
+	}
+	if relayDrainer.timeout != 5*time.Second {
+		t.Errorf("relay drain timeout %v, want %v", relayDrainer.timeout, 5*time.Second)
+	}
+	if n := closed.Load(); n != 1 {
+		t.Errorf("node closed %d times, want 1", n)
+	}
+}
+
+func TestDrainerTimeout(t *testing.T) {
+	url, arrived, release := newBlockingUpstream(t)
+	defer close(release)
+	proxy := newRPCProxy(url, rpc.NewServer())
+	d := newDrainer(new(testRelayDrainer), proxy, 100*time.Millisecond, func() error { return nil })
+
+	go proxyCall(proxy)
+	<-arrived
+	result, err := d.drain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RPCCalls != 1 {
+		t.Errorf("RPC calls in flight after drain: %d, want 1", result.RPCCalls)
+	}
+}
+
+// proxyDraining reports whether the RPC proxy refuses new calls.
+func (d *drainer) proxyDraining() bool {
+	proxy := d.proxy.(*rpcProxy)
+	proxy.mu.RLock()
+	defer proxy.mu.RUnlock()
+	return proxy.draining
+}
@@ -0,0 +1,471 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"slices"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/misc/eip4844"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/eth/relay"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// P2P read fallback.
+//
+// When the upstream endpoint is down, block and transaction reads are answered
+// from the relay's eth peers instead of failing. A header is only trusted if
+// several peers return the same one, blocks by number are only requested from
+// peers whose advertised block range has them. Everything else is checked
+// against a trusted header: ancestors by their parent hash, bodies and
+// receipts by the roots in the header, so a single peer is enough for them.
+// Receipts are searched for in the recent blocks, transactions by hash in the
+// transaction pools of the peers. Peers are asked a few at a time, all
+// requests of a query share one deadline.
+
+const (
+	fallbackQuorum       = 2               // peers that must return the same header
+	fallbackSearchDepth  = 128             // recent blocks searched for a transaction receipt
+	fallbackBodiesBatch  = 32              // block bodies per request
+	fallbackPoolPeers    = 4               // peers asked for a pending transaction
+	fallbackParallel     = 4               // requests of a query in flight at once
+	fallbackQueryTimeout = 5 * time.Second // time the peers have to answer a query
+)
+
+var (
+	errFallbackPeers  = errors.New("not enough peers to cross-check the header")
+	errFallbackQuorum = errors.New("peers do not agree on the header")
+	errFallbackBodies = errors.New("block bodies not available from peers")
+)
+
+// fallbackMethods are the methods the fallback can answer.
+var fallbackMethods = map[string]bool{
+	"eth_getBlockByHash":        true,
+	"eth_getBlockByNumber":      true,
+	"eth_getTransactionReceipt": true,
+	"eth_getTransactionByHash":  true,
+}
+
+// relayPeers gives the fallback access to the relay's eth peers.
+type relayPeers interface {
+	Peers() []*relay.RelayPeer
+	Request(ctx context.Context, peer enode.ID, msgCode uint64, payload []byte) ([]byte, error)
+}
+
+// fallbackAPI answers block and transaction reads from peers.
+type fallbackAPI struct {
+	peers  relayPeers
+	config *params.ChainConfig
+	log    log.Logger
+}
+
+// newFallbackServer creates the RPC server answering fallbackMethods from peers.
+func newFallbackServer(peers relayPeers, config *params.ChainConfig) (*rpc.Server, error) {
+	server := rpc.NewServer()
+	api := &fallbackAPI{peers: peers, config: config, log: log.New("module", "fallback")}
+	if err := server.RegisterName("eth", api); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+// GetBlockByHash returns the block with the given hash.
+func (api *fallbackAPI) GetBlockByHash(ctx context.Context, hash common.Hash, fullTx bool) (map[string]interface{}, error) {
+	header, err := api.crossCheck(ctx, api.peerList(nil), eth.HashOrNumber{Hash: hash})
+	if header == nil || err != nil {
+		return nil, err
+	}
+	return api.marshalBlock(ctx, header, fullTx)
+}
+
+// GetBlockByNumber returns the block with the given number. The latest block
+// is the highest one advertised by enough peers to cross-check it, the
+// pending, safe and finalized blocks are not known to peers.
+func (api *fallbackAPI) GetBlockByNumber(ctx context.Context, number rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
+	var n uint64
+	switch {
+	case number == rpc.LatestBlockNumber:
+		latest, err := api.latestNumber()
+		if err != nil {
+			return nil, err
+		}
+		n = latest
+	case number >= 0:
+		n = uint64(number)
+	default:
+		return nil, fmt.Errorf("%s block not available from peers", number)
+	}
+	header, err := api.crossCheck(ctx, api.peerList(&n), eth.HashOrNumber{Number: n})
+	if header == nil || err != nil {
+		return nil, err
+	}
+	return api.marshalBlock(ctx, header, fullTx)
+}
+
+// GetTransactionReceipt returns the receipt of a transaction included in one
+// of the recent blocks.
+func (api *fallbackAPI) GetTransactionReceipt(ctx context.Context, hash common.Hash) (map[string]interface{}, error) {
+	block, index, err := api.findTransaction(ctx, hash)
+	if block == nil || err != nil {
+		return nil, err
+	}
+	receipts, err := api.receipts(ctx, block)
+	if err != nil {
+		return nil, err
+	}
+	signer := types.MakeSigner(api.config, block.Number(), block.Time())
+	return ethapi.MarshalReceipt(receipts[index], block.Hash(), block.NumberU64(), signer, block.Transactions()[index], index), nil
+}
+
+// GetTransactionByHash returns a pending transaction from the pool of a peer.
+func (api *fallbackAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) (*ethapi.RPCTransaction, error) {
+	peers := api.peerList(nil)
+	if len(peers) > fallbackPoolPeers {
+		peers = peers[:fallbackPoolPeers]
+	}
+	var tx *types.Transaction
+	api.query(ctx, peers, eth.GetPooledTransactionsMsg, eth.GetPooledTransactionsRequest{hash}, func(peer *relay.RelayPeer, data []byte) bool {
+		var txs eth.PooledTransactionsResponse
+		if err := rlp.DecodeBytes(data, &txs); err != nil || len(txs) == 0 || txs[0].Hash() != hash {
+			return false
+		}
+		tx = txs[0]
+		return true
+	})
+	if tx == nil {
+		return nil, nil
+	}
+	// The current header sets the signer and base fee, without it the
+	// transaction is marshalled as of genesis.
+	latest, err := api.latestHeader(ctx)
+	if err != nil {
+		api.log.Debug("Latest header not available", "err", err)
+	}
+	return ethapi.NewRPCPendingTransaction(tx, latest, api.config), nil
+}
+
+// query sends a request to the peers, at most fallbackParallel of them at a
+// time, and passes the responses to handle as they arrive. It stops once
+// handle returns true, all peers answered or the deadline of the query passed.
+// Requests still in flight are cancelled.
+func (api *fallbackAPI) query(ctx context.Context, peers []*relay.RelayPeer, msgCode uint64, req interface{}, handle func(peer *relay.RelayPeer, data []byte) bool) {
+	payload, err := rlp.EncodeToBytes(req)
+	if err != nil {
+		api.log.Error("Failed to encode request", "err", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, fallbackQueryTimeout)
+	defer cancel()
+
+	type response struct {
+		peer *relay.RelayPeer
+		data []byte
+		err  error
+	}
+	// Buffered for all peers, so requests finishing after the query do not block
+	responses := make(chan response, len(peers))
+	next, inflight := 0, 0
+	for {
+		for ; inflight < fallbackParallel && next < len(peers) && ctx.Err() == nil; next++ {
+			peer := peers[next]
+			inflight++
+			go func() {
+				data, err := api.peers.Request(ctx, peer.ID, msgCode, payload)
+				responses <- response{peer, data, err}
+			}()
+		}
+		if inflight == 0 {
+			return
+		}
+		res := <-responses
+		inflight--
+		if res.err != nil {
+			api.log.Debug("Peer request failed", "peer", res.peer.ID, "code", msgCode, "err", res.err)
+			continue
+		}
+		if handle(res.peer, res.data) {
+			return
+		}
+	}
+}
+
+// peerList returns the peers in random order. If number is set, only the
+// peers advertising a block range with it are returned.
+func (api *fallbackAPI) peerList(number *uint64) []*relay.RelayPeer {
+	var peers []*relay.RelayPeer
+	for _, peer := range api.peers.Peers() {
+		if number != nil {
+			r, ok := peer.BlockRange()
+			if !ok || *number < r.EarliestBlock || *number > r.LatestBlock {
+				continue
+			}
+		}
+		peers = append(peers, peer)
+	}
+	rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	return peers
+}
+
+// latestNumber returns the highest block advertised by enough peers to
+// cross-check its header.
+func (api *fallbackAPI) latestNumber() (uint64, error) {
+	var latest []uint64
+	for _, peer := range api.peers.Peers() {
+		if r, ok := peer.BlockRange(); ok {
+			latest = append(latest, r.LatestBlock)
+		}
+	}
+	if len(latest) < fallbackQuorum {
+		return 0, errFallbackPeers
+	}
+	slices.Sort(latest)
+	return latest[len(latest)-fallbackQuorum], nil
+}
+
+// latestHeader returns the cross-checked header of the latest block.
+func (api *fallbackAPI) latestHeader(ctx context.Context) (*types.Header, error) {
+	n, err := api.latestNumber()
+	if err != nil {
+		return nil, err
+	}
+	header, err := api.crossCheck(ctx, api.peerList(&n), eth.HashOrNumber{Number: n})
+	if err == nil && header == nil {
+		err = errFallbackQuorum
+	}
+	return header, err
+}
+
+// crossCheck requests a header from peers until fallbackQuorum of them
+// returned the same one. It returns nil if enough peers do not have it.
+func (api *fallbackAPI) crossCheck(ctx context.Context, peers []*relay.RelayPeer, origin eth.HashOrNumber) (*types.Header, error) {
+	if len(peers) < fallbackQuorum {
+		return nil, errFallbackPeers
+	}
+	var (
+		votes   = make(map[common.Hash]int)
+		missing int
+		trusted *types.Header
+	)
+	req := &eth.GetBlockHeadersRequest{Origin: origin, Amount: 1}
+	api.query(ctx, peers, eth.GetBlockHeadersMsg, req, func(peer *relay.RelayPeer, data []byte) bool {
+		var headers eth.BlockHeadersRequest
+		if err := rlp.DecodeBytes(data, &headers); err != nil {
+			api.log.Debug("Invalid header response", "peer", peer.ID, "err", err)
+			return false
+		}
+		if len(headers) == 0 {
+			missing++
+			return false
+		}
+		header := headers[0]
+		if (origin.Hash != common.Hash{} && header.Hash() != origin.Hash) || (origin.Hash == common.Hash{} && header.Number.Uint64() != origin.Number) {
+			api.log.Debug("Peer returned the wrong header", "peer", peer.ID, "number", header.Number, "hash", header.Hash())
+			return false
+		}
+		hash := header.Hash()
+		if votes[hash]++; votes[hash] >= fallbackQuorum {
+			trusted = header
+			return true
+		}
+		return false
+	})
+	if trusted != nil {
+		return trusted, nil
+	}
+	if len(votes) == 0 && missing >= fallbackQuorum {
+		return nil, nil
+	}
+	return nil, errFallbackQuorum
+}
+
+// ancestors returns up to n headers starting at head and going back, linked
+// by their parent hashes.
+func (api *fallbackAPI) ancestors(ctx context.Context, head *types.Header, n uint64) ([]*types.Header, error) {
+	req := &eth.GetBlockHeadersRequest{Origin: eth.HashOrNumber{Hash: head.Hash()}, Amount: n, Reverse: true}
+	ancestors := []*types.Header{head}
+	api.query(ctx, api.peerList(nil), eth.GetBlockHeadersMsg, req, func(peer *relay.RelayPeer, data []byte) bool {
+		var headers eth.BlockHeadersRequest
+		if err := rlp.DecodeBytes(data, &headers); err != nil || len(headers) == 0 || headers[0].Hash() != head.Hash() {
+			return false
+		}
+		linked := 1
+		for ; linked < len(headers); linked++ {
+			if headers[linked].Hash() != headers[linked-1].ParentHash {
+				break
+			}
+		}
+		ancestors = headers[:linked]
+		return true
+	})
+	return ancestors, nil
+}
+
+// blocks fetches the bodies of headers from peers and assembles the blocks.
+func (api *fallbackAPI) blocks(ctx context.Context, headers []*types.Header) ([]*types.Block, error) {
+	hashes := make(eth.GetBlockBodiesRequest, len(headers))
+	for i, header := range headers {
+		hashes[i] = header.Hash()
+	}
+	var (
+		blocks = make([]*types.Block, len(headers))
+		found  int
+	)
+	api.query(ctx, api.peerList(nil), eth.GetBlockBodiesMsg, hashes, func(peer *relay.RelayPeer, data []byte) bool {
+		var bodies eth.BlockBodiesResponse
+		if err := rlp.DecodeBytes(data, &bodies); err != nil {
+			api.log.Debug("Invalid block bodies response", "peer", peer.ID, "err", err)
+			return false
+		}
+		for i, body := range bodies {
+			if i >= len(headers) || !verifyBody(headers[i], body) {
+				break
+			}
+			if blocks[i] == nil {
+				blocks[i] = types.NewBlockWithHeader(headers[i]).WithBody(types.Body{
+					Transactions: body.Transactions,
+					Uncles:       body.Uncles,
+					Withdrawals:  body.Withdrawals,
+				})
+				found++
+			}
+		}
+		return found == len(headers)
+	})
+	if found < len(headers) {
+		return nil, errFallbackBodies
+	}
+	return blocks, nil
+}
+
+// verifyBody checks a block body against the roots in its header.
+func verifyBody(header *types.Header, body *eth.BlockBody) bool {
+	if types.DeriveSha(types.Transactions(body.Transactions), trie.NewStackTrie(nil)) != header.TxHash {
+		return false
+	}
+	if types.CalcUncleHash(body.Uncles) != header.UncleHash {
+		return false
+	}
+	if header.WithdrawalsHash == nil {
+		return body.Withdrawals == nil
+	}
+	return body.Withdrawals != nil && types.DeriveSha(types.Withdrawals(body.Withdrawals), trie.NewStackTrie(nil)) == *header.WithdrawalsHash
+}
+
+// marshalBlock fetches the body of a block and marshals it.
+func (api *fallbackAPI) marshalBlock(ctx context.Context, header *types.Header, fullTx bool) (map[string]interface{}, error) {
+	blocks, err := api.blocks(ctx, []*types.Header{header})
+	if err != nil {
+		return nil, err
+	}
+	return ethapi.RPCMarshalBlock(blocks[0], true, fullTx, api.config), nil
+}
+
+// findTransaction searches the recent blocks for a transaction and returns
+// the block including it and its index, or nil if it was not found.
+func (api *fallbackAPI) findTransaction(ctx context.Context, hash common.Hash) (*types.Block, int, error) {
+	latest, err := api.latestHeader(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	headers, err := api.ancestors(ctx, latest, fallbackSearchDepth)
+	if err != nil {
+		return nil, 0, err
+	}
+	for start := 0; start < len(headers); start += fallbackBodiesBatch {
+		blocks, err := api.blocks(ctx, headers[start:min(start+fallbackBodiesBatch, len(headers))])
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, block := range blocks {
+			for i, tx := range block.Transactions() {
+				if tx.Hash() == hash {
+					return block, i, nil
+				}
+			}
+		}
+	}
+	return nil, 0, nil
+}
+
+// receipts fetches the receipts of a block from peers and derives their
+// fields.
+func (api *fallbackAPI) receipts(ctx context.Context, block *types.Block) (types.Receipts, error) {
+	var receipts types.Receipts
+	api.query(ctx, api.peerList(nil), eth.GetReceiptsMsg, eth.GetReceiptsRequest{block.Hash()}, func(peer *relay.RelayPeer, data []byte) bool {
+		var err error
+		if peer.Version >= eth.ETH69 {
+			receipts, err = decodeReceipts[*eth.ReceiptList69](data, block.Header())
+		} else {
+			receipts, err = decodeReceipts[*eth.ReceiptList68](data, block.Header())
+		}
+		if err != nil {
+			api.log.Debug("Invalid receipts response", "peer", peer.ID, "err", err)
+			return false
+		}
+		return receipts != nil
+	})
+	if receipts == nil {
+		return nil, errors.New("block receipts not available from peers")
+	}
+	var blobGasPrice *big.Int
+	if block.ExcessBlobGas() != nil {
+		blobGasPrice = eip4844.CalcBlobFee(api.config, block.Header())
+	}
+	if err := receipts.DeriveFields(api.config, block.Hash(), block.NumberU64(), block.Time(), block.BaseFee(), blobGasPrice, block.Transactions()); err != nil {
+		return nil, err
+	}
+	return receipts, nil
+}
+
+// decodeReceipts decodes the receipts of a block from a receipts response and
+// checks them against the receipt root. It returns nil if the peer does not
+// have them.
+func decodeReceipts[L eth.ReceiptsList](data []byte, header *types.Header) (types.Receipts, error) {
+	var lists []L
+	if err := rlp.DecodeBytes(data, &lists); err != nil {
+		return nil, err
+	}
+	if len(lists) == 0 {
+		return nil, nil
+	}
+	if root := types.DeriveSha(lists[0], trie.NewStackTrie(nil)); root != header.ReceiptHash {
+		return nil, fmt.Errorf("receipt root mismatch: have %x, want %x", root, header.ReceiptHash)
+	}
+	var stored []*types.ReceiptForStorage
+	if err := rlp.DecodeBytes(lists[0].EncodeForStorage(), &stored); err != nil {
+		return nil, err
+	}
+	receipts := make(types.Receipts, len(stored))
+	for i, r := range stored {
+		receipts[i] = (*types.Receipt)(r)
+	}
+	return receipts, nil
+}
@@ -0,0 +1,543 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/eth/relay"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// testChainPeers serves a test chain to the fallback. The peers are connected
+// to a relay backend over the eth protocol, requests of the fallback take the
+// same path as with a running relay. Forging peers answer header requests
+// with a header of their own.
+type testChainPeers struct {
+	t        *testing.T
+	backend  *relay.Backend
+	proxy    *relay.RequestProxy
+	remotes  []*p2p.MsgPipeRW
+	chain    []*types.Block
+	receipts map[common.Hash]types.Receipts
+	pool     map[common.Hash]*types.Transaction
+
+	lock       sync.Mutex
+	forged     map[enode.ID]*types.Header
+	noReceipts map[enode.ID]bool
+	silent     map[enode.ID]bool
+}
+
+// newTestChainPeers creates a chain of n blocks with one transaction each.
+func newTestChainPeers(t *testing.T, n int) *testChainPeers {
+	backend := relay.NewBackend(&relay.Config{NetworkID: 1, GenesisHash: common.Hash{1}}, nil)
+	tp := &testChainPeers{
+		t:          t,
+		backend:    backend,
+		proxy:      relay.NewRequestProxy(backend, relay.NewRoundRobinSelector(backend), time.Minute),
+		receipts:   make(map[common.Hash]types.Receipts),
+		pool:       make(map[common.Hash]*types.Transaction),
+		forged:     make(map[enode.ID]*types.Header),
+		noReceipts: make(map[enode.ID]bool),
+		silent:     make(map[enode.ID]bool),
+	}
+	t.Cleanup(func() {
+		tp.proxy.Stop()
+		backend.Stop()
+	})
+	var parent common.Hash
+	for i := 0; i < n; i++ {
+		tx, _ := testSignedTx(t, uint64(i))
+		receipt := &types.Receipt{Type: tx.Type(), Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: 21000, Logs: []*types.Log{}}
+		receipt.Bloom = types.CreateBloom(receipt)
+		header := &types.Header{
+			ParentHash: parent,
+			Number:     big.NewInt(int64(i)),
+			GasLimit:   30_000_000,
+			GasUsed:    21000,
+			BaseFee:    big.NewInt(params.InitialBaseFee),
+			Time:       uint64(i * 12),
+		}
+		block := types.NewBlock(header, &types.Body{Transactions: types.Transactions{tx}}, []*types.Receipt{receipt}, trie.NewStackTrie(nil))
+		tp.chain = append(tp.chain, block)
+		tp.receipts[block.Hash()] = types.Receipts{receipt}
+		parent = block.Hash()
+	}
+	return tp
+}
+
+// addPeer connects a peer advertising the given block range. Range updates
+// only exist since eth/69, older peers advertise none.
+func (tp *testChainPeers) addPeer(version uint, earliest, latest uint64) enode.ID {
+	tp.t.Helper()
+	id := enode.ID{byte(len(tp.remotes) + 1)}
+	local, remote := p2p.MsgPipe()
+	tp.t.Cleanup(func() { local.Close() })
+
+	for _, proto := range eth.MakeRelayProtocols(eth.NewRelayBackend(tp.backend), 1, nil) {
+		if proto.Version == version {
+			go proto.Run(p2p.NewPeer(id, "test", nil), local)
+		}
+	}
+	peer := eth.NewPeer(version, p2p.NewPeer(enode.ID{0xff}, "relay", nil), remote, nil)
+	tp.t.Cleanup(peer.Close)
+	head := eth.BlockRangeUpdatePacket{EarliestBlock: earliest, LatestBlock: latest, LatestBlockHash: tp.chain[latest].Hash()}
+	if err := peer.RelayHandshake(1, common.Hash{1}, nil, tp.backend.GetForkID(), head); err != nil {
+		tp.t.Fatalf("handshake failed: %v", err)
+	}
+	tp.remotes = append(tp.remotes, remote)
+	go tp.serve(id, version, remote)
+
+	for i := 0; i < 100; i++ {
+		for _, p := range tp.backend.Peers() {
+			if p.ID == id {
+				return id
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	tp.t.Fatal("peer not registered")
+	return id
+}
+
+// setRange announces a new block range of a peer and waits for the relay to
+// take it.
+func (tp *testChainPeers) setRange(id enode.ID, earliest, latest uint64) {
+	tp.t.Helper()
+	update := relay.BlockRange{EarliestBlock: earliest, LatestBlock: latest, LatestBlockHash: tp.chain[latest].Hash()}
+	p2p.Send(tp.remotes[id[0]-1], eth.BlockRangeUpdateMsg, &eth.BlockRangeUpdatePacket{
+		EarliestBlock:   update.EarliestBlock,
+		LatestBlock:     update.LatestBlock,
+		LatestBlockHash: update.LatestBlockHash,
+	})
+	for i := 0; i < 100; i++ {
+		for _, p := range tp.backend.Peers() {
+			if r, ok := p.BlockRange(); p.ID == id && ok && r == update {
+				return
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	tp.t.Fatal("block range not updated")
+}
+
+func (tp *testChainPeers) setForged(id enode.ID, header *types.Header) {
+	tp.lock.Lock()
+	defer tp.lock.Unlock()
+	tp.forged[id] = header
+}
+
+func (tp *testChainPeers) setNoReceipts(id enode.ID) {
+	tp.lock.Lock()
+	defer tp.lock.Unlock()
+	tp.noReceipts[id] = true
+}
+
+func (tp *testChainPeers) setSilent(id enode.ID) {
+	tp.lock.Lock()
+	defer tp.lock.Unlock()
+	tp.silent[id] = true
+}
+
+func (tp *testChainPeers) Peers() []*relay.RelayPeer {
+	return tp.backend.Peers()
+}
+
+func (tp *testChainPeers) Request(ctx context.Context, id enode.ID, msgCode uint64, payload []byte) ([]byte, error) {
+	return tp.proxy.Request(ctx, id, msgCode, payload)
+}
+
+// testPacket is a request or response with its request ID.
+type testPacket struct {
+	RequestID uint64
+	Payload   rlp.RawValue
+}
+
+// serve answers the requests the relay sends to a peer, silent peers do not
+// answer.
+func (tp *testChainPeers) serve(id enode.ID, version uint, rw p2p.MsgReadWriter) {
+	for {
+		msg, err := rw.ReadMsg()
+		if err != nil {
+			return
+		}
+		tp.lock.Lock()
+		silent := tp.silent[id]
+		tp.lock.Unlock()
+		if silent {
+			msg.Discard()
+			continue
+		}
+		var req testPacket
+		if err := msg.Decode(&req); err != nil {
+			continue
+		}
+		code, resp, err := tp.answer(id, version, msg.Code, req.Payload)
+		if err != nil {
+			tp.t.Error(err)
+			return
+		}
+		p2p.Send(rw, code, &testPacket{RequestID: req.RequestID, Payload: resp})
+	}
+}
+
+// answer returns the response code and payload for a request.
+func (tp *testChainPeers) answer(id enode.ID, version uint, msgCode uint64, payload []byte) (uint64, []byte, error) {
+	tp.lock.Lock()
+	defer tp.lock.Unlock()
+
+	switch msgCode {
+	case eth.GetBlockHeadersMsg:
+		var req eth.GetBlockHeadersRequest
+		if err := rlp.DecodeBytes(payload, &req); err != nil {
+			return 0, nil, err
+		}
+		if header := tp.forged[id]; header != nil {
+			resp, err := rlp.EncodeToBytes(eth.BlockHeadersRequest{header})
+			return eth.BlockHeadersMsg, resp, err
+		}
+		number := -1
+		for i, block := range tp.chain {
+			if block.Hash() == req.Origin.Hash || (req.Origin.Hash == common.Hash{} && uint64(i) == req.Origin.Number) {
+				number = i
+			}
+		}
+		headers := eth.BlockHeadersRequest{}
+		for number >= 0 && number < len(tp.chain) && uint64(len(headers)) < req.Amount {
+			headers = append(headers, tp.chain[number].Header())
+			if req.Reverse {
+				number--
+			} else {
+				number++
+			}
+		}
+		resp, err := rlp.EncodeToBytes(headers)
+		return eth.BlockHeadersMsg, resp, err
+
+	case eth.GetBlockBodiesMsg:
+		var hashes eth.GetBlockBodiesRequest
+		if err := rlp.DecodeBytes(payload, &hashes); err != nil {
+			return 0, nil, err
+		}
+		bodies := eth.BlockBodiesResponse{}
+		for _, hash := range hashes {
+			if block := tp.block(hash); block != nil {
+				bodies = append(bodies, &eth.BlockBody{Transactions: block.Transactions(), Uncles: block.Uncles()})
+			}
+		}
+		resp, err := rlp.EncodeToBytes(bodies)
+		return eth.BlockBodiesMsg, resp, err
+
+	case eth.GetReceiptsMsg:
+		var hashes eth.GetReceiptsRequest
+		if err := rlp.DecodeBytes(payload, &hashes); err != nil {
+			return 0, nil, err
+		}
+		if tp.noReceipts[id] {
+			hashes = nil
+		}
+		if version >= eth.ETH69 {
+			lists := []*eth.ReceiptList69{}
+			for _, hash := range hashes {
+				if receipts, ok := tp.receipts[hash]; ok {
+					lists = append(lists, eth.NewReceiptList69(receipts))
+				}
+			}
+			resp, err := rlp.EncodeToBytes(lists)
+			return eth.ReceiptsMsg, resp, err
+		}
+		lists := []*eth.ReceiptList68{}
+		for _, hash := range hashes {
+			if receipts, ok := tp.receipts[hash]; ok {
+				lists = append(lists, eth.NewReceiptList68(receipts))
+			}
+		}
+		resp, err := rlp.EncodeToBytes(lists)
+		return eth.ReceiptsMsg, resp, err
+
+	case eth.GetPooledTransactionsMsg:
+		var hashes eth.GetPooledTransactionsRequest
+		if err := rlp.DecodeBytes(payload, &hashes); err != nil {
+			return 0, nil, err
+		}
+		txs := eth.PooledTransactionsResponse{}
+		for _, hash := range hashes {
+			if tx := tp.pool[hash]; tx != nil {
+				txs = append(txs, tx)
+			}
+		}
+		resp, err := rlp.EncodeToBytes(txs)
+		return eth.PooledTransactionsMsg, resp, err
+	}
+	return 0, nil, fmt.Errorf("unexpected message code %d", msgCode)
+}
+
+func (tp *testChainPeers) block(hash common.Hash) *types.Block {
+	for _, block := range tp.chain {
+		if block.Hash() == hash {
+			return block
+		}
+	}
+	return nil
+}
+
+// newTestFallbackProxy creates an RPC proxy whose upstream is down and which
+// answers from the given peers.
+func newTestFallbackProxy(t *testing.T, peers relayPeers) *rpcProxy {
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	upstream.Close()
+	fallback, err := newFallbackServer(peers, params.TestChainConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := newRPCProxy(upstream.URL, nil)
+	proxy.fallback = fallback
+	return proxy
+}
+
+func TestFallbackBlocks(t *testing.T) {
+	tp := newTestChainPeers(t, 10)
+	tp.addPeer(eth.ETH69, 0, 9)
+	tp.addPeer(eth.ETH69, 0, 9)
+	tp.addPeer(eth.ETH69, 5, 9)
+	proxy := newTestFallbackProxy(t, tp)
+
+	var block struct {
+		Hash         common.Hash       `json:"hash"`
+		Transactions []json.RawMessage `json:"transactions"`
+	}
+	resp := callProxy(t, proxy, "eth_getBlockByNumber", `"latest", false`)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error.Message)
+	}
+	if err := json.Unmarshal(resp.Result, &block); err != nil || block.Hash != tp.chain[9].Hash() {
+		t.Fatalf("wrong latest block: %s", resp.Result)
+	}
+
+	resp = callProxy(t, proxy, "eth_getBlockByHash", fmt.Sprintf(`"%s", true`, tp.chain[3].Hash()))
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error.Message)
+	}
+	if err := json.Unmarshal(resp.Result, &block); err != nil || block.Hash != tp.chain[3].Hash() || len(block.Transactions) != 1 {
+		t.Fatalf("wrong block by hash: %s", resp.Result)
+	}
+	var tx struct {
+		Hash common.Hash `json:"hash"`
+	}
+	if err := json.Unmarshal(block.Transactions[0], &tx); err != nil || tx.Hash != tp.chain[3].Transactions()[0].Hash() {
+		t.Fatalf("wrong full transaction: %s", block.Transactions[0])
+	}
+
+	// Unknown blocks are null.
+	if resp := callProxy(t, proxy, "eth_getBlockByHash", `"0x0000000000000000000000000000000000000000000000000000000000000001", false`); resp.Error != nil || string(resp.Result) != "null" {
+		t.Fatalf("wrong response for unknown block: %s %+v", resp.Result, resp.Error)
+	}
+
+	// Only one peer advertises block 2 in its range, which is not enough to
+	// cross-check it.
+	tp.setRange(enode.ID{2}, 5, 9)
+	if resp := callProxy(t, proxy, "eth_getBlockByNumber", `"0x2", false`); resp.Error == nil || resp.Error.Message != errFallbackPeers.Error() {
+		t.Fatalf("block outside of the peer ranges served: %s %+v", resp.Result, resp.Error)
+	}
+	if resp := callProxy(t, proxy, "eth_getBlockByNumber", `"pending", false`); resp.Error == nil {
+		t.Fatal("pending block served")
+	}
+}
+
+func TestFallbackCrossCheck(t *testing.T) {
+	tp := newTestChainPeers(t, 10)
+	tp.addPeer(eth.ETH69, 0, 9)
+	forger := tp.addPeer(eth.ETH69, 0, 9)
+	proxy := newTestFallbackProxy(t, tp)
+
+	// A header returned by a single peer is not trusted.
+	forged := tp.chain[9].Header()
+	forged.Extra = []byte("forged")
+	tp.setForged(forger, forged)
+	if resp := callProxy(t, proxy, "eth_getBlockByNumber", `"0x9", false`); resp.Error == nil || resp.Error.Message != errFallbackQuorum.Error() {
+		t.Fatalf("header without quorum served: %s %+v", resp.Result, resp.Error)
+	}
+
+	// With another honest peer, the forged header is outvoted.
+	tp.addPeer(eth.ETH69, 0, 9)
+	for i := 0; i < 10; i++ {
+		resp := callProxy(t, proxy, "eth_getBlockByNumber", `"0x9", false`)
+		if resp.Error != nil {
+			t.Fatalf("unexpected error: %v", resp.Error.Message)
+		}
+		var block struct {
+			Hash common.Hash `json:"hash"`
+		}
+		if err := json.Unmarshal(resp.Result, &block); err != nil || block.Hash != tp.chain[9].Hash() {
+			t.Fatalf("wrong block: %s", resp.Result)
+		}
+	}
+}
+
+func TestFallbackSilentPeers(t *testing.T) {
+	tp := newTestChainPeers(t, 10)
+	silent := []enode.ID{tp.addPeer(eth.ETH69, 0, 9), tp.addPeer(eth.ETH69, 0, 9)}
+	for _, id := range silent {
+		tp.setSilent(id)
+	}
+	tp.addPeer(eth.ETH69, 0, 9)
+	tp.addPeer(eth.ETH69, 0, 9)
+	proxy := newTestFallbackProxy(t, tp)
+
+	// Peers are asked at once, the silent ones do not hold up the answer.
+	start := time.Now()
+	resp := callProxy(t, proxy, "eth_getBlockByNumber", `"0x9", false`)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error.Message)
+	}
+	if elapsed := time.Since(start); elapsed >= fallbackQueryTimeout {
+		t.Fatalf("block served after %v, peers not asked concurrently", elapsed)
+	}
+	// Requests cancelled once the others answered are not held against peers.
+	for _, id := range silent {
+		if score := tp.backend.Reputation().Score(id); score != 0 {
+			t.Fatalf("silent peer %v has score %v", id, score)
+		}
+	}
+}
+
+func TestFallbackReceipt(t *testing.T) {
+	for _, version := range []uint{eth.ETH68, eth.ETH69} {
+		t.Run(fmt.Sprintf("eth%d", version), func(t *testing.T) {
+			tp := newTestChainPeers(t, 10)
+			tp.addPeer(version, 0, 9)
+			tp.addPeer(version, 0, 9)
+			if version < eth.ETH69 {
+				// The latest block is only known from the ranges of eth/69
+				// peers, the receipts are served by the older ones.
+				tp.setNoReceipts(tp.addPeer(eth.ETH69, 0, 9))
+				tp.setNoReceipts(tp.addPeer(eth.ETH69, 0, 9))
+			}
+			proxy := newTestFallbackProxy(t, tp)
+
+			tx := tp.chain[7].Transactions()[0]
+			resp := callProxy(t, proxy, "eth_getTransactionReceipt", fmt.Sprintf(`"%s"`, tx.Hash()))
+			if resp.Error != nil {
+				t.Fatalf("unexpected error: %v", resp.Error.Message)
+			}
+			var receipt struct {
+				TxHash      common.Hash `json:"transactionHash"`
+				BlockHash   common.Hash `json:"blockHash"`
+				BlockNumber string      `json:"blockNumber"`
+				Status      string      `json:"status"`
+				GasUsed     string      `json:"gasUsed"`
+			}
+			if err := json.Unmarshal(resp.Result, &receipt); err != nil {
+				t.Fatal(err)
+			}
+			if receipt.TxHash != tx.Hash() || receipt.BlockHash != tp.chain[7].Hash() || receipt.BlockNumber != "0x7" || receipt.Status != "0x1" || receipt.GasUsed != "0x5208" {
+				t.Fatalf("wrong receipt: %s", resp.Result)
+			}
+
+			// Receipts not matching the receipt root are rejected.
+			tp.lock.Lock()
+			tp.receipts[tp.chain[7].Hash()][0].Status = types.ReceiptStatusFailed
+			tp.lock.Unlock()
+			if resp := callProxy(t, proxy, "eth_getTransactionReceipt", fmt.Sprintf(`"%s"`, tx.Hash())); resp.Error == nil {
+				t.Fatalf("invalid receipt served: %s", resp.Result)
+			}
+		})
+	}
+}
+
+func TestFallbackPendingTransaction(t *testing.T) {
+	tp := newTestChainPeers(t, 10)
+	tp.addPeer(eth.ETH69, 0, 9)
+	tp.addPeer(eth.ETH69, 0, 9)
+	proxy := newTestFallbackProxy(t, tp)
+
+	tx, _ := testSignedTx(t, 1)
+	tp.lock.Lock()
+	tp.pool[tx.Hash()] = tx
+	tp.lock.Unlock()
+	resp := callProxy(t, proxy, "eth_getTransactionByHash", fmt.Sprintf(`"%s"`, tx.Hash()))
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error.Message)
+	}
+	var result struct {
+		Hash      common.Hash    `json:"hash"`
+		BlockHash *common.Hash   `json:"blockHash"`
+		From      common.Address `json:"from"`
+	}
+	if err := json.Unmarshal(resp.Result, &result); err != nil || result.Hash != tx.Hash() || result.BlockHash != nil || result.From == (common.Address{}) {
+		t.Fatalf("wrong pending transaction: %s", resp.Result)
+	}
+	if resp := callProxy(t, proxy, "eth_getTransactionByHash", `"0x0000000000000000000000000000000000000000000000000000000000000001"`); resp.Error != nil || string(resp.Result) != "null" {
+		t.Fatalf("wrong response for unknown transaction: %s %+v", resp.Result, resp.Error)
+	}
+}
+
+func TestFallbackUpstream(t *testing.T) {
+	tp := newTestChainPeers(t, 10)
+	tp.addPeer(eth.ETH69, 0, 9)
+	tp.addPeer(eth.ETH69, 0, 9)
+	status := http.StatusOK
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":"upstream"}`)
+	}))
+	defer upstream.Close()
+	fallback, err := newFallbackServer(tp, params.TestChainConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := newRPCProxy(upstream.URL, nil)
+	proxy.fallback = fallback
+
+	// A working upstream answers all reads.
+	if resp := callProxy(t, proxy, "eth_getBlockByNumber", `"latest", false`); string(resp.Result) != `"upstream"` {
+		t.Fatalf("read not sent upstream: %s", resp.Result)
+	}
+
+	// Server errors fall back to peers.
+	status = http.StatusServiceUnavailable
+	resp := callProxy(t, proxy, "eth_getBlockByNumber", `"latest", false`)
+	if resp.Error != nil || !strings.Contains(string(resp.Result), tp.chain[9].Hash().Hex()) {
+		t.Fatalf("read not answered from peers: %s %+v", resp.Result, resp.Error)
+	}
+
+	// Other methods are not answered by peers.
+	body := `{"jsonrpc":"2.0","method":"eth_call","params":[],"id":1}`
+	req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("wrong status %d for eth_call", w.Code)
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/eth/relay"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+// Health endpoints.
+//
+// /healthz reports whether the relay is alive, /readyz whether it should
+// receive traffic. Both are served on the JSON-RPC proxy port and answer with
+// 200 or 503 and a JSON body explaining each check. Unlike probing the RPC port
+// with a call, they report the state of the relay itself rather than that of
+// the upstream endpoint.
+
+const healthUpstreamTimeout = 5 * time.Second
+
+// Health check results.
+const (
+	checkOK       = "ok"
+	checkFail     = "fail"
+	checkDisabled = "disabled"
+)
+
+// healthConfig configures the readiness checks.
+type healthConfig struct {
+	MinPeers      int           // eth peers needed to be ready
+	RequireOnion  bool          // require the P2P hidden service to be published
+	CheckUpstream bool          // require the upstream RPC endpoint to answer
+	MaxQueueDepth int           // relay queue depth at which the relay loop counts as stalled (0 = no limit)
+	MaxIdle       time.Duration // time without messages from peers to relay after which the relay counts as stalled (0 = no limit)
+}
+
+// relayStatus is the part of the relay backend inspected by the health checks.
+type relayStatus interface {
+	Peers() []*relay.RelayPeer
+	RelayQueue() relay.QueueInfo
+	LastReceived() time.Time
+	Draining() bool
+}
+
+// healthCheck is the result of a single check.
+type healthCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// healthReport is the response body of the health endpoints.
+type healthReport struct {
+	Status string        `json:"status"`
+	Checks []healthCheck `json:"checks"`
+}
+
+// p2pStatus is the state of the P2P server, implemented by p2p.Server.
+type p2pStatus interface {
+	Running() bool
+	PeerCount() int
+	Self() *enode.Node
+}
+
+// healthChecker serves the health endpoints.
+type healthChecker struct {
+	config  healthConfig
+	relay   relayStatus
+	p2p     p2pStatus
+	proxy   *rpcProxy
+	started time.Time
+}
+
+func newHealthChecker(config healthConfig, relay relayStatus, p2p p2pStatus, proxy *rpcProxy) *healthChecker {
+	return &healthChecker{
+		config:  config,
+		relay:   relay,
+		p2p:     p2p,
+		proxy:   proxy,
+		started: time.Now(),
+	}
+}
+
+// handler routes the health endpoints and passes other requests to next.
+func (h *healthChecker) handler(next http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, h.liveness())
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, h.readiness(r.Context()))
+	})
+	mux.Handle("/", next)
+	return mux
+}
+
+// liveness checks that the P2P server is running.
+func (h *healthChecker) liveness() *healthReport {
+	return newHealthReport([]healthCheck{h.checkP2P()})
+}
+
+// readiness runs the configured readiness checks.
+func (h *healthChecker) readiness(ctx context.Context) *healthReport {
+	return newHealthReport([]healthCheck{
+		h.checkP2P(),
+		h.checkDrain(),
+		h.checkPeers(),
+		h.checkOnion(),
+		h.checkUpstream(ctx),
+		h.checkRelayLoop(),
+	})
+}
+
+func (h *healthChecker) checkP2P() healthCheck {
+	// The peer count is only available while the server runs.
+	if !h.p2p.Running() {
+		return healthCheck{Name: "p2p", Status: checkFail, Detail: "P2P server not running"}
+	}
+	return healthCheck{Name: "p2p", Status: checkOK, Detail: fmt.Sprintf("P2P server running with %d peers", h.p2p.PeerCount())}
+}
+
+func (h *healthChecker) checkDrain() healthCheck {
+	if h.relay.Draining() {
+		return healthCheck{Name: "drain", Status: checkFail, Detail: "draining before shutdown"}
+	}
+	return healthCheck{Name: "drain", Status: checkOK, Detail: "not draining"}
+}
+
+func (h *healthChecker) checkPeers() healthCheck {
+	c := healthCheck{Name: "peers", Status: checkOK}
+	peers := len(h.relay.Peers())
+	if peers < h.config.MinPeers {
+		c.Status = checkFail
+	}
+	c.Detail = fmt.Sprintf("%d eth peers, minimum %d", peers, h.config.MinPeers)
+	return c
+}
+
+func (h *healthChecker) checkOnion() healthCheck {
+	c := healthCheck{Name: "onion", Status: checkDisabled, Detail: "hidden service not required"}
+	if !h.config.RequireOnion {
+		return c
+	}
+	var onion enr.Onion3
+	if !h.p2p.Running() || h.p2p.Self().Load(&onion) != nil {
+		c.Status, c.Detail = checkFail, "P2P hidden service not published"
+		return c
+	}
+	c.Status, c.Detail = checkOK, "P2P hidden service published at "+string(onion)
+	return c
+}
+
+func (h *healthChecker) checkUpstream(ctx context.Context) healthCheck {
+	c := healthCheck{Name: "upstream", Status: checkDisabled, Detail: "upstream not required"}
+	if !h.config.CheckUpstream {
+		return c
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthUpstreamTimeout)
+	defer cancel()
+	if err := h.proxy.pingUpstream(ctx); err != nil {
+		c.Status, c.Detail = checkFail, fmt.Sprintf("upstream unreachable: %v", err)
+		return c
+	}
+	c.Status, c.Detail = checkOK, "upstream reachable"
+	return c
+}
+
+func (h *healthChecker) checkRelayLoop() healthCheck {
+	c := healthCheck{Name: "relay", Status: checkOK}
+	queue := h.relay.RelayQueue()
+	last := h.relay.LastReceived()
+	if last.IsZero() {
+		last = h.started
+	}
+	idle := time.Since(last).Truncate(time.Second)
+	c.Detail = fmt.Sprintf("queue %d/%d, last message %v ago", queue.Len, queue.Cap, idle)
+
+	switch {
+	case h.config.MaxQueueDepth > 0 && queue.Len >= h.config.MaxQueueDepth:
+		c.Status = checkFail
+		c.Detail += fmt.Sprintf(", queue depth limit %d reached", h.config.MaxQueueDepth)
+	case h.config.MaxIdle > 0 && idle > h.config.MaxIdle:
+		c.Status = checkFail
+		c.Detail += fmt.Sprintf(", idle limit %v exceeded", h.config.MaxIdle)
+	}
+	return c
+}
+
+func newHealthReport(checks []healthCheck) *healthReport {
+	report := &healthReport{Status: checkOK, Checks: checks}
+	for _, c := range checks {
+		if c.Status == checkFail {
+			report.Status = checkFail
+		}
+	}
+	return report
+}
+
+func writeHealthReport(w http.ResponseWriter, report *healthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status != checkOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,223 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/eth/relay"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// testRelayStatus is a relayStatus with fixed values.
+type testRelayStatus struct {
+	peers    int
+	queue    relay.QueueInfo
+	last     time.Time
+	draining bool
+}
+
+func (s *testRelayStatus) Peers() []*relay.RelayPeer {
+	peers := make([]*relay.RelayPeer, s.peers)
+	for i := range peers {
+		p := p2p.NewPeer(enode.ID{byte(i)}, "test", []p2p.Cap{{Name: "eth", Version: 69}})
+		peers[i] = relay.NewRelayPeer(p, 69, nil)
+	}
+	return peers
+}
+
+func (s *testRelayStatus) RelayQueue() relay.QueueInfo { return s.queue }
+func (s *testRelayStatus) LastReceived() time.Time     { return s.last }
+func (s *testRelayStatus) Draining() bool              { return s.draining }
+
+// newTestP2PServer creates a P2P server that neither listens nor dials.
+func newTestP2PServer(t *testing.T) *p2p.Server {
+	t.Helper()
+	key, _ := crypto.GenerateKey()
+	srv := &p2p.Server{Config: p2p.Config{PrivateKey: key, MaxPeers: 10, NoDiscovery: true, NoDial: true}}
+	t.Cleanup(srv.Stop)
+	return srv
+}
+
+func startTestP2PServer(t *testing.T) *p2p.Server {
+	t.Helper()
+	srv := newTestP2PServer(t)
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+// checkStatus returns the status of the named check in the report.
+func checkStatus(report *healthReport, name string) string {
+	for _, c := range report.Checks {
+		if c.Name == name {
+			return c.Status
+		}
+	}
+	return ""
+}
+
+func TestReadiness(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	}))
+	defer upstream.Close()
+
+	srv := startTestP2PServer(t)
+	status := &testRelayStatus{peers: 1, queue: relay.QueueInfo{Cap: 1000}, last: time.Now()}
+	config := healthConfig{
+		MinPeers:      2,
+		RequireOnion:  true,
+		CheckUpstream: true,
+		MaxQueueDepth: 1000,
+		MaxIdle:       time.Minute,
+	}
+	proxy := newRPCProxy("http://127.0.0.1:1", rpc.NewServer())
+	h := newHealthChecker(config, status, srv, proxy)
+
+	report := h.readiness(context.Background())
+	if report.Status != checkFail {
+		t.Errorf("status %q, want %q", report.Status, checkFail)
+	}
+	for name, want := range map[string]string{"p2p": checkOK, "drain": checkOK, "peers": checkFail, "onion": checkFail, "upstream": checkFail, "relay": checkOK} {
+		if got := checkStatus(report, name); got != want {
+			t.Errorf("check %s: status %q, want %q", name, got, want)
+		}
+	}
+
+	status.peers = 2
+	srv.LocalNode().Set(enr.Onion3("2gzyxa5ihm7nsggfxnu52rck2vv4rvmdlkiu3zzui5du4xyclen53wid.onion"))
+	proxy.setUpstreamURL(upstream.URL)
+	report = h.readiness(context.Background())
+	if report.Status != checkOK {
+		t.Errorf("status %q, want %q: %+v", report.Status, checkOK, report.Checks)
+	}
+
+	// A full relay queue or a long silence means the relay loop is stalled.
+	status.queue.Len = 1000
+	if got := checkStatus(h.readiness(context.Background()), "relay"); got != checkFail {
+		t.Errorf("full queue: relay check %q, want %q", got, checkFail)
+	}
+	status.queue.Len = 0
+	status.last = time.Now().Add(-2 * time.Minute)
+	if got := checkStatus(h.readiness(context.Background()), "relay"); got != checkFail {
+		t.Errorf("idle relay: relay check %q, want %q", got, checkFail)
+	}
+	status.last = time.Now()
+
+	// Draining relays are not ready, but still alive.
+	status.draining = true
+	if got := checkStatus(h.readiness(context.Background()), "drain"); got != checkFail {
+		t.Errorf("draining: drain check %q, want %q", got, checkFail)
+	}
+	if report := h.liveness(); report.Status != checkOK {
+		t.Errorf("draining: liveness %q, want %q", report.Status, checkOK)
+	}
+}
+
+func TestReadinessDisabledChecks(t *testing.T) {
+	h := newHealthChecker(healthConfig{}, &testRelayStatus{}, startTestP2PServer(t), nil)
+
+	report := h.readiness(context.Background())
+	if report.Status != checkOK {
+		t.Errorf("status %q, want %q: %+v", report.Status, checkOK, report.Checks)
+	}
+	for _, name := range []string{"onion", "upstream"} {
+		if got := checkStatus(report, name); got != checkDisabled {
+			t.Errorf("check %s: status %q, want %q", name, got, checkDisabled)
+		}
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	srv := newTestP2PServer(t)
+	h := newHealthChecker(healthConfig{}, &testRelayStatus{}, srv, nil)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	handler := h.handler(next)
+
+	get := func(path string) (int, *healthReport) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code == http.StatusTeapot {
+			return w.Code, nil
+		}
+		var report healthReport
+		if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+			t.Fatalf("%s: invalid body: %v", path, err)
+		}
+		return w.Code, &report
+	}
+
+	// Not live until the P2P server is started.
+	if code, report := get("/healthz"); code != http.StatusServiceUnavailable || report.Status != checkFail {
+		t.Errorf("/healthz before start: %d %q", code, report.Status)
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if code, report := get("/healthz"); code != http.StatusOK || report.Status != checkOK {
+		t.Errorf("/healthz: %d %q", code, report.Status)
+	}
+	if code, report := get("/readyz"); code != http.StatusOK || len(report.Checks) != 6 {
+		t.Errorf("/readyz: %d %+v", code, report)
+	}
+	if code, _ := get("/"); code != http.StatusTeapot {
+		t.Errorf("/: %d, want request passed to the RPC proxy", code)
+	}
+	srv.Stop()
+	if code, _ := get("/healthz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/healthz after stop: %d", code)
+	}
+}
+
+func TestReadinessPeerTraffic(t *testing.T) {
+	tp := newTestChainPeers(t, 1)
+	tp.addPeer(eth.ETH69, 0, 0)
+	srv := startTestP2PServer(t)
+	h := newHealthChecker(healthConfig{MaxIdle: time.Second}, tp.backend, srv, nil)
+	h.started = time.Now().Add(-time.Minute)
+
+	if got := checkStatus(h.readiness(context.Background()), "relay"); got != checkFail {
+		t.Fatalf("silent relay: relay check %q, want %q", got, checkFail)
+	}
+	// Transactions from a peer count as relay traffic.
+	tx, _ := testSignedTx(t, 0)
+	if err := p2p.Send(tp.remotes[0], eth.TransactionsMsg, eth.TransactionsPacket{tx}); err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	for i := 0; i < 100 && got != checkOK; i++ {
+		time.Sleep(5 * time.Millisecond)
+		got = checkStatus(h.readiness(context.Background()), "relay")
+	}
+	if got != checkOK {
+		t.Fatalf("relay check %q after peer traffic, want %q", got, checkOK)
+	}
+}
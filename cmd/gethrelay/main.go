@@ -17,24 +17,26 @@
 package main
 
 import (
+	"crypto/ecdsa"
 	"fmt"
+	"net/http"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
-	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/core"
+	bparams "github.com/ethereum/go-ethereum/beacon/params"
 	"github.com/ethereum/go-ethereum/core/forkid"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth/relay"
+	"github.com/ethereum/go-ethereum/ethstats"
 	"github.com/ethereum/go-ethereum/internal/debug"
 	"github.com/ethereum/go-ethereum/internal/flags"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
-	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
-	"github.com/ethereum/go-ethereum/p2p/nat"
-	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethereum/go-ethereum/triedb"
 	"github.com/urfave/cli/v2"
 )
@@ -49,6 +51,10 @@ var (
 			Name:  "identity",
 			Usage: "Custom node name",
 		},
+		&cli.StringFlag{
+			Name:  "datadir",
+			Usage: "Data directory for the node key, peer database and Tor keys (default: in-memory)",
+		},
 		&cli.StringFlag{
 			Name:  "bootnodes",
 			Usage: "Comma separated list of bootstrap nodes",
@@ -127,6 +133,18 @@ var (
 			Usage: "Upstream RPC endpoint URL for proxying requests (default: https://ethereum-rpc.publicnode.com)",
 			Value: "https://ethereum-rpc.publicnode.com",
 		},
+		&cli.StringFlag{
+			Name:  "rpc.builders",
+			Usage: "Comma-separated block builder endpoints receiving eth_sendPrivateTransaction and eth_sendBundle",
+		},
+		&cli.StringFlag{
+			Name:  "rpc.builder-key",
+			Usage: "Key file signing builder requests (default: <datadir>/gethrelay/builderkey, generated if missing)",
+		},
+		&cli.BoolFlag{
+			Name:  "rpc.p2p-fallback",
+			Usage: "Answer block and transaction reads from peers when the upstream endpoint is down",
+		},
 		// Tor configuration flags
 		&cli.StringFlag{
 			Name:  "tor-proxy",
@@ -140,6 +158,202 @@ var (
 			Name:  "only-onion",
 			Usage: "Restrict to .onion addresses only (requires --tor-proxy)",
 		},
+		&cli.BoolFlag{
+			Name:  "tor-all",
+			Usage: "Dial clearnet peers through the Tor SOCKS proxy as exit traffic (requires --tor-proxy, disables UDP discovery)",
+		},
+		// Transport-aware peer slot quotas
+		&cli.IntFlag{
+			Name:  "min-onion-peers",
+			Usage: "Number of peer slots kept for onion peers; onion candidates are dialed first while short (requires --tor-proxy)",
+		},
+		&cli.IntFlag{
+			Name:  "max-onion-peers",
+			Usage: "Maximum number of onion peers, static and trusted nodes excluded (0 = no limit)",
+		},
+		&cli.IntFlag{
+			Name:  "max-clearnet-inbound",
+			Usage: "Maximum number of inbound clearnet peers (0 = no limit)",
+		},
+		&cli.IntFlag{
+			Name:  "reserved-onion-slots",
+			Usage: "Peer slots reserved for static and trusted onion nodes (requires --tor-proxy)",
+		},
+		// Outbound proxy configuration flags
+		&cli.StringFlag{
+			Name:  "proxy",
+			Usage: "Outbound proxy for clearnet peers (socks5://[user:pass@]host:port or http://[user:pass@]host:port)",
+		},
+		&cli.BoolFlag{
+			Name:  "pex",
+			Usage: "Exchange onion/I2P node records with connected peers (always on with --only-onion and --tor-all)",
+		},
+		// Peer reputation flags
+		&cli.IntFlag{
+			Name:  "ban-threshold",
+			Usage: "Reputation score below which misbehaving peers are banned",
+			Value: -100,
+		},
+		&cli.DurationFlag{
+			Name:  "ban-duration",
+			Usage: "How long misbehaving peers stay banned",
+			Value: time.Hour,
+		},
+		// Bandwidth limit flags
+		&cli.Uint64Flag{
+			Name:  "bandwidth.egress",
+			Usage: "Global egress limit in bytes/s (0 = unlimited)",
+		},
+		&cli.Uint64Flag{
+			Name:  "bandwidth.ingress",
+			Usage: "Global ingress limit in bytes/s (0 = unlimited)",
+		},
+		&cli.Uint64Flag{
+			Name:  "bandwidth.peer-egress",
+			Usage: "Egress limit per peer in bytes/s (0 = unlimited)",
+		},
+		&cli.Uint64Flag{
+			Name:  "bandwidth.peer-ingress",
+			Usage: "Ingress limit per peer in bytes/s (0 = unlimited)",
+		},
+		// Outbound queue flags
+		&cli.IntFlag{
+			Name:  "queue.limit",
+			Usage: "Maximum payload bytes queued for each peer",
+			Value: 4 * 1024 * 1024,
+		},
+		&cli.StringFlag{
+			Name:  "queue.policy",
+			Usage: "Messages dropped from full peer queues (drop-gossip, drop-oldest)",
+			Value: string(relay.DropGossip),
+		},
+		&cli.DurationFlag{
+			Name:  "queue.slow-peer-timeout",
+			Usage: "How long a peer queue may stay full before the peer is disconnected",
+			Value: 30 * time.Second,
+		},
+		&cli.DurationFlag{
+			Name:  "broadcast.delay",
+			Usage: "Mean random delay of transaction broadcasts to each peer, announcements are batched meanwhile (0 = no delay)",
+		},
+		// Dandelion++ flags
+		&cli.BoolFlag{
+			Name:  "dandelion",
+			Usage: "Propagate submitted transactions with Dandelion++ and pass on the stem transactions of peers",
+		},
+		&cli.Float64Flag{
+			Name:  "dandelion.fluff-probability",
+			Usage: "Probability that a stem transaction is broadcast instead of passed on",
+			Value: 0.1,
+		},
+		&cli.DurationFlag{
+			Name:  "dandelion.embargo",
+			Usage: "Minimum time before a stem transaction not seen on the network is broadcast",
+			Value: 30 * time.Second,
+		},
+		&cli.DurationFlag{
+			Name:  "dandelion.epoch",
+			Usage: "How long the stem peer is kept",
+			Value: 10 * time.Minute,
+		},
+		// Multi-network flags
+		&cli.StringFlag{
+			Name:  "networks",
+			Usage: "Further chains relayed by this process, as comma separated chain:port (e.g. sepolia:30304,holesky:30305)",
+		},
+		// Beacon light client flags
+		&cli.StringFlag{
+			Name:  "beacon.api",
+			Usage: "Comma separated beacon node REST endpoints followed by the light client to verify the chain head",
+		},
+		&cli.StringFlag{
+			Name:  "beacon.checkpoint",
+			Usage: "Weak subjectivity checkpoint (beacon block root) to start the light client from (default = chain preset)",
+		},
+		&cli.IntFlag{
+			Name:  "beacon.threshold",
+			Usage: "Sync committee signatures required for a verified head",
+			Value: bparams.SyncCommitteeSupermajority,
+		},
+		// Relay overlay flags
+		&cli.IntFlag{
+			Name:  "overlay.links",
+			Usage: "Number of links to other relays kept (0 = only accept links)",
+			Value: 4,
+		},
+		&cli.StringFlag{
+			Name:  "overlay.operator",
+			Usage: "Compressed public key of the relay operator, announced to other relays",
+		},
+		// Wire capture flags
+		&cli.StringFlag{
+			Name:  "capture.file",
+			Usage: "Record the eth messages exchanged with peers to this file (JSONL, relative to the datadir)",
+		},
+		&cli.IntFlag{
+			Name:  "capture.maxsize",
+			Usage: "Size in megabytes at which the capture file is rotated",
+			Value: 100,
+		},
+		&cli.IntFlag{
+			Name:  "capture.maxfiles",
+			Usage: "Number of rotated capture files kept (0 = all)",
+			Value: 10,
+		},
+		// Readiness check flags
+		&cli.IntFlag{
+			Name:  "health.min-peers",
+			Usage: "Number of eth peers needed for /readyz to report ready",
+			Value: 1,
+		},
+		&cli.BoolFlag{
+			Name:  "health.onion",
+			Usage: "Require the P2P Tor hidden service to be published for /readyz (requires --tor-control)",
+		},
+		&cli.BoolFlag{
+			Name:  "health.upstream",
+			Usage: "Require the upstream RPC endpoint to be reachable for /readyz",
+		},
+		&cli.IntFlag{
+			Name:  "health.max-queue",
+			Usage: "Relay queue depth at which /readyz reports the relay loop as stalled (0 = no limit)",
+			Value: 1000,
+		},
+		&cli.DurationFlag{
+			Name:  "health.max-idle",
+			Usage: "Time without messages from peers to relay after which /readyz reports the relay as stalled (0 = no limit)",
+		},
+		&cli.DurationFlag{
+			Name:  "drain.timeout",
+			Usage: "How long shutdown waits for in-flight requests and outbound queues before disconnecting peers",
+			Value: 20 * time.Second,
+		},
+		&cli.StringFlag{
+			Name:  "ethstats",
+			Usage: "Reporting URL of a ethstats service (nodename:secret@host:port)",
+		},
+		// I2P configuration flags
+		&cli.StringFlag{
+			Name:  "i2p-sam",
+			Usage: "SAM v3 bridge address of an I2P router for I2P peers (e.g., 127.0.0.1:7656)",
+		},
+		&cli.BoolFlag{
+			Name:  "prefer-i2p",
+			Usage: "Prefer I2P destinations when a peer is also reachable otherwise",
+		},
+		&cli.BoolFlag{
+			Name:  "only-i2p",
+			Usage: "Restrict outbound connections to I2P destinations only (requires --i2p-sam)",
+		},
+		&cli.StringFlag{
+			Name:  "tor-control",
+			Usage: "Tor control port address; publishes the P2P port as a hidden service (e.g., 127.0.0.1:9051)",
+		},
+		&cli.StringFlag{
+			Name:  "tor-cookie",
+			Usage: "Tor control port authentication cookie (relative paths resolve against the datadir)",
+			Value: node.DefaultTorCookiePath,
+		},
 		// HTTP RPC configuration flags
 		&cli.BoolFlag{
 			Name:  "http",
@@ -178,11 +392,26 @@ var (
 	}
 
 	app = flags.NewApp("lightweight Ethereum P2P relay node")
+
+	onionAuthCommand = &cli.Command{
+		Name:      "onion-auth",
+		Usage:     "Generate a Tor v3 client authorization key pair for a relay's hidden service",
+		ArgsUsage: "<onion address>",
+		Action:    onionAuth,
+		Description: `
+Generates a key pair that lets one relay reach another relay's restricted
+hidden service. The .auth line goes into tor/authorized_clients/<name>.auth
+in the datadir of the relay serving the onion address, the .auth_private line
+into tor/client_auth/<name>.auth_private in the datadir of the dialing relay.
+Once any authorized client is configured, the hidden service is invisible to
+everyone else.`,
+	}
 )
 
 func init() {
 	app.Action = runRelay
-	app.Flags = append(relayFlags, debug.Flags...)
+	app.Commands = []*cli.Command{onionAuthCommand, dumpConfigCommand}
+	app.Flags = slices.Concat([]cli.Flag{configFileFlag}, relayFlags, debug.Flags)
 	flags.AutoEnvVars(app.Flags, "GETHRELAY")
 
 	app.Before = func(ctx *cli.Context) error {
@@ -209,158 +438,23 @@ func runRelay(ctx *cli.Context) error {
 		return fmt.Errorf("invalid command: %q", args[0])
 	}
 
-	// Validate Tor configuration
-	if ctx.Bool("only-onion") && !ctx.IsSet("tor-proxy") {
-		return fmt.Errorf("--only-onion requires --tor-proxy to be set")
-	}
-
-	// Load chain configuration
-	chainPreset := ctx.String("chain")
-	var chainConfig *params.ChainConfig
-	var genesisHash common.Hash
-	var networkID uint64
-
-	switch chainPreset {
-	case "mainnet":
-		chainConfig = params.MainnetChainConfig
-		genesisHash = params.MainnetGenesisHash
-		networkID = 1
-	case "holesky":
-		chainConfig = params.HoleskyChainConfig
-		genesisHash = params.HoleskyGenesisHash
-		networkID = 17000
-	case "sepolia":
-		chainConfig = params.SepoliaChainConfig
-		genesisHash = params.SepoliaGenesisHash
-		networkID = 11155111
-	default:
-		return fmt.Errorf("unknown chain preset: %s", chainPreset)
-	}
-
-	// Override genesis hash if provided
-	if ctx.IsSet("genesis") {
-		genesisHash = common.HexToHash(ctx.String("genesis"))
-	}
-
-	// Override network ID if provided
-	if ctx.IsSet("networkid") {
-		networkID = ctx.Uint64("networkid")
-	}
-
-	// Calculate fork ID
-	// For relay, we use current block = latest block or 0
-	latestBlock := ctx.Uint64("latest-block")
-	if latestBlock == 0 {
-		latestBlock = 0 // Start from genesis
-	}
-	
-	// Get current timestamp for fork ID calculation
-	currentTime := uint64(time.Now().Unix())
-	
-	// Create a minimal genesis block structure for fork ID calculation
-	// We only need the hash and timestamp, which we already have
-	// For forkid.NewID, we need a types.Block, so we'll use a simplified approach
-	// Create genesis from preset
-	var genesis *core.Genesis
-	switch chainPreset {
-	case "mainnet":
-		genesis = core.DefaultGenesisBlock()
-	case "holesky":
-		genesis = core.DefaultHoleskyGenesisBlock()
-	case "sepolia":
-		genesis = core.DefaultSepoliaGenesisBlock()
-	default:
-		genesis = core.DefaultGenesisBlock()
-	}
-	
-	// Commit genesis to get block for fork ID calculation
-	// Use in-memory database to avoid any disk writes
-	db := rawdb.NewMemoryDatabase()
-	genesisBlock, _ := genesis.Commit(db, triedb.NewDatabase(db, nil))
-	
-	// Calculate fork ID (using latest block or 0)
-	forkID := forkid.NewID(chainConfig, genesisBlock, latestBlock, currentTime)
-
-	// Get block range
-	latestHash := common.Hash{}
-	if ctx.IsSet("latest-hash") {
-		latestHash = common.HexToHash(ctx.String("latest-hash"))
-	} else if latestBlock == 0 {
-		// If no latest block specified, use genesis block hash for ETH69 compatibility
-		// ETH69 requires a non-empty LatestBlockHash in the status packet
-		latestHash = genesisHash
-	}
-
-	blockRange := relay.BlockRange{
-		EarliestBlock:   ctx.Uint64("earliest-block"),
-		LatestBlock:     latestBlock,
-		LatestBlockHash: latestHash,
-	}
-
-	// Create relay configuration
-	relayConfig := &relay.Config{
-		NetworkID:   networkID,
-		GenesisHash: genesisHash,
-		ChainConfig: chainConfig,
-		ForkID:      forkID,
-		BlockRange:  blockRange,
+	cfg, err := makeConfig(ctx)
+	if err != nil {
+		return err
 	}
+	cfg.resolve()
+	preset := chainPresets[cfg.Chain]
+	relayConfig, nodeConfig := &cfg.Relay, &cfg.Node
+	networkID, genesisHash := relayConfig.NetworkID, relayConfig.GenesisHash
 
-	// Create minimal node (no database)
-	nodeConfig := &node.Config{
-		P2P: p2p.Config{
-			MaxPeers:      ctx.Int("maxpeers"),
-			ListenAddr:    fmt.Sprintf(":%d", ctx.Int("port")),
-			DiscoveryV4:   ctx.Bool("v4disc"),
-			DiscoveryV5:   ctx.Bool("v5disc"),
-			NoDiscovery:   ctx.Bool("nodiscover"),
-			// Tor configuration
-			TorSOCKSProxy: ctx.String("tor-proxy"),
-			PreferTor:     ctx.Bool("prefer-tor"),
-			OnlyOnion:     ctx.Bool("only-onion"),
-		},
-		UserIdent: ctx.String("identity"),
-	}
+	relayConfig.ChainConfig = preset.config
+	relayConfig.LightClient.ChainConfig = preset.beacon
+	relayConfig.ForkID = chainForkID(preset, relayConfig.BlockRange.LatestBlock)
 
-	// Enable admin API server if requested
-	if ctx.Bool("admin") {
-		nodeConfig.HTTPHost = ctx.String("admin.addr")
-		nodeConfig.HTTPPort = ctx.Int("admin.port")
-		nodeConfig.HTTPModules = []string{"admin"}
+	if nodeConfig.HTTPHost != "" {
 		log.Info("Admin API server enabled", "addr", nodeConfig.HTTPHost, "port", nodeConfig.HTTPPort)
 	}
-
-	// Set NAT
-	if ctx.IsSet("nat") {
-		natif, err := nat.Parse(ctx.String("nat"))
-		if err != nil {
-			return fmt.Errorf("invalid NAT option: %v", err)
-		}
-		nodeConfig.P2P.NAT = natif
-	}
-	
-	// Set bootstrap nodes
-	if ctx.IsSet("bootnodes") {
-		urls := splitAndTrim(ctx.String("bootnodes"))
-		nodeConfig.P2P.BootstrapNodes = mustParseBootnodes(urls)
-	} else {
-		// Use default bootnodes based on chain
-		var urls []string
-		switch chainPreset {
-		case "holesky":
-			urls = params.HoleskyBootnodes
-		case "sepolia":
-			urls = params.SepoliaBootnodes
-		default:
-			urls = params.MainnetBootnodes
-		}
-		nodeConfig.P2P.BootstrapNodes = mustParseBootnodes(urls)
-	}
-
-	// Set static nodes (for persistent .onion connections)
-	if ctx.IsSet("staticnodes") {
-		urls := splitAndTrim(ctx.String("staticnodes"))
-		nodeConfig.P2P.StaticNodes = mustParseBootnodes(urls)
+	if len(nodeConfig.P2P.StaticNodes) > 0 {
 		log.Info("Configured static peer nodes", "count", len(nodeConfig.P2P.StaticNodes))
 	}
 
@@ -370,15 +464,6 @@ func runRelay(ctx *cli.Context) error {
 	}
 	defer stack.Close()
 
-	// Setup RPC proxy with configured HTTP settings
-	httpAddr := ctx.String("http.addr")
-	httpPort := ctx.Int("http.port")
-	upstreamURL := ctx.String("rpc.upstream")
-
-	if err := setupRPCProxy(stack, upstreamURL, httpAddr, httpPort); err != nil {
-		return fmt.Errorf("failed to setup RPC proxy: %v", err)
-	}
-
 	// Create relay service
 	relayService, err := relay.NewRelay(stack, relayConfig, networkID, nil)
 	if err != nil {
@@ -388,6 +473,70 @@ func runRelay(ctx *cli.Context) error {
 	// Register relay service
 	stack.RegisterLifecycle(relayService)
 
+	// Setup RPC proxy with configured HTTP settings
+	var stem transactionStemmer
+	if cfg.Relay.Dandelion.Enabled {
+		stem = relayService
+	}
+	var builderKey *ecdsa.PrivateKey
+	if len(cfg.RPC.Builders) > 0 {
+		if builderKey, err = loadBuilderKey(stack, cfg.RPC.BuilderKey); err != nil {
+			return err
+		}
+		log.Info("Forwarding private transactions to builders", "builders", len(cfg.RPC.Builders), "signer", crypto.PubkeyToAddress(builderKey.PublicKey))
+	}
+	builders := newBuilderAPI(cfg.RPC.Builders, builderKey)
+	var fallback *rpc.Server
+	if cfg.RPC.P2PFallback {
+		if fallback, err = newFallbackServer(relayService, relayConfig.ChainConfig); err != nil {
+			return fmt.Errorf("failed to setup P2P fallback: %v", err)
+		}
+	}
+	proxy, err := newChainProxy(cfg.RPC.Upstream, stem, builders, fallback, relayService.Backend())
+	if err != nil {
+		return fmt.Errorf("failed to setup RPC proxy: %v", err)
+	}
+
+	// Further chains run their own node and relay and share the RPC server
+	var (
+		networks []*network
+		chains   map[string]http.Handler
+		relays   = relayGroup{relayService}
+		proxies  = proxyGroup{proxy}
+	)
+	if len(cfg.Networks) > 0 {
+		chains = map[string]http.Handler{cfg.Chain: proxy}
+	}
+	for i := range cfg.Networks {
+		n, err := newNetwork(cfg, &cfg.Networks[i])
+		if err != nil {
+			return err
+		}
+		defer n.stack.Close()
+		networks = append(networks, n)
+		chains[n.chain] = n.proxy
+		relays = append(relays, n.relay)
+		proxies = append(proxies, n.proxy)
+	}
+	setupRPCProxy(stack, cfg.RPC.HTTPHost, cfg.RPC.HTTPPort, cfg.Health, relayService.Backend(), proxy, chains)
+
+	// Apply configuration changes on SIGHUP, relay_reloadConfig and file edits
+	reloader := newConfigReloader(ctx, cfg, stack.Server(), relayService.Backend(), proxy)
+	stack.RegisterLifecycle(reloader)
+	stack.RegisterAPIs([]rpc.API{{Namespace: "relay", Service: &reloadAPI{reloader}}})
+
+	// Drain before shutting down on SIGINT, SIGTERM and relay_drain
+	drainer := newDrainer(relays, proxies, cfg.DrainTimeout, stack.Close)
+	stack.RegisterLifecycle(drainer)
+	stack.RegisterAPIs([]rpc.API{{Namespace: "relay", Service: &drainAPI{drainer}}})
+
+	// Report to the ethstats server if requested
+	if cfg.Ethstats != "" {
+		if err := ethstats.NewRelay(stack, relayService.Backend(), cfg.Ethstats); err != nil {
+			return fmt.Errorf("failed to setup ethstats: %v", err)
+		}
+	}
+
 	// Register relay protocols BEFORE starting the stack
 	// Protocols must be registered before the node starts
 	if err := relayService.RegisterProtocols(stack); err != nil {
@@ -397,9 +546,12 @@ func runRelay(ctx *cli.Context) error {
 	log.Info("Starting Ethereum P2P relay", 
 		"network", networkID,
 		"genesis", genesisHash.Hex(),
-		"chain", chainPreset)
+		"chain", cfg.Chain)
 
-	// Start the node
+	// Start the nodes
+	if err := startNetworks(networks); err != nil {
+		return err
+	}
 	if err := stack.Start(); err != nil {
 		return fmt.Errorf("failed to start node: %v", err)
 	}
@@ -409,6 +561,33 @@ func runRelay(ctx *cli.Context) error {
 	return nil
 }
 
+// chainForkID returns the fork ID of a chain preset at the given block and the
+// current time.
+func chainForkID(preset chainPreset, latestBlock uint64) forkid.ID {
+	// Commit genesis to get block for fork ID calculation
+	// Use in-memory database to avoid any disk writes
+	db := rawdb.NewMemoryDatabase()
+	genesisBlock, _ := preset.genesis().Commit(db, triedb.NewDatabase(db, nil))
+	return forkid.NewID(preset.config, genesisBlock, latestBlock, uint64(time.Now().Unix()))
+}
+
+// onionAuth prints a fresh client authorization key pair for the given onion
+// address.
+func onionAuth(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("need onion address as the only argument")
+	}
+	auth, authPrivate, err := node.GenerateTorClientAuth(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+	fmt.Println("# tor/authorized_clients/<name>.auth on the serving relay")
+	fmt.Println(auth)
+	fmt.Println("# tor/client_auth/<name>.auth_private on the dialing relay")
+	fmt.Println(authPrivate)
+	return nil
+}
+
 // Helper functions to avoid importing cmd/utils
 
 func splitAndTrim(input string) []string {
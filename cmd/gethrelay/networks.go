@@ -0,0 +1,341 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/eth/relay"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Multi-network relay.
+//
+// Besides the primary chain, one process can relay further chains. Every
+// network runs its own node with its own P2P port, node key, peer set and
+// discovery, and its own relay configured like the primary one except for the
+// chain parameters. The RPC proxy is shared: requests to /<chain> go to the
+// chain's upstream, all other paths to the primary chain.
+
+// networkConfig is a further chain relayed by the process. Zero network ID,
+// genesis hash, bootstrap nodes and upstream select the defaults of the chain
+// preset.
+type networkConfig struct {
+	Chain          string // chain preset: mainnet, holesky or sepolia
+	ListenAddr     string // P2P listening address, distinct from the other networks
+	NetworkID      uint64
+	GenesisHash    common.Hash
+	BootstrapNodes []*enode.Node
+	Upstream       string // upstream RPC endpoint served at /<chain>
+
+	LatestBlock uint64      // latest block announced to peers (0 = past all block number forks)
+	LatestHash  common.Hash // hash of the latest block (default: genesis hash)
+
+	EthDiscoveryURLs  []string // DNS discovery URLs for eth protocol
+	SnapDiscoveryURLs []string // DNS discovery URLs for snap protocol
+	BeaconAPIs        []string // beacon node endpoints of the light client
+}
+
+// parseNetworks parses the --networks flag, a comma separated list of
+// chain:port.
+func parseNetworks(input string) ([]networkConfig, error) {
+	var networks []networkConfig
+	for _, spec := range splitAndTrim(input) {
+		chain, port, ok := strings.Cut(spec, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid --networks entry %q, want chain:port", spec)
+		}
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return nil, fmt.Errorf("invalid --networks port %q", port)
+		}
+		networks = append(networks, networkConfig{Chain: chain, ListenAddr: ":" + port})
+	}
+	return networks, nil
+}
+
+// resolve fills in the defaults of the chain preset.
+func (n *networkConfig) resolve() {
+	preset := chainPresets[n.Chain]
+	if n.NetworkID == 0 {
+		n.NetworkID = preset.networkID
+	}
+	if n.GenesisHash == (common.Hash{}) {
+		n.GenesisHash = preset.hash
+	}
+	if len(n.BootstrapNodes) == 0 {
+		n.BootstrapNodes = mustParseBootnodes(preset.bootnodes)
+	}
+	if n.Upstream == "" {
+		n.Upstream = preset.upstream
+	}
+	if n.LatestHash == (common.Hash{}) && n.LatestBlock == 0 {
+		// ETH69 requires a non-empty LatestBlockHash in the status packet
+		n.LatestHash = n.GenesisHash
+	}
+}
+
+// forkHead returns the block the fork ID of the network is computed at.
+// Without a configured latest block, all block number forks count as passed:
+// the presets are merged chains, their peers are past them.
+func (n *networkConfig) forkHead() uint64 {
+	if n.LatestBlock != 0 {
+		return n.LatestBlock
+	}
+	return math.MaxUint64
+}
+
+// checkNetworks validates the further networks.
+func (cfg *gethrelayConfig) checkNetworks() error {
+	chains := map[string]bool{cfg.Chain: true}
+	ports := map[string]bool{listenPort(cfg.Node.P2P.ListenAddr): true}
+	for _, n := range cfg.Networks {
+		if _, ok := chainPresets[n.Chain]; !ok {
+			return fmt.Errorf("unknown chain preset in networks: %s", n.Chain)
+		}
+		if chains[n.Chain] {
+			return fmt.Errorf("chain %s is relayed more than once", n.Chain)
+		}
+		chains[n.Chain] = true
+		port := listenPort(n.ListenAddr)
+		if port == "" || ports[port] {
+			return fmt.Errorf("network %s needs a listening port of its own", n.Chain)
+		}
+		ports[port] = true
+		if n.Upstream != "" {
+			if u, err := url.Parse(n.Upstream); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("invalid upstream endpoint %q of network %s", n.Upstream, n.Chain)
+			}
+		}
+		for _, api := range n.BeaconAPIs {
+			if u, err := url.Parse(api); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("invalid beacon API endpoint %q of network %s", api, n.Chain)
+			}
+		}
+	}
+	return nil
+}
+
+// listenPort returns the port of a listening address, empty if there is none.
+func listenPort(addr string) string {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil || port == "0" {
+		return ""
+	}
+	return port
+}
+
+// nodeConfig returns the node configuration of a network. It is the primary
+// node configuration with the chain's P2P settings and datadir. The admin API
+// is served by the primary node only.
+func (n *networkConfig) nodeConfig(primary node.Config) node.Config {
+	cfg := primary
+	cfg.P2P.ListenAddr = n.ListenAddr
+	cfg.P2P.BootstrapNodes = n.BootstrapNodes
+	cfg.P2P.StaticNodes = nil
+	cfg.P2P.TrustedNodes = nil
+	cfg.HTTPHost, cfg.WSHost, cfg.IPCPath = "", "", ""
+	if cfg.DataDir != "" {
+		cfg.DataDir = filepath.Join(cfg.DataDir, n.Chain)
+	}
+	return cfg
+}
+
+// relayConfig returns the relay configuration of a network. It is the primary
+// relay configuration with the chain parameters of the network.
+func (n *networkConfig) relayConfig(primary relay.Config) relay.Config {
+	preset := chainPresets[n.Chain]
+	cfg := primary
+	cfg.NetworkID = n.NetworkID
+	cfg.GenesisHash = n.GenesisHash
+	cfg.BlockRange = relay.BlockRange{LatestBlock: n.LatestBlock, LatestBlockHash: n.LatestHash}
+	cfg.ChainConfig = preset.config
+	cfg.ForkID = chainForkID(preset, n.forkHead())
+	cfg.EthDiscoveryURLs = n.EthDiscoveryURLs
+	cfg.SnapDiscoveryURLs = n.SnapDiscoveryURLs
+	cfg.LightClient = relay.LightClientConfig{
+		BeaconAPIs:  n.BeaconAPIs,
+		Threshold:   primary.LightClient.Threshold,
+		ChainConfig: preset.beacon,
+	}
+	// Every network captures to a file and bans peers in a list of its own.
+	// Without a configured ban list, it is kept in the network's datadir.
+	cfg.Capture.File = n.chainFile(cfg.Capture.File)
+	cfg.BanList = n.chainFile(cfg.BanList)
+	return cfg
+}
+
+// chainFile returns the file name of the network for a file configured for
+// all networks, with the chain name appended to the base name.
+func (n *networkConfig) chainFile(file string) string {
+	if file == "" {
+		return ""
+	}
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + "-" + n.Chain + ext
+}
+
+// network is a running relay of a further chain.
+type network struct {
+	chain string
+	stack *node.Node
+	relay *relay.Relay
+	proxy *rpcProxy
+}
+
+// newNetwork creates the node, relay and RPC proxy of a further chain. The
+// node is not started.
+func newNetwork(cfg *gethrelayConfig, n *networkConfig) (*network, error) {
+	nodeConfig := n.nodeConfig(cfg.Node)
+	stack, err := node.New(&nodeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s node: %v", n.Chain, err)
+	}
+	relayConfig := n.relayConfig(cfg.Relay)
+	relayService, err := relay.NewRelay(stack, &relayConfig, relayConfig.NetworkID, nil)
+	if err != nil {
+		stack.Close()
+		return nil, fmt.Errorf("failed to create %s relay: %v", n.Chain, err)
+	}
+	stack.RegisterLifecycle(relayService)
+	if err := relayService.RegisterProtocols(stack); err != nil {
+		stack.Close()
+		return nil, fmt.Errorf("failed to register %s protocols: %v", n.Chain, err)
+	}
+
+	var stem transactionStemmer
+	if relayConfig.Dandelion.Enabled {
+		stem = relayService
+	}
+	var fallback *rpc.Server
+	if cfg.RPC.P2PFallback {
+		if fallback, err = newFallbackServer(relayService, relayConfig.ChainConfig); err != nil {
+			stack.Close()
+			return nil, fmt.Errorf("failed to setup %s P2P fallback: %v", n.Chain, err)
+		}
+	}
+	proxy, err := newChainProxy(n.Upstream, stem, newBuilderAPI(nil, nil), fallback, relayService.Backend())
+	if err != nil {
+		stack.Close()
+		return nil, err
+	}
+	return &network{chain: n.Chain, stack: stack, relay: relayService, proxy: proxy}, nil
+}
+
+// chainRouter routes RPC requests by the chain name in the first path segment,
+// /sepolia or /sepolia/..., to the proxy of the chain. Other paths go to the
+// primary chain.
+func chainRouter(primary http.Handler, chains map[string]http.Handler) http.Handler {
+	if len(chains) == 0 {
+		return primary
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if h, ok := chains[name]; ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		primary.ServeHTTP(w, r)
+	})
+}
+
+// relayGroup drains the relays of all networks at once.
+type relayGroup []relayDrainer
+
+// Drain implements relayDrainer. The result adds up the relays.
+func (g relayGroup) Drain(timeout time.Duration) (*relay.DrainInfo, error) {
+	var (
+		wg    sync.WaitGroup
+		infos = make([]*relay.DrainInfo, len(g))
+		errs  = make([]error, len(g))
+	)
+	for i, r := range g {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			infos[i], errs[i] = r.Drain(timeout)
+		}()
+	}
+	wg.Wait()
+
+	total := &relay.DrainInfo{Flushed: true}
+	for i, info := range infos {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		total.Duration = max(total.Duration, info.Duration)
+		total.Flushed = total.Flushed && info.Flushed
+		total.PendingRequests += info.PendingRequests
+		total.QueuedMessages += info.QueuedMessages
+		total.Peers += info.Peers
+	}
+	return total, nil
+}
+
+// proxyGroup drains the RPC proxies of all networks at once.
+type proxyGroup []*rpcProxy
+
+// drain implements rpcDrainer. It returns the calls still being served by all
+// proxies at the deadline.
+func (g proxyGroup) drain(ctx context.Context) int {
+	var (
+		wg    sync.WaitGroup
+		calls = make([]int, len(g))
+	)
+	for i, p := range g {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			calls[i] = p.drain(ctx)
+		}()
+	}
+	wg.Wait()
+
+	var total int
+	for _, c := range calls {
+		total += c
+	}
+	return total
+}
+
+// startNetworks starts the nodes of the further networks. On failure, the
+// nodes already started are closed.
+func startNetworks(networks []*network) error {
+	for i, n := range networks {
+		log.Info("Starting Ethereum P2P relay", "network", n.relay.Backend().GetNetworkID(), "genesis", n.relay.Backend().GetGenesisHash().Hex(), "chain", n.chain)
+		if err := n.stack.Start(); err != nil {
+			for _, started := range networks[:i] {
+				started.stack.Close()
+			}
+			return fmt.Errorf("failed to start %s node: %v", n.chain, err)
+		}
+	}
+	return nil
+}
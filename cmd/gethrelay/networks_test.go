@@ -0,0 +1,281 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"io/fs"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/relay"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// TestNetworksFlag tests parsing and validation of --networks
+func TestNetworksFlag(t *testing.T) {
+	cfg, err := runMakeConfig(t, "--networks", "sepolia:30304, holesky:30305")
+	if err != nil {
+		t.Fatalf("makeConfig() error = %v", err)
+	}
+	if len(cfg.Networks) != 2 || cfg.Networks[0].Chain != "sepolia" || cfg.Networks[1].ListenAddr != ":30305" {
+		t.Fatalf("unexpected networks %+v", cfg.Networks)
+	}
+	cfg.resolve()
+	sepolia := cfg.Networks[0]
+	if sepolia.NetworkID != 11155111 || sepolia.GenesisHash != params.SepoliaGenesisHash {
+		t.Errorf("sepolia preset not applied: %+v", sepolia)
+	}
+	if sepolia.Upstream != "https://ethereum-sepolia-rpc.publicnode.com" || len(sepolia.BootstrapNodes) == 0 {
+		t.Errorf("sepolia upstream or bootnodes not set: %+v", sepolia)
+	}
+
+	for name, value := range map[string]string{
+		"no port":       "sepolia",
+		"bad port":      "sepolia:http",
+		"unknown chain": "goerli:30304",
+		"primary chain": "mainnet:30304",
+		"twice":         "sepolia:30304,sepolia:30305",
+		"primary port":  "sepolia:30303",
+		"shared port":   "sepolia:30304,holesky:30304",
+	} {
+		if _, err := runMakeConfig(t, "--networks", value); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+// TestNetworksConfigFile tests networks configured in the config file
+func TestNetworksConfigFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "relay.toml")
+	content := `[Relay]
+BanThreshold = -50
+
+[[Networks]]
+Chain = "holesky"
+ListenAddr = ":30305"
+Upstream = "http://127.0.0.1:8546"
+BeaconAPIs = ["http://127.0.0.1:5052"]
+`
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := runMakeConfig(t, "--config", file, "--datadir", "/data", "--capture.file", "capture.jsonl")
+	if err != nil {
+		t.Fatalf("makeConfig() error = %v", err)
+	}
+	cfg.resolve()
+	n := &cfg.Networks[0]
+
+	// The relay settings are those of the primary network, with the chain
+	// parameters of the preset.
+	rc := n.relayConfig(cfg.Relay)
+	if rc.BanThreshold != -50 || rc.QueueLimit != cfg.Relay.QueueLimit {
+		t.Errorf("relay settings not inherited: %+v", rc)
+	}
+	if rc.NetworkID != 17000 || rc.ChainConfig != params.HoleskyChainConfig || rc.BlockRange.LatestBlockHash != params.HoleskyGenesisHash {
+		t.Errorf("holesky parameters not applied: %+v", rc)
+	}
+	if rc.ForkID == cfg.Relay.ForkID {
+		t.Error("fork ID not computed for holesky")
+	}
+	if len(rc.LightClient.BeaconAPIs) != 1 || rc.LightClient.ChainConfig == nil {
+		t.Errorf("light client not configured: %+v", rc.LightClient)
+	}
+	if rc.Capture.File != "capture-holesky.jsonl" {
+		t.Errorf("capture file %q, want capture-holesky.jsonl", rc.Capture.File)
+	}
+
+	nc := n.nodeConfig(cfg.Node)
+	if nc.P2P.ListenAddr != ":30305" || nc.DataDir != filepath.Join("/data", "holesky") {
+		t.Errorf("node settings not applied: listen %q, datadir %q", nc.P2P.ListenAddr, nc.DataDir)
+	}
+	if nc.HTTPHost != "" || cfg.Node.P2P.ListenAddr != ":30303" {
+		t.Error("primary node settings changed")
+	}
+}
+
+// testForkChain is a chain at a fixed head, for the fork ID filter.
+type testForkChain struct {
+	config  *params.ChainConfig
+	genesis *types.Block
+	head    *types.Header
+}
+
+func (c *testForkChain) Config() *params.ChainConfig  { return c.config }
+func (c *testForkChain) Genesis() *types.Block        { return c.genesis }
+func (c *testForkChain) CurrentHeader() *types.Header { return c.head }
+
+// TestNetworksForkID tests that further networks announce the fork ID of
+// peers past the forks of the chain, and that the peers accept it
+func TestNetworksForkID(t *testing.T) {
+	preset := chainPresets["sepolia"]
+	chain := &testForkChain{
+		config:  preset.config,
+		genesis: preset.genesis().ToBlock(),
+		head:    &types.Header{Number: big.NewInt(8_000_000), Time: uint64(time.Now().Unix())},
+	}
+	peer, peerID := forkid.NewFilter(chain), forkid.NewIDWithChain(chain)
+	if chainForkID(preset, 0) == peerID {
+		t.Fatal("fork ID of block 0 is the current one")
+	}
+
+	n := &networkConfig{Chain: "sepolia", ListenAddr: ":30304"}
+	n.resolve()
+	rc := n.relayConfig(relay.Config{})
+	if rc.ForkID != peerID {
+		t.Errorf("fork ID %v, want %v of the peer", rc.ForkID, peerID)
+	}
+	if err := peer(rc.ForkID); err != nil {
+		t.Errorf("peer rejects the default fork ID: %v", err)
+	}
+	if rc.BlockRange.LatestBlockHash != params.SepoliaGenesisHash {
+		t.Errorf("latest hash %x, want genesis", rc.BlockRange.LatestBlockHash)
+	}
+
+	// A configured latest block is announced and sets the fork ID.
+	n = &networkConfig{Chain: "sepolia", ListenAddr: ":30304", LatestBlock: 7_000_000, LatestHash: common.Hash{7}}
+	n.resolve()
+	rc = n.relayConfig(relay.Config{})
+	if rc.ForkID != peerID {
+		t.Errorf("fork ID %v at block 7000000, want %v", rc.ForkID, peerID)
+	}
+	if rc.BlockRange.LatestBlock != 7_000_000 || rc.BlockRange.LatestBlockHash != (common.Hash{7}) {
+		t.Errorf("latest block not announced: %+v", rc.BlockRange)
+	}
+}
+
+// TestNetworksBanList tests that every network bans peers in a list of its own
+func TestNetworksBanList(t *testing.T) {
+	datadir := t.TempDir()
+	cfg, err := runMakeConfig(t, "--datadir", datadir, "--networks", "holesky:30305")
+	if err != nil {
+		t.Fatalf("makeConfig() error = %v", err)
+	}
+	cfg.resolve()
+	stack, err := node.New(&cfg.Node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stack.Close()
+	primary, err := relay.NewRelay(stack, &cfg.Relay, cfg.Relay.NetworkID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Relay.BanList != "" {
+		t.Fatalf("primary relay set the shared ban list to %q", cfg.Relay.BanList)
+	}
+	holesky, err := newNetwork(cfg, &cfg.Networks[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer holesky.stack.Close()
+
+	primary.Backend().Reputation().Ban(enode.ID{1}, "test", time.Hour)
+	holesky.relay.Backend().Reputation().Ban(enode.ID{2}, "test", time.Hour)
+
+	// Both lists are in the datadirs of their networks, with one ban each.
+	var lists []string
+	filepath.WalkDir(datadir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.Name() == "banlist.json" {
+			lists = append(lists, path)
+		}
+		return nil
+	})
+	if len(lists) != 2 {
+		t.Fatalf("ban lists %v, want one per network", lists)
+	}
+	for _, path := range lists {
+		data, _ := os.ReadFile(path)
+		var bans []json.RawMessage
+		if err := json.Unmarshal(data, &bans); err != nil || len(bans) != 1 {
+			t.Errorf("%s: %d bans, want 1 (%v)", path, len(bans), err)
+		}
+	}
+
+	// A configured ban list is split by chain like the capture file.
+	n := &cfg.Networks[0]
+	if rc := n.relayConfig(relay.Config{BanList: "/data/bans.json"}); rc.BanList != "/data/bans-holesky.json" {
+		t.Errorf("ban list %q, want /data/bans-holesky.json", rc.BanList)
+	}
+}
+
+// TestChainRouter tests that RPC requests are routed by chain path
+func TestChainRouter(t *testing.T) {
+	handler := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, name)
+		})
+	}
+	primary := handler("mainnet")
+	router := chainRouter(primary, map[string]http.Handler{
+		"mainnet": primary,
+		"sepolia": handler("sepolia"),
+	})
+	for path, want := range map[string]string{
+		"/":           "mainnet",
+		"/mainnet":    "mainnet",
+		"/sepolia":    "sepolia",
+		"/sepolia/ws": "sepolia",
+		"/sepoliax":   "mainnet",
+		"/other":      "mainnet",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader("{}")))
+		if got := w.Body.String(); got != want {
+			t.Errorf("%s routed to %s, want %s", path, got, want)
+		}
+	}
+	if chainRouter(primary, nil) == nil {
+		t.Error("no handler without further chains")
+	}
+}
+
+// TestRelayGroupDrain tests that the drain results of all networks add up
+func TestRelayGroupDrain(t *testing.T) {
+	a, b := new(testRelayDrainer), new(testRelayDrainer)
+	info, err := relayGroup{a, b, drainInfo{Duration: time.Second, Peers: 3, PendingRequests: 1}}.Drain(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.timeout != time.Minute || b.timeout != time.Minute {
+		t.Error("relays not drained")
+	}
+	if info.Flushed || info.Peers != 3 || info.PendingRequests != 1 || info.Duration != time.Second {
+		t.Errorf("unexpected drain result %+v", info)
+	}
+}
+
+// drainInfo is a relay returning a fixed drain result.
+type drainInfo relay.DrainInfo
+
+func (d drainInfo) Drain(time.Duration) (*relay.DrainInfo, error) {
+	info := relay.DrainInfo(d)
+	return &info, nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/relay"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// pendingSource is the relay's cache of transactions received from peers.
+type pendingSource interface {
+	PendingTransaction(hash common.Hash) *types.Transaction
+	SubscribePendingTransactions(ch chan<- []*types.Transaction) event.Subscription
+	GetBlockRange() relay.BlockRange
+	GetChainConfig() *params.ChainConfig
+}
+
+// pendingAPI serves the transactions seen on the wire: eth_getTransactionByHash
+// for cached transactions and eth_subscribe("newPendingTransactions").
+type pendingAPI struct {
+	source pendingSource
+}
+
+// isCachedTransaction reports whether a request asks for a transaction in the
+// cache, so it can be answered without the upstream.
+func isCachedTransaction(source pendingSource, req jsonrpcMessage) bool {
+	if source == nil || req.Method != "eth_getTransactionByHash" {
+		return false
+	}
+	var args []common.Hash
+	if err := json.Unmarshal(req.Params, &args); err != nil || len(args) != 1 {
+		return false
+	}
+	return source.PendingTransaction(args[0]) != nil
+}
+
+// GetTransactionByHash returns a transaction from the cache.
+func (api *pendingAPI) GetTransactionByHash(hash common.Hash) *ethapi.RPCTransaction {
+	tx := api.source.PendingTransaction(hash)
+	if tx == nil {
+		return nil
+	}
+	return api.marshal(tx)
+}
+
+// NewPendingTransactions creates a subscription that is notified of every
+// transaction received from peers, with its hash or, if fullTx is true, the
+// full transaction.
+func (api *pendingAPI) NewPendingTransactions(ctx context.Context, fullTx *bool) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		txs := make(chan []*types.Transaction, 128)
+		sub := api.source.SubscribePendingTransactions(txs)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case batch := <-txs:
+				for _, tx := range batch {
+					if fullTx != nil && *fullTx {
+						notifier.Notify(rpcSub.ID, api.marshal(tx))
+					} else {
+						notifier.Notify(rpcSub.ID, tx.Hash())
+					}
+				}
+			case err := <-sub.Err():
+				if err != nil {
+					log.Debug("Dropped pending transaction subscription", "id", rpcSub.ID, "err", err)
+				}
+				return
+			case <-rpcSub.Err():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// marshal returns the RPC representation of a pending transaction. The relay
+// has no chain head: the announced block range and the current time select
+// the signer, the base fee only matters for included transactions.
+func (api *pendingAPI) marshal(tx *types.Transaction) *ethapi.RPCTransaction {
+	head := &types.Header{
+		Number:  new(big.Int).SetUint64(api.source.GetBlockRange().LatestBlock),
+		Time:    uint64(time.Now().Unix()),
+		BaseFee: new(big.Int),
+	}
+	return ethapi.NewRPCPendingTransaction(tx, head, api.source.GetChainConfig())
+}
@@ -0,0 +1,167 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/relay"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// testPendingSource is a transaction cache filled by the test.
+type testPendingSource struct {
+	txs  map[common.Hash]*types.Transaction
+	feed event.Feed
+}
+
+func (s *testPendingSource) PendingTransaction(hash common.Hash) *types.Transaction {
+	return s.txs[hash]
+}
+
+func (s *testPendingSource) SubscribePendingTransactions(ch chan<- []*types.Transaction) event.Subscription {
+	return s.feed.Subscribe(ch)
+}
+
+func (s *testPendingSource) GetBlockRange() relay.BlockRange {
+	return relay.BlockRange{LatestBlock: 100}
+}
+
+func (s *testPendingSource) GetChainConfig() *params.ChainConfig {
+	return params.TestChainConfig
+}
+
+// sendUntil sends transactions to the subscribers until ready is closed. A new
+// subscription only receives once its goroutine subscribed to the feed.
+func (s *testPendingSource) sendUntil(ready <-chan struct{}, txs ...*types.Transaction) {
+	for {
+		s.feed.Send(txs)
+		select {
+		case <-ready:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// newTestPendingProxy creates an RPC proxy serving the pending transactions of
+// source. It returns the number of upstream calls.
+func newTestPendingProxy(t *testing.T, source *testPendingSource) (*rpcProxy, *atomic.Int64) {
+	var calls atomic.Int64
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		calls.Add(1)
+		fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":null}`)
+	}))
+	t.Cleanup(upstream.Close)
+
+	localServer := rpc.NewServer()
+	if err := localServer.RegisterName("eth", &pendingAPI{source: source}); err != nil {
+		t.Fatal(err)
+	}
+	proxy := newRPCProxy(upstream.URL, localServer)
+	proxy.pending = source
+	return proxy, &calls
+}
+
+func TestPendingTransactionByHash(t *testing.T) {
+	tx, _ := testSignedTx(t, 1)
+	source := &testPendingSource{txs: map[common.Hash]*types.Transaction{tx.Hash(): tx}}
+	proxy, calls := newTestPendingProxy(t, source)
+
+	// Cached transactions are answered locally.
+	resp := callProxy(t, proxy, "eth_getTransactionByHash", fmt.Sprintf(`"%s"`, tx.Hash()))
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error.Message)
+	}
+	if !strings.Contains(string(resp.Result), tx.Hash().Hex()) || !strings.Contains(string(resp.Result), `"blockHash":null`) {
+		t.Fatalf("wrong transaction: %s", resp.Result)
+	}
+	if calls.Load() != 0 {
+		t.Fatal("cached transaction requested upstream")
+	}
+
+	// Others are looked up upstream.
+	callProxy(t, proxy, "eth_getTransactionByHash", `"0x0000000000000000000000000000000000000000000000000000000000000001"`)
+	if calls.Load() != 1 {
+		t.Fatal("unknown transaction not requested upstream")
+	}
+}
+
+func TestPendingTransactionSubscription(t *testing.T) {
+	source := &testPendingSource{}
+	proxy, _ := newTestPendingProxy(t, source)
+	srv := httptest.NewServer(proxy)
+	defer srv.Close()
+
+	client, err := rpc.Dial("ws" + strings.TrimPrefix(srv.URL, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	key, _ := crypto.GenerateKey()
+	signer := types.LatestSignerForChainID(params.TestChainConfig.ChainID)
+	tx, err := types.SignNewTx(key, signer, &types.DynamicFeeTx{ChainID: params.TestChainConfig.ChainID, Gas: 21000, To: &common.Address{1}, GasFeeCap: common.Big2, GasTipCap: common.Big1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Full transactions have their sender.
+	full := make(chan *ethapi.RPCTransaction, 1)
+	sub, err := client.Subscribe(context.Background(), "eth", full, "newPendingTransactions", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ready := make(chan struct{})
+	go source.sendUntil(ready, tx)
+	got := <-full
+	close(ready)
+	if got.Hash != tx.Hash() || got.From != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Fatalf("wrong transaction: hash %v from %v", got.Hash, got.From)
+	}
+	sub.Unsubscribe()
+
+	// Without fullTx, hashes are sent.
+	hashes := make(chan common.Hash, 1)
+	sub, err = client.Subscribe(context.Background(), "eth", hashes, "newPendingTransactions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+	ready = make(chan struct{})
+	go source.sendUntil(ready, tx)
+	hash := <-hashes
+	close(ready)
+	if hash != tx.Hash() {
+		t.Fatalf("wrong hash %v", hash)
+	}
+}
@@ -18,6 +18,7 @@ package main
 
 import (
 	relayeth "github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/eth/protocols/snap"
 	"github.com/ethereum/go-ethereum/eth/relay"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
@@ -27,7 +28,10 @@ func init() {
 	// Register protocol registry to avoid import cycles
 	relay.RegisterProtocolRegistry(func(backend *relay.Backend, networkID uint64, discCandidates enode.Iterator) []p2p.Protocol {
 		relayBackend := relayeth.NewRelayBackend(backend)
-		return relayeth.MakeRelayProtocols(relayBackend, networkID, discCandidates)
+		protocols := relayeth.MakeRelayProtocols(relayBackend, networkID, discCandidates)
+		// snap runs alongside eth on the same connections, requests are
+		// passed on to the peers that support it.
+		return append(protocols, snap.MakeRelayProtocols(snap.NewRelayBackend(backend))...)
 	})
 }
 
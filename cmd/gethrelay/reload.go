@@ -0,0 +1,257 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/eth/relay"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/urfave/cli/v2"
+)
+
+// Configuration reload.
+//
+// The configuration is reloaded on SIGHUP, through relay_reloadConfig and when
+// the configuration file changes. Static and trusted nodes, the upstream RPC
+// endpoint and the bandwidth limits are applied to the running relay. Other
+// settings need a restart.
+
+const configPollInterval = 5 * time.Second
+
+// configChange is a setting changed by a reload. Added list entries have no
+// old value, removed ones no new value.
+type configChange struct {
+	Setting string `json:"setting"`
+	Old     string `json:"old,omitempty"`
+	New     string `json:"new,omitempty"`
+}
+
+// peerManager is the part of the P2P server managing static and trusted peers.
+type peerManager interface {
+	AddPeer(*enode.Node)
+	RemovePeer(*enode.Node)
+	AddTrustedPeer(*enode.Node)
+	RemoveTrustedPeer(*enode.Node)
+}
+
+// configReloader applies configuration changes to the running relay.
+type configReloader struct {
+	ctx     *cli.Context
+	peers   peerManager
+	backend *relay.Backend
+	proxy   *rpcProxy
+
+	lock    sync.Mutex
+	current *gethrelayConfig
+	modTime time.Time // modification time of the file when last loaded
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newConfigReloader(ctx *cli.Context, cfg *gethrelayConfig, peers peerManager, backend *relay.Backend, proxy *rpcProxy) *configReloader {
+	// The relay and node keep pointers into cfg, work on a copy.
+	current := *cfg
+	r := &configReloader{
+		ctx:     ctx,
+		peers:   peers,
+		backend: backend,
+		proxy:   proxy,
+		current: &current,
+		quit:    make(chan struct{}),
+	}
+	r.modTime, _ = r.fileModTime()
+	return r
+}
+
+// Start implements node.Lifecycle.
+func (r *configReloader) Start() error {
+	r.wg.Add(1)
+	go r.loop()
+	return nil
+}
+
+// Stop implements node.Lifecycle.
+func (r *configReloader) Stop() error {
+	close(r.quit)
+	r.wg.Wait()
+	return nil
+}
+
+func (r *configReloader) loop() {
+	defer r.wg.Done()
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	poll := time.NewTicker(configPollInterval)
+	defer poll.Stop()
+
+	for {
+		select {
+		case <-sighup:
+			log.Info("Got SIGHUP, reloading configuration")
+			r.reload()
+		case <-poll.C:
+			if mtime, err := r.fileModTime(); err == nil && !mtime.Equal(r.lastModTime()) {
+				log.Info("Configuration file changed, reloading")
+				r.reload()
+			}
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+// fileModTime returns the modification time of the configuration file.
+func (r *configReloader) fileModTime() (time.Time, error) {
+	file := r.ctx.String(configFileFlag.Name)
+	if file == "" {
+		return time.Time{}, os.ErrNotExist
+	}
+	stat, err := os.Stat(file)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return stat.ModTime(), nil
+}
+
+func (r *configReloader) lastModTime() time.Time {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.modTime
+}
+
+// reload reads the configuration and applies the changes. Flags keep
+// overriding the file.
+func (r *configReloader) reload() ([]configChange, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.modTime, _ = r.fileModTime()
+	cfg, err := makeConfig(r.ctx)
+	if err != nil {
+		log.Error("Failed to reload configuration", "err", err)
+		return nil, err
+	}
+	cfg.resolve()
+
+	var changes []configChange
+	added, removed := diffNodes(r.current.Node.P2P.StaticNodes, cfg.Node.P2P.StaticNodes)
+	for _, n := range removed {
+		r.peers.RemovePeer(n)
+		changes = append(changes, configChange{Setting: "Node.P2P.StaticNodes", Old: n.URLv4()})
+	}
+	for _, n := range added {
+		r.peers.AddPeer(n)
+		changes = append(changes, configChange{Setting: "Node.P2P.StaticNodes", New: n.URLv4()})
+	}
+	added, removed = diffNodes(r.current.Node.P2P.TrustedNodes, cfg.Node.P2P.TrustedNodes)
+	for _, n := range removed {
+		r.peers.RemoveTrustedPeer(n)
+		changes = append(changes, configChange{Setting: "Node.P2P.TrustedNodes", Old: n.URLv4()})
+	}
+	for _, n := range added {
+		r.peers.AddTrustedPeer(n)
+		changes = append(changes, configChange{Setting: "Node.P2P.TrustedNodes", New: n.URLv4()})
+	}
+	if old := r.current.RPC.Upstream; cfg.RPC.Upstream != old {
+		r.proxy.setUpstreamURL(cfg.RPC.Upstream)
+		changes = append(changes, configChange{Setting: "RPC.Upstream", Old: old, New: cfg.RPC.Upstream})
+	}
+	if old := r.current.Relay.Bandwidth; cfg.Relay.Bandwidth != old {
+		r.backend.SetBandwidth(cfg.Relay.Bandwidth)
+		changes = append(changes, configChange{
+			Setting: "Relay.Bandwidth",
+			Old:     fmt.Sprintf("%+v", old),
+			New:     fmt.Sprintf("%+v", cfg.Relay.Bandwidth),
+		})
+	}
+
+	if !r.sameFixedSettings(cfg) {
+		log.Warn("Configuration changes other than static and trusted nodes, upstream and bandwidth limits need a restart")
+	}
+	for _, c := range changes {
+		log.Info("Configuration changed", "setting", c.Setting, "old", c.Old, "new", c.New)
+	}
+	log.Info("Reloaded configuration", "changes", len(changes))
+
+	// Keep the settings that are not applied, so the restart warning repeats
+	// until the relay is restarted.
+	r.current.Node.P2P.StaticNodes = cfg.Node.P2P.StaticNodes
+	r.current.Node.P2P.TrustedNodes = cfg.Node.P2P.TrustedNodes
+	r.current.RPC.Upstream = cfg.RPC.Upstream
+	r.current.Relay.Bandwidth = cfg.Relay.Bandwidth
+	return changes, nil
+}
+
+// sameFixedSettings reports whether cfg differs from the running configuration
+// only in the settings applied by reload. It must be called with the lock held.
+func (r *configReloader) sameFixedSettings(cfg *gethrelayConfig) bool {
+	fixed := *cfg
+	fixed.Node.P2P.StaticNodes = r.current.Node.P2P.StaticNodes
+	fixed.Node.P2P.TrustedNodes = r.current.Node.P2P.TrustedNodes
+	fixed.RPC.Upstream = r.current.RPC.Upstream
+	fixed.Relay.Bandwidth = r.current.Relay.Bandwidth
+
+	a, errA := tomlSettings.Marshal(&fixed)
+	b, errB := tomlSettings.Marshal(r.current)
+	return errA == nil && errB == nil && bytes.Equal(a, b)
+}
+
+// diffNodes returns the nodes of next missing in prev and those of prev missing
+// in next.
+func diffNodes(prev, next []*enode.Node) (added, removed []*enode.Node) {
+	index := func(nodes []*enode.Node) map[string]bool {
+		m := make(map[string]bool, len(nodes))
+		for _, n := range nodes {
+			m[n.URLv4()] = true
+		}
+		return m
+	}
+	prevSet, nextSet := index(prev), index(next)
+	for _, n := range next {
+		if !prevSet[n.URLv4()] {
+			added = append(added, n)
+		}
+	}
+	for _, n := range prev {
+		if !nextSet[n.URLv4()] {
+			removed = append(removed, n)
+		}
+	}
+	return added, removed
+}
+
+// reloadAPI adds relay_reloadConfig to the relay namespace.
+type reloadAPI struct {
+	reloader *configReloader
+}
+
+// ReloadConfig reloads the configuration and returns the applied changes.
+func (api *reloadAPI) ReloadConfig() ([]configChange, error) {
+	return api.reloader.reload()
+}
@@ -0,0 +1,161 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/eth/relay"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	testNodeA = "enode://d860a01f9722d78051619d1e2351aba3f43f943f6f00718d1b9baa4101932a1f5011f16bb2b1bb35db20d6fe28fa0bf09636d26a87d31de9ec6203eeedb1f666@127.0.0.1:30303"
+	testNodeB = "enode://22a8232c3abc76a16ae9d6c3b164f98775fe226f0917b0ca871128a74a8e9630b458460865bab457221f1d448dd9791d24c4e5d88786180ac185df813a68d4de@127.0.0.2:30303"
+)
+
+// testPeerManager records static and trusted peer changes.
+type testPeerManager struct {
+	added, removed, trusted, untrusted []string
+}
+
+func (m *testPeerManager) AddPeer(n *enode.Node)    { m.added = append(m.added, n.URLv4()) }
+func (m *testPeerManager) RemovePeer(n *enode.Node) { m.removed = append(m.removed, n.URLv4()) }
+func (m *testPeerManager) AddTrustedPeer(n *enode.Node) {
+	m.trusted = append(m.trusted, n.URLv4())
+}
+func (m *testPeerManager) RemoveTrustedPeer(n *enode.Node) {
+	m.untrusted = append(m.untrusted, n.URLv4())
+}
+
+// newTestReloader creates a reloader for the given config file and arguments.
+func newTestReloader(t *testing.T, file string, args ...string) (*configReloader, *testPeerManager, *relay.Backend) {
+	t.Helper()
+	var ctx *cli.Context
+	app := &cli.App{
+		Flags:  slices.Concat([]cli.Flag{configFileFlag}, relayFlags),
+		Action: func(c *cli.Context) error { ctx = c; return nil },
+	}
+	if err := app.Run(append([]string{"gethrelay", "--config", file}, args...)); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := makeConfig(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.resolve()
+
+	peers := new(testPeerManager)
+	backend := relay.NewBackend(&cfg.Relay, nil)
+	proxy := newRPCProxy(cfg.RPC.Upstream, rpc.NewServer())
+	return newConfigReloader(ctx, cfg, peers, backend, proxy), peers, backend
+}
+
+func writeConfig(t *testing.T, file, content string) {
+	t.Helper()
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestConfigReload tests that reloads apply static/trusted node, upstream and
+// bandwidth changes
+func TestConfigReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "relay.toml")
+	writeConfig(t, file, `
+[Node.P2P]
+StaticNodes = ["`+testNodeA+`"]
+
+[RPC]
+Upstream = "http://127.0.0.1:8545"
+`)
+	r, peers, backend := newTestReloader(t, file)
+
+	// Nothing changed yet.
+	changes, err := r.reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+
+	writeConfig(t, file, `
+[Relay.Bandwidth]
+Egress = 1000000
+
+[Node.P2P]
+StaticNodes = ["`+testNodeB+`"]
+TrustedNodes = ["`+testNodeA+`"]
+
+[RPC]
+Upstream = "http://127.0.0.1:9545"
+`)
+	changes, err = r.reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 5 {
+		t.Errorf("got %d changes, want 5: %+v", len(changes), changes)
+	}
+	if !slices.Equal(peers.added, []string{testNodeB}) || !slices.Equal(peers.removed, []string{testNodeA}) {
+		t.Errorf("static peers: added %v, removed %v", peers.added, peers.removed)
+	}
+	if !slices.Equal(peers.trusted, []string{testNodeA}) || len(peers.untrusted) != 0 {
+		t.Errorf("trusted peers: added %v, removed %v", peers.trusted, peers.untrusted)
+	}
+	if got := r.proxy.getUpstreamURL(); got != "http://127.0.0.1:9545" {
+		t.Errorf("upstream = %q", got)
+	}
+	if got := backend.Bandwidth().Limits.Egress; got != 1000000 {
+		t.Errorf("egress limit = %d", got)
+	}
+
+	// An invalid file leaves the running configuration alone.
+	writeConfig(t, file, "[Relay]\nQueuePolicy = \"drop-all\"\n")
+	if _, err := r.reload(); err == nil {
+		t.Error("expected error for invalid config")
+	}
+	if got := r.proxy.getUpstreamURL(); got != "http://127.0.0.1:9545" {
+		t.Errorf("upstream changed by failed reload: %q", got)
+	}
+}
+
+// TestConfigReloadFlagsOverride tests that flags keep precedence over reloaded
+// file values
+func TestConfigReloadFlagsOverride(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "relay.toml")
+	writeConfig(t, file, "[RPC]\nUpstream = \"http://127.0.0.1:8545\"\n")
+	r, _, _ := newTestReloader(t, file, "--rpc.upstream", "http://10.0.0.1:8545")
+
+	writeConfig(t, file, "[RPC]\nUpstream = \"http://127.0.0.1:9545\"\n")
+	changes, err := r.reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("flag value overridden by file: %+v", changes)
+	}
+	if got := r.proxy.getUpstreamURL(); got != "http://10.0.0.1:8545" {
+		t.Errorf("upstream = %q", got)
+	}
+}
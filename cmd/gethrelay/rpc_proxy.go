@@ -23,31 +23,54 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
+// localMethods are the methods handled by the local RPC server. They are
+// never sent to the upstream endpoint.
+var localMethods = map[string]bool{
+	"eth_sendRawTransaction":     true,
+	"eth_sendPrivateTransaction": true,
+	"eth_sendBundle":             true,
+}
+
 // rpcProxy wraps an RPC server and proxies requests to an upstream endpoint
-// unless the method is eth_sendRawTransaction, which is handled locally.
+// unless the method is one of localMethods, or asks for a transaction in the
+// relay's cache, which are handled locally. If the upstream fails, reads in
+// fallbackMethods are answered by the fallback server. WebSocket connections
+// are served by the local server only.
 type rpcProxy struct {
 	localServer *rpc.Server
+	wsHandler   http.Handler  // local server over WebSocket, nil without a local server
+	fallback    *rpc.Server   // serves fallbackMethods from peers, nil if disabled
+	pending     pendingSource // transactions received from peers, nil if unknown
 	upstreamURL string
 	httpClient  *http.Client
 	log          log.Logger
 	mu           sync.RWMutex
+
+	draining bool         // new calls are refused, protected by mu
+	inflight atomic.Int64 // calls being served
 }
 
 // newRPCProxy creates a new RPC proxy handler.
 func newRPCProxy(upstreamURL string, localServer *rpc.Server) *rpcProxy {
-	return &rpcProxy{
+	p := &rpcProxy{
 		localServer: localServer,
 		upstreamURL: upstreamURL,
 		httpClient:  &http.Client{Timeout: 30 * time.Second},
 		log:         log.New("module", "rpcproxy"),
 	}
+	if localServer != nil {
+		p.wsHandler = localServer.WebsocketHandler(nil)
+	}
+	return p
 }
 
 // setUpstreamURL updates the upstream URL.
@@ -60,6 +83,25 @@ func (p *rpcProxy) setUpstreamURL(url string) {
 
 // ServeHTTP implements http.Handler and proxies requests.
 func (p *rpcProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// WebSocket connections carry eth_subscribe notifications. They are long
+	// lived and not counted as calls in flight.
+	if p.wsHandler != nil && isWebsocket(r) {
+		p.mu.RLock()
+		draining := p.draining
+		p.mu.RUnlock()
+		if draining {
+			http.Error(w, "Relay is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		p.wsHandler.ServeHTTP(w, r)
+		return
+	}
+	if !p.beginCall() {
+		http.Error(w, "Relay is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer p.inflight.Add(-1)
+
 	// Read the request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -97,7 +139,7 @@ func (p *rpcProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	localIndices := make(map[int]bool)
 
 	for i, req := range requests {
-		if req.Method == "eth_sendRawTransaction" {
+		if localMethods[req.Method] || isCachedTransaction(p.pending, req) {
 			localRequests = append(localRequests, req)
 			localIndices[i] = true
 		} else {
@@ -107,7 +149,7 @@ func (p *rpcProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// If all requests should be forwarded, proxy to upstream
 	if len(localRequests) == 0 {
-		p.forwardToUpstream(w, r, body)
+		p.forwardToUpstream(w, r, body, requests)
 		return
 	}
 
@@ -118,8 +160,8 @@ func (p *rpcProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Mixed case: handle some locally, forward others
-	// For simplicity, forward all to upstream and let it handle non-eth_sendRawTransaction
-	// Then handle eth_sendRawTransaction locally and merge responses
+	// For simplicity, forward all to upstream and let it handle non-local methods
+	// Then handle local methods locally and merge responses
 	// This is complex for batches, so we'll forward everything and intercept locally
 	// For now, let's handle batches by splitting them
 	p.handleMixedRequest(w, r, localRequests, forwardRequests)
@@ -254,7 +296,7 @@ func (r *responseRecorder) WriteHeader(statusCode int) {
 	r.code = statusCode
 }
 
-func (p *rpcProxy) forwardToUpstream(w http.ResponseWriter, r *http.Request, body []byte) {
+func (p *rpcProxy) forwardToUpstream(w http.ResponseWriter, r *http.Request, body []byte, requests []jsonrpcMessage) {
 	upstreamURL := p.getUpstreamURL()
 	
 	forwardReq, err := http.NewRequestWithContext(r.Context(), http.MethodPost, upstreamURL, bytes.NewReader(body))
@@ -274,11 +316,21 @@ func (p *rpcProxy) forwardToUpstream(w http.ResponseWriter, r *http.Request, bod
 
 	upstreamResp, err := p.httpClient.Do(forwardReq)
 	if err != nil {
+		if p.canFallback(requests) {
+			p.log.Warn("Upstream unavailable, answering from peers", "err", err, "upstream", upstreamURL)
+			p.fallback.ServeHTTP(w, r)
+			return
+		}
 		p.log.Error("Failed to forward request to upstream", "err", err, "upstream", upstreamURL)
 		http.Error(w, "Failed to forward request to upstream", http.StatusBadGateway)
 		return
 	}
 	defer upstreamResp.Body.Close()
+	if upstreamResp.StatusCode >= http.StatusInternalServerError && p.canFallback(requests) {
+		p.log.Warn("Upstream failed, answering from peers", "status", upstreamResp.Status, "upstream", upstreamURL)
+		p.fallback.ServeHTTP(w, r)
+		return
+	}
 
 	// Copy response headers
 	for key, values := range upstreamResp.Header {
@@ -292,12 +344,91 @@ func (p *rpcProxy) forwardToUpstream(w http.ResponseWriter, r *http.Request, bod
 	io.Copy(w, upstreamResp.Body)
 }
 
+// isWebsocket reports whether r asks for a WebSocket upgrade.
+func isWebsocket(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
+
+// canFallback reports whether the fallback server can answer all requests.
+func (p *rpcProxy) canFallback(requests []jsonrpcMessage) bool {
+	if p.fallback == nil {
+		return false
+	}
+	for _, req := range requests {
+		if !fallbackMethods[req.Method] {
+			return false
+		}
+	}
+	return true
+}
+
+// beginCall registers a call unless the proxy is draining.
+func (p *rpcProxy) beginCall() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.draining {
+		return false
+	}
+	p.inflight.Add(1)
+	return true
+}
+
+// drain refuses new calls and waits for the calls being served to finish or
+// ctx to expire. It returns the number of calls still being served.
+func (p *rpcProxy) drain(ctx context.Context) int {
+	p.mu.Lock()
+	p.draining = true
+	p.mu.Unlock()
+
+	poll := time.NewTicker(drainPollInterval)
+	defer poll.Stop()
+	for {
+		n := p.inflight.Load()
+		if n == 0 {
+			return 0
+		}
+		select {
+		case <-poll.C:
+		case <-ctx.Done():
+			return int(n)
+		}
+	}
+}
+
 func (p *rpcProxy) getUpstreamURL() string {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 	return p.upstreamURL
 }
 
+// pingUpstream checks that the upstream endpoint answers eth_chainId.
+func (p *rpcProxy) pingUpstream(ctx context.Context) error {
+	body := []byte(`{"jsonrpc":"2.0","method":"eth_chainId","params":[],"id":1}`)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.getUpstreamURL(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP status %s", resp.Status)
+	}
+	var msg jsonrpcMessage
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		return fmt.Errorf("invalid response: %v", err)
+	}
+	if msg.Error != nil {
+		return fmt.Errorf("RPC error: %s (code: %d)", msg.Error.Message, msg.Error.Code)
+	}
+	return nil
+}
+
 // jsonrpcMessage is a copy of rpc.jsonrpcMessage for use in this package
 type jsonrpcMessage struct {
 	Version string          `json:"jsonrpc,omitempty"`
@@ -20,6 +20,7 @@ import (
 	"context"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -35,13 +36,41 @@ import (
 // ethAPI provides the eth_sendRawTransaction method
 type ethAPI struct {
 	upstreamURL string
+	proxy       *rpcProxy // supplies the current upstream URL if set
+	stem        transactionStemmer // Dandelion++ propagation, nil if disabled
 	httpClient  *http.Client
 	log         log.Logger
 }
 
+// transactionStemmer propagates transactions with Dandelion++.
+type transactionStemmer interface {
+	StemTransaction(tx *types.Transaction) error
+}
+
+// Propagation modes of submitted transactions.
+const (
+	propagationDirect    = "direct"    // forwarded to the upstream endpoint
+	propagationDandelion = "dandelion" // stem and fluff over the relay's peers
+)
+
+// sendOptions are the gethrelay specific options of eth_sendRawTransaction.
+type sendOptions struct {
+	Propagation string `json:"propagation"` // direct or dandelion, defaults to dandelion if enabled
+}
+
+// upstream returns the upstream RPC endpoint.
+func (api *ethAPI) upstream() string {
+	if api.proxy != nil {
+		return api.proxy.getUpstreamURL()
+	}
+	return api.upstreamURL
+}
+
 // SendRawTransaction handles eth_sendRawTransaction requests
-// For relay nodes, we validate the transaction locally then forward to upstream
-func (api *ethAPI) SendRawTransaction(ctx context.Context, encodedTx hexutil.Bytes) (common.Hash, error) {
+// For relay nodes, we validate the transaction locally then forward to upstream,
+// or propagate it with Dandelion++ if selected by the optional second parameter
+// or by default
+func (api *ethAPI) SendRawTransaction(ctx context.Context, encodedTx hexutil.Bytes, opts *sendOptions) (common.Hash, error) {
 	// Validate the transaction
 	tx := new(types.Transaction)
 	if err := tx.UnmarshalBinary(encodedTx); err != nil {
@@ -50,9 +79,23 @@ func (api *ethAPI) SendRawTransaction(ctx context.Context, encodedTx hexutil.Byt
 
 	api.log.Info("Received raw transaction", "hash", tx.Hash().Hex())
 
+	switch mode := api.propagation(opts); mode {
+	case propagationDandelion:
+		if api.stem == nil {
+			return common.Hash{}, errors.New("dandelion propagation is disabled")
+		}
+		if err := api.stem.StemTransaction(tx); err != nil {
+			return common.Hash{}, fmt.Errorf("invalid transaction: %v", err)
+		}
+		return tx.Hash(), nil
+	case propagationDirect:
+	default:
+		return common.Hash{}, fmt.Errorf("unknown propagation mode %q", mode)
+	}
+
 	// Forward to upstream RPC endpoint
 	body := fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_sendRawTransaction","params":["0x%s"],"id":1}`, hex.EncodeToString(encodedTx))
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, api.upstreamURL, strings.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, api.upstream(), strings.NewReader(body))
 	if err != nil {
 		return common.Hash{}, fmt.Errorf("failed to create upstream request: %v", err)
 	}
@@ -91,41 +134,69 @@ func (api *ethAPI) SendRawTransaction(ctx context.Context, encodedTx hexutil.Byt
 	return *upstreamResp.Result, nil
 }
 
-// setupRPCProxy configures the RPC proxy for the node
-// It creates a standalone HTTP server on the specified address and port
-func setupRPCProxy(stack *node.Node, upstreamURL string, addr string, port int) error {
+// propagation returns the propagation mode of a submission.
+func (api *ethAPI) propagation(opts *sendOptions) string {
+	if opts != nil && opts.Propagation != "" {
+		return opts.Propagation
+	}
+	if api.stem != nil {
+		return propagationDandelion
+	}
+	return propagationDirect
+}
+
+// newChainProxy creates the RPC proxy of a chain, serving the local methods
+// and forwarding the others to the upstream endpoint.
+func newChainProxy(upstreamURL string, stem transactionStemmer, builders *builderAPI, fallback *rpc.Server, pending pendingSource) (*rpcProxy, error) {
 	// Create a minimal RPC server for local methods
 	localServer := rpc.NewServer()
 	
 	// Create eth API
 	ethAPI := &ethAPI{
 		upstreamURL: upstreamURL,
+		stem:        stem,
 		httpClient:  &http.Client{},
 		log:         log.New("module", "ethapi"),
 	}
 	
 	// Register the eth API
 	if err := localServer.RegisterName("eth", ethAPI); err != nil {
-		return fmt.Errorf("failed to register eth API: %v", err)
+		return nil, fmt.Errorf("failed to register eth API: %v", err)
+	}
+	if err := localServer.RegisterName("eth", builders); err != nil {
+		return nil, fmt.Errorf("failed to register builder API: %v", err)
+	}
+	if pending != nil {
+		if err := localServer.RegisterName("eth", &pendingAPI{source: pending}); err != nil {
+			return nil, fmt.Errorf("failed to register pending transaction API: %v", err)
+		}
 	}
 	
 	// Create the proxy handler
 	proxy := newRPCProxy(upstreamURL, localServer)
+	proxy.fallback = fallback
+	proxy.pending = pending
+	ethAPI.proxy = proxy
+	return proxy, nil
+}
+
+// setupRPCProxy starts the HTTP server of the RPC proxy on the specified
+// address and port, which also serves the health endpoints. Requests for the
+// further chains are routed to their proxies by path.
+func setupRPCProxy(stack *node.Node, addr string, port int, health healthConfig, backend relayStatus, proxy *rpcProxy, chains map[string]http.Handler) {
+	checker := newHealthChecker(health, backend, stack.Server(), proxy)
 
 	// Start HTTP server on configured address and port
 	listenAddr := fmt.Sprintf("%s:%d", addr, port)
 	go func() {
 		server := &http.Server{
 			Addr:    listenAddr,
-			Handler: proxy,
+			Handler: checker.handler(chainRouter(proxy, chains)),
 		}
 
-		log.Info("Starting JSON-RPC proxy server", "upstream", upstreamURL, "addr", addr, "port", port)
+		log.Info("Starting JSON-RPC proxy server", "upstream", proxy.getUpstreamURL(), "addr", addr, "port", port, "chains", len(chains))
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Error("RPC proxy server error", "err", err)
 		}
 	}()
-
-	return nil
 }
-
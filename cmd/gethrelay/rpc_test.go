@@ -238,3 +238,76 @@ func TestRPCProxy_BatchRequest(t *testing.T) {
 	}
 }
 
+// testStemmer records the transactions propagated with Dandelion++.
+type testStemmer struct {
+	txs []*types.Transaction
+}
+
+func (s *testStemmer) StemTransaction(tx *types.Transaction) error {
+	s.txs = append(s.txs, tx)
+	return nil
+}
+
+// TestRPCProxy_SendRawTransactionDandelion tests the propagation option of
+// eth_sendRawTransaction
+func TestRPCProxy_SendRawTransactionDandelion(t *testing.T) {
+	txHex, err := createTestTransaction()
+	if err != nil {
+		t.Fatalf("failed to create test transaction: %v", err)
+	}
+	var upstreamCalls int
+	upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls++
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef"}`))
+	}))
+	defer upstreamServer.Close()
+
+	stem := new(testStemmer)
+	localServer := rpc.NewServer()
+	if err := localServer.RegisterName("eth", &ethAPI{upstreamURL: upstreamServer.URL, stem: stem, httpClient: &http.Client{}, log: testLogger}); err != nil {
+		t.Fatalf("failed to register eth API: %v", err)
+	}
+	proxy := newRPCProxy(upstreamServer.URL, localServer)
+
+	send := func(params string) map[string]interface{} {
+		reqBody := fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_sendRawTransaction","params":[%s],"id":1}`, params)
+		req := httptest.NewRequest("POST", "/", bytes.NewReader([]byte(reqBody)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		var resp map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return resp
+	}
+
+	// Dandelion is the default when enabled, the upstream is not involved.
+	resp := send(fmt.Sprintf(`"%s"`, txHex))
+	if len(stem.txs) != 1 || upstreamCalls != 0 {
+		t.Fatalf("default submission: %d stemmed, %d upstream calls", len(stem.txs), upstreamCalls)
+	}
+	if resp["result"] != stem.txs[0].Hash().Hex() {
+		t.Errorf("wrong result %v, want %v", resp["result"], stem.txs[0].Hash().Hex())
+	}
+
+	// Direct submissions go to the upstream endpoint.
+	send(fmt.Sprintf(`"%s", {"propagation": "direct"}`, txHex))
+	if len(stem.txs) != 1 || upstreamCalls != 1 {
+		t.Fatalf("direct submission: %d stemmed, %d upstream calls", len(stem.txs), upstreamCalls)
+	}
+
+	// Unknown modes are refused.
+	if resp := send(fmt.Sprintf(`"%s", {"propagation": "flood"}`, txHex)); resp["error"] == nil {
+		t.Error("unknown propagation mode accepted")
+	}
+
+	// Dandelion cannot be selected if it is disabled.
+	api := &ethAPI{upstreamURL: upstreamServer.URL, httpClient: &http.Client{}, log: testLogger}
+	if _, err := api.SendRawTransaction(t.Context(), common.FromHex(txHex), &sendOptions{Propagation: propagationDandelion}); err == nil {
+		t.Error("dandelion submission accepted while disabled")
+	}
+	if _, err := api.SendRawTransaction(t.Context(), common.FromHex(txHex), nil); err != nil || upstreamCalls != 2 {
+		t.Errorf("default submission while disabled: err %v, %d upstream calls", err, upstreamCalls)
+	}
+}
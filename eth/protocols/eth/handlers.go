@@ -44,12 +44,8 @@ func handleGetBlockHeaders(backend Backend, msg Decoder, peer *Peer) error {
 	// Check if this is a relay backend (no chain)
 	chain := backend.Chain()
 	if chain == nil {
-		// Relay mode: request should be proxied, not answered locally
-		// For now, return empty response - the relay infrastructure should
-		// intercept and proxy these requests before they reach here.
-		// This is a safety check to prevent nil pointer panics.
-		log.Debug("Relay mode: GetBlockHeaders request received but cannot be handled locally", "peer", peer.id, "requestId", query.RequestId)
-		return peer.ReplyBlockHeadersRLP(query.RequestId, nil)
+		// Relay mode: the relay proxies the request to another peer
+		return backend.Handle(peer, &query)
 	}
 	
 	response := ServiceGetBlockHeadersQuery(chain, query.GetBlockHeadersRequest, peer)
@@ -239,9 +235,8 @@ func handleGetBlockBodies(backend Backend, msg Decoder, peer *Peer) error {
 	// Check if this is a relay backend (no chain)
 	chain := backend.Chain()
 	if chain == nil {
-		// Relay mode: return empty response
-		log.Debug("Relay mode: GetBlockBodies request received but cannot be handled locally", "peer", peer.id, "requestId", query.RequestId)
-		return peer.ReplyBlockBodiesRLP(query.RequestId, nil)
+		// Relay mode: the relay proxies the request to another peer
+		return backend.Handle(peer, &query)
 	}
 	
 	response := ServiceGetBlockBodiesQuery(chain, query.GetBlockBodiesRequest)
@@ -279,9 +274,8 @@ func handleGetReceipts68(backend Backend, msg Decoder, peer *Peer) error {
 	// Check if this is a relay backend (no chain)
 	chain := backend.Chain()
 	if chain == nil {
-		// Relay mode: return empty response
-		log.Debug("Relay mode: GetReceipts request received but cannot be handled locally", "peer", peer.id, "requestId", query.RequestId)
-		return peer.ReplyReceiptsRLP(query.RequestId, nil)
+		// Relay mode: the relay proxies the request to another peer
+		return backend.Handle(peer, &query)
 	}
 	
 	response := ServiceGetReceiptsQuery68(chain, query.GetReceiptsRequest)
@@ -298,9 +292,8 @@ func handleGetReceipts69(backend Backend, msg Decoder, peer *Peer) error {
 	// Check if this is a relay backend (no chain)
 	chain := backend.Chain()
 	if chain == nil {
-		// Relay mode: return empty response
-		log.Debug("Relay mode: GetReceipts request received but cannot be handled locally", "peer", peer.id, "requestId", query.RequestId)
-		return peer.ReplyReceiptsRLP(query.RequestId, nil)
+		// Relay mode: the relay proxies the request to another peer
+		return backend.Handle(peer, &query)
 	}
 	
 	response := serviceGetReceiptsQuery69(chain, query.GetReceiptsRequest)
@@ -500,9 +493,8 @@ func handleGetPooledTransactions(backend Backend, msg Decoder, peer *Peer) error
 	// Check if this is a relay backend (no txpool)
 	txPool := backend.TxPool()
 	if txPool == nil {
-		// Relay mode: return empty response
-		log.Debug("Relay mode: GetPooledTransactions request received but cannot be handled locally", "peer", peer.id, "requestId", query.RequestId)
-		return peer.ReplyPooledTransactionsRLP(query.RequestId, nil, nil)
+		// Relay mode: the relay proxies the request to another peer
+		return backend.Handle(peer, &query)
 	}
 	
 	hashes, txs := answerGetPooledTransactions(backend, query.GetPooledTransactionsRequest)
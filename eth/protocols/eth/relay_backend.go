@@ -17,10 +17,16 @@
 package eth
 
 import (
+	"bytes"
+	"errors"
+	"io"
+
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/eth/relay"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 // RelayBackend implements eth.Backend for relay mode.
@@ -53,13 +59,21 @@ func (rb *RelayBackend) TxPool() TxPool {
 	return nil
 }
 
-// AcceptTxs returns false - relay doesn't accept transactions.
+// AcceptTxs returns true - relay decodes transactions to score the peers
+// sending them.
 func (rb *RelayBackend) AcceptTxs() bool {
-	return false
+	return true
 }
 
 // RunPeer is invoked when a peer joins on the eth protocol.
 func (rb *RelayBackend) RunPeer(peer *Peer, handler Handler) error {
+	if rb.relay.IsBanned(peer.Peer.ID()) {
+		return p2p.DiscUselessPeer
+	}
+	// A draining relay is about to shut down, new peers would only be dropped
+	if rb.relay.Draining() {
+		return p2p.DiscQuitting
+	}
 	// Execute relay handshake
 	blockRange := rb.relay.GetBlockRange()
 		rangePacket := BlockRangeUpdatePacket{
@@ -78,9 +92,120 @@ func (rb *RelayBackend) RunPeer(peer *Peer, handler Handler) error {
 	); err != nil {
 		return err
 	}
-	
-	// Run the handler
-	return handler(peer)
+
+	// Register the peer with the relay for the lifetime of the connection
+	if !rb.relay.AddPeer(relay.NewRelayPeer(peer.Peer, peer.Version(), relayPeerConn{peer})) {
+		return p2p.DiscAlreadyConnected
+	}
+	defer rb.relay.RemovePeer(peer.Peer.ID())
+
+	// Run the handler, malformed messages count against the peer
+	err := handler(peer)
+	if isDecodeError(err) {
+		rb.relay.ReportPeer(peer.Peer.ID(), relay.MisbehaviourDecode)
+	}
+	return err
+}
+
+// isDecodeError reports whether a handler error was caused by a malformed
+// message.
+func isDecodeError(err error) bool {
+	return p2p.IsDecodeError(err) || errors.Is(err, errInvalidMsgCode)
+}
+
+// relayPeerConn exposes an eth peer to the relay backend.
+type relayPeerConn struct {
+	peer *Peer
+}
+
+// BlockRange implements relay.PeerConn.
+func (c relayPeerConn) BlockRange() (relay.BlockRange, bool) {
+	r := c.peer.BlockRange()
+	if r == nil {
+		return relay.BlockRange{}, false
+	}
+	return relay.BlockRange{
+		EarliestBlock:   r.EarliestBlock,
+		LatestBlock:     r.LatestBlock,
+		LatestBlockHash: r.LatestBlockHash,
+	}, true
+}
+
+// SendBlockRange implements relay.PeerConn. Range updates only exist since
+// eth/69, older peers are skipped.
+func (c relayPeerConn) SendBlockRange(r relay.BlockRange) error {
+	if c.peer.Version() < ETH69 {
+		return nil
+	}
+	return c.peer.SendBlockRangeUpdate(BlockRangeUpdatePacket{
+		EarliestBlock:   r.EarliestBlock,
+		LatestBlock:     r.LatestBlock,
+		LatestBlockHash: r.LatestBlockHash,
+	})
+}
+
+// Send implements relay.PeerConn.
+func (c relayPeerConn) Send(msgCode uint64, payload []byte) error {
+	return c.peer.rw.WriteMsg(p2p.Msg{
+		Code:    msgCode,
+		Size:    uint32(len(payload)),
+		Payload: bytes.NewReader(payload),
+	})
+}
+
+// relayResponseRW passes the responses to requests sent by the relay to the
+// relay backend. The request dispatcher of the peer does not track them, so
+// they never reach the protocol handler.
+type relayResponseRW struct {
+	p2p.MsgReadWriter
+	relay *relay.Backend
+	peer  enode.ID
+}
+
+func (rw *relayResponseRW) ReadMsg() (p2p.Msg, error) {
+	for {
+		msg, err := rw.MsgReadWriter.ReadMsg()
+		if err != nil {
+			return msg, err
+		}
+		switch msg.Code {
+		case BlockHeadersMsg, BlockBodiesMsg, ReceiptsMsg, PooledTransactionsMsg:
+		default:
+			return msg, nil
+		}
+		// Oversized messages are rejected by the handler
+		if msg.Size > maxMessageSize {
+			return msg, nil
+		}
+		data, err := io.ReadAll(msg.Payload)
+		if err != nil {
+			return msg, err
+		}
+		msg.Payload = bytes.NewReader(data)
+
+		requestID, payload, err := splitRequestID(data)
+		if err != nil || !rw.relay.DeliverResponse(rw.peer, msg.Code, requestID, payload) {
+			return msg, nil
+		}
+	}
+}
+
+// splitRequestID splits a response packet into its request ID and the
+// encoded response.
+func splitRequestID(data []byte) (uint64, []byte, error) {
+	s := rlp.NewStream(bytes.NewReader(data), uint64(len(data)))
+	if _, err := s.List(); err != nil {
+		return 0, nil, err
+	}
+	requestID, err := s.Uint64()
+	if err != nil {
+		return 0, nil, err
+	}
+	payload, err := s.Raw()
+	if err != nil {
+		return 0, nil, err
+	}
+	return requestID, payload, nil
 }
 
 // PeerInfo retrieves relay peer information.
@@ -96,13 +221,61 @@ func (rb *RelayBackend) PeerInfo(id enode.ID) interface{} {
 }
 
 // Handle is invoked when a data packet is received from the remote peer.
+// Transaction broadcasts are relayed to the other peers, requests are proxied
+// to another peer and answered with its response.
 func (rb *RelayBackend) Handle(peer *Peer, packet Packet) error {
-	// Relay mode: packets are handled by the protocol handlers
-	// This method is called for unhandled packets - in relay mode we forward them
-	// TODO: Implement packet forwarding through message router
+	id := peer.Peer.ID()
+	switch packet := packet.(type) {
+	case *TransactionsPacket:
+		rb.relay.CheckTransactions(id, *packet)
+		rb.relayBroadcast(id, TransactionsMsg, packet)
+	case *PooledTransactionsResponse:
+		rb.relay.CheckTransactions(id, *packet)
+	case *NewPooledTransactionHashesPacket:
+		rb.relay.TransactionsAnnounced(id, packet.Hashes)
+		rb.relayBroadcast(id, NewPooledTransactionHashesMsg, packet)
+	case *GetBlockHeadersPacket:
+		if !rb.relayRequest(id, GetBlockHeadersMsg, packet.RequestId, packet.GetBlockHeadersRequest) {
+			return peer.ReplyBlockHeadersRLP(packet.RequestId, nil)
+		}
+	case *GetBlockBodiesPacket:
+		if !rb.relayRequest(id, GetBlockBodiesMsg, packet.RequestId, packet.GetBlockBodiesRequest) {
+			return peer.ReplyBlockBodiesRLP(packet.RequestId, nil)
+		}
+	case *GetReceiptsPacket:
+		if !rb.relayRequest(id, GetReceiptsMsg, packet.RequestId, packet.GetReceiptsRequest) {
+			return peer.ReplyReceiptsRLP(packet.RequestId, nil)
+		}
+	case *GetPooledTransactionsPacket:
+		if !rb.relayRequest(id, GetPooledTransactionsMsg, packet.RequestId, packet.GetPooledTransactionsRequest) {
+			return peer.ReplyPooledTransactionsRLP(packet.RequestId, nil, nil)
+		}
+	}
 	return nil
 }
 
+// relayBroadcast queues a broadcast for the other peers.
+func (rb *RelayBackend) relayBroadcast(from enode.ID, msgCode uint64, packet interface{}) {
+	payload, err := rlp.EncodeToBytes(packet)
+	if err != nil {
+		log.Error("Failed to encode relayed packet", "code", msgCode, "err", err)
+		return
+	}
+	rb.relay.Receive(&relay.RelayMessage{From: from, MsgCode: msgCode, Payload: payload})
+}
+
+// relayRequest queues a request for proxying. The request is encoded without
+// its request ID, the relay sends it under an ID of its own. It returns false
+// if the request is not proxied, the peer gets an empty response then.
+func (rb *RelayBackend) relayRequest(from enode.ID, msgCode uint64, requestID uint64, request interface{}) bool {
+	payload, err := rlp.EncodeToBytes(request)
+	if err != nil {
+		log.Error("Failed to encode proxied request", "code", msgCode, "err", err)
+		return false
+	}
+	return rb.relay.Receive(&relay.RelayMessage{From: from, MsgCode: msgCode, Payload: payload, RequestID: requestID})
+}
+
 // IsRelay returns true - this is a relay backend.
 func (rb *RelayBackend) IsRelay() bool {
 	return true
@@ -0,0 +1,281 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/relay"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func newTestRelayBackend() *relay.Backend {
+	return relay.NewBackend(&relay.Config{
+		NetworkID:   1,
+		GenesisHash: common.Hash{1},
+		BlockRange:  relay.BlockRange{LatestBlock: 10, LatestBlockHash: common.Hash{10}},
+	}, nil)
+}
+
+// newTestRelay starts a relay service on a node that neither listens nor
+// dials, peers are connected with startRelayPeer.
+func newTestRelay(t *testing.T, config *relay.Config) *relay.Relay {
+	t.Helper()
+	stack, err := node.New(&node.Config{
+		DataDir: t.TempDir(),
+		P2P:     p2p.Config{MaxPeers: 10, NoDiscovery: true, NoDial: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.NetworkID = 1
+	config.GenesisHash = common.Hash{1}
+	config.BlockRange = relay.BlockRange{LatestBlock: 10, LatestBlockHash: common.Hash{10}}
+	r, err := relay.NewRelay(stack, config, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stack.RegisterLifecycle(r)
+	if err := stack.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { stack.Close() })
+	return r
+}
+
+// startRelayPeer connects a peer to the relay over the eth protocol and
+// returns the remote end of the connection after the handshake.
+func startRelayPeer(t *testing.T, r *relay.Backend, id byte, version uint) *p2p.MsgPipeRW {
+	t.Helper()
+	local, remote := p2p.MsgPipe()
+	t.Cleanup(func() { local.Close() })
+
+	for _, proto := range MakeRelayProtocols(NewRelayBackend(r), 1, nil) {
+		if proto.Version == version {
+			go proto.Run(p2p.NewPeer(enode.ID{id}, "test", nil), local)
+		}
+	}
+	peer := NewPeer(version, p2p.NewPeer(enode.ID{0xff}, "relay", nil), remote, nil)
+	t.Cleanup(peer.Close)
+	head := BlockRangeUpdatePacket{LatestBlock: 10, LatestBlockHash: common.Hash{10}}
+	if err := peer.RelayHandshake(1, common.Hash{1}, nil, r.GetForkID(), head); err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+	// Wait for the peer to be registered, so requests can be sent to it.
+	for i := 0; i < 100; i++ {
+		for _, p := range r.Peers() {
+			if p.ID == (enode.ID{id}) {
+				return remote
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("peer not registered")
+	return nil
+}
+
+func TestRelayRequest(t *testing.T) {
+	r := newTestRelayBackend()
+	proxy := relay.NewRequestProxy(r, relay.NewRoundRobinSelector(r), time.Minute)
+	defer proxy.Stop()
+	remote := startRelayPeer(t, r, 1, ETH69)
+
+	type result struct {
+		resp []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		req, _ := rlp.EncodeToBytes(&GetBlockHeadersRequest{Origin: HashOrNumber{Number: 5}, Amount: 1})
+		resp, err := proxy.Request(context.Background(), enode.ID{1}, GetBlockHeadersMsg, req)
+		done <- result{resp, err}
+	}()
+
+	// The request is sent on the connection with an ID of the relay.
+	msg, err := remote.ReadMsg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req GetBlockHeadersPacket
+	if msg.Code != GetBlockHeadersMsg || msg.Decode(&req) != nil {
+		t.Fatalf("unexpected message %d", msg.Code)
+	}
+	if req.Origin.Number != 5 || req.Amount != 1 {
+		t.Fatalf("wrong request %+v", req.GetBlockHeadersRequest)
+	}
+	if pending := proxy.Pending(); len(pending) != 1 || pending[0].RequestID != req.RequestId {
+		t.Fatalf("request ID %d not pending: %+v", req.RequestId, pending)
+	}
+
+	// The response is matched by its ID and returned without it.
+	headers := BlockHeadersRequest{{Number: big.NewInt(5), Difficulty: new(big.Int)}}
+	p2p.Send(remote, BlockHeadersMsg, &BlockHeadersPacket{RequestId: req.RequestId, BlockHeadersRequest: headers})
+	res := <-done
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	want, _ := rlp.EncodeToBytes(headers)
+	if !bytes.Equal(res.resp, want) {
+		t.Fatalf("wrong response %x, want %x", res.resp, want)
+	}
+	if len(proxy.Pending()) != 0 {
+		t.Fatal("answered request still pending")
+	}
+	if stats := r.Stats(); stats.Answered != 1 || stats.Failed != 0 {
+		t.Fatalf("wrong stats %+v", stats)
+	}
+
+	// Cancelled requests are dropped.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := proxy.Request(ctx, enode.ID{1}, GetBlockHeadersMsg, want); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled request returned %v", err)
+	}
+	if len(proxy.Pending()) != 0 {
+		t.Fatal("cancelled request still pending")
+	}
+}
+
+func TestRelayRequestTimeout(t *testing.T) {
+	r := newTestRelayBackend()
+	proxy := relay.NewRequestProxy(r, relay.NewRoundRobinSelector(r), 50*time.Millisecond)
+	defer proxy.Stop()
+	remote := startRelayPeer(t, r, 1, ETH68)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := proxy.Request(context.Background(), enode.ID{1}, GetBlockBodiesMsg, []byte{0xc0})
+		done <- err
+	}()
+	msg, err := remote.ReadMsg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req GetBlockBodiesPacket
+	if msg.Code != GetBlockBodiesMsg || msg.Decode(&req) != nil {
+		t.Fatalf("unexpected message %d", msg.Code)
+	}
+	// Only a response with the request ID answers the request.
+	p2p.Send(remote, BlockBodiesMsg, &BlockBodiesPacket{RequestId: req.RequestId + 1})
+	if err := <-done; !errors.Is(err, relay.ErrRequestTimeout) {
+		t.Fatalf("request returned %v, want timeout", err)
+	}
+	if score := r.Reputation().Score(enode.ID{1}); score >= 0 {
+		t.Fatalf("score %v after timeout, want negative", score)
+	}
+}
+
+func TestRelayForwardTransactions(t *testing.T) {
+	r := newTestRelay(t, &relay.Config{})
+	sender := startRelayPeer(t, r.Backend(), 1, ETH69)
+	receiver := startRelayPeer(t, r.Backend(), 2, ETH69)
+
+	tx := types.NewTx(&types.LegacyTx{Nonce: 1, Gas: 21000, To: &common.Address{1}, GasPrice: common.Big1})
+	if err := p2p.Send(sender, TransactionsMsg, TransactionsPacket{tx}); err != nil {
+		t.Fatal(err)
+	}
+	// The transactions pass the relay loop and the router to the other peer.
+	msg, err := receiver.ReadMsg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var txs TransactionsPacket
+	if msg.Code != TransactionsMsg || msg.Decode(&txs) != nil {
+		t.Fatalf("unexpected message %d", msg.Code)
+	}
+	if len(txs) != 1 || txs[0].Hash() != tx.Hash() {
+		t.Fatalf("wrong transactions forwarded: %v", txs)
+	}
+}
+
+func TestRelayProxyRequest(t *testing.T) {
+	r := newTestRelay(t, &relay.Config{})
+	requester := startRelayPeer(t, r.Backend(), 1, ETH69)
+	server := startRelayPeer(t, r.Backend(), 2, ETH69)
+
+	req := &GetBlockHeadersRequest{Origin: HashOrNumber{Number: 5}, Amount: 1}
+	if err := p2p.Send(requester, GetBlockHeadersMsg, &GetBlockHeadersPacket{RequestId: 7, GetBlockHeadersRequest: req}); err != nil {
+		t.Fatal(err)
+	}
+	// The request reaches the other peer under an ID of the relay.
+	msg, err := server.ReadMsg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var proxied GetBlockHeadersPacket
+	if msg.Code != GetBlockHeadersMsg || msg.Decode(&proxied) != nil {
+		t.Fatalf("unexpected message %d", msg.Code)
+	}
+	if proxied.Origin.Number != 5 || proxied.Amount != 1 {
+		t.Fatalf("wrong request %+v", proxied.GetBlockHeadersRequest)
+	}
+	headers := BlockHeadersRequest{{Number: big.NewInt(5), Difficulty: new(big.Int)}}
+	p2p.Send(server, BlockHeadersMsg, &BlockHeadersPacket{RequestId: proxied.RequestId, BlockHeadersRequest: headers})
+
+	// The response returns to the requester under its own ID.
+	msg, err = requester.ReadMsg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res BlockHeadersPacket
+	if msg.Code != BlockHeadersMsg || msg.Decode(&res) != nil {
+		t.Fatalf("unexpected message %d", msg.Code)
+	}
+	if res.RequestId != 7 || len(res.BlockHeadersRequest) != 1 || res.BlockHeadersRequest[0].Number.Uint64() != 5 {
+		t.Fatalf("wrong response %+v", res)
+	}
+}
+
+func TestRelayIngressLimit(t *testing.T) {
+	r := relay.NewBackend(&relay.Config{
+		NetworkID:   1,
+		GenesisHash: common.Hash{1},
+		BlockRange:  relay.BlockRange{LatestBlock: 10, LatestBlockHash: common.Hash{10}},
+		Bandwidth:   relay.BandwidthLimits{PeerIngress: 1},
+	}, nil)
+	remote := startRelayPeer(t, r, 1, ETH69)
+
+	// Transactions are charged as they are read, the peer's bucket runs out
+	// after the first few 100KB messages.
+	for nonce := uint64(0); nonce < 10; nonce++ {
+		tx := types.NewTx(&types.LegacyTx{Nonce: nonce, Gas: 1_000_000, To: &common.Address{1}, GasPrice: common.Big1, Data: make([]byte, 100*1024)})
+		if err := p2p.Send(remote, TransactionsMsg, TransactionsPacket{tx}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var queued, throttled int
+	for i := 0; i < 100 && queued+throttled < 10; i++ {
+		time.Sleep(5 * time.Millisecond)
+		queued, throttled = r.RelayQueue().Len, int(r.Peers()[0].Stats().Throttled)
+	}
+	if queued == 0 || throttled == 0 {
+		t.Fatalf("%d messages queued, %d dropped over the ingress limit", queued, throttled)
+	}
+	if dropped := r.Bandwidth().IngressDropped; dropped != uint64(throttled) {
+		t.Fatalf("%d messages dropped in total, want %d", dropped, throttled)
+	}
+}
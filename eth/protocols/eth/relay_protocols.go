@@ -31,6 +31,10 @@ func MakeRelayProtocols(backend *RelayBackend, network uint64, disc enode.Iterat
 			Version: version,
 			Length:  protocolLengths[version],
 			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+				// Record the messages of the peer if capture is enabled
+				rw = backend.relay.CaptureRW(p, ProtocolName, version, rw)
+				// Responses to requests of the relay bypass the handler
+				rw = &relayResponseRW{MsgReadWriter: rw, relay: backend.relay, peer: p.ID()}
 				peer := NewPeer(version, p, rw, nil) // No txpool in relay mode
 				defer peer.Close()
 
@@ -0,0 +1,388 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pex
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+const (
+	// requestInterval is the time between two record requests to the same peer.
+	requestInterval = 5 * time.Minute
+
+	// minServeInterval is the minimum time between two requests served to the
+	// same peer. Peers asking more often are disconnected.
+	minServeInterval = time.Minute
+
+	// requeueInterval is the time after which all known records are handed to
+	// the dial iterator again, so nodes that failed to dial are retried.
+	requeueInterval = 10 * time.Minute
+
+	// defaultMaxRecords is the default size of the record table.
+	defaultMaxRecords = 512
+
+	// foundQueueSize is the number of dial candidates buffered for the iterator.
+	foundQueueSize = 64
+)
+
+// Config contains the settings of the record exchange.
+type Config struct {
+	// Self returns the local node record. It is shared with peers if it carries
+	// an onion3 or i2p entry.
+	Self func() *enode.Node
+
+	// Filter is an additional acceptance check for learned records, typically
+	// the fork ID filter of the eth protocol. All records are accepted if nil.
+	Filter func(*enode.Node) bool
+
+	// MaxRecords is the maximum number of records kept. Defaults to 512.
+	MaxRecords int
+}
+
+// Exchange maintains a table of node records learned from connected peers and
+// serves it over the pex protocol.
+type Exchange struct {
+	config Config
+
+	lock  sync.Mutex
+	table map[enode.ID]*enode.Node
+	order []enode.ID // insertion order, oldest first
+
+	found   chan *enode.Node
+	quit    chan struct{}
+	closeMu sync.Once
+	wg      sync.WaitGroup
+}
+
+// NewExchange creates a record exchange. Close must be called to release the
+// background requeue loop.
+func NewExchange(config Config) *Exchange {
+	if config.MaxRecords <= 0 {
+		config.MaxRecords = defaultMaxRecords
+	}
+	ex := &Exchange{
+		config: config,
+		table:  make(map[enode.ID]*enode.Node),
+		found:  make(chan *enode.Node, foundQueueSize),
+		quit:   make(chan struct{}),
+	}
+	ex.wg.Add(1)
+	go ex.requeueLoop()
+	return ex
+}
+
+// Close stops the exchange and ends all iterators.
+func (ex *Exchange) Close() {
+	ex.closeMu.Do(func() {
+		close(ex.quit)
+		ex.wg.Wait()
+	})
+}
+
+// Protocols returns the p2p protocols implementing the record exchange.
+func (ex *Exchange) Protocols() []p2p.Protocol {
+	protocols := make([]p2p.Protocol, 0, len(ProtocolVersions))
+	for _, version := range ProtocolVersions {
+		protocols = append(protocols, p2p.Protocol{
+			Name:    ProtocolName,
+			Version: version,
+			Length:  protocolLengths[version],
+			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+				return ex.runPeer(p, rw)
+			},
+			NodeInfo: func() interface{} {
+				return &NodeInfo{Records: ex.Len()}
+			},
+			DialCandidates: ex.Iterator(),
+		})
+	}
+	return protocols
+}
+
+// NodeInfo represents a short summary of the `pex` sub-protocol metadata
+// known about the host peer.
+type NodeInfo struct {
+	Records int `json:"records"` // Number of records in the local table
+}
+
+// Len returns the number of known records.
+func (ex *Exchange) Len() int {
+	ex.lock.Lock()
+	defer ex.lock.Unlock()
+	return len(ex.table)
+}
+
+// Iterator returns a dial candidate iterator yielding all new or updated
+// records, as well as the full table every requeueInterval.
+func (ex *Exchange) Iterator() enode.Iterator {
+	return &iterator{ex: ex, closed: make(chan struct{})}
+}
+
+// Add validates a record and inserts it into the table. It returns an error
+// only if the record signature is invalid; records that are merely not
+// useful, e.g. lacking an anonymous address or failing the filter, are
+// silently ignored.
+func (ex *Exchange) Add(r *enr.Record) error {
+	n, err := enode.New(enode.ValidSchemes, r)
+	if err != nil {
+		return err
+	}
+	if !hasAnonymousAddress(n) {
+		return nil
+	}
+	if ex.config.Filter != nil && !ex.config.Filter(n) {
+		return nil
+	}
+	if self := ex.self(); self != nil && self.ID() == n.ID() {
+		return nil
+	}
+
+	ex.lock.Lock()
+	if old, ok := ex.table[n.ID()]; ok {
+		if old.Seq() >= n.Seq() {
+			ex.lock.Unlock()
+			return nil
+		}
+	} else {
+		if len(ex.order) >= ex.config.MaxRecords {
+			delete(ex.table, ex.order[0])
+			ex.order = ex.order[1:]
+		}
+		ex.order = append(ex.order, n.ID())
+	}
+	ex.table[n.ID()] = n
+	ex.lock.Unlock()
+
+	ex.queue(n)
+	return nil
+}
+
+// Records returns up to limit records for a peer, starting with the local
+// record. Records of the requesting node itself are left out.
+func (ex *Exchange) Records(exclude enode.ID, limit int) []*enr.Record {
+	if limit > maxRecordsServe {
+		limit = maxRecordsServe
+	}
+	records := make([]*enr.Record, 0, limit)
+	if self := ex.self(); self != nil && hasAnonymousAddress(self) && limit > 0 {
+		records = append(records, self.Record())
+	}
+
+	ex.lock.Lock()
+	defer ex.lock.Unlock()
+	for _, i := range rand.Perm(len(ex.order)) {
+		if len(records) >= limit {
+			break
+		}
+		if id := ex.order[i]; id != exclude {
+			records = append(records, ex.table[id].Record())
+		}
+	}
+	return records
+}
+
+func (ex *Exchange) self() *enode.Node {
+	if ex.config.Self == nil {
+		return nil
+	}
+	return ex.config.Self()
+}
+
+// queue hands a record to the dial iterator. Records are dropped if the
+// iterator is not keeping up, they will be requeued later.
+func (ex *Exchange) queue(n *enode.Node) {
+	select {
+	case ex.found <- n:
+	default:
+	}
+}
+
+func (ex *Exchange) requeueLoop() {
+	defer ex.wg.Done()
+
+	ticker := time.NewTicker(requeueInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ex.lock.Lock()
+			nodes := make([]*enode.Node, 0, len(ex.table))
+			for _, id := range ex.order {
+				nodes = append(nodes, ex.table[id])
+			}
+			ex.lock.Unlock()
+			for _, n := range nodes {
+				ex.queue(n)
+			}
+		case <-ex.quit:
+			return
+		}
+	}
+}
+
+// peer is the per-connection state of the record exchange.
+type peer struct {
+	*p2p.Peer
+	rw p2p.MsgReadWriter
+
+	lock       sync.Mutex
+	pending    uint64    // ID of the outstanding request, zero if none
+	lastServed time.Time // time of the last served request
+}
+
+// runPeer is the protocol handler for a single peer. It requests records
+// right after the connection is established and every requestInterval.
+func (ex *Exchange) runPeer(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+	peer := &peer{Peer: p, rw: rw}
+	done := make(chan struct{})
+	defer close(done)
+	go ex.requestLoop(peer, done)
+
+	for {
+		if err := ex.handleMessage(peer); err != nil {
+			peer.Log().Debug("Message handling failed in `pex`", "err", err)
+			return err
+		}
+	}
+}
+
+func (ex *Exchange) requestLoop(peer *peer, done chan struct{}) {
+	ticker := time.NewTicker(requestInterval)
+	defer ticker.Stop()
+	for {
+		id := rand.Uint64() | 1 // never zero
+		peer.lock.Lock()
+		peer.pending = id
+		peer.lock.Unlock()
+		if err := p2p.Send(peer.rw, GetRecordsMsg, &GetRecordsPacket{RequestId: id, Limit: maxRecordsServe}); err != nil {
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		case <-ex.quit:
+			return
+		}
+	}
+}
+
+// handleMessage is invoked whenever an inbound message is received from a
+// remote peer. The remote connection is torn down upon returning any error.
+func (ex *Exchange) handleMessage(peer *peer) error {
+	msg, err := peer.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Size > maxMessageSize {
+		return fmt.Errorf("%w: %v > %v", errMsgTooLarge, msg.Size, maxMessageSize)
+	}
+	defer msg.Discard()
+
+	switch msg.Code {
+	case GetRecordsMsg:
+		var req GetRecordsPacket
+		if err := msg.Decode(&req); err != nil {
+			return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+		}
+		peer.lock.Lock()
+		now := time.Now()
+		if !peer.lastServed.IsZero() && now.Sub(peer.lastServed) < minServeInterval {
+			peer.lock.Unlock()
+			return errRequestRateLimit
+		}
+		peer.lastServed = now
+		peer.lock.Unlock()
+
+		records := ex.Records(peer.ID(), int(min(req.Limit, maxRecordsServe)))
+		return p2p.Send(peer.rw, RecordsMsg, &RecordsPacket{RequestId: req.RequestId, Records: records})
+
+	case RecordsMsg:
+		var res RecordsPacket
+		if err := msg.Decode(&res); err != nil {
+			return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+		}
+		peer.lock.Lock()
+		expected := peer.pending != 0 && peer.pending == res.RequestId
+		if expected {
+			peer.pending = 0
+		}
+		peer.lock.Unlock()
+		if !expected {
+			return errUnexpectedReply
+		}
+		if len(res.Records) > maxRecordsServe {
+			return fmt.Errorf("%w: %d", errTooManyRecords, len(res.Records))
+		}
+		for _, r := range res.Records {
+			if err := ex.Add(r); err != nil {
+				return fmt.Errorf("%w: %v", errInvalidRecord, err)
+			}
+		}
+		log.Trace("Received node records", "peer", peer.ID(), "count", len(res.Records), "known", ex.Len())
+		return nil
+
+	default:
+		return fmt.Errorf("%w: %v", errInvalidMsgCode, msg.Code)
+	}
+}
+
+// hasAnonymousAddress reports whether the node is reachable through Tor or I2P.
+func hasAnonymousAddress(n *enode.Node) bool {
+	var onion enr.Onion3
+	if n.Load(&onion) == nil {
+		return true
+	}
+	var i2p enr.I2P
+	return n.Load(&i2p) == nil
+}
+
+// iterator is the dial candidate iterator of an Exchange.
+type iterator struct {
+	ex        *Exchange
+	cur       *enode.Node
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (it *iterator) Next() bool {
+	select {
+	case n := <-it.ex.found:
+		it.cur = n
+		return true
+	case <-it.closed:
+	case <-it.ex.quit:
+	}
+	it.cur = nil
+	return false
+}
+
+func (it *iterator) Node() *enode.Node {
+	return it.cur
+}
+
+func (it *iterator) Close() {
+	it.closeOnce.Do(func() { close(it.closed) })
+}
@@ -0,0 +1,225 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pex
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+const testOnion = "vww6ybal4bd7szmgncyruucpgfkqahzddi37ktceo3ah7ngmcopnpyyd.onion"
+
+// newTestNode creates a signed node record, optionally with an onion address.
+func newTestNode(t *testing.T, onion bool, seq uint64) *enode.Node {
+	t.Helper()
+	key, _ := crypto.GenerateKey()
+	var r enr.Record
+	r.SetSeq(seq)
+	if onion {
+		r.Set(enr.Onion3(testOnion))
+	}
+	if err := enode.SignV4(&r, key); err != nil {
+		t.Fatal(err)
+	}
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// runTestPeer starts the exchange protocol handler on one end of a message
+// pipe and returns the other end.
+func runTestPeer(t *testing.T, ex *Exchange) (*p2p.MsgPipeRW, chan error) {
+	t.Helper()
+	app, net := p2p.MsgPipe()
+	t.Cleanup(func() { app.Close(); net.Close() })
+
+	var id enode.ID
+	id[0] = 1
+	peer := p2p.NewPeer(id, "test", []p2p.Cap{{Name: ProtocolName, Version: PEX1}})
+	errc := make(chan error, 1)
+	go func() { errc <- ex.runPeer(peer, net) }()
+	return app, errc
+}
+
+func TestExchangeAdd(t *testing.T) {
+	ex := NewExchange(Config{MaxRecords: 2})
+	defer ex.Close()
+
+	// Records without an anonymous address are ignored.
+	if err := ex.Add(newTestNode(t, false, 1).Record()); err != nil || ex.Len() != 0 {
+		t.Fatalf("clearnet record accepted: len %d, err %v", ex.Len(), err)
+	}
+	// Records with a broken signature are rejected.
+	bad := *newTestNode(t, true, 1).Record()
+	bad.Set(enr.TCP(30303))
+	if err := ex.Add(&bad); err == nil {
+		t.Fatal("record with invalid signature accepted")
+	}
+	// The table is bounded, the oldest record is evicted.
+	nodes := []*enode.Node{newTestNode(t, true, 1), newTestNode(t, true, 1), newTestNode(t, true, 1)}
+	for _, n := range nodes {
+		if err := ex.Add(n.Record()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ex.Len() != 2 {
+		t.Fatalf("wrong table size %d", ex.Len())
+	}
+	if _, ok := ex.table[nodes[0].ID()]; ok {
+		t.Fatal("oldest record not evicted")
+	}
+	// Every accepted record is handed to the iterator.
+	it := ex.Iterator()
+	defer it.Close()
+	for i := range nodes {
+		if !it.Next() || it.Node().ID() != nodes[i].ID() {
+			t.Fatalf("iterator yielded wrong node at %d", i)
+		}
+	}
+}
+
+func TestExchangeFilter(t *testing.T) {
+	rejected := newTestNode(t, true, 1)
+	ex := NewExchange(Config{Filter: func(n *enode.Node) bool { return n.ID() != rejected.ID() }})
+	defer ex.Close()
+
+	ex.Add(rejected.Record())
+	if ex.Len() != 0 {
+		t.Fatal("filtered record accepted")
+	}
+	ex.Add(newTestNode(t, true, 1).Record())
+	if ex.Len() != 1 {
+		t.Fatal("valid record not accepted")
+	}
+}
+
+func TestExchangeServe(t *testing.T) {
+	self := newTestNode(t, true, 1)
+	ex := NewExchange(Config{Self: func() *enode.Node { return self }})
+	defer ex.Close()
+	known := newTestNode(t, true, 1)
+	ex.Add(known.Record())
+
+	rw, errc := runTestPeer(t, ex)
+
+	// The handler requests records on connect.
+	msg, err := rw.ReadMsg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req GetRecordsPacket
+	if msg.Code != GetRecordsMsg || msg.Decode(&req) != nil {
+		t.Fatalf("expected records request, got code %d", msg.Code)
+	}
+	learned := newTestNode(t, true, 1)
+	if err := p2p.Send(rw, RecordsMsg, &RecordsPacket{RequestId: req.RequestId, Records: []*enr.Record{learned.Record()}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ask for records, the local record comes first.
+	if err := p2p.Send(rw, GetRecordsMsg, &GetRecordsPacket{RequestId: 7, Limit: 10}); err != nil {
+		t.Fatal(err)
+	}
+	msg, err = rw.ReadMsg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res RecordsPacket
+	if msg.Code != RecordsMsg || msg.Decode(&res) != nil {
+		t.Fatalf("expected records response, got code %d", msg.Code)
+	}
+	if res.RequestId != 7 || len(res.Records) != 3 {
+		t.Fatalf("wrong response: id %d, %d records", res.RequestId, len(res.Records))
+	}
+	if n, _ := enode.New(enode.ValidSchemes, res.Records[0]); n.ID() != self.ID() {
+		t.Fatal("local record not served first")
+	}
+
+	// A second request within the serve interval is a protocol violation.
+	if err := p2p.Send(rw, GetRecordsMsg, &GetRecordsPacket{RequestId: 8, Limit: 10}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errc:
+		if !errors.Is(err, errRequestRateLimit) {
+			t.Fatalf("wrong error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("peer not dropped for request flooding")
+	}
+}
+
+func TestExchangeUnsolicitedResponse(t *testing.T) {
+	ex := NewExchange(Config{})
+	defer ex.Close()
+
+	rw, errc := runTestPeer(t, ex)
+	if _, err := rw.ReadMsg(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p2p.Send(rw, RecordsMsg, &RecordsPacket{RequestId: 0}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errc:
+		if !errors.Is(err, errUnexpectedReply) {
+			t.Fatalf("wrong error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("peer not dropped for unsolicited response")
+	}
+}
+
+func TestExchangeTooManyRecords(t *testing.T) {
+	ex := NewExchange(Config{})
+	defer ex.Close()
+
+	rw, errc := runTestPeer(t, ex)
+	msg, err := rw.ReadMsg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req GetRecordsPacket
+	msg.Decode(&req)
+
+	records := make([]*enr.Record, maxRecordsServe+1)
+	for i := range records {
+		records[i] = newTestNode(t, true, 1).Record()
+	}
+	if err := p2p.Send(rw, RecordsMsg, &RecordsPacket{RequestId: req.RequestId, Records: records}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errc:
+		if !errors.Is(err, errTooManyRecords) {
+			t.Fatalf("wrong error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("peer not dropped for oversized response")
+	}
+	if ex.Len() != 0 {
+		t.Fatal("records of oversized response were accepted")
+	}
+}
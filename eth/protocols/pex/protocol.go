@@ -0,0 +1,79 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package pex implements the `pex` peer exchange protocol. Connected peers
+// swap signed node records that carry an anonymity network address (onion3 or
+// i2p), which lets nodes that cannot run the UDP based discovery protocols
+// bootstrap a mesh from a single static peer.
+package pex
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+// Constants to match up protocol versions and messages
+const (
+	PEX1 = 1
+)
+
+// ProtocolName is the official short name of the `pex` protocol used during
+// devp2p capability negotiation.
+const ProtocolName = "pex"
+
+// ProtocolVersions are the supported versions of the `pex` protocol (first
+// is primary).
+var ProtocolVersions = []uint{PEX1}
+
+// protocolLengths are the number of implemented message corresponding to
+// different protocol versions.
+var protocolLengths = map[uint]uint64{PEX1: 2}
+
+// maxMessageSize is the maximum cap on the size of a protocol message. It
+// allows maxRecordsServe records of the maximum ENR size plus list overhead.
+const maxMessageSize = (maxRecordsServe + 1) * enr.SizeLimit
+
+// maxRecordsServe is the maximum number of records in a single response.
+const maxRecordsServe = 16
+
+const (
+	GetRecordsMsg = 0x00
+	RecordsMsg    = 0x01
+)
+
+var (
+	errMsgTooLarge      = errors.New("message too long")
+	errDecode           = errors.New("invalid message")
+	errInvalidMsgCode   = errors.New("invalid message code")
+	errInvalidRecord    = errors.New("invalid node record")
+	errTooManyRecords   = errors.New("too many records in response")
+	errUnexpectedReply  = errors.New("unsolicited records response")
+	errRequestRateLimit = errors.New("records requested too often")
+)
+
+// GetRecordsPacket requests node records from a peer.
+type GetRecordsPacket struct {
+	RequestId uint64 // Request ID to match up responses with
+	Limit     uint64 // Maximum number of records to return
+}
+
+// RecordsPacket is the response to GetRecordsPacket. The first record is the
+// sender's own record if it has an anonymity network address.
+type RecordsPacket struct {
+	RequestId uint64
+	Records   []*enr.Record
+}
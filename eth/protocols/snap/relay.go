@@ -0,0 +1,368 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/eth/relay"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Relay mode.
+//
+// A relay has no state to serve. It passes the snap requests of a peer on to
+// another snap peer and the response back. Forwarded requests get a fresh ID,
+// so requests of different peers cannot collide, and the response is returned
+// with the requester's ID. The byte limits and lookup counts of requests are
+// capped like the server side would, so responses stay within the limits of a
+// full node. Late responses to timed out requests are dropped.
+
+const (
+	// relayRequestTimeout is how long a forwarded request may stay unanswered.
+	relayRequestTimeout = 30 * time.Second
+
+	// relayExpiredRequests is the number of timed out requests remembered, so
+	// their late responses are dropped without penalising the peer again.
+	relayExpiredRequests = 1024
+)
+
+// RelayBackend proxies the snap requests of relay peers.
+type RelayBackend struct {
+	relay   *relay.Backend
+	timeout time.Duration
+
+	lock    sync.Mutex
+	peers   map[enode.ID]*Peer
+	pending map[uint64]*relayRequest       // forwarded requests by forwarded ID
+	expired lru.BasicLRU[uint64, enode.ID] // timed out requests and their targets
+	next    int                            // round-robin position of the target selection
+}
+
+// relayRequest is a request forwarded to a target peer.
+type relayRequest struct {
+	origin *Peer
+	id     uint64 // request ID of the origin
+	target enode.ID
+	code   uint64 // expected response code
+	timer  *time.Timer
+}
+
+// relayResponse is any snap response, with the payload after the request ID
+// kept as is.
+type relayResponse struct {
+	ID   uint64
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// NewRelayBackend creates the snap proxy of a relay.
+func NewRelayBackend(r *relay.Backend) *RelayBackend {
+	return &RelayBackend{
+		relay:   r,
+		timeout: relayRequestTimeout,
+		peers:   make(map[enode.ID]*Peer),
+		pending: make(map[uint64]*relayRequest),
+		expired: lru.NewBasicLRU[uint64, enode.ID](relayExpiredRequests),
+	}
+}
+
+// MakeRelayProtocols constructs the `snap` protocols of a relay.
+func MakeRelayProtocols(backend *RelayBackend) []p2p.Protocol {
+	protocols := make([]p2p.Protocol, len(ProtocolVersions))
+	for i, version := range ProtocolVersions {
+		protocols[i] = p2p.Protocol{
+			Name:    ProtocolName,
+			Version: version,
+			Length:  protocolLengths[version],
+			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+				return backend.RunPeer(NewPeer(version, p, rw))
+			},
+			NodeInfo: func() interface{} {
+				return &NodeInfo{}
+			},
+			Attributes: []enr.Entry{&enrEntry{}},
+		}
+	}
+	return protocols
+}
+
+// RunPeer registers a snap peer and relays its messages until it disconnects.
+func (rb *RelayBackend) RunPeer(peer *Peer) error {
+	id := peer.Peer.ID()
+	if rb.relay.IsBanned(id) {
+		return p2p.DiscUselessPeer
+	}
+	if rb.relay.Draining() {
+		return p2p.DiscQuitting
+	}
+	rb.lock.Lock()
+	if _, ok := rb.peers[id]; ok {
+		rb.lock.Unlock()
+		return p2p.DiscAlreadyConnected
+	}
+	rb.peers[id] = peer
+	rb.lock.Unlock()
+	defer rb.removePeer(id)
+
+	for {
+		if err := rb.handleMessage(peer); err != nil {
+			peer.Log().Debug("Message relaying failed in `snap`", "err", err)
+			if isDecodeError(err) {
+				rb.relay.ReportPeer(id, relay.MisbehaviourDecode)
+			}
+			return err
+		}
+	}
+}
+
+// isDecodeError reports whether the peer sent a malformed message.
+func isDecodeError(err error) bool {
+	return errors.Is(err, errDecode) || errors.Is(err, errInvalidMsgCode) || errors.Is(err, errBadRequest)
+}
+
+// removePeer unregisters a peer and drops the requests it sent or was sent.
+func (rb *RelayBackend) removePeer(id enode.ID) {
+	rb.lock.Lock()
+	defer rb.lock.Unlock()
+
+	delete(rb.peers, id)
+	for reqID, req := range rb.pending {
+		if req.target == id || req.origin.Peer.ID() == id {
+			req.timer.Stop()
+			delete(rb.pending, reqID)
+		}
+	}
+}
+
+// handleMessage relays the next message of a peer.
+func (rb *RelayBackend) handleMessage(peer *Peer) error {
+	msg, err := peer.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Size > maxMessageSize {
+		return fmt.Errorf("%w: %v > %v", errMsgTooLarge, msg.Size, maxMessageSize)
+	}
+	defer msg.Discard()
+
+	switch msg.Code {
+	case GetAccountRangeMsg:
+		var req GetAccountRangePacket
+		if err := msg.Decode(&req); err != nil {
+			return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+		}
+		req.Bytes = min(req.Bytes, softResponseLimit)
+		return rb.forward(peer, req.ID, AccountRangeMsg, func(target *Peer, id uint64) error {
+			return target.RequestAccountRange(id, req.Root, req.Origin, req.Limit, req.Bytes)
+		})
+
+	case GetStorageRangesMsg:
+		var req GetStorageRangesPacket
+		if err := msg.Decode(&req); err != nil {
+			return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+		}
+		if len(req.Accounts) == 0 {
+			return fmt.Errorf("%w: no accounts", errBadRequest)
+		}
+		req.Bytes = min(req.Bytes, softResponseLimit)
+		return rb.forward(peer, req.ID, StorageRangesMsg, func(target *Peer, id uint64) error {
+			return target.RequestStorageRanges(id, req.Root, req.Accounts, req.Origin, req.Limit, req.Bytes)
+		})
+
+	case GetByteCodesMsg:
+		var req GetByteCodesPacket
+		if err := msg.Decode(&req); err != nil {
+			return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+		}
+		req.Bytes = min(req.Bytes, softResponseLimit)
+		if len(req.Hashes) > maxCodeLookups {
+			req.Hashes = req.Hashes[:maxCodeLookups]
+		}
+		return rb.forward(peer, req.ID, ByteCodesMsg, func(target *Peer, id uint64) error {
+			return target.RequestByteCodes(id, req.Hashes, req.Bytes)
+		})
+
+	case GetTrieNodesMsg:
+		var req GetTrieNodesPacket
+		if err := msg.Decode(&req); err != nil {
+			return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+		}
+		req.Bytes = min(req.Bytes, softResponseLimit)
+		req.Paths = capTrieNodePaths(req.Paths, maxTrieNodeLookups)
+		return rb.forward(peer, req.ID, TrieNodesMsg, func(target *Peer, id uint64) error {
+			return target.RequestTrieNodes(id, req.Root, req.Paths, req.Bytes)
+		})
+
+	case AccountRangeMsg, StorageRangesMsg, ByteCodesMsg, TrieNodesMsg:
+		var res relayResponse
+		if err := msg.Decode(&res); err != nil {
+			return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+		}
+		rb.deliver(peer, msg.Code, &res)
+		return nil
+
+	default:
+		return fmt.Errorf("%w: %v", errInvalidMsgCode, msg.Code)
+	}
+}
+
+// forward sends a request of origin to another snap peer under a fresh ID.
+// Without a target peer, the origin gets an empty response, telling it that
+// the relay cannot serve the state.
+func (rb *RelayBackend) forward(origin *Peer, id uint64, code uint64, send func(target *Peer, id uint64) error) error {
+	if rb.relay.Draining() {
+		return p2p.Send(origin.rw, code, emptyResponse(code, id))
+	}
+	rb.lock.Lock()
+	target := rb.selectTarget(origin.Peer.ID())
+	if target == nil {
+		rb.lock.Unlock()
+		origin.Log().Trace("No target peer for snap request", "reqid", id)
+		return p2p.Send(origin.rw, code, emptyResponse(code, id))
+	}
+	fwdID := rand.Uint64()
+	for rb.pending[fwdID] != nil || rb.expired.Contains(fwdID) {
+		fwdID = rand.Uint64()
+	}
+	req := &relayRequest{origin: origin, id: id, target: target.Peer.ID(), code: code}
+	req.timer = time.AfterFunc(rb.timeout, func() { rb.expire(fwdID, req) })
+	rb.pending[fwdID] = req
+	rb.lock.Unlock()
+
+	if err := send(target, fwdID); err != nil {
+		rb.lock.Lock()
+		if rb.pending[fwdID] == req {
+			req.timer.Stop()
+			delete(rb.pending, fwdID)
+		}
+		rb.lock.Unlock()
+		target.Log().Debug("Failed to forward snap request", "err", err)
+		return p2p.Send(origin.rw, code, emptyResponse(code, id))
+	}
+	return nil
+}
+
+// selectTarget picks the next snap peer other than origin in round-robin
+// order. It must be called with the lock held.
+func (rb *RelayBackend) selectTarget(origin enode.ID) *Peer {
+	candidates := make([]*Peer, 0, len(rb.peers))
+	for id, peer := range rb.peers {
+		if id != origin && !rb.relay.IsBanned(id) {
+			candidates = append(candidates, peer)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	// Map iteration order is random, sort for a stable rotation.
+	slices.SortFunc(candidates, func(a, b *Peer) int {
+		return strings.Compare(a.id, b.id)
+	})
+	rb.next++
+	return candidates[rb.next%len(candidates)]
+}
+
+// expire drops a forwarded request that was not answered in time.
+func (rb *RelayBackend) expire(fwdID uint64, req *relayRequest) {
+	rb.lock.Lock()
+	if rb.pending[fwdID] != req {
+		rb.lock.Unlock()
+		return
+	}
+	delete(rb.pending, fwdID)
+	rb.expired.Add(fwdID, req.target)
+	rb.lock.Unlock()
+
+	log.Debug("Relayed snap request timed out", "peer", req.target, "reqid", fwdID)
+	if !rb.relay.Draining() {
+		rb.relay.ReportPeer(req.target, relay.MisbehaviourTimeout)
+	}
+}
+
+// deliver returns a response to the peer that sent the request.
+func (rb *RelayBackend) deliver(from *Peer, code uint64, res *relayResponse) {
+	rb.lock.Lock()
+	req := rb.pending[res.ID]
+	if req == nil {
+		// The target was penalised for the timeout already, drop the late
+		// response of an expired request quietly.
+		if target, ok := rb.expired.Peek(res.ID); ok && target == from.Peer.ID() {
+			rb.expired.Remove(res.ID)
+			rb.lock.Unlock()
+			from.Log().Trace("Late snap response", "code", code, "reqid", res.ID)
+			return
+		}
+	}
+	if req == nil || req.target != from.Peer.ID() || req.code != code {
+		rb.lock.Unlock()
+		from.Log().Debug("Unsolicited snap response", "code", code, "reqid", res.ID)
+		rb.relay.ReportPeer(from.Peer.ID(), relay.MisbehaviourMismatch)
+		return
+	}
+	req.timer.Stop()
+	delete(rb.pending, res.ID)
+	rb.lock.Unlock()
+
+	requestTracker.Fulfil(from.id, from.version, code, res.ID)
+	res.ID = req.id
+	if err := p2p.Send(req.origin.rw, code, res); err != nil {
+		req.origin.Log().Debug("Failed to return snap response", "err", err)
+	}
+}
+
+// capTrieNodePaths limits the trie node paths of a request to the number of
+// nodes a server looks up. Storage path sets are cut short, keeping the account
+// path, or dropped if no storage path fits.
+func capTrieNodePaths(paths []TrieNodePathSet, limit int) []TrieNodePathSet {
+	for i, pathset := range paths {
+		if len(pathset) <= limit {
+			limit -= len(pathset)
+			continue
+		}
+		if limit >= 2 {
+			paths[i] = pathset[:limit]
+			return paths[:i+1]
+		}
+		return paths[:i]
+	}
+	return paths
+}
+
+// emptyResponse returns the response to a request that cannot be served.
+func emptyResponse(code uint64, id uint64) Packet {
+	switch code {
+	case AccountRangeMsg:
+		return &AccountRangePacket{ID: id}
+	case StorageRangesMsg:
+		return &StorageRangesPacket{ID: id}
+	case ByteCodesMsg:
+		return &ByteCodesPacket{ID: id}
+	default:
+		return &TrieNodesPacket{ID: id}
+	}
+}
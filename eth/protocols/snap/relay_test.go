@@ -0,0 +1,234 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/eth/relay"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// startRelayPeer connects a peer to the relay and returns the remote end of
+// the connection.
+func startRelayPeer(t *testing.T, backend *RelayBackend, id byte) *p2p.MsgPipeRW {
+	t.Helper()
+	local, remote := p2p.MsgPipe()
+	t.Cleanup(func() { local.Close() })
+
+	peer := NewPeer(SNAP1, p2p.NewPeer(enode.ID{id}, "test", nil), local)
+	go backend.RunPeer(peer)
+
+	// Wait for the peer to be registered, so it can be selected as target.
+	for i := 0; i < 100; i++ {
+		backend.lock.Lock()
+		_, ok := backend.peers[enode.ID{id}]
+		backend.lock.Unlock()
+		if ok {
+			return remote
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("peer not registered")
+	return nil
+}
+
+// readMsg reads and decodes the next message of the given code.
+func readMsg(t *testing.T, rw p2p.MsgReader, code uint64, val interface{}) {
+	t.Helper()
+	msg, err := rw.ReadMsg()
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if msg.Code != code {
+		t.Fatalf("message code %d, want %d", msg.Code, code)
+	}
+	if err := msg.Decode(val); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+}
+
+func TestRelayAccountRange(t *testing.T) {
+	backend := NewRelayBackend(relay.NewBackend(&relay.Config{}, nil))
+	requester := startRelayPeer(t, backend, 1)
+
+	// Without another snap peer, the request is answered empty.
+	p2p.Send(requester, GetAccountRangeMsg, &GetAccountRangePacket{ID: 7, Bytes: 1000})
+	var empty AccountRangePacket
+	readMsg(t, requester, AccountRangeMsg, &empty)
+	if empty.ID != 7 || len(empty.Accounts) != 0 {
+		t.Fatalf("unexpected response %+v", empty)
+	}
+
+	server := startRelayPeer(t, backend, 2)
+	root := common.Hash{0xaa}
+	p2p.Send(requester, GetAccountRangeMsg, &GetAccountRangePacket{ID: 8, Root: root, Limit: common.MaxHash, Bytes: 100 * softResponseLimit})
+
+	// The request is forwarded under a new ID with the byte limit capped.
+	var req GetAccountRangePacket
+	readMsg(t, server, GetAccountRangeMsg, &req)
+	if req.Root != root || req.Limit != common.MaxHash {
+		t.Fatalf("request not forwarded as is: %+v", req)
+	}
+	if req.Bytes != softResponseLimit {
+		t.Fatalf("byte limit %d, want %d", req.Bytes, softResponseLimit)
+	}
+	res := &AccountRangePacket{
+		ID:       req.ID,
+		Accounts: []*AccountData{{Hash: common.Hash{1}, Body: []byte{0xc0}}},
+		Proof:    [][]byte{{1, 2, 3}},
+	}
+	p2p.Send(server, AccountRangeMsg, res)
+
+	// The response is returned under the requester's ID.
+	var got AccountRangePacket
+	readMsg(t, requester, AccountRangeMsg, &got)
+	res.ID = 8
+	if !reflect.DeepEqual(&got, res) {
+		t.Fatalf("response %+v, want %+v", got, res)
+	}
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	if len(backend.pending) != 0 {
+		t.Fatalf("%d requests still pending", len(backend.pending))
+	}
+}
+
+func TestRelayByteCodesCapped(t *testing.T) {
+	backend := NewRelayBackend(relay.NewBackend(&relay.Config{}, nil))
+	requester := startRelayPeer(t, backend, 1)
+	server := startRelayPeer(t, backend, 2)
+
+	hashes := make([]common.Hash, 2*maxCodeLookups)
+	p2p.Send(requester, GetByteCodesMsg, &GetByteCodesPacket{ID: 1, Hashes: hashes, Bytes: softResponseLimit})
+
+	var req GetByteCodesPacket
+	readMsg(t, server, GetByteCodesMsg, &req)
+	if len(req.Hashes) != maxCodeLookups {
+		t.Fatalf("%d hashes forwarded, want %d", len(req.Hashes), maxCodeLookups)
+	}
+	p2p.Send(server, ByteCodesMsg, &ByteCodesPacket{ID: req.ID, Codes: [][]byte{{0x60}}})
+
+	var got ByteCodesPacket
+	readMsg(t, requester, ByteCodesMsg, &got)
+	if got.ID != 1 || len(got.Codes) != 1 {
+		t.Fatalf("unexpected response %+v", got)
+	}
+}
+
+func TestRelayUnsolicitedResponse(t *testing.T) {
+	r := relay.NewBackend(&relay.Config{}, nil)
+	backend := NewRelayBackend(r)
+	requester := startRelayPeer(t, backend, 1)
+	server := startRelayPeer(t, backend, 2)
+
+	p2p.Send(requester, GetTrieNodesMsg, &GetTrieNodesPacket{ID: 3, Bytes: softResponseLimit})
+	var req GetTrieNodesPacket
+	readMsg(t, server, GetTrieNodesMsg, &req)
+
+	// A response of the wrong type does not match the request.
+	p2p.Send(server, ByteCodesMsg, &ByteCodesPacket{ID: req.ID})
+	for i := 0; i < 100 && r.Reputation().Score(enode.ID{2}) == 0; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if score := r.Reputation().Score(enode.ID{2}); score >= 0 {
+		t.Fatalf("score %v after unsolicited response, want negative", score)
+	}
+
+	// The request is still answered by the matching response.
+	p2p.Send(server, TrieNodesMsg, &TrieNodesPacket{ID: req.ID, Nodes: [][]byte{{1}}})
+	var got TrieNodesPacket
+	readMsg(t, requester, TrieNodesMsg, &got)
+	if got.ID != 3 || len(got.Nodes) != 1 {
+		t.Fatalf("unexpected response %+v", got)
+	}
+}
+
+func TestRelayRequestTimeout(t *testing.T) {
+	r := relay.NewBackend(&relay.Config{}, nil)
+	backend := NewRelayBackend(r)
+	backend.timeout = 20 * time.Millisecond
+	requester := startRelayPeer(t, backend, 1)
+	server := startRelayPeer(t, backend, 2)
+
+	p2p.Send(requester, GetStorageRangesMsg, &GetStorageRangesPacket{ID: 4, Accounts: []common.Hash{{1}}, Bytes: softResponseLimit})
+	var req GetStorageRangesPacket
+	readMsg(t, server, GetStorageRangesMsg, &req)
+
+	for i := 0; i < 100 && r.Reputation().Score(enode.ID{2}) == 0; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	score := r.Reputation().Score(enode.ID{2})
+	if score >= 0 {
+		t.Fatalf("score %v after timeout, want negative", score)
+	}
+	backend.lock.Lock()
+	if len(backend.pending) != 0 {
+		t.Fatalf("%d requests still pending", len(backend.pending))
+	}
+	backend.lock.Unlock()
+
+	// A late response is dropped without penalising the peer again.
+	p2p.Send(server, StorageRangesMsg, &StorageRangesPacket{ID: req.ID})
+	for i := 0; i < 100; i++ {
+		backend.lock.Lock()
+		expired := backend.expired.Contains(req.ID)
+		backend.lock.Unlock()
+		if !expired {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if late := r.Reputation().Score(enode.ID{2}); late < score {
+		t.Fatalf("score %v after late response, want at least %v", late, score)
+	}
+}
+
+func TestRelayTrieNodesCapped(t *testing.T) {
+	backend := NewRelayBackend(relay.NewBackend(&relay.Config{}, nil))
+	requester := startRelayPeer(t, backend, 1)
+	server := startRelayPeer(t, backend, 2)
+
+	// Account paths up to one below the limit, then a storage path set that
+	// only fits partially.
+	paths := make([]TrieNodePathSet, maxTrieNodeLookups-1, maxTrieNodeLookups+1)
+	for i := range paths {
+		paths[i] = TrieNodePathSet{{byte(i)}}
+	}
+	paths = append(paths, TrieNodePathSet{{0x01}, {0x02}, {0x03}}, TrieNodePathSet{{0x04}})
+	p2p.Send(requester, GetTrieNodesMsg, &GetTrieNodesPacket{ID: 5, Paths: paths, Bytes: softResponseLimit})
+
+	var req GetTrieNodesPacket
+	readMsg(t, server, GetTrieNodesMsg, &req)
+	if len(req.Paths) != maxTrieNodeLookups-1 {
+		t.Fatalf("%d path sets forwarded, want %d", len(req.Paths), maxTrieNodeLookups-1)
+	}
+
+	want := []TrieNodePathSet{{{1}}, {{1}, {2}, {3}}, {{4}}, {{5}, {6}}}
+	if got := capTrieNodePaths(slices.Clone(want), 4); !reflect.DeepEqual(got, want[:2]) {
+		t.Fatalf("capped paths %v, want %v", got, want[:2])
+	}
+	cut := []TrieNodePathSet{{{1}}, {{1}, {2}}}
+	if got := capTrieNodePaths(slices.Clone(want), 3); !reflect.DeepEqual(got, cut) {
+		t.Fatalf("capped paths %v, want %v", got, cut)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package stem
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// Config contains the settings of the stem router.
+type Config struct {
+	// Deliver is called with the transactions received from a peer. The peer is
+	// disconnected if it returns an error.
+	Deliver func(peer enode.ID, txs []*types.Transaction) error
+}
+
+// Router keeps track of the peers speaking the stem protocol and exchanges
+// stem phase transactions with them. Routing decisions are left to the user.
+type Router struct {
+	config Config
+
+	lock  sync.RWMutex
+	peers map[enode.ID]p2p.MsgReadWriter
+}
+
+// NewRouter creates a stem router.
+func NewRouter(config Config) *Router {
+	return &Router{
+		config: config,
+		peers:  make(map[enode.ID]p2p.MsgReadWriter),
+	}
+}
+
+// Protocols returns the p2p protocols implementing the stem transport.
+func (r *Router) Protocols() []p2p.Protocol {
+	protocols := make([]p2p.Protocol, 0, len(ProtocolVersions))
+	for _, version := range ProtocolVersions {
+		protocols = append(protocols, p2p.Protocol{
+			Name:    ProtocolName,
+			Version: version,
+			Length:  protocolLengths[version],
+			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+				return r.runPeer(p, rw)
+			},
+			NodeInfo: func() interface{} {
+				return &NodeInfo{Peers: len(r.Peers())}
+			},
+		})
+	}
+	return protocols
+}
+
+// NodeInfo represents a short summary of the `stem` sub-protocol metadata
+// known about the host peer.
+type NodeInfo struct {
+	Peers int `json:"peers"` // Number of peers speaking stem
+}
+
+// Peers returns the IDs of the peers speaking the stem protocol.
+func (r *Router) Peers() []enode.ID {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	ids := make([]enode.ID, 0, len(r.peers))
+	for id := range r.peers {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Send sends stem phase transactions to a peer.
+func (r *Router) Send(id enode.ID, txs []*types.Transaction) error {
+	r.lock.RLock()
+	rw := r.peers[id]
+	r.lock.RUnlock()
+	if rw == nil {
+		return errUnknownPeer
+	}
+	return p2p.Send(rw, TransactionsMsg, TransactionsPacket(txs))
+}
+
+// runPeer is the protocol handler for a single peer.
+func (r *Router) runPeer(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+	r.lock.Lock()
+	r.peers[p.ID()] = rw
+	r.lock.Unlock()
+	defer func() {
+		r.lock.Lock()
+		delete(r.peers, p.ID())
+		r.lock.Unlock()
+	}()
+
+	for {
+		if err := r.handleMessage(p.ID(), rw); err != nil {
+			p.Log().Debug("Message handling failed in `stem`", "err", err)
+			return err
+		}
+	}
+}
+
+// handleMessage is invoked whenever an inbound message is received from a
+// remote peer. The remote connection is torn down upon returning any error.
+func (r *Router) handleMessage(id enode.ID, rw p2p.MsgReadWriter) error {
+	msg, err := rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Size > maxMessageSize {
+		return fmt.Errorf("%w: %v > %v", errMsgTooLarge, msg.Size, maxMessageSize)
+	}
+	defer msg.Discard()
+
+	switch msg.Code {
+	case TransactionsMsg:
+		var txs TransactionsPacket
+		if err := msg.Decode(&txs); err != nil {
+			return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+		}
+		for i, tx := range txs {
+			if tx == nil {
+				return fmt.Errorf("%w: transaction %d is nil", errDecode, i)
+			}
+		}
+		if len(txs) == 0 || r.config.Deliver == nil {
+			return nil
+		}
+		return r.config.Deliver(id, txs)
+
+	default:
+		return fmt.Errorf("%w: %v", errInvalidMsgCode, msg.Code)
+	}
+}
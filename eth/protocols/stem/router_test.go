@@ -0,0 +1,134 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package stem
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+var testPeerID = enode.ID{1}
+
+// runTestPeer starts the stem protocol handler on one end of a message pipe
+// and returns the other end once the peer is registered.
+func runTestPeer(t *testing.T, r *Router) (*p2p.MsgPipeRW, chan error) {
+	t.Helper()
+	app, net := p2p.MsgPipe()
+	t.Cleanup(func() { app.Close(); net.Close() })
+
+	peer := p2p.NewPeer(testPeerID, "test", []p2p.Cap{{Name: ProtocolName, Version: STEM1}})
+	errc := make(chan error, 1)
+	go func() { errc <- r.runPeer(peer, net) }()
+	for len(r.Peers()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	return app, errc
+}
+
+func newTestTx(nonce uint64) *types.Transaction {
+	return types.NewTx(&types.LegacyTx{Nonce: nonce, Gas: 21000, To: &common.Address{1}, GasPrice: common.Big1})
+}
+
+func TestRouterExchange(t *testing.T) {
+	delivered := make(chan []*types.Transaction, 1)
+	r := NewRouter(Config{Deliver: func(id enode.ID, txs []*types.Transaction) error {
+		if id != testPeerID {
+			t.Errorf("delivered from %v, want %v", id, testPeerID)
+		}
+		delivered <- txs
+		return nil
+	}})
+	rw, _ := runTestPeer(t, r)
+
+	if peers := r.Peers(); len(peers) != 1 || peers[0] != testPeerID {
+		t.Fatalf("wrong peers: %v", peers)
+	}
+
+	// Transactions sent to the peer arrive as one stem message.
+	tx := newTestTx(1)
+	go r.Send(testPeerID, []*types.Transaction{tx})
+	msg, err := rw.ReadMsg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent TransactionsPacket
+	if msg.Code != TransactionsMsg || msg.Decode(&sent) != nil || len(sent) != 1 || sent[0].Hash() != tx.Hash() {
+		t.Fatalf("wrong stem message: code %d, %d transactions", msg.Code, len(sent))
+	}
+
+	// Transactions received from the peer are delivered.
+	if err := p2p.Send(rw, TransactionsMsg, TransactionsPacket{newTestTx(2)}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case txs := <-delivered:
+		if len(txs) != 1 || txs[0].Nonce() != 2 {
+			t.Fatalf("wrong transactions delivered: %v", txs)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("transactions not delivered")
+	}
+
+	// Unknown peers cannot be sent to.
+	if err := r.Send(enode.ID{2}, []*types.Transaction{tx}); !errors.Is(err, errUnknownPeer) {
+		t.Fatalf("send to unknown peer: %v", err)
+	}
+}
+
+func TestRouterDeliverError(t *testing.T) {
+	errInvalid := errors.New("invalid transaction")
+	r := NewRouter(Config{Deliver: func(enode.ID, []*types.Transaction) error { return errInvalid }})
+	rw, errc := runTestPeer(t, r)
+
+	if err := p2p.Send(rw, TransactionsMsg, TransactionsPacket{newTestTx(1)}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errc:
+		if !errors.Is(err, errInvalid) {
+			t.Fatalf("wrong error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("peer not dropped for rejected transactions")
+	}
+	if len(r.Peers()) != 0 {
+		t.Fatal("dropped peer still registered")
+	}
+}
+
+func TestRouterInvalidMessage(t *testing.T) {
+	r := NewRouter(Config{})
+	rw, errc := runTestPeer(t, r)
+
+	if err := p2p.Send(rw, TransactionsMsg, []uint64{1}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errc:
+		if !errors.Is(err, errDecode) {
+			t.Fatalf("wrong error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("peer not dropped for invalid message")
+	}
+}
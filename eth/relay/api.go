@@ -0,0 +1,266 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// API is the relay_ RPC namespace. It exposes the relay's internal state and
+// allows operators to steer it. It is meant to be served on the admin endpoint
+// only.
+type API struct {
+	relay *Relay
+}
+
+// NewAPI creates the relay_ API.
+func NewAPI(r *Relay) *API {
+	return &API{relay: r}
+}
+
+// APIs returns the RPC APIs offered by the relay.
+func (r *Relay) APIs() []rpc.API {
+	return []rpc.API{{
+		Namespace: "relay",
+		Service:   NewAPI(r),
+	}}
+}
+
+// PeerInfo describes a relay peer.
+type PeerInfo struct {
+	ID         enode.ID    `json:"id"`
+	Name       string      `json:"name"`
+	RemoteAddr string      `json:"remoteAddress"`
+	Inbound    bool        `json:"inbound"`
+	Transport  string      `json:"transport"`
+	Relay      bool        `json:"relay"` // peer is a relay linked over the overlay
+	EthVersion uint        `json:"ethVersion"`
+	BlockRange *BlockRange `json:"blockRange"` // last range announced by the peer
+	Connected  time.Time   `json:"connected"`
+	Stats      PeerStats   `json:"stats"`
+	Score      float64     `json:"score"` // reputation, banned below the threshold
+}
+
+// Peers returns all peers registered with the relay, sorted by ID.
+func (api *API) Peers() []*PeerInfo {
+	peers := api.relay.backend.Peers()
+	infos := make([]*PeerInfo, 0, len(peers))
+	for _, p := range peers {
+		info := &PeerInfo{
+			ID:         p.ID,
+			Inbound:    p.Inbound,
+			Transport:  p.Transport,
+			Relay:      p.Overlay,
+			EthVersion: p.Version,
+			Connected:  p.AddedAt,
+			Stats:      p.Stats(),
+			Score:      api.relay.backend.reputation.Score(p.ID),
+		}
+		if p.Peer != nil {
+			info.Name = p.Peer.Fullname()
+			info.RemoteAddr = p.Peer.RemoteAddr().String()
+		}
+		if r, ok := p.BlockRange(); ok {
+			info.BlockRange = &r
+		}
+		infos = append(infos, info)
+	}
+	slices.SortFunc(infos, func(a, b *PeerInfo) int {
+		return strings.Compare(a.ID.String(), b.ID.String())
+	})
+	return infos
+}
+
+// PendingRequests returns the proxied requests awaiting a response.
+func (api *API) PendingRequests() []PendingRequestInfo {
+	if api.relay.proxy == nil {
+		return []PendingRequestInfo{}
+	}
+	pending := api.relay.proxy.Pending()
+	slices.SortFunc(pending, func(a, b PendingRequestInfo) int {
+		return a.Deadline.Compare(b.Deadline)
+	})
+	return pending
+}
+
+// QueueStats describes the fill level of the relay queues.
+type QueueStats struct {
+	RelayQueue QueueInfo                  `json:"relayQueue"`
+	PeerQueues map[enode.ID]PeerQueueInfo `json:"peerQueues"` // per-peer outbound queues
+}
+
+// QueueStats returns the fill level of the relay queues.
+func (api *API) QueueStats() *QueueStats {
+	stats := &QueueStats{
+		RelayQueue: api.relay.backend.RelayQueue(),
+		PeerQueues: make(map[enode.ID]PeerQueueInfo),
+	}
+	if api.relay.router != nil {
+		stats.PeerQueues = api.relay.router.QueueStats()
+	}
+	return stats
+}
+
+// Stats returns the relay counters since startup.
+func (api *API) Stats() RelayStats {
+	return api.relay.backend.Stats()
+}
+
+// PendingStats returns the number of transactions in the recent transaction
+// cache and how many transactions every connected peer delivered.
+func (api *API) PendingStats() *PendingStats {
+	return api.relay.backend.PendingStats()
+}
+
+// SetBlockRange changes the block range announced to peers.
+func (api *API) SetBlockRange(earliest, latest uint64, latestHash common.Hash) (*BlockRange, error) {
+	r := BlockRange{EarliestBlock: earliest, LatestBlock: latest, LatestBlockHash: latestHash}
+	if err := api.relay.backend.SetBlockRange(r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ConfigInfo is the effective relay configuration.
+type ConfigInfo struct {
+	NetworkID         uint64              `json:"networkId"`
+	Genesis           common.Hash         `json:"genesis"`
+	ForkHash          hexutil.Bytes       `json:"forkHash"`
+	ForkNext          uint64              `json:"forkNext"`
+	BlockRange        BlockRange          `json:"blockRange"`
+	EthDiscoveryURLs  []string            `json:"ethDiscoveryURLs"`
+	SnapDiscoveryURLs []string            `json:"snapDiscoveryURLs"`
+	PeerExchange      bool                `json:"peerExchange"`
+	OverlayLinks      int                 `json:"overlayLinks"`
+	Operator          hexutil.Bytes       `json:"operator,omitempty"`
+	ChainConfig       *params.ChainConfig `json:"chainConfig"`
+}
+
+// Config returns the effective relay configuration.
+func (api *API) Config() *ConfigInfo {
+	b := api.relay.backend
+	forkID := b.GetForkID()
+	return &ConfigInfo{
+		NetworkID:         b.GetNetworkID(),
+		Genesis:           b.GetGenesisHash(),
+		ForkHash:          forkID.Hash[:],
+		ForkNext:          forkID.Next,
+		BlockRange:        b.GetBlockRange(),
+		EthDiscoveryURLs:  api.relay.config.EthDiscoveryURLs,
+		SnapDiscoveryURLs: api.relay.config.SnapDiscoveryURLs,
+		PeerExchange:      api.relay.config.PeerExchange,
+		OverlayLinks:      api.relay.config.Overlay.Links,
+		Operator:          api.relay.config.Overlay.Operator,
+		ChainConfig:       b.GetChainConfig(),
+	}
+}
+
+// VerifiedHead returns the latest head verified by the beacon light client.
+func (api *API) VerifiedHead() (*VerifiedHead, error) {
+	if api.relay.light == nil {
+		return nil, errLightClientDisabled
+	}
+	head := api.relay.light.verifiedHead()
+	if head == nil {
+		return nil, errNoVerifiedHead
+	}
+	return head, nil
+}
+
+// Bandwidth returns the bandwidth limits and the state of the global buckets.
+func (api *API) Bandwidth() *BandwidthInfo {
+	return api.relay.backend.Bandwidth()
+}
+
+// SetBandwidth changes the bandwidth limits in bytes per second, zero meaning
+// unlimited.
+func (api *API) SetBandwidth(limits BandwidthLimits) *BandwidthInfo {
+	api.relay.backend.SetBandwidth(limits)
+	return api.relay.backend.Bandwidth()
+}
+
+// Bans returns the active peer bans, earliest expiry first.
+func (api *API) Bans() []Ban {
+	bans := api.relay.backend.reputation.Bans()
+	slices.SortFunc(bans, func(a, b Ban) int {
+		return a.Until.Compare(b.Until)
+	})
+	return bans
+}
+
+// Ban bans a peer and disconnects it. The duration (e.g. "24h") defaults to
+// the configured ban duration.
+func (api *API) Ban(id enode.ID, duration *string, reason *string) (*Ban, error) {
+	var d time.Duration
+	if duration != nil {
+		var err error
+		if d, err = time.ParseDuration(*duration); err != nil {
+			return nil, err
+		}
+		if d <= 0 {
+			return nil, errors.New("ban duration must be positive")
+		}
+	}
+	why := "banned by operator"
+	if reason != nil && *reason != "" {
+		why = *reason
+	}
+	return api.relay.backend.BanPeer(id, why, d), nil
+}
+
+// Unban lifts the ban of a peer. It returns false if the peer was not banned.
+func (api *API) Unban(id enode.ID) bool {
+	return api.relay.backend.UnbanPeer(id)
+}
+
+// Events creates a subscription that is notified of peer and relay events
+// (relay_subscribe("events")).
+func (api *API) Events(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan Event, 64)
+		sub := api.relay.backend.SubscribeEvents(events)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-events:
+				notifier.Notify(rpcSub.ID, ev)
+			case <-sub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
@@ -0,0 +1,309 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPeerConn is a PeerConn recording the block ranges and messages sent to
+// it.
+type testPeerConn struct {
+	lock   sync.Mutex
+	remote *BlockRange
+	sent   []BlockRange
+	msgs   []p2p.Msg
+}
+
+func (c *testPeerConn) BlockRange() (BlockRange, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.remote == nil {
+		return BlockRange{}, false
+	}
+	return *c.remote, true
+}
+
+func (c *testPeerConn) SendBlockRange(r BlockRange) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.sent = append(c.sent, r)
+	return nil
+}
+
+func (c *testPeerConn) Send(msgCode uint64, payload []byte) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.msgs = append(c.msgs, p2p.Msg{Code: msgCode, Size: uint32(len(payload)), Payload: bytes.NewReader(payload)})
+	return nil
+}
+
+// waitSent waits until n messages were sent and returns them.
+func (c *testPeerConn) waitSent(t *testing.T, n int) []p2p.Msg {
+	for i := 0; i < 100; i++ {
+		c.lock.Lock()
+		msgs := slices.Clone(c.msgs)
+		c.lock.Unlock()
+		if len(msgs) >= n {
+			return msgs
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%d messages not sent", n)
+	return nil
+}
+
+// sentPacket returns the request ID and payload of the i-th message sent.
+func (c *testPeerConn) sentPacket(t *testing.T, i int) (uint64, uint64, []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	require.Greater(t, len(c.msgs), i, "message not sent")
+	var packet struct {
+		RequestID uint64
+		Payload   rlp.RawValue
+	}
+	require.NoError(t, c.msgs[i].Decode(&packet))
+	return c.msgs[i].Code, packet.RequestID, packet.Payload
+}
+
+func newTestRelay() *Relay {
+	config := &Config{
+		NetworkID:   1,
+		GenesisHash: common.HexToHash("0x01"),
+		BlockRange:  BlockRange{LatestBlock: 100, LatestBlockHash: common.HexToHash("0x64")},
+	}
+	backend := NewBackend(config, nil)
+	return &Relay{
+		backend: backend,
+		router:  NewMessageRouter(backend),
+		config:  config,
+		quit:    make(chan struct{}),
+	}
+}
+
+func newTestRelayPeer(id byte, conn PeerConn) *RelayPeer {
+	p := p2p.NewPeer(enode.ID{id}, "test", []p2p.Cap{{Name: "eth", Version: 69}})
+	return NewRelayPeer(p, 69, conn)
+}
+
+func TestAPIPeers(t *testing.T) {
+	r := newTestRelay()
+	api := NewAPI(r)
+
+	remote := &BlockRange{EarliestBlock: 5, LatestBlock: 50, LatestBlockHash: common.HexToHash("0x32")}
+	require.True(t, r.backend.AddPeer(newTestRelayPeer(2, &testPeerConn{remote: remote})))
+	require.True(t, r.backend.AddPeer(newTestRelayPeer(1, &testPeerConn{})))
+	require.False(t, r.backend.AddPeer(newTestRelayPeer(1, &testPeerConn{})), "duplicate peer added")
+	r.backend.peerStats(enode.ID{2}).received.Add(3)
+
+	peers := api.Peers()
+	require.Len(t, peers, 2)
+	assert.Equal(t, enode.ID{1}, peers[0].ID)
+	assert.Nil(t, peers[0].BlockRange)
+	assert.Equal(t, "clearnet", peers[0].Transport)
+	assert.Equal(t, uint(69), peers[0].EthVersion)
+	assert.Equal(t, enode.ID{2}, peers[1].ID)
+	assert.Equal(t, remote, peers[1].BlockRange)
+	assert.Equal(t, uint64(3), peers[1].Stats.Received)
+
+	r.backend.RemovePeer(enode.ID{1})
+	assert.Len(t, api.Peers(), 1)
+}
+
+func TestAPISetBlockRange(t *testing.T) {
+	r := newTestRelay()
+	api := NewAPI(r)
+	conn := &testPeerConn{}
+	r.backend.AddPeer(newTestRelayPeer(1, conn))
+
+	hash := common.HexToHash("0xc8")
+	got, err := api.SetBlockRange(10, 200, hash)
+	require.NoError(t, err)
+	want := BlockRange{EarliestBlock: 10, LatestBlock: 200, LatestBlockHash: hash}
+	assert.Equal(t, want, *got)
+	assert.Equal(t, want, r.backend.GetBlockRange())
+	assert.Equal(t, []BlockRange{want}, conn.sent, "range not announced to peer")
+	assert.Equal(t, want, api.Config().BlockRange)
+
+	_, err = api.SetBlockRange(300, 200, hash)
+	assert.Error(t, err, "inverted range accepted")
+	_, err = api.SetBlockRange(10, 200, common.Hash{})
+	assert.Error(t, err, "empty hash accepted")
+	assert.Equal(t, want, r.backend.GetBlockRange())
+}
+
+func TestAPIPendingRequests(t *testing.T) {
+	r := newTestRelay()
+	api := NewAPI(r)
+	assert.Empty(t, api.PendingRequests())
+
+	target := &testPeerConn{}
+	r.backend.AddPeer(newTestRelayPeer(1, &testPeerConn{}))
+	r.backend.AddPeer(newTestRelayPeer(2, target))
+	r.proxy = NewRequestProxy(r.backend, NewRoundRobinSelector(r.backend), time.Minute)
+	defer r.proxy.Stop()
+
+	// The request blocks until the response arrives or it times out.
+	go r.proxy.ProxyRequest(enode.ID{1}, 0x03, 7, []byte{0xc0})
+	var pending []PendingRequestInfo
+	for i := 0; i < 100 && len(pending) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		pending = api.PendingRequests()
+	}
+	require.Len(t, pending, 1)
+	assert.Equal(t, enode.ID{1}, pending[0].From)
+	assert.Equal(t, enode.ID{2}, pending[0].To)
+	assert.Equal(t, uint64(1), r.backend.peerStats(enode.ID{2}).requests.Load())
+
+	// The request is sent under an ID of the relay, not the one of the requester.
+	code, id, payload := target.sentPacket(t, 0)
+	assert.Equal(t, uint64(0x03), code)
+	assert.Equal(t, pending[0].RequestID, id)
+	assert.Equal(t, []byte{0xc0}, payload)
+}
+
+func TestAPIStats(t *testing.T) {
+	r := newTestRelay()
+	api := NewAPI(r)
+	origin := &testPeerConn{}
+	r.backend.AddPeer(newTestRelayPeer(1, origin))
+	r.backend.AddPeer(newTestRelayPeer(2, &testPeerConn{}))
+	r.proxy = NewRequestProxy(r.backend, NewRoundRobinSelector(r.backend), time.Minute)
+	defer r.proxy.Stop()
+
+	done := make(chan error, 1)
+	go func() { done <- r.proxy.ProxyRequest(enode.ID{1}, 0x03, 7, []byte{0xc0}) }()
+	var pending []PendingRequestInfo
+	for i := 0; i < 100 && len(pending) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		pending = api.PendingRequests()
+	}
+	require.Len(t, pending, 1)
+	require.True(t, r.backend.DeliverResponse(enode.ID{2}, 0x04, pending[0].RequestID, []byte{0xc0}))
+	require.NoError(t, <-done)
+
+	// The response is returned to the requester under its request ID.
+	code, id, payload := origin.sentPacket(t, 0)
+	assert.Equal(t, uint64(0x04), code)
+	assert.Equal(t, uint64(7), id)
+	assert.Equal(t, []byte{0xc0}, payload)
+
+	stats := api.Stats()
+	assert.Equal(t, uint64(1), stats.Requests)
+	assert.Equal(t, uint64(1), stats.Answered)
+	assert.Zero(t, stats.Failed)
+
+	// Responses to requests the relay did not send are left to the handler.
+	assert.False(t, r.backend.DeliverResponse(enode.ID{2}, 0x04, pending[0].RequestID, []byte{0xc0}))
+}
+
+func TestAPIQueueStats(t *testing.T) {
+	r := newTestRelay()
+	defer r.router.Stop()
+	api := NewAPI(r)
+
+	stats := api.QueueStats()
+	assert.Equal(t, cap(r.backend.relayQueue), stats.RelayQueue.Cap)
+	assert.Empty(t, stats.PeerQueues)
+
+	assert.Nil(t, r.router.getQueue(enode.ID{1}), "queue created for unknown peer")
+	r.backend.AddPeer(newTestRelayPeer(1, nil))
+	r.router.getQueue(enode.ID{1})
+	stats = api.QueueStats()
+	require.Contains(t, stats.PeerQueues, enode.ID{1})
+	assert.Equal(t, defaultQueueLimit, stats.PeerQueues[enode.ID{1}].Limit)
+}
+
+func TestAPIEventsSubscription(t *testing.T) {
+	r := newTestRelay()
+	server := rpc.NewServer()
+	defer server.Stop()
+	require.NoError(t, server.RegisterName("relay", NewAPI(r)))
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events := make(chan Event, 8)
+	sub, err := client.Subscribe(ctx, "relay", events, "events")
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	// Wait for the subscription to be attached to the event feed.
+	for i := 0; i < 100; i++ {
+		if r.backend.events.Send(Event{}) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	r.backend.AddPeer(newTestRelayPeer(1, nil))
+	r.backend.RemovePeer(enode.ID{1})
+
+	var got []string
+	for len(got) < 2 {
+		select {
+		case ev := <-events:
+			if ev.Type == "" {
+				continue
+			}
+			require.NotNil(t, ev.Peer)
+			assert.Equal(t, enode.ID{1}, *ev.Peer)
+			got = append(got, ev.Type)
+		case err := <-sub.Err():
+			t.Fatal(err)
+		case <-ctx.Done():
+			t.Fatal("timeout waiting for events")
+		}
+	}
+	assert.Equal(t, []string{EventPeerAdded, EventPeerRemoved}, got)
+}
+
+func TestAPIBan(t *testing.T) {
+	r := newTestRelay()
+	api := NewAPI(r)
+	r.backend.AddPeer(newTestRelayPeer(1, nil))
+
+	_, err := api.Ban(enode.ID{1}, strPtr("-1h"), nil)
+	assert.Error(t, err, "negative duration accepted")
+	ban, err := api.Ban(enode.ID{1}, strPtr("24h"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 24*time.Hour, ban.Until.Sub(ban.Since))
+	assert.True(t, r.backend.IsBanned(enode.ID{1}))
+	assert.Len(t, api.Bans(), 1)
+
+	assert.True(t, api.Unban(enode.ID{1}))
+	assert.False(t, api.Unban(enode.ID{1}))
+	assert.Empty(t, api.Bans())
+
+	r.backend.ReportPeer(enode.ID{1}, MisbehaviourMismatch)
+	assert.InDelta(t, -misbehaviourPenalty[MisbehaviourMismatch], api.Peers()[0].Score, 0.1)
+}
+
+func strPtr(s string) *string { return &s }
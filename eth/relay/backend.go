@@ -17,13 +17,22 @@
 package relay
 
 import (
+	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 // Backend is the relay backend that manages peer connections and message routing.
@@ -35,6 +44,7 @@ type Backend struct {
 	chainConfig *params.ChainConfig
 	forkID forkid.ID
 	blockRange BlockRange
+	rangeLock sync.RWMutex
 
 	// Peer management
 	peers *RelayPeerSet
@@ -42,7 +52,64 @@ type Backend struct {
 
 	// Message relay queue
 	relayQueue chan *RelayMessage
+	lastReceived atomic.Int64 // unix nanoseconds of the last message received for relaying
 	quit chan struct{}
+
+	// Set by Drain, new peers, requests and gossip are refused
+	draining atomic.Bool
+
+	// Relay counters since startup, unlike peer stats they survive disconnects
+	totals relayCounters
+
+	// Peer scores and ban list
+	reputation *Reputation
+
+	// Egress and ingress bandwidth limits
+	shaper *bandwidthShaper
+
+	// Peer and relay events for relay_subscribe
+	events event.Feed
+
+	// Transactions recently received from peers
+	pending *pendingTxs
+
+	// Wire capture of peer messages, nil if disabled
+	capture *capture
+
+	// Checks block headers returned by peers, nil without the light client
+	verifyHeaders func([]*types.Header) error
+
+	// Request proxy waiting for the responses of peers, nil until it is created
+	proxy atomic.Pointer[RequestProxy]
+
+	// Hooks run after a peer is unregistered, and when peers send or
+	// announce transactions
+	removeHooks []func(enode.ID)
+	seenHooks []func([]common.Hash)
+	hooksLock sync.Mutex
+}
+
+// Event types sent on the relay event feed.
+const (
+	EventPeerAdded      = "peerAdded"
+	EventPeerRemoved    = "peerRemoved"
+	EventBlockRange     = "blockRange"
+	EventRequestTimeout = "requestTimeout"
+	EventPeerBanned     = "peerBanned"
+	EventPeerUnbanned   = "peerUnbanned"
+	EventPeerEvicted    = "peerEvicted"
+	EventDraining       = "draining"
+)
+
+// Event is a peer or relay event.
+type Event struct {
+	Type       string      `json:"type"`
+	Time       time.Time   `json:"time"`
+	Peer       *enode.ID   `json:"peer,omitempty"`
+	Transport  string      `json:"transport,omitempty"`
+	BlockRange *BlockRange `json:"blockRange,omitempty"`
+	RequestID  uint64      `json:"requestId,omitempty"`
+	Reason     string      `json:"reason,omitempty"`
 }
 
 // RelayMessage represents a message to be forwarded.
@@ -56,7 +123,7 @@ type RelayMessage struct {
 
 // NewBackend creates a new relay backend.
 func NewBackend(config *Config, p2pServer *p2p.Server) *Backend {
-	return &Backend{
+	b := &Backend{
 		config: config,
 		p2pServer: p2pServer,
 		networkID: config.NetworkID,
@@ -65,9 +132,14 @@ func NewBackend(config *Config, p2pServer *p2p.Server) *Backend {
 		forkID: config.ForkID,
 		blockRange: config.BlockRange,
 		peers: NewRelayPeerSet(),
+		reputation: NewReputation(config.BanThreshold, config.BanDuration, config.BanList),
+		shaper: newBandwidthShaper(config.Bandwidth, mclock.System{}),
 		relayQueue: make(chan *RelayMessage, 1000),
+		pending: newPendingTxs(),
 		quit: make(chan struct{}),
 	}
+	b.onPeerRemoved(b.pending.removePeer)
+	return b
 }
 
 // GetNetworkID returns the network ID.
@@ -92,23 +164,296 @@ func (b *Backend) GetForkID() forkid.ID {
 
 // GetBlockRange returns the block range.
 func (b *Backend) GetBlockRange() BlockRange {
+	b.rangeLock.RLock()
+	defer b.rangeLock.RUnlock()
 	return b.blockRange
 }
 
-// sendToPeer sends a raw message to a peer via P2P.
-// This will be connected to actual protocol handlers through the relay service.
-func (b *Backend) sendToPeer(peerID enode.ID, msgCode uint64, payload []byte) error {
-	// Queue message for forwarding through protocol handlers
-	select {
-	case b.relayQueue <- &RelayMessage{
-		From:    peerID,
-		MsgCode: msgCode,
-		Payload: payload,
-	}:
+// SetBlockRange updates the block range announced to peers. The new range is
+// sent to all connected peers that support range updates.
+func (b *Backend) SetBlockRange(r BlockRange) error {
+	if r.EarliestBlock > r.LatestBlock {
+		return errors.New("earliest block is after latest block")
+	}
+	if r.LatestBlockHash == (common.Hash{}) {
+		return errors.New("latest block hash is empty")
+	}
+	b.rangeLock.Lock()
+	b.blockRange = r
+	b.rangeLock.Unlock()
+
+	for _, peer := range b.Peers() {
+		if peer.conn == nil {
+			continue
+		}
+		if err := peer.conn.SendBlockRange(r); err != nil {
+			log.Debug("Failed to send block range update", "peer", peer.ID, "err", err)
+		}
+	}
+	b.events.Send(Event{Type: EventBlockRange, Time: time.Now(), BlockRange: &r})
+	return nil
+}
+
+// AddPeer registers a peer that completed the protocol handshake. It returns
+// false if the peer is already known.
+func (b *Backend) AddPeer(peer *RelayPeer) bool {
+	b.peersLock.Lock()
+	added := b.peers.Add(peer)
+	b.peersLock.Unlock()
+	if added {
+		id := peer.ID
+		b.events.Send(Event{Type: EventPeerAdded, Time: time.Now(), Peer: &id, Transport: peer.Transport})
+	}
+	return added
+}
+
+// RemovePeer unregisters a disconnected peer.
+func (b *Backend) RemovePeer(id enode.ID) {
+	b.peersLock.Lock()
+	peer := b.peers.Get(id)
+	b.peers.Remove(id)
+	b.peersLock.Unlock()
+	b.shaper.removePeer(id)
+	b.hooksLock.Lock()
+	hooks := b.removeHooks
+	b.hooksLock.Unlock()
+	for _, hook := range hooks {
+		hook(id)
+	}
+	if peer != nil {
+		b.events.Send(Event{Type: EventPeerRemoved, Time: time.Now(), Peer: &id, Transport: peer.Transport})
+	}
+}
+
+// onPeerRemoved registers a function called with the ID of every peer removed
+// by RemovePeer.
+func (b *Backend) onPeerRemoved(fn func(enode.ID)) {
+	b.hooksLock.Lock()
+	defer b.hooksLock.Unlock()
+	b.removeHooks = append(b.removeHooks, fn)
+}
+
+// onTransactionsSeen registers a function called with the hashes of the valid
+// transactions peers send and of the transactions they announce.
+func (b *Backend) onTransactionsSeen(fn func([]common.Hash)) {
+	b.hooksLock.Lock()
+	defer b.hooksLock.Unlock()
+	b.seenHooks = append(b.seenHooks, fn)
+}
+
+func (b *Backend) transactionsSeen(hashes []common.Hash) {
+	b.hooksLock.Lock()
+	hooks := b.seenHooks
+	b.hooksLock.Unlock()
+	for _, hook := range hooks {
+		hook(hashes)
+	}
+}
+
+// Peers returns all registered peers.
+func (b *Backend) Peers() []*RelayPeer {
+	b.peersLock.RLock()
+	defer b.peersLock.RUnlock()
+	return b.peers.All()
+}
+
+// SubscribeEvents subscribes to peer and relay events.
+func (b *Backend) SubscribeEvents(ch chan<- Event) event.Subscription {
+	return b.events.Subscribe(ch)
+}
+
+// peerStats returns the counters of a registered peer, or nil.
+func (b *Backend) peerStats(id enode.ID) *peerStats {
+	if peer := b.peers.Get(id); peer != nil {
+		return &peer.stats
+	}
+	return nil
+}
+
+// ReportPeer lowers the score of a misbehaving peer. Peers dropping below the
+// ban threshold are banned and disconnected.
+func (b *Backend) ReportPeer(id enode.ID, m Misbehaviour) {
+	log.Debug("Peer misbehaved", "peer", id, "reason", m)
+	if b.reputation.Penalize(id, m) {
+		b.peerBanned(id, m.String())
+	}
+}
+
+// BanPeer bans a peer for the given duration, or the configured ban duration
+// if zero, and disconnects it.
+func (b *Backend) BanPeer(id enode.ID, reason string, duration time.Duration) *Ban {
+	ban := b.reputation.Ban(id, reason, duration)
+	b.peerBanned(id, reason)
+	return ban
+}
+
+// UnbanPeer lifts the ban of a peer. It returns false if the peer was not
+// banned.
+func (b *Backend) UnbanPeer(id enode.ID) bool {
+	if !b.reputation.Unban(id) {
+		return false
+	}
+	log.Info("Lifted peer ban", "peer", id)
+	b.events.Send(Event{Type: EventPeerUnbanned, Time: time.Now(), Peer: &id})
+	return true
+}
+
+// IsBanned reports whether a peer is currently banned.
+func (b *Backend) IsBanned(id enode.ID) bool {
+	return b.reputation.IsBanned(id)
+}
+
+// Bandwidth returns the bandwidth limits and their current state.
+func (b *Backend) Bandwidth() *BandwidthInfo {
+	return b.shaper.info()
+}
+
+// SetBandwidth changes the bandwidth limits.
+func (b *Backend) SetBandwidth(limits BandwidthLimits) {
+	b.shaper.setLimits(limits)
+	log.Info("Updated bandwidth limits", "egress", limits.Egress, "ingress", limits.Ingress,
+		"peerEgress", limits.PeerEgress, "peerIngress", limits.PeerIngress)
+}
+
+// Reputation returns the peer scores and ban list.
+func (b *Backend) Reputation() *Reputation {
+	return b.reputation
+}
+
+// CheckTransactions validates transactions received from a peer and returns
+// the number of invalid ones. A message carrying invalid transactions counts
+// as one offence.
+func (b *Backend) CheckTransactions(from enode.ID, txs []*types.Transaction) int {
+	var signer types.Signer
+	if b.chainConfig != nil {
+		signer = types.LatestSigner(b.chainConfig)
+	}
+	b.totals.transactions.Add(uint64(len(txs)))
+	invalid := 0
+	valid := make([]*types.Transaction, 0, len(txs))
+	for _, tx := range txs {
+		if err := validateTransaction(signer, tx); err != nil {
+			log.Trace("Invalid transaction from peer", "peer", from, "hash", tx.Hash(), "err", err)
+			invalid++
+			continue
+		}
+		valid = append(valid, tx)
+	}
+	if invalid > 0 {
+		b.ReportPeer(from, MisbehaviourInvalidTx)
+	}
+	b.pending.add(from, valid)
+	hashes := make([]common.Hash, len(valid))
+	for i, tx := range valid {
+		hashes[i] = tx.Hash()
+	}
+	b.transactionsSeen(hashes)
+	return invalid
+}
+
+// checkHeaders verifies the block headers of a BlockHeaders response against
+// the chain verified by the light client. Decode failures wrap errDecode.
+func (b *Backend) checkHeaders(payload []byte) error {
+	if b.verifyHeaders == nil {
 		return nil
-	case <-b.quit:
+	}
+	var headers []*types.Header
+	if err := rlp.DecodeBytes(payload, &headers); err != nil {
+		return fmt.Errorf("%w: %v", errDecode, err)
+	}
+	return b.verifyHeaders(headers)
+}
+
+// TransactionsAnnounced records the transaction hashes announced by a peer.
+func (b *Backend) TransactionsAnnounced(from enode.ID, hashes []common.Hash) {
+	b.pending.announced(from, len(hashes))
+	b.transactionsSeen(hashes)
+}
+
+func (b *Backend) peerBanned(id enode.ID, reason string) {
+	log.Info("Banned peer", "peer", id, "reason", reason)
+	if peer := b.peers.Get(id); peer != nil && peer.Peer != nil {
+		peer.Peer.Disconnect(p2p.DiscUselessPeer)
+	}
+	b.events.Send(Event{Type: EventPeerBanned, Time: time.Now(), Peer: &id, Reason: reason})
+}
+
+// evictSlowPeer disconnects a peer whose outbound queue stayed full for too
+// long.
+func (b *Backend) evictSlowPeer(id enode.ID, backlog time.Duration) {
+	log.Info("Evicting slow peer", "peer", id, "backlog", backlog)
+	if peer := b.peers.Get(id); peer != nil && peer.Peer != nil {
+		peer.Peer.Disconnect(p2p.DiscUselessPeer)
+	}
+	b.events.Send(Event{Type: EventPeerEvicted, Time: time.Now(), Peer: &id, Reason: "outbound queue backlogged"})
+}
+
+// sendToPeer writes a message to a peer over its connection. Writes block
+// while the peer is slow to read, the router sends from a queue per peer.
+func (b *Backend) sendToPeer(peerID enode.ID, msgCode uint64, payload []byte) error {
+	if !b.shaper.allowEgress(peerID, msgCode, len(payload)) {
+		if stats := b.peerStats(peerID); stats != nil {
+			stats.throttled.Add(1)
+		}
+		return errThrottled
+	}
+	peer := b.peers.Get(peerID)
+	if peer == nil || peer.conn == nil {
 		return ErrPeerDisconnected
 	}
+	return peer.conn.Send(msgCode, payload)
+}
+
+// sendPacket sends a request or response to a peer. The payload is the packet
+// without its request ID, which is prepended.
+func (b *Backend) sendPacket(peerID enode.ID, msgCode uint64, requestID uint64, payload []byte) error {
+	packet, err := rlp.EncodeToBytes([]interface{}{requestID, rlp.RawValue(payload)})
+	if err != nil {
+		return err
+	}
+	return b.sendToPeer(peerID, msgCode, packet)
+}
+
+// DeliverResponse passes the response to a request sent by the relay to the
+// request proxy. The payload is the response without its request ID. It
+// returns false if the relay is not waiting for the response, the protocol
+// handler must process it then.
+func (b *Backend) DeliverResponse(from enode.ID, msgCode uint64, requestID uint64, payload []byte) bool {
+	proxy := b.proxy.Load()
+	if proxy == nil {
+		return false
+	}
+	return !errors.Is(proxy.HandleResponse(from, msgCode, requestID, payload), ErrUnknownRequest)
+}
+
+// Receive queues a message received from a peer for the relay loop, without
+// blocking the peer. Messages are charged to the ingress limits as they are
+// read. It returns false if the message is not relayed, because the relay is
+// draining, the message is over the ingress limits or the queue is full.
+func (b *Backend) Receive(msg *RelayMessage) bool {
+	b.lastReceived.Store(time.Now().UnixNano())
+	if b.Draining() {
+		return false
+	}
+	if !b.shaper.allowIngress(msg.From, msg.MsgCode, len(msg.Payload)) {
+		if stats := b.peerStats(msg.From); stats != nil {
+			stats.throttled.Add(1)
+		}
+		log.Trace("Dropped message over ingress limit",
+			"from", msg.From.String()[:16]+"...",
+			"code", msgCodeToString(msg.MsgCode),
+			"size", len(msg.Payload))
+		return false
+	}
+	select {
+	case b.relayQueue <- msg:
+		return true
+	default:
+		log.Trace("Dropped message, relay queue full",
+			"from", msg.From.String()[:16]+"...",
+			"code", msgCodeToString(msg.MsgCode))
+		return false
+	}
 }
 
 // GetRelayQueue returns the relay message queue (for use by relay service).
@@ -116,6 +461,50 @@ func (b *Backend) GetRelayQueue() <-chan *RelayMessage {
 	return b.relayQueue
 }
 
+// RelayStats contains the relay counters since startup.
+type RelayStats struct {
+	Relayed      uint64 `json:"relayed"`      // messages forwarded to peers
+	Transactions uint64 `json:"transactions"` // transactions received from peers
+	Requests     uint64 `json:"requests"`     // requests proxied to peers
+	Answered     uint64 `json:"answered"`     // proxied requests answered
+	Failed       uint64 `json:"failed"`       // proxied requests not sent or not answered in time
+}
+
+type relayCounters struct {
+	relayed, transactions, requests, answered, failed atomic.Uint64
+}
+
+// Stats returns the relay counters since startup.
+func (b *Backend) Stats() RelayStats {
+	return RelayStats{
+		Relayed:      b.totals.relayed.Load(),
+		Transactions: b.totals.transactions.Load(),
+		Requests:     b.totals.requests.Load(),
+		Answered:     b.totals.answered.Load(),
+		Failed:       b.totals.failed.Load(),
+	}
+}
+
+// Draining reports whether the relay is draining before shutdown. New peers
+// must be refused while it is.
+func (b *Backend) Draining() bool {
+	return b.draining.Load()
+}
+
+// RelayQueue returns the fill level of the relay message queue.
+func (b *Backend) RelayQueue() QueueInfo {
+	return QueueInfo{Len: len(b.relayQueue), Cap: cap(b.relayQueue)}
+}
+
+// LastReceived returns when a peer last sent a message for relaying, or the
+// zero time if none has been received yet.
+func (b *Backend) LastReceived() time.Time {
+	if ns := b.lastReceived.Load(); ns != 0 {
+		return time.Unix(0, ns)
+	}
+	return time.Time{}
+}
+
 // Stop stops the backend.
 func (b *Backend) Stop() {
 	close(b.quit)
@@ -0,0 +1,266 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// Bandwidth shaping.
+//
+// Egress and ingress bytes are limited by token buckets, one global bucket per
+// direction and one per peer and direction. A message passes if every bucket
+// it is charged to admits it. Traffic is treated by class:
+//
+//   - Requests and responses always pass. They are charged to the buckets and
+//     may leave them in debt, which the other classes then have to wait out.
+//   - Announcements pass if the buckets hold enough tokens for them.
+//   - Other gossip (full transactions, blocks) additionally has to leave a
+//     reserve in the buckets, so announcements win when bandwidth is short.
+//
+// Gossip that does not pass is dropped, never queued.
+
+const (
+	minBucketBurst = 1024 * 1024 // minimum burst size in bytes
+	gossipReserve  = 0.25        // fraction of the burst kept for announcements
+)
+
+var errThrottled = errors.New("bandwidth limit exceeded")
+
+// BandwidthLimits are the bandwidth limits of the relay in bytes per second.
+// Zero means unlimited.
+type BandwidthLimits struct {
+	Egress      uint64 `json:"egress"`      // global egress rate
+	Ingress     uint64 `json:"ingress"`     // global ingress rate
+	PeerEgress  uint64 `json:"peerEgress"`  // egress rate to each peer
+	PeerIngress uint64 `json:"peerIngress"` // ingress rate from each peer
+}
+
+type trafficClass int
+
+const (
+	classRequest trafficClass = iota
+	classAnnounce
+	classGossip
+)
+
+// classify returns the traffic class of an eth message.
+func classify(msgCode uint64) trafficClass {
+	switch msgCode {
+	case 0x01, newPooledTransactionHashesMsg: // NewBlockHashes, NewPooledTransactionHashes
+		return classAnnounce
+	case transactionsMsg, 0x07: // Transactions, NewBlock
+		return classGossip
+	default:
+		return classRequest
+	}
+}
+
+// tokenBucket is a token bucket holding up to burst bytes, refilled at rate
+// bytes per second. The level may drop to -burst when traffic is charged
+// unconditionally.
+type tokenBucket struct {
+	rate, burst float64
+	tokens      float64
+	last        mclock.AbsTime
+}
+
+func newTokenBucket(rate uint64, now mclock.AbsTime) *tokenBucket {
+	if rate == 0 {
+		return nil
+	}
+	b := &tokenBucket{rate: float64(rate), burst: float64(max(rate, minBucketBurst)), last: now}
+	b.tokens = b.burst
+	return b
+}
+
+func (b *tokenBucket) refill(now mclock.AbsTime) {
+	elapsed := float64(now-b.last) / 1e9
+	b.tokens = min(b.tokens+elapsed*b.rate, b.burst)
+	b.last = now
+}
+
+// admits reports whether the bucket holds enough tokens for size bytes of
+// traffic of the given class.
+func (b *tokenBucket) admits(class trafficClass, size float64) bool {
+	switch class {
+	case classRequest:
+		return true
+	case classAnnounce:
+		return b.tokens >= size
+	default:
+		return b.tokens-size >= b.burst*gossipReserve
+	}
+}
+
+// bandwidthShaper applies the bandwidth limits.
+type bandwidthShaper struct {
+	clock mclock.Clock
+
+	lock    sync.Mutex
+	limits  BandwidthLimits
+	egress  *tokenBucket // nil if unlimited
+	ingress *tokenBucket // nil if unlimited
+	peers   map[enode.ID]*peerBuckets
+
+	egressDropped, ingressDropped uint64
+}
+
+type peerBuckets struct {
+	egress, ingress *tokenBucket
+}
+
+func newBandwidthShaper(limits BandwidthLimits, clock mclock.Clock) *bandwidthShaper {
+	s := &bandwidthShaper{clock: clock, peers: make(map[enode.ID]*peerBuckets)}
+	s.setLimits(limits)
+	return s
+}
+
+// setLimits changes the limits. Buckets of changed limits start out full.
+func (s *bandwidthShaper) setLimits(limits BandwidthLimits) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.clock.Now()
+	if limits.Egress != s.limits.Egress {
+		s.egress = newTokenBucket(limits.Egress, now)
+	}
+	if limits.Ingress != s.limits.Ingress {
+		s.ingress = newTokenBucket(limits.Ingress, now)
+	}
+	if limits.PeerEgress != s.limits.PeerEgress || limits.PeerIngress != s.limits.PeerIngress {
+		s.peers = make(map[enode.ID]*peerBuckets)
+	}
+	s.limits = limits
+}
+
+// allowEgress reports whether a message may be sent to a peer, and charges it
+// to the egress buckets if so.
+func (s *bandwidthShaper) allowEgress(peer enode.ID, msgCode uint64, size int) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var peerBucket *tokenBucket
+	if s.limits.PeerEgress > 0 {
+		peerBucket = s.peerBuckets(peer).egress
+	}
+	if !s.admit(msgCode, size, s.egress, peerBucket) {
+		s.egressDropped++
+		return false
+	}
+	return true
+}
+
+// allowIngress reports whether a message received from a peer may be relayed,
+// and charges it to the ingress buckets if so.
+func (s *bandwidthShaper) allowIngress(peer enode.ID, msgCode uint64, size int) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var peerBucket *tokenBucket
+	if s.limits.PeerIngress > 0 {
+		peerBucket = s.peerBuckets(peer).ingress
+	}
+	if !s.admit(msgCode, size, s.ingress, peerBucket) {
+		s.ingressDropped++
+		return false
+	}
+	return true
+}
+
+// admit charges a message to the given buckets if all of them admit it. Nil
+// buckets are unlimited. It must be called with the lock held.
+func (s *bandwidthShaper) admit(msgCode uint64, size int, buckets ...*tokenBucket) bool {
+	var (
+		now   = s.clock.Now()
+		class = classify(msgCode)
+		n     = float64(size)
+	)
+	for _, b := range buckets {
+		if b == nil {
+			continue
+		}
+		b.refill(now)
+		if !b.admits(class, n) {
+			return false
+		}
+	}
+	for _, b := range buckets {
+		if b != nil {
+			b.tokens = max(b.tokens-n, -b.burst)
+		}
+	}
+	return true
+}
+
+// peerBuckets returns the buckets of a peer, creating them if necessary. It
+// must be called with the lock held.
+func (s *bandwidthShaper) peerBuckets(peer enode.ID) *peerBuckets {
+	pb := s.peers[peer]
+	if pb == nil {
+		now := s.clock.Now()
+		pb = &peerBuckets{
+			egress:  newTokenBucket(s.limits.PeerEgress, now),
+			ingress: newTokenBucket(s.limits.PeerIngress, now),
+		}
+		s.peers[peer] = pb
+	}
+	return pb
+}
+
+// removePeer drops the buckets of a disconnected peer.
+func (s *bandwidthShaper) removePeer(peer enode.ID) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.peers, peer)
+}
+
+// BandwidthInfo describes the bandwidth limits and their current state.
+type BandwidthInfo struct {
+	Limits         BandwidthLimits `json:"limits"`
+	EgressTokens   *float64        `json:"egressTokens"`   // bytes available in the global egress bucket
+	IngressTokens  *float64        `json:"ingressTokens"`  // bytes available in the global ingress bucket
+	EgressDropped  uint64          `json:"egressDropped"`  // messages not sent due to the limits
+	IngressDropped uint64          `json:"ingressDropped"` // received messages not relayed due to the limits
+}
+
+func (s *bandwidthShaper) info() *BandwidthInfo {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.clock.Now()
+	info := &BandwidthInfo{
+		Limits:         s.limits,
+		EgressDropped:  s.egressDropped,
+		IngressDropped: s.ingressDropped,
+	}
+	if s.egress != nil {
+		s.egress.refill(now)
+		tokens := s.egress.tokens
+		info.EgressTokens = &tokens
+	}
+	if s.ingress != nil {
+		s.ingress.refill(now)
+		tokens := s.ingress.tokens
+		info.IngressTokens = &tokens
+	}
+	return info
+}
@@ -0,0 +1,95 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testRequestMsg = 0x04 // BlockHeaders
+	testAnnounce   = newPooledTransactionHashesMsg
+	testGossip     = transactionsMsg
+)
+
+func TestBandwidthPriorities(t *testing.T) {
+	clock := new(mclock.Simulated)
+	s := newBandwidthShaper(BandwidthLimits{Egress: minBucketBurst}, clock)
+	peer := enode.ID{1}
+
+	// Gossip may use the bucket down to the reserve.
+	gossip := minBucketBurst / 4
+	for i := 0; i < 3; i++ {
+		require.True(t, s.allowEgress(peer, testGossip, gossip), "gossip %d dropped", i)
+	}
+	assert.False(t, s.allowEgress(peer, testGossip, 1), "gossip took the reserve")
+	assert.True(t, s.allowEgress(peer, testAnnounce, gossip), "announcement dropped with reserve left")
+	assert.False(t, s.allowEgress(peer, testAnnounce, 1), "announcement passed an empty bucket")
+
+	// Responses always pass and leave the bucket in debt.
+	assert.True(t, s.allowEgress(peer, testRequestMsg, minBucketBurst/2))
+	clock.Run(400 * time.Millisecond)
+	assert.False(t, s.allowEgress(peer, testAnnounce, 1), "announcement passed while in debt")
+	clock.Run(200 * time.Millisecond)
+	assert.True(t, s.allowEgress(peer, testAnnounce, 1000))
+
+	info := s.info()
+	assert.Equal(t, uint64(3), info.EgressDropped)
+	assert.Nil(t, info.IngressTokens, "unlimited ingress has a bucket")
+}
+
+func TestBandwidthPerPeer(t *testing.T) {
+	clock := new(mclock.Simulated)
+	s := newBandwidthShaper(BandwidthLimits{PeerIngress: minBucketBurst}, clock)
+	a, b := enode.ID{1}, enode.ID{2}
+
+	require.True(t, s.allowIngress(a, testAnnounce, minBucketBurst))
+	assert.False(t, s.allowIngress(a, testAnnounce, 1), "peer limit not applied")
+	assert.True(t, s.allowIngress(b, testAnnounce, minBucketBurst), "peers share a bucket")
+	assert.True(t, s.allowEgress(a, testGossip, 10*minBucketBurst), "egress limited")
+
+	// A disconnected peer starts over with a full bucket.
+	s.removePeer(a)
+	assert.True(t, s.allowIngress(a, testAnnounce, minBucketBurst))
+
+	// Lifting the limit at runtime.
+	s.setLimits(BandwidthLimits{})
+	assert.True(t, s.allowIngress(a, testGossip, 10*minBucketBurst))
+}
+
+func TestBandwidthSendToPeer(t *testing.T) {
+	r := newTestRelay()
+	defer r.router.Stop()
+	api := NewAPI(r)
+	conn := &testPeerConn{}
+	r.backend.AddPeer(newTestRelayPeer(1, conn))
+
+	info := api.SetBandwidth(BandwidthLimits{PeerEgress: minBucketBurst})
+	assert.Equal(t, uint64(minBucketBurst), info.Limits.PeerEgress)
+
+	payload := make([]byte, minBucketBurst)
+	assert.ErrorIs(t, r.backend.sendToPeer(enode.ID{1}, testGossip, payload), errThrottled)
+	assert.NoError(t, r.backend.sendToPeer(enode.ID{1}, testRequestMsg, payload))
+	assert.Equal(t, uint64(1), api.Peers()[0].Stats.Throttled)
+	assert.Len(t, conn.msgs, 1, "message not written to the connection")
+}
@@ -0,0 +1,285 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Transaction broadcast scheduling.
+//
+// Relayed right away, a transaction leaves the relay on all connections at
+// once, and an observer connected over Tor and clearnet can correlate the
+// timing. With a broadcast delay, transactions and announcements are held per
+// peer and sent in rounds. Every peer has its own round timer with
+// exponentially distributed intervals, so rounds follow a Poisson process and
+// no two peers see the same timing. The announcements of a round are merged
+// into NewPooledTransactionHashes messages. Transactions broadcast by the
+// relay itself reach clearnet peers only after all Tor and I2P peers got
+// them, so a clearnet observer never sees them first.
+
+// maxAnnounceBatch is the maximum number of hashes in a merged announcement,
+// the limit of the transaction fetcher.
+const maxAnnounceBatch = 4096
+
+// isTxBroadcast reports whether a relayed message is a transaction broadcast.
+func isTxBroadcast(msgCode uint64) bool {
+	return msgCode == transactionsMsg || msgCode == newPooledTransactionHashesMsg
+}
+
+// broadcastScheduler holds transaction broadcasts until the round of their
+// target peer is due.
+type broadcastScheduler struct {
+	delay   time.Duration // mean time between rounds, 0 sends broadcasts right away
+	clock   mclock.Clock
+	enqueue func(*QueuedMessage) // hands a message to the outbound queue
+
+	lock  sync.Mutex
+	rand  *rand.Rand
+	peers map[enode.ID]*peerRound // peers with a pending round
+	held  []*heldBroadcast        // local broadcasts waiting for anonymous peers
+}
+
+// peerRound is the pending broadcast round of a peer.
+type peerRound struct {
+	txs      [][]byte // Transactions payloads
+	announce newPooledTransactionHashesPacket
+	timer    mclock.Timer // nil if the round is due right away
+}
+
+// heldBroadcast is a local broadcast that clearnet peers get once all
+// anonymous peers got it.
+type heldBroadcast struct {
+	msgCode  uint64
+	payload  []byte
+	announce *newPooledTransactionHashesPacket
+	waiting  map[enode.ID]struct{} // anonymous peers whose round is pending
+	clearnet []enode.ID
+}
+
+func newBroadcastScheduler(delay time.Duration, clock mclock.Clock, enqueue func(*QueuedMessage)) *broadcastScheduler {
+	return &broadcastScheduler{
+		delay:   delay,
+		clock:   clock,
+		enqueue: enqueue,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		peers:   make(map[enode.ID]*peerRound),
+	}
+}
+
+// schedule adds a validated transaction broadcast to the rounds of peers.
+// Local broadcasts are sent by the relay itself.
+func (s *broadcastScheduler) schedule(msgCode uint64, payload []byte, peers []*RelayPeer, local bool) {
+	var announce *newPooledTransactionHashesPacket
+	if msgCode == newPooledTransactionHashesMsg {
+		announce = new(newPooledTransactionHashesPacket)
+		if err := rlp.DecodeBytes(payload, announce); err != nil {
+			log.Error("Failed to decode validated announcement", "err", err)
+			return
+		}
+	}
+	var anonymous, clearnet []enode.ID
+	for _, peer := range peers {
+		if peer.Transport == "onion" || peer.Transport == "i2p" {
+			anonymous = append(anonymous, peer.ID)
+		} else {
+			clearnet = append(clearnet, peer.ID)
+		}
+	}
+
+	s.lock.Lock()
+	var due []enode.ID
+	if local && len(anonymous) > 0 && len(clearnet) > 0 {
+		due = s.add(anonymous, msgCode, payload, announce)
+		h := &heldBroadcast{
+			msgCode:  msgCode,
+			payload:  payload,
+			announce: announce,
+			waiting:  make(map[enode.ID]struct{}, len(anonymous)),
+			clearnet: clearnet,
+		}
+		for _, id := range anonymous {
+			h.waiting[id] = struct{}{}
+		}
+		s.held = append(s.held, h)
+	} else {
+		due = s.add(append(anonymous, clearnet...), msgCode, payload, announce)
+	}
+	s.lock.Unlock()
+
+	s.flush(due...)
+}
+
+// add appends a broadcast to the rounds of peers and starts their round timers.
+// It returns the peers whose round is due right away. It must be called with
+// the lock held.
+func (s *broadcastScheduler) add(ids []enode.ID, msgCode uint64, payload []byte, announce *newPooledTransactionHashesPacket) []enode.ID {
+	var due []enode.ID
+	for _, id := range ids {
+		round := s.peers[id]
+		if round == nil {
+			round = new(peerRound)
+			s.peers[id] = round
+		}
+		if announce != nil {
+			round.announce.Types = append(round.announce.Types, announce.Types...)
+			round.announce.Sizes = append(round.announce.Sizes, announce.Sizes...)
+			round.announce.Hashes = append(round.announce.Hashes, announce.Hashes...)
+		} else {
+			round.txs = append(round.txs, payload)
+		}
+		switch {
+		case s.delay == 0:
+			due = append(due, id)
+		case round.timer == nil:
+			wait := time.Duration(s.rand.ExpFloat64() * float64(s.delay))
+			round.timer = s.clock.AfterFunc(wait, func() { s.flush(id) })
+		}
+	}
+	return due
+}
+
+// flush sends the rounds of peers, and the rounds of clearnet peers that
+// become due when held broadcasts are released.
+func (s *broadcastScheduler) flush(ids ...enode.ID) {
+	var msgs []*QueuedMessage
+	s.lock.Lock()
+	for len(ids) > 0 {
+		id := ids[0]
+		ids = ids[1:]
+		msgs = append(msgs, s.pop(id)...)
+		ids = append(ids, s.release(id)...)
+	}
+	s.lock.Unlock()
+
+	for _, msg := range msgs {
+		s.enqueue(msg)
+	}
+}
+
+// pop removes the round of a peer and returns its messages. Transactions are
+// sent as relayed, announcements are merged. It must be called with the lock
+// held.
+func (s *broadcastScheduler) pop(id enode.ID) []*QueuedMessage {
+	round := s.peers[id]
+	if round == nil {
+		return nil
+	}
+	delete(s.peers, id)
+	if round.timer != nil {
+		round.timer.Stop()
+	}
+	msgs := make([]*QueuedMessage, 0, len(round.txs)+1)
+	for _, payload := range round.txs {
+		msgs = append(msgs, &QueuedMessage{MsgCode: transactionsMsg, Payload: payload, ToPeer: id})
+	}
+	for ann := round.announce; len(ann.Hashes) > 0; {
+		n := min(len(ann.Hashes), maxAnnounceBatch)
+		payload, err := rlp.EncodeToBytes(&newPooledTransactionHashesPacket{
+			Types:  ann.Types[:n],
+			Sizes:  ann.Sizes[:n],
+			Hashes: ann.Hashes[:n],
+		})
+		if err != nil {
+			log.Error("Failed to encode announcement", "err", err)
+			break
+		}
+		msgs = append(msgs, &QueuedMessage{MsgCode: newPooledTransactionHashesMsg, Payload: payload, ToPeer: id})
+		ann.Types, ann.Sizes, ann.Hashes = ann.Types[n:], ann.Sizes[n:], ann.Hashes[n:]
+	}
+	return msgs
+}
+
+// release marks the held broadcasts as sent to a peer. Broadcasts all
+// anonymous peers got are added to the rounds of the clearnet peers, the
+// peers whose round is due right away are returned. It must be called with
+// the lock held.
+func (s *broadcastScheduler) release(id enode.ID) []enode.ID {
+	var due []enode.ID
+	held := s.held[:0]
+	for _, h := range s.held {
+		delete(h.waiting, id)
+		if len(h.waiting) > 0 {
+			held = append(held, h)
+			continue
+		}
+		due = append(due, s.add(h.clearnet, h.msgCode, h.payload, h.announce)...)
+	}
+	clear(s.held[len(held):])
+	s.held = held
+	return due
+}
+
+// removePeer drops the round of a disconnected peer.
+func (s *broadcastScheduler) removePeer(id enode.ID) {
+	s.lock.Lock()
+	if round := s.peers[id]; round != nil {
+		if round.timer != nil {
+			round.timer.Stop()
+		}
+		delete(s.peers, id)
+	}
+	due := s.release(id)
+	s.lock.Unlock()
+
+	s.flush(due...)
+}
+
+// flushAll sends all pending rounds and held broadcasts right away.
+func (s *broadcastScheduler) flushAll() {
+	s.lock.Lock()
+	for _, h := range s.held {
+		s.add(h.clearnet, h.msgCode, h.payload, h.announce)
+	}
+	clear(s.held)
+	s.held = s.held[:0]
+	ids := make([]enode.ID, 0, len(s.peers))
+	for id := range s.peers {
+		ids = append(ids, id)
+	}
+	s.lock.Unlock()
+
+	s.flush(ids...)
+}
+
+// pending returns the number of peers with a pending round.
+func (s *broadcastScheduler) pending() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.peers)
+}
+
+// stop cancels all rounds.
+func (s *broadcastScheduler) stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for id, round := range s.peers {
+		if round.timer != nil {
+			round.timer.Stop()
+		}
+		delete(s.peers, id)
+	}
+	s.held = nil
+}
@@ -0,0 +1,211 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sentBroadcast is a message handed to an outbound queue by the scheduler.
+type sentBroadcast struct {
+	msg  *QueuedMessage
+	time mclock.AbsTime
+}
+
+func newTestScheduler(delay time.Duration) (*broadcastScheduler, *mclock.Simulated, *[]sentBroadcast) {
+	var (
+		clock = new(mclock.Simulated)
+		sent  []sentBroadcast
+	)
+	s := newBroadcastScheduler(delay, clock, func(msg *QueuedMessage) {
+		sent = append(sent, sentBroadcast{msg, clock.Now()})
+	})
+	s.rand = rand.New(rand.NewSource(1))
+	return s, clock, &sent
+}
+
+// runClock advances the simulated clock in small steps, so timers created by
+// timer callbacks fire in the same run and see the time they were due.
+func runClock(clock *mclock.Simulated, d time.Duration) {
+	const step = 10 * time.Millisecond
+	for ; d > 0; d -= step {
+		clock.Run(min(step, d))
+	}
+}
+
+func testBroadcastPeers(transports ...string) []*RelayPeer {
+	peers := make([]*RelayPeer, len(transports))
+	for i, transport := range transports {
+		peers[i] = &RelayPeer{ID: enode.ID{byte(i + 1)}, Transport: transport}
+	}
+	return peers
+}
+
+func testAnnouncement(t *testing.T, hashes ...common.Hash) []byte {
+	ann := newPooledTransactionHashesPacket{Hashes: hashes}
+	for range hashes {
+		ann.Types = append(ann.Types, 0)
+		ann.Sizes = append(ann.Sizes, 100)
+	}
+	payload, err := rlp.EncodeToBytes(&ann)
+	require.NoError(t, err)
+	return payload
+}
+
+func decodeAnnouncement(t *testing.T, payload []byte) []common.Hash {
+	var ann newPooledTransactionHashesPacket
+	require.NoError(t, rlp.DecodeBytes(payload, &ann))
+	return ann.Hashes
+}
+
+func TestBroadcastRounds(t *testing.T) {
+	s, clock, sent := newTestScheduler(time.Second)
+	peers := testBroadcastPeers("clearnet", "clearnet", "clearnet")
+
+	tx := []byte{0xc0}
+	s.schedule(newPooledTransactionHashesMsg, testAnnouncement(t, common.Hash{1}), peers, false)
+	s.schedule(transactionsMsg, tx, peers, false)
+	s.schedule(newPooledTransactionHashesMsg, testAnnouncement(t, common.Hash{2}, common.Hash{3}), peers, false)
+	assert.Empty(t, *sent, "broadcasts sent before their round")
+	assert.Equal(t, 3, s.pending())
+
+	// Every peer gets the transactions and one merged announcement in its
+	// own round.
+	runClock(clock, time.Minute)
+	require.Len(t, *sent, 6)
+	times := make(map[enode.ID]mclock.AbsTime)
+	for i := 0; i < len(*sent); i += 2 {
+		txs, ann := (*sent)[i], (*sent)[i+1]
+		assert.Equal(t, txs.msg.ToPeer, ann.msg.ToPeer)
+		assert.Equal(t, txs.time, ann.time)
+		assert.Equal(t, uint64(transactionsMsg), txs.msg.MsgCode)
+		assert.Equal(t, tx, txs.msg.Payload)
+		assert.Equal(t, uint64(newPooledTransactionHashesMsg), ann.msg.MsgCode)
+		assert.Equal(t, []common.Hash{{1}, {2}, {3}}, decodeAnnouncement(t, ann.msg.Payload))
+		times[txs.msg.ToPeer] = txs.time
+	}
+	assert.Len(t, times, 3, "peers with a round")
+	assert.Zero(t, s.pending())
+}
+
+func TestBroadcastPoissonDelay(t *testing.T) {
+	const delay = time.Second
+	s, clock, sent := newTestScheduler(delay)
+	peers := testBroadcastPeers("clearnet")
+
+	// Round intervals are exponentially distributed with the configured mean.
+	var total time.Duration
+	const rounds = 2000
+	for i := 0; i < rounds; i++ {
+		start := clock.Now()
+		s.schedule(transactionsMsg, []byte{0xc0}, peers, false)
+		for len(*sent) == i {
+			runClock(clock, 10*time.Millisecond)
+		}
+		total += time.Duration((*sent)[i].time - start)
+	}
+	mean := total / rounds
+	assert.InDelta(t, float64(delay), float64(mean), float64(delay)/10, "mean delay %v", mean)
+}
+
+func TestBroadcastNoDelay(t *testing.T) {
+	s, _, sent := newTestScheduler(0)
+	peers := testBroadcastPeers("onion", "clearnet")
+
+	s.schedule(transactionsMsg, []byte{0xc0}, peers, true)
+	require.Len(t, *sent, 2)
+	assert.Equal(t, peers[0].ID, (*sent)[0].msg.ToPeer, "onion peer first")
+	assert.Zero(t, s.pending())
+}
+
+func TestBroadcastLocalAnonymousFirst(t *testing.T) {
+	s, clock, sent := newTestScheduler(time.Second)
+	peers := testBroadcastPeers("onion", "i2p", "clearnet", "onion", "clearnet")
+
+	for i := 0; i < 50; i++ {
+		*sent = nil
+		s.schedule(transactionsMsg, []byte{0xc0}, peers, true)
+		runClock(clock, time.Minute)
+		require.Len(t, *sent, len(peers))
+
+		// All anonymous peers get the transactions before any clearnet peer.
+		var lastAnonymous mclock.AbsTime
+		for _, b := range (*sent)[:3] {
+			assert.NotEqual(t, "clearnet", peers[b.msg.ToPeer[0]-1].Transport)
+			lastAnonymous = b.time
+		}
+		for _, b := range (*sent)[3:] {
+			assert.Equal(t, "clearnet", peers[b.msg.ToPeer[0]-1].Transport)
+			assert.Greater(t, b.time, lastAnonymous)
+		}
+	}
+
+	// Relayed transactions are not held back.
+	*sent = nil
+	s.schedule(transactionsMsg, []byte{0xc0}, peers, false)
+	assert.Len(t, s.peers, len(peers))
+	runClock(clock, time.Minute)
+	assert.Len(t, *sent, len(peers))
+}
+
+func TestBroadcastRemovePeer(t *testing.T) {
+	s, clock, sent := newTestScheduler(time.Second)
+	peers := testBroadcastPeers("onion", "clearnet")
+
+	// A disconnecting anonymous peer releases the held broadcasts.
+	s.schedule(transactionsMsg, []byte{0xc0}, peers, true)
+	s.removePeer(peers[0].ID)
+	runClock(clock, time.Minute)
+	require.Len(t, *sent, 1)
+	assert.Equal(t, peers[1].ID, (*sent)[0].msg.ToPeer)
+	assert.Empty(t, s.held)
+}
+
+func TestBroadcastFlushAll(t *testing.T) {
+	s, _, sent := newTestScheduler(time.Hour)
+	peers := testBroadcastPeers("onion", "clearnet", "clearnet")
+
+	s.schedule(transactionsMsg, []byte{0xc0}, peers, true)
+	s.schedule(newPooledTransactionHashesMsg, testAnnouncement(t, common.Hash{1}), peers, false)
+	s.flushAll()
+	assert.Len(t, *sent, 6)
+	assert.Zero(t, s.pending())
+	assert.Empty(t, s.held)
+}
+
+func TestBroadcastAnnounceBatches(t *testing.T) {
+	s, _, sent := newTestScheduler(0)
+	peers := testBroadcastPeers("clearnet")
+
+	hashes := make([]common.Hash, maxAnnounceBatch+10)
+	for i := range hashes {
+		hashes[i] = common.Hash{byte(i), byte(i >> 8)}
+	}
+	s.schedule(newPooledTransactionHashesMsg, testAnnouncement(t, hashes...), peers, false)
+	require.Len(t, *sent, 2)
+	assert.Equal(t, hashes[:maxAnnounceBatch], decodeAnnouncement(t, (*sent)[0].msg.Payload))
+	assert.Equal(t, hashes[maxAnnounceBatch:], decodeAnnouncement(t, (*sent)[1].msg.Payload))
+}
@@ -0,0 +1,184 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Wire capture.
+//
+// With capture enabled, every message received from or sent to a peer on the
+// wrapped protocols is written to a rotating file, one JSON object per line:
+//
+//	{"time":"2025-01-02T15:04:05.999999999Z","peer":"8a2f...","dir":"in","proto":"eth","version":69,"code":2,"data":"0xf8..."}
+//
+// time is the time the message was read or written, peer the node ID of the
+// remote end, dir "in" for messages received and "out" for messages sent,
+// proto and version the capability the message belongs to, code the message
+// code within the capability and data the RLP payload. Rotated files keep the
+// name of the capture file with the time of the rotation added.
+
+// Capture directions.
+const (
+	CaptureIn  = "in"
+	CaptureOut = "out"
+)
+
+// CaptureConfig configures the wire capture.
+type CaptureConfig struct {
+	File     string // File the messages are written to, capture is disabled if empty
+	MaxSize  int    // Size in megabytes at which the file is rotated (default 100)
+	MaxFiles int    // Rotated files kept (0 = all)
+}
+
+// CaptureRecord is a captured message.
+type CaptureRecord struct {
+	Time    time.Time     `json:"time"`
+	Peer    enode.ID      `json:"peer"`
+	Dir     string        `json:"dir"`
+	Proto   string        `json:"proto"`
+	Version uint          `json:"version"`
+	Code    uint64        `json:"code"`
+	Data    hexutil.Bytes `json:"data"`
+}
+
+// CaptureReader reads the records of a capture file.
+type CaptureReader struct {
+	dec *json.Decoder
+}
+
+// NewCaptureReader creates a reader of the capture file contents r.
+func NewCaptureReader(r io.Reader) *CaptureReader {
+	return &CaptureReader{dec: json.NewDecoder(r)}
+}
+
+// Next returns the next record, or io.EOF at the end of the capture.
+func (r *CaptureReader) Next() (*CaptureRecord, error) {
+	rec := new(CaptureRecord)
+	if err := r.dec.Decode(rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// capture writes the captured messages.
+type capture struct {
+	lock   sync.Mutex
+	out    io.WriteCloser
+	failed bool // last write failed, the next failure is not logged
+}
+
+func newCapture(config CaptureConfig) *capture {
+	return &capture{
+		out: &lumberjack.Logger{
+			Filename:   config.File,
+			MaxSize:    config.MaxSize,
+			MaxBackups: config.MaxFiles,
+		},
+	}
+}
+
+// record writes a message to the capture file.
+func (c *capture) record(rec *CaptureRecord) {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		log.Error("Failed to encode captured message", "err", err)
+		return
+	}
+	line = append(line, '\n')
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, err = c.out.Write(line)
+	if err != nil && !c.failed {
+		log.Warn("Failed to write message capture", "err", err)
+	}
+	c.failed = err != nil
+}
+
+func (c *capture) close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.out.Close()
+}
+
+// captureRW records the messages passing through a protocol connection.
+type captureRW struct {
+	rw      p2p.MsgReadWriter
+	capture *capture
+	peer    enode.ID
+	proto   string
+	version uint
+}
+
+// ReadMsg implements p2p.MsgReader.
+func (c *captureRW) ReadMsg() (p2p.Msg, error) {
+	msg, err := c.rw.ReadMsg()
+	if err != nil {
+		return msg, err
+	}
+	msg.Payload, err = c.recordPayload(CaptureIn, msg)
+	return msg, err
+}
+
+// WriteMsg implements p2p.MsgWriter.
+func (c *captureRW) WriteMsg(msg p2p.Msg) error {
+	var err error
+	if msg.Payload, err = c.recordPayload(CaptureOut, msg); err != nil {
+		return err
+	}
+	return c.rw.WriteMsg(msg)
+}
+
+// recordPayload captures a message. The payload is consumed, the returned
+// reader replaces it.
+func (c *captureRW) recordPayload(dir string, msg p2p.Msg) (io.Reader, error) {
+	data, err := io.ReadAll(msg.Payload)
+	if err != nil {
+		return nil, err
+	}
+	c.capture.record(&CaptureRecord{
+		Time:    time.Now(),
+		Peer:    c.peer,
+		Dir:     dir,
+		Proto:   c.proto,
+		Version: c.version,
+		Code:    msg.Code,
+		Data:    data,
+	})
+	return bytes.NewReader(data), nil
+}
+
+// CaptureRW returns a connection recording the messages of a peer on the given
+// protocol, or rw itself if capture is disabled.
+func (b *Backend) CaptureRW(p *p2p.Peer, proto string, version uint, rw p2p.MsgReadWriter) p2p.MsgReadWriter {
+	if b.capture == nil {
+		return rw
+	}
+	return &captureRW{rw: rw, capture: b.capture, peer: p.ID(), proto: proto, version: version}
+}
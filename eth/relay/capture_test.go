@@ -0,0 +1,85 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureDisabled(t *testing.T) {
+	backend := NewBackend(&Config{}, nil)
+	local, remote := p2p.MsgPipe()
+	defer local.Close()
+	defer remote.Close()
+
+	assert.Equal(t, p2p.MsgReadWriter(local), backend.CaptureRW(newTestRelayPeer(1, nil).Peer, "eth", 69, local))
+}
+
+func TestCaptureRW(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "capture.jsonl")
+	backend := NewBackend(&Config{}, nil)
+	backend.capture = newCapture(CaptureConfig{File: file})
+
+	local, remote := p2p.MsgPipe()
+	defer local.Close()
+	defer remote.Close()
+	rw := backend.CaptureRW(newTestRelayPeer(1, nil).Peer, "eth", 69, local)
+
+	// Messages pass through unchanged in both directions.
+	go p2p.Send(remote, 0x03, []uint{1, 2})
+	msg, err := rw.ReadMsg()
+	require.NoError(t, err)
+	var req []uint
+	require.NoError(t, msg.Decode(&req))
+	assert.Equal(t, []uint{1, 2}, req)
+
+	go p2p.Send(rw, 0x04, []uint{3})
+	require.NoError(t, p2p.ExpectMsg(remote, 0x04, []uint{3}))
+	require.NoError(t, backend.capture.close())
+
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+	r := NewCaptureReader(f)
+
+	in, err := r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, enode.ID{1}, in.Peer)
+	assert.Equal(t, CaptureIn, in.Dir)
+	assert.Equal(t, "eth", in.Proto)
+	assert.Equal(t, uint(69), in.Version)
+	assert.Equal(t, uint64(0x03), in.Code)
+	assert.Equal(t, []byte{0xc2, 0x01, 0x02}, []byte(in.Data))
+
+	out, err := r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, CaptureOut, out.Dir)
+	assert.Equal(t, uint64(0x04), out.Code)
+	assert.Equal(t, []byte{0xc1, 0x03}, []byte(out.Data))
+	assert.False(t, out.Time.Before(in.Time))
+
+	_, err = r.Next()
+	assert.Equal(t, io.EOF, err)
+}
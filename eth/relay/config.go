@@ -17,6 +17,8 @@
 package relay
 
 import (
+	"time"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/forkid"
 	"github.com/ethereum/go-ethereum/params"
@@ -27,8 +29,8 @@ type Config struct {
 	// Network configuration
 	NetworkID   uint64            // Ethereum network ID (1=Mainnet, 17000=Holesky)
 	GenesisHash common.Hash       // Hard-coded genesis block hash
-	ChainConfig *params.ChainConfig // Hard-coded chain configuration
-	ForkID      forkid.ID         // Pre-computed fork ID
+	ChainConfig *params.ChainConfig `toml:"-"` // Hard-coded chain configuration
+	ForkID      forkid.ID         `toml:"-"` // Pre-computed fork ID
 	
 	// Block range tracking (for handshake)
 	BlockRange BlockRange // Initial block range
@@ -36,13 +38,42 @@ type Config struct {
 	// Discovery configuration
 	EthDiscoveryURLs  []string // DNS discovery URLs for eth protocol
 	SnapDiscoveryURLs []string // DNS discovery URLs for snap protocol
+	PeerExchange      bool     // Exchange onion/I2P node records over pex
+
+	// Peer reputation
+	BanThreshold int           // Score below which peers are banned (default -100)
+	BanDuration  time.Duration // How long misbehaving peers stay banned (default 1h)
+	BanList      string        // File the ban list is persisted to (default banlist.json in the datadir)
+
+	// Bandwidth limits, adjustable at runtime
+	Bandwidth BandwidthLimits
+
+	// Outbound queues
+	QueueLimit      int           // Payload bytes queued per peer (default 4 MiB)
+	QueuePolicy     DropPolicy    // Messages dropped from full queues (default drop-gossip)
+	SlowPeerTimeout time.Duration // How long a queue may stay full before the peer is dropped (default 30s)
+
+	// Transaction broadcasts
+	BroadcastDelay time.Duration // Mean delay of transaction broadcast rounds per peer (default 0, no delay)
+
+	// Dandelion++ propagation of submitted transactions
+	Dandelion DandelionConfig
+
+	// Beacon light client verifying the chain head
+	LightClient LightClientConfig
+
+	// Links to other relays
+	Overlay OverlayConfig
+
+	// Capture of the eth messages exchanged with peers
+	Capture CaptureConfig
 }
 
 // BlockRange represents the available block range for the relay.
 type BlockRange struct {
-	EarliestBlock   uint64
-	LatestBlock     uint64
-	LatestBlockHash common.Hash
+	EarliestBlock   uint64      `json:"earliestBlock"`
+	LatestBlock     uint64      `json:"latestBlock"`
+	LatestBlockHash common.Hash `json:"latestBlockHash"`
 }
 
 // DiscoveryConfig wraps discovery-related configuration.
@@ -0,0 +1,350 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// Dandelion++ propagation.
+//
+// Transactions submitted in dandelion mode are not broadcast by the relay that
+// receives them. In the stem phase they are passed over the stem protocol to a
+// single relay peer, which passes them on or, with the fluff probability,
+// broadcasts them like any other transaction: the fluff phase. The first
+// broadcast thus happens a random number of hops away from the submitting
+// relay. The stem peer is kept for an epoch and onion peers are preferred.
+// Every relay on the stem holds the transactions under embargo and fluffs
+// them itself if they are not seen on the network before its embargo timer
+// expires, so a stem peer dropping them does not lose them. A stem running
+// into a loop is fluffed by the first relay seeing a transaction twice.
+
+const (
+	defaultFluffProbability = 0.1
+	defaultEmbargo          = 30 * time.Second
+	defaultStemEpoch        = 10 * time.Minute
+
+	stemmedCache = 4096 // stem transaction hashes remembered to break loops
+)
+
+var errDandelionDisabled = errors.New("dandelion propagation is disabled")
+
+// DandelionConfig configures Dandelion++ transaction propagation.
+type DandelionConfig struct {
+	Enabled          bool          // Take part in stem routing and accept dandelion submissions
+	FluffProbability float64       // Probability that a relay fluffs a stem transaction instead of passing it on (default 0.1)
+	Embargo          time.Duration // Minimum time before a relay on the stem fluffs a transaction itself (default 30s)
+	Epoch            time.Duration // How long the stem peer is kept (default 10m)
+}
+
+// stemTransport sends stem phase transactions to peers.
+type stemTransport interface {
+	Peers() []enode.ID
+	Send(id enode.ID, txs []*types.Transaction) error
+}
+
+// dandelion routes stem phase transactions and keeps their embargo timers.
+type dandelion struct {
+	fluffProbability float64
+	embargo          time.Duration
+	epoch            time.Duration
+	backend          *Backend
+	transport        stemTransport
+	clock            mclock.Clock
+
+	lock      sync.Mutex
+	rand      *rand.Rand
+	fluff     func([]*types.Transaction) // broadcasts transactions, nil until started
+	stemPeer  enode.ID
+	epochEnd  mclock.AbsTime
+	embargoed map[common.Hash]*embargo
+	stemmed   lru.BasicLRU[common.Hash, struct{}]
+}
+
+// embargo is a transaction in the stem phase.
+type embargo struct {
+	tx    *types.Transaction
+	local bool // submitted to this relay
+	timer mclock.Timer
+}
+
+func newDandelion(config DandelionConfig, backend *Backend, transport stemTransport) *dandelion {
+	d := &dandelion{
+		fluffProbability: config.FluffProbability,
+		embargo:          config.Embargo,
+		epoch:            config.Epoch,
+		backend:          backend,
+		transport:        transport,
+		clock:            mclock.System{},
+		rand:             rand.New(rand.NewSource(time.Now().UnixNano())),
+		embargoed:        make(map[common.Hash]*embargo),
+		stemmed:          lru.NewBasicLRU[common.Hash, struct{}](stemmedCache),
+	}
+	if d.fluffProbability == 0 {
+		d.fluffProbability = defaultFluffProbability
+	}
+	if d.embargo == 0 {
+		d.embargo = defaultEmbargo
+	}
+	if d.epoch == 0 {
+		d.epoch = defaultStemEpoch
+	}
+	return d
+}
+
+// start sets the function broadcasting fluffed transactions. Stem transactions
+// received before are dropped, their senders fluff them when the embargo ends.
+func (d *dandelion) start(fluff func([]*types.Transaction)) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.fluff = fluff
+}
+
+// stop cancels all embargo timers.
+func (d *dandelion) stop() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for hash, e := range d.embargoed {
+		e.timer.Stop()
+		delete(d.embargoed, hash)
+	}
+	d.fluff = nil
+}
+
+// submit starts the stem phase of a locally submitted transaction. Local
+// transactions always take at least one stem hop.
+func (d *dandelion) submit(tx *types.Transaction) {
+	d.lock.Lock()
+	markSeen(&d.stemmed, tx.Hash())
+	d.setEmbargo(tx, true)
+	d.lock.Unlock()
+
+	d.route(enode.ID{}, []*types.Transaction{tx})
+}
+
+// handleStem handles transactions received from a peer in the stem phase. A
+// transaction that is already under embargo went around a loop: it is fluffed,
+// or passed on again if it is our own.
+func (d *dandelion) handleStem(from enode.ID, txs []*types.Transaction) error {
+	if d.backend.Draining() {
+		return nil
+	}
+	var signer types.Signer
+	if d.backend.chainConfig != nil {
+		signer = types.LatestSigner(d.backend.chainConfig)
+	}
+	d.lock.Lock()
+	if d.fluff == nil {
+		d.lock.Unlock()
+		return nil
+	}
+	var (
+		fresh, looped, returned []*types.Transaction
+		invalid                 bool
+	)
+	for _, tx := range txs {
+		if err := validateTransaction(signer, tx); err != nil {
+			log.Trace("Invalid stem transaction from peer", "peer", from, "hash", tx.Hash(), "err", err)
+			invalid = true
+			continue
+		}
+		hash := tx.Hash()
+		switch e := d.embargoed[hash]; {
+		case e != nil && e.local:
+			returned = append(returned, tx)
+		case e != nil:
+			looped = append(looped, tx)
+		case markSeen(&d.stemmed, hash):
+			fresh = append(fresh, tx)
+		}
+	}
+	fluff := d.rand.Float64() < d.fluffProbability
+	d.lock.Unlock()
+
+	if invalid {
+		d.backend.ReportPeer(from, MisbehaviourInvalidTx)
+	}
+	if len(looped) > 0 {
+		log.Debug("Stem looped, fluffing transactions", "peer", from, "count", len(looped))
+		d.fluffNow(looped)
+	}
+	if len(returned) > 0 {
+		d.route(from, returned)
+	}
+	switch {
+	case len(fresh) == 0:
+	case fluff:
+		d.fluffNow(fresh)
+	default:
+		d.route(from, fresh)
+	}
+	return nil
+}
+
+// route passes transactions on to the stem peer and puts them under embargo.
+// They are fluffed right away if there is no stem peer to pass them to.
+func (d *dandelion) route(from enode.ID, txs []*types.Transaction) {
+	d.lock.Lock()
+	to := d.nextHop(from)
+	for _, tx := range txs {
+		d.setEmbargo(tx, false)
+	}
+	d.lock.Unlock()
+
+	if to == (enode.ID{}) {
+		log.Debug("No stem peer, fluffing transactions", "count", len(txs))
+		d.fluffNow(txs)
+		return
+	}
+	if err := d.transport.Send(to, txs); err != nil {
+		log.Debug("Failed to send stem transactions, fluffing", "peer", to, "err", err)
+		d.fluffNow(txs)
+		return
+	}
+	log.Trace("Passed on stem transactions", "peer", to, "count", len(txs))
+}
+
+// nextHop returns the peer to pass stem transactions from the given peer to,
+// or the zero ID if there is none. The stem peer is replaced when the epoch
+// ends or it disconnects or gets banned, transactions coming from it go to
+// another peer. It must be called with the lock held.
+func (d *dandelion) nextHop(from enode.ID) enode.ID {
+	peers := d.transport.Peers()
+	slices.SortFunc(peers, func(a, b enode.ID) int { return bytes.Compare(a[:], b[:]) })
+
+	now := d.clock.Now()
+	if now >= d.epochEnd || !slices.Contains(peers, d.stemPeer) || d.backend.IsBanned(d.stemPeer) {
+		d.stemPeer = d.choosePeer(peers, enode.ID{})
+		d.epochEnd = now.Add(d.epoch)
+		if d.stemPeer != (enode.ID{}) {
+			log.Debug("Selected stem peer", "peer", d.stemPeer)
+		}
+	}
+	if d.stemPeer != from {
+		return d.stemPeer
+	}
+	return d.choosePeer(peers, from)
+}
+
+// choosePeer picks a random peer other than exclude, onion peers first. It
+// must be called with the lock held.
+func (d *dandelion) choosePeer(peers []enode.ID, exclude enode.ID) enode.ID {
+	var onion, other []enode.ID
+	for _, id := range peers {
+		if id == exclude || d.backend.IsBanned(id) {
+			continue
+		}
+		if peer := d.backend.peers.Get(id); peer != nil && peer.Transport == "onion" {
+			onion = append(onion, id)
+		} else {
+			other = append(other, id)
+		}
+	}
+	switch {
+	case len(onion) > 0:
+		return onion[d.rand.Intn(len(onion))]
+	case len(other) > 0:
+		return other[d.rand.Intn(len(other))]
+	default:
+		return enode.ID{}
+	}
+}
+
+// setEmbargo starts the embargo timer of a transaction. The timer runs for
+// one to two embargo periods, so the relays on the stem do not all fluff at
+// the same time. It must be called with the lock held.
+func (d *dandelion) setEmbargo(tx *types.Transaction, local bool) {
+	hash := tx.Hash()
+	if _, ok := d.embargoed[hash]; ok {
+		return
+	}
+	timeout := d.embargo + time.Duration(d.rand.Int63n(int64(d.embargo)))
+	d.embargoed[hash] = &embargo{
+		tx:    tx,
+		local: local,
+		timer: d.clock.AfterFunc(timeout, func() { d.expire(hash) }),
+	}
+}
+
+// expire fluffs a transaction whose embargo ended before it was seen.
+func (d *dandelion) expire(hash common.Hash) {
+	d.lock.Lock()
+	e := d.embargoed[hash]
+	d.lock.Unlock()
+	if e != nil {
+		log.Debug("Stem transaction embargo expired, fluffing", "hash", hash)
+		d.fluffNow([]*types.Transaction{e.tx})
+	}
+}
+
+// seen lifts the embargo of transactions received or announced by a peer.
+func (d *dandelion) seen(hashes []common.Hash) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, hash := range hashes {
+		if e := d.embargoed[hash]; e != nil {
+			e.timer.Stop()
+			delete(d.embargoed, hash)
+		}
+	}
+}
+
+// fluffNow lifts the embargo of transactions and broadcasts them.
+func (d *dandelion) fluffNow(txs []*types.Transaction) {
+	d.lock.Lock()
+	for _, tx := range txs {
+		if e := d.embargoed[tx.Hash()]; e != nil {
+			e.timer.Stop()
+			delete(d.embargoed, tx.Hash())
+		}
+	}
+	fluff := d.fluff
+	d.lock.Unlock()
+
+	if fluff != nil {
+		fluff(txs)
+	}
+}
+
+// fluffAll broadcasts all transactions under embargo.
+func (d *dandelion) fluffAll() {
+	d.lock.Lock()
+	txs := make([]*types.Transaction, 0, len(d.embargoed))
+	for _, e := range d.embargoed {
+		txs = append(txs, e.tx)
+	}
+	d.lock.Unlock()
+
+	if len(txs) > 0 {
+		log.Info("Fluffing stem transactions under embargo", "count", len(txs))
+		d.fluffNow(txs)
+	}
+}
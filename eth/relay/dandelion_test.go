@@ -0,0 +1,289 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testEmbargo = 30 * time.Second
+
+// simNetwork connects relays over an in-memory stem transport. Fluffed
+// transactions reach all relays at once.
+type simNetwork struct {
+	clock  *mclock.Simulated
+	nodes  map[enode.ID]*simNode
+	hops   map[common.Hash]int        // stem messages sent per transaction
+	fluffs map[common.Hash][]enode.ID // relays that fluffed each transaction
+}
+
+// simNode is a relay of the simulation, and its stem transport.
+type simNode struct {
+	id        enode.ID
+	net       *simNetwork
+	dandelion *dandelion
+	peers     []enode.ID
+	blackhole bool // drops stem transactions
+}
+
+func newSimNetwork() *simNetwork {
+	return &simNetwork{
+		clock:  new(mclock.Simulated),
+		nodes:  make(map[enode.ID]*simNode),
+		hops:   make(map[common.Hash]int),
+		fluffs: make(map[common.Hash][]enode.ID),
+	}
+}
+
+// addNode adds a relay with the given fluff probability and random seed.
+func (net *simNetwork) addNode(id enode.ID, fluffProbability float64, seed int64) *simNode {
+	n := &simNode{id: id, net: net}
+	config := DandelionConfig{Enabled: true, FluffProbability: fluffProbability, Embargo: testEmbargo}
+	n.dandelion = newDandelion(config, NewBackend(&Config{}, nil), n)
+	n.dandelion.clock = net.clock
+	n.dandelion.rand = rand.New(rand.NewSource(seed))
+	n.dandelion.start(func(txs []*types.Transaction) { net.fluff(id, txs) })
+	net.nodes[id] = n
+	return n
+}
+
+// mesh creates n fully connected relays.
+func (net *simNetwork) mesh(n int, fluffProbability float64) []*simNode {
+	nodes := make([]*simNode, n)
+	for i := range nodes {
+		nodes[i] = net.addNode(enode.ID{byte(i + 1)}, fluffProbability, int64(i))
+	}
+	for _, a := range nodes {
+		for _, b := range nodes {
+			if a != b {
+				a.peers = append(a.peers, b.id)
+			}
+		}
+	}
+	return nodes
+}
+
+// fluff records a broadcast and lets all other relays see the transactions.
+func (net *simNetwork) fluff(from enode.ID, txs []*types.Transaction) {
+	hashes := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.Hash()
+		net.fluffs[tx.Hash()] = append(net.fluffs[tx.Hash()], from)
+	}
+	for id, n := range net.nodes {
+		if id != from {
+			n.dandelion.seen(hashes)
+		}
+	}
+}
+
+func (n *simNode) Peers() []enode.ID {
+	return slices.Clone(n.peers)
+}
+
+func (n *simNode) Send(id enode.ID, txs []*types.Transaction) error {
+	for _, tx := range txs {
+		n.net.hops[tx.Hash()]++
+	}
+	if to := n.net.nodes[id]; !to.blackhole {
+		return to.dandelion.handleStem(n.id, txs)
+	}
+	return nil
+}
+
+func (n *simNode) embargoes() int {
+	n.dandelion.lock.Lock()
+	defer n.dandelion.lock.Unlock()
+	return len(n.dandelion.embargoed)
+}
+
+func newTestTx(nonce uint64) *types.Transaction {
+	return types.NewTx(&types.LegacyTx{Nonce: nonce, Gas: 21000, To: &common.Address{1}, GasPrice: common.Big1})
+}
+
+func TestDandelionStemPhase(t *testing.T) {
+	net := newSimNetwork()
+	nodes := net.mesh(10, 0.2)
+	rng := rand.New(rand.NewSource(1))
+
+	origins := make(map[common.Hash]enode.ID)
+	for i := uint64(0); i < 200; i++ {
+		tx, origin := newTestTx(i), nodes[rng.Intn(len(nodes))]
+		origins[tx.Hash()] = origin.id
+		origin.dandelion.submit(tx)
+
+		// Submitted transactions leave the origin over the stem, never by a
+		// broadcast of the origin.
+		require.GreaterOrEqual(t, net.hops[tx.Hash()], 1, "transaction %d not stemmed", i)
+		assert.NotContains(t, net.fluffs[tx.Hash()], origin.id, "origin fluffed transaction %d", i)
+	}
+
+	// Every transaction is broadcast exactly once, also when the stem runs
+	// into a loop. The other relays see it and lift their embargo.
+	for hash, origin := range origins {
+		require.Len(t, net.fluffs[hash], 1, "transaction %x", hash)
+		assert.NotEqual(t, origin, net.fluffs[hash][0], "origin fluffed transaction %x", hash)
+	}
+	for _, n := range nodes {
+		assert.Zero(t, n.embargoes(), "embargoes left on %v", n.id)
+	}
+	net.clock.Run(2 * testEmbargo)
+	for hash := range origins {
+		assert.Len(t, net.fluffs[hash], 1, "transaction %x fluffed again", hash)
+	}
+}
+
+func TestDandelionHops(t *testing.T) {
+	// On a line of relays, the stem length follows the geometric distribution
+	// with mean 1/q.
+	const q = 0.25
+	net := newSimNetwork()
+	nodes := make([]*simNode, 200)
+	for i := range nodes {
+		nodes[i] = net.addNode(enode.ID{byte(i), byte(i >> 8), 1}, q, int64(i))
+		if i > 0 {
+			nodes[i-1].peers = []enode.ID{nodes[i].id}
+		}
+	}
+	var total int
+	const count = 500
+	for i := uint64(0); i < count; i++ {
+		tx := newTestTx(i)
+		nodes[0].dandelion.submit(tx)
+		require.Len(t, net.fluffs[tx.Hash()], 1, "transaction %d not fluffed", i)
+		total += net.hops[tx.Hash()]
+	}
+	mean := float64(total) / count
+	assert.InDelta(t, 1/q, mean, 0.5, "mean stem length")
+}
+
+func TestDandelionEmbargo(t *testing.T) {
+	net := newSimNetwork()
+	origin := net.addNode(enode.ID{1}, 0.1, 1)
+	stem := net.addNode(enode.ID{2}, 0.1, 2)
+	stem.blackhole = true
+	origin.peers = []enode.ID{stem.id}
+
+	// The stem peer drops the transaction, the origin fluffs it after one to
+	// two embargo periods.
+	tx := newTestTx(1)
+	origin.dandelion.submit(tx)
+	net.clock.Run(testEmbargo - time.Second)
+	assert.Empty(t, net.fluffs[tx.Hash()], "fluffed before the embargo ended")
+	net.clock.Run(testEmbargo + time.Second)
+	assert.Equal(t, []enode.ID{origin.id}, net.fluffs[tx.Hash()])
+
+	// Transactions seen on the network are not fluffed.
+	tx = newTestTx(2)
+	origin.dandelion.submit(tx)
+	origin.dandelion.seen([]common.Hash{tx.Hash()})
+	net.clock.Run(2 * testEmbargo)
+	assert.Empty(t, net.fluffs[tx.Hash()], "seen transaction fluffed")
+	assert.Zero(t, origin.embargoes())
+}
+
+func TestDandelionNoStemPeer(t *testing.T) {
+	net := newSimNetwork()
+	origin := net.addNode(enode.ID{1}, 0.1, 1)
+
+	tx := newTestTx(1)
+	origin.dandelion.submit(tx)
+	assert.Equal(t, []enode.ID{origin.id}, net.fluffs[tx.Hash()])
+	assert.Zero(t, origin.embargoes())
+}
+
+func TestDandelionStemPeerSelection(t *testing.T) {
+	net := newSimNetwork()
+	nodes := net.mesh(6, 0.1)
+	origin := nodes[0]
+
+	// Onion peers are preferred.
+	onion := newTestRelayPeer(nodes[3].id[0], nil)
+	onion.Transport = "onion"
+	require.True(t, origin.dandelion.backend.AddPeer(onion))
+	for _, n := range nodes[1:] {
+		if n.id != onion.ID {
+			origin.dandelion.backend.AddPeer(newTestRelayPeer(n.id[0], nil))
+		}
+	}
+	origin.dandelion.lock.Lock()
+	assert.Equal(t, onion.ID, origin.dandelion.nextHop(enode.ID{}))
+
+	// The stem peer is kept for the epoch, but transactions coming from it
+	// go elsewhere.
+	origin.dandelion.stemPeer = nodes[1].id
+	assert.Equal(t, nodes[1].id, origin.dandelion.nextHop(enode.ID{}))
+	assert.Equal(t, onion.ID, origin.dandelion.nextHop(nodes[1].id))
+	origin.dandelion.lock.Unlock()
+
+	// A new stem peer is chosen in the next epoch.
+	net.clock.Run(defaultStemEpoch)
+	origin.dandelion.lock.Lock()
+	assert.Equal(t, onion.ID, origin.dandelion.nextHop(enode.ID{}))
+	origin.dandelion.lock.Unlock()
+
+	// Banned peers are never chosen.
+	origin.dandelion.backend.BanPeer(onion.ID, "test", time.Hour)
+	origin.dandelion.lock.Lock()
+	assert.NotEqual(t, onion.ID, origin.dandelion.nextHop(enode.ID{}))
+	origin.dandelion.lock.Unlock()
+}
+
+func TestDandelionInvalidStem(t *testing.T) {
+	net := newSimNetwork()
+	nodes := net.mesh(3, 0.1)
+	sender := nodes[1].id
+
+	invalid := types.NewTx(&types.LegacyTx{Nonce: 1, Gas: 0, To: &common.Address{1}, GasPrice: common.Big1})
+	require.NoError(t, nodes[0].dandelion.handleStem(sender, []*types.Transaction{invalid}))
+	assert.Zero(t, net.hops[invalid.Hash()], "invalid transaction passed on")
+	assert.Empty(t, net.fluffs[invalid.Hash()], "invalid transaction fluffed")
+	assert.Less(t, nodes[0].dandelion.backend.reputation.Score(sender), 0.0)
+}
+
+func TestDandelionFluffToPeers(t *testing.T) {
+	r := newTestRelay()
+	defer r.router.Stop()
+	conns := []*testPeerConn{{}, {}}
+	for i, conn := range conns {
+		r.backend.AddPeer(newTestRelayPeer(byte(i+1), conn))
+	}
+
+	// Fluffed transactions are written to the connections of all peers.
+	txs := []*types.Transaction{newTestTx(1), newTestTx(2)}
+	r.broadcastTransactions(txs)
+	for i, conn := range conns {
+		msg := conn.waitSent(t, 1)[0]
+		require.Equal(t, uint64(transactionsMsg), msg.Code, "peer %d", i+1)
+		var sent []*types.Transaction
+		require.NoError(t, msg.Decode(&sent))
+		require.Len(t, sent, len(txs))
+		for j := range txs {
+			assert.Equal(t, txs[j].Hash(), sent[j].Hash())
+		}
+	}
+}
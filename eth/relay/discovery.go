@@ -121,6 +121,12 @@ func StartRelayENRUpdater(localNode *enode.LocalNode, config *Config) {
 		ForkID: config.ForkID,
 	}
 	localNode.Set(entry)
+
+	// Announce the relay to other relays
+	localNode.Set(&relayENREntry{
+		Version:  overlayVersion,
+		Operator: config.Overlay.Operator,
+	})
 }
 
 // newRelayNodeFilter creates a node filter for relay mode.
@@ -145,6 +151,15 @@ func newRelayNodeFilter(config *Config) func(*enode.Node) bool {
 	}
 }
 
+// newOverlayNodeFilter creates a node filter accepting the relays of the chain
+// other than the local node.
+func newOverlayNodeFilter(config *Config, self enode.ID) func(*enode.Node) bool {
+	relayFilter := newRelayNodeFilter(config)
+	return func(node *enode.Node) bool {
+		return node.ID() != self && IsRelayNode(node) && relayFilter(node)
+	}
+}
+
 // MakeRelayDialCandidates creates a discovery iterator for relay mode.
 func MakeRelayDialCandidates(
 	p2pServer *p2p.Server,
@@ -0,0 +1,121 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+// Graceful drain.
+//
+// Before shutting down, the relay stops taking new peers, requests and gossip,
+// gives the proxied requests in flight a chance to be answered and the outbound
+// queues a chance to be flushed, and then says goodbye to its peers with
+// DiscQuitting instead of dropping the connections.
+
+// drainPollInterval is how often Drain checks for outstanding work.
+const drainPollInterval = 50 * time.Millisecond
+
+var errAlreadyDraining = errors.New("relay is already draining")
+
+// DrainInfo is the outcome of a drain.
+type DrainInfo struct {
+	Duration        time.Duration `json:"duration"`
+	Flushed         bool          `json:"flushed"`         // all work was done before the deadline
+	PendingRequests int           `json:"pendingRequests"` // proxied requests still unanswered at the deadline
+	QueuedMessages  int           `json:"queuedMessages"`  // outbound messages discarded at the deadline
+	Peers           int           `json:"peers"`           // peers disconnected
+}
+
+// Drain prepares the relay for shutdown. New peers, requests and gossip are
+// refused from now on. Drain waits up to timeout for the proxied requests in
+// flight to be answered and the outbound queues to be flushed, then disconnects
+// all peers with DiscQuitting. The relay stays idle until it is stopped.
+func (r *Relay) Drain(timeout time.Duration) (*DrainInfo, error) {
+	if !r.backend.draining.CompareAndSwap(false, true) {
+		return nil, errAlreadyDraining
+	}
+	start := time.Now()
+	log.Info("Draining relay", "timeout", timeout)
+	r.backend.events.Send(Event{Type: EventDraining, Time: start})
+
+	// Transactions under embargo would be lost, fluff them while peers are
+	// still connected
+	if r.dandelion != nil {
+		r.dandelion.fluffAll()
+	}
+	// Delayed broadcast rounds go out right away
+	if r.router != nil {
+		r.router.broadcasts.flushAll()
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	poll := time.NewTicker(drainPollInterval)
+	defer poll.Stop()
+
+	info := new(DrainInfo)
+wait:
+	for {
+		info.PendingRequests, info.QueuedMessages = r.outstanding()
+		if info.PendingRequests == 0 && info.QueuedMessages == 0 {
+			info.Flushed = true
+			break
+		}
+		select {
+		case <-poll.C:
+		case <-deadline.C:
+			break wait
+		case <-r.quit:
+			break wait
+		}
+	}
+
+	for _, peer := range r.backend.Peers() {
+		if peer.Peer != nil {
+			peer.Peer.Disconnect(p2p.DiscQuitting)
+		}
+		info.Peers++
+	}
+	info.Duration = time.Since(start)
+	if info.Flushed {
+		log.Info("Relay drained", "peers", info.Peers, "elapsed", info.Duration)
+	} else {
+		log.Warn("Relay drain timed out", "peers", info.Peers, "pending", info.PendingRequests, "queued", info.QueuedMessages)
+	}
+	return info, nil
+}
+
+// outstanding returns the number of proxied requests awaiting a response and of
+// messages waiting to be relayed or sent.
+func (r *Relay) outstanding() (requests, messages int) {
+	if r.proxy != nil {
+		requests = len(r.proxy.Pending())
+	}
+	messages = r.backend.RelayQueue().Len
+	if r.router != nil {
+		messages += r.router.broadcasts.pending()
+		for _, q := range r.router.QueueStats() {
+			messages += q.Len
+		}
+	}
+	return requests, messages
+}
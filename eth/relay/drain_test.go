@@ -0,0 +1,92 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDrainTestRelay creates a relay with two peers and a request proxied from
+// the first to the second.
+func newDrainTestRelay(t *testing.T) *Relay {
+	r := newTestRelay()
+	r.proxy = NewRequestProxy(r.backend, NewRoundRobinSelector(r.backend), time.Minute)
+	t.Cleanup(func() {
+		r.proxy.Stop()
+		r.router.Stop()
+	})
+	r.backend.AddPeer(newTestRelayPeer(1, nil))
+	r.backend.AddPeer(newTestRelayPeer(2, nil))
+	r.proxy.pendingRequests[7] = &PendingRequest{
+		RequestID:    7,
+		FromPeer:     enode.ID{1},
+		ToPeer:       enode.ID{2},
+		MsgCode:      0x03,
+		ResponseChan: make(chan []byte, 1),
+		Timeout:      time.Now().Add(time.Minute),
+	}
+	return r
+}
+
+func TestDrain(t *testing.T) {
+	r := newDrainTestRelay(t)
+	events := make(chan Event, 10)
+	sub := r.backend.SubscribeEvents(events)
+	defer sub.Unsubscribe()
+
+	result := make(chan *DrainInfo, 1)
+	go func() {
+		info, err := r.Drain(5 * time.Second)
+		assert.NoError(t, err)
+		result <- info
+	}()
+
+	// The drain waits for the in-flight request.
+	ev := <-events
+	assert.Equal(t, EventDraining, ev.Type)
+	assert.True(t, r.backend.Draining())
+	select {
+	case <-result:
+		t.Fatal("drain finished with a request in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+	require.NoError(t, r.proxy.HandleResponse(enode.ID{2}, 0x04, 7, []byte{0xc0}))
+
+	info := <-result
+	assert.True(t, info.Flushed)
+	assert.Zero(t, info.PendingRequests)
+	assert.Equal(t, 2, info.Peers)
+	assert.Less(t, info.Duration, 5*time.Second)
+
+	_, err := r.Drain(time.Second)
+	assert.ErrorIs(t, err, errAlreadyDraining)
+}
+
+func TestDrainTimeout(t *testing.T) {
+	r := newDrainTestRelay(t)
+
+	info, err := r.Drain(100 * time.Millisecond)
+	require.NoError(t, err)
+	assert.False(t, info.Flushed)
+	assert.Equal(t, 1, info.PendingRequests)
+	assert.Equal(t, 2, info.Peers)
+}
@@ -0,0 +1,264 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/beacon/light"
+	"github.com/ethereum/go-ethereum/beacon/light/api"
+	"github.com/ethereum/go-ethereum/beacon/light/request"
+	lightsync "github.com/ethereum/go-ethereum/beacon/light/sync"
+	bparams "github.com/ethereum/go-ethereum/beacon/params"
+	btypes "github.com/ethereum/go-ethereum/beacon/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Trustless head tracking.
+//
+// The light client follows the sync committee of the beacon chain through the
+// light client endpoints of beacon nodes, starting from a weak subjectivity
+// checkpoint. Heads signed by the sync committee are trusted without trusting
+// the beacon nodes serving them. The execution block of the verified head is
+// announced in the relay's block range, and block headers returned by peers
+// are checked against the recently finalized blocks. The optimistic head can
+// still be reorged, peers returning another block of its number are not wrong.
+
+const finalizedBlocksCache = 1024 // finalized execution block hashes remembered by number
+
+var (
+	errLightClientDisabled = errors.New("light client is not enabled")
+	errNoVerifiedHead      = errors.New("no verified head yet")
+	errHeaderMismatch      = errors.New("header conflicts with the finalized chain")
+)
+
+// LightClientConfig configures the beacon light client.
+type LightClientConfig struct {
+	BeaconAPIs  []string             // Beacon node REST endpoints, the light client is off if empty
+	Checkpoint  common.Hash          // Weak subjectivity checkpoint, the chain's default if zero
+	Threshold   int                  // Sync committee signers needed for a head (default 342)
+	ChainConfig *bparams.ChainConfig `toml:"-"` // Hard-coded beacon chain configuration
+}
+
+// VerifiedBlock is an execution block verified by the light client.
+type VerifiedBlock struct {
+	Number uint64      `json:"number"`
+	Hash   common.Hash `json:"hash"`
+}
+
+// VerifiedHead is the latest head signed by the sync committee.
+type VerifiedHead struct {
+	VerifiedBlock
+	BeaconSlot uint64         `json:"beaconSlot"`
+	BeaconRoot common.Hash    `json:"beaconRoot"`
+	Signers    int            `json:"signers"`   // sync committee members that signed the head
+	Finalized  *VerifiedBlock `json:"finalized"` // latest finalized block, if known
+	Time       time.Time      `json:"time"`      // when the head was verified
+}
+
+// lightClient tracks the verified head. It is a request.Module of the beacon
+// light client scheduler, which calls Process whenever the head tracker
+// changes.
+type lightClient struct {
+	apis      []string
+	backend   *Backend
+	scheduler *request.Scheduler
+	tracker   *light.HeadTracker
+
+	lock      sync.RWMutex
+	head      *VerifiedHead
+	finalized lru.BasicLRU[uint64, common.Hash]
+
+	wake chan struct{} // signals the update loop to announce a new head
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// threshold returns the number of signers needed for a head.
+func (c *LightClientConfig) threshold() int {
+	if c.Threshold == 0 {
+		return bparams.SyncCommitteeSupermajority
+	}
+	return c.Threshold
+}
+
+// newCommitteeChain creates the in-memory committee chain of the light client.
+func newCommitteeChain(config *LightClientConfig) *light.CommitteeChain {
+	return light.NewCommitteeChain(memorydb.New(), config.ChainConfig, config.threshold(), true)
+}
+
+// newLightClient creates the light client syncing the given committee chain.
+func newLightClient(config *LightClientConfig, backend *Backend, chain *light.CommitteeChain) *lightClient {
+	checkpoint := config.Checkpoint
+	if checkpoint == (common.Hash{}) {
+		checkpoint = config.ChainConfig.Checkpoint
+	}
+	lc := &lightClient{
+		apis:      config.BeaconAPIs,
+		backend:   backend,
+		scheduler: request.NewScheduler(),
+		tracker:   light.NewHeadTracker(chain, config.threshold(), nil),
+		finalized: lru.NewBasicLRU[uint64, common.Hash](finalizedBlocksCache),
+		wake:      make(chan struct{}, 1),
+		quit:      make(chan struct{}),
+	}
+	lc.scheduler.RegisterTarget(lc.tracker)
+	lc.scheduler.RegisterTarget(chain)
+	lc.scheduler.RegisterModule(lightsync.NewCheckpointInit(chain, checkpoint), "checkpointInit")
+	lc.scheduler.RegisterModule(lightsync.NewForwardUpdateSync(chain), "forwardSync")
+	lc.scheduler.RegisterModule(lightsync.NewHeadSync(lc.tracker, chain), "headSync")
+	lc.scheduler.RegisterModule(lc, "relayHead")
+	return lc
+}
+
+// start connects to the beacon nodes.
+func (lc *lightClient) start() {
+	lc.wg.Add(1)
+	go lc.updateLoop()
+
+	lc.scheduler.Start()
+	for _, url := range lc.apis {
+		beaconAPI := api.NewBeaconLightApi(url, nil)
+		lc.scheduler.RegisterServer(request.NewServer(api.NewApiServer(beaconAPI), &mclock.System{}))
+	}
+	log.Info("Started beacon light client", "apis", len(lc.apis))
+}
+
+// stop disconnects from the beacon nodes.
+func (lc *lightClient) stop() {
+	lc.scheduler.Stop()
+	close(lc.quit)
+	lc.wg.Wait()
+}
+
+// Process implements request.Module. It records the head and finalized block
+// whenever the head tracker validated a newer one.
+func (lc *lightClient) Process(requester request.Requester, events []request.Event) {
+	update, ok := lc.tracker.ValidatedOptimistic()
+	if !ok {
+		return
+	}
+	head := &VerifiedHead{
+		VerifiedBlock: executionBlock(update.Attested.PayloadHeader),
+		BeaconSlot:    update.Attested.Slot,
+		BeaconRoot:    update.Attested.Hash(),
+		Signers:       update.Signature.SignerCount(),
+		Time:          time.Now(),
+	}
+	if finality, ok := lc.tracker.ValidatedFinality(); ok {
+		finalized := executionBlock(finality.Finalized.PayloadHeader)
+		head.Finalized = &finalized
+	}
+	lc.setHead(head)
+}
+
+// setHead records a new verified head and wakes the update loop.
+func (lc *lightClient) setHead(head *VerifiedHead) {
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+
+	if old := lc.head; old != nil && old.BeaconRoot == head.BeaconRoot && sameBlock(old.Finalized, head.Finalized) {
+		return
+	}
+	lc.head = head
+	if head.Finalized != nil {
+		lc.finalized.Add(head.Finalized.Number, head.Finalized.Hash)
+	}
+	log.Debug("Verified new head", "number", head.Number, "hash", head.Hash, "slot", head.BeaconSlot, "signers", head.Signers)
+
+	select {
+	case lc.wake <- struct{}{}:
+	default:
+	}
+}
+
+// executionBlock returns the number and hash of an execution payload header.
+func executionBlock(header *btypes.ExecutionHeader) VerifiedBlock {
+	return VerifiedBlock{Number: header.BlockNumber(), Hash: header.BlockHash()}
+}
+
+func sameBlock(a, b *VerifiedBlock) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// updateLoop announces new verified heads to peers. It runs apart from the
+// scheduler, so slow peers do not hold up the light client.
+func (lc *lightClient) updateLoop() {
+	defer lc.wg.Done()
+
+	var announced uint64
+	for {
+		select {
+		case <-lc.wake:
+			head := lc.verifiedHead()
+			if head == nil || head.Number <= announced {
+				continue
+			}
+			r := lc.backend.GetBlockRange()
+			r.LatestBlock, r.LatestBlockHash = head.Number, head.Hash
+			if err := lc.backend.SetBlockRange(r); err != nil {
+				log.Warn("Failed to announce verified head", "number", head.Number, "err", err)
+				continue
+			}
+			announced = head.Number
+		case <-lc.quit:
+			return
+		}
+	}
+}
+
+// verifiedHead returns the latest verified head, or nil if the light client
+// did not verify any yet.
+func (lc *lightClient) verifiedHead() *VerifiedHead {
+	lc.lock.RLock()
+	defer lc.lock.RUnlock()
+
+	if lc.head == nil {
+		return nil
+	}
+	head := *lc.head
+	return &head
+}
+
+// checkHeaders verifies that none of the headers conflicts with a finalized
+// block of the same number. Headers of other blocks cannot be checked and are
+// accepted.
+func (lc *lightClient) checkHeaders(headers []*types.Header) error {
+	lc.lock.RLock()
+	defer lc.lock.RUnlock()
+
+	for _, header := range headers {
+		if header.Number == nil {
+			continue
+		}
+		if want, ok := lc.finalized.Peek(header.Number.Uint64()); ok && header.Hash() != want {
+			return fmt.Errorf("%w: block %d is %x, finalized %x", errHeaderMismatch, header.Number, header.Hash(), want)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/beacon/light"
+	"github.com/ethereum/go-ethereum/beacon/merkle"
+	bparams "github.com/ethereum/go-ethereum/beacon/params"
+	btypes "github.com/ethereum/go-ethereum/beacon/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+	zrntcommon "github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/deneb"
+	"github.com/protolambda/ztyp/view"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testBeaconConfig = (&bparams.ChainConfig{
+	GenesisValidatorsRoot: common.Hash{1},
+}).AddFork("GENESIS", 0, []byte{0, 0, 0, 0})
+
+// testBeaconAPI is a beacon node stand-in serving the light client bootstrap
+// of a checkpoint and one optimistic update signed by the same committee.
+type testBeaconAPI struct {
+	*httptest.Server
+	checkpoint common.Hash
+	bootstrap  []byte
+	optimistic []byte
+}
+
+// newTestBeaconAPI creates a beacon API whose optimistic update attests to the
+// given execution block.
+func newTestBeaconAPI(t *testing.T, block *types.Header) *testBeaconAPI {
+	const period = 10
+	committee := light.GenerateTestCommittee()
+	bootstrap := light.GenerateTestCheckpoint(period, committee)
+
+	b := &testBeaconAPI{checkpoint: bootstrap.Header.Hash()}
+	var err error
+	b.bootstrap, err = json.Marshal(map[string]any{
+		"version": "electra",
+		"data": map[string]any{
+			"header":                        map[string]any{"beacon": jsonHeader(bootstrap.Header)},
+			"current_sync_committee":        bootstrap.Committee,
+			"current_sync_committee_branch": hexValues(bootstrap.CommitteeBranch),
+		},
+	})
+	require.NoError(t, err)
+
+	// The beacon block body commits to the execution payload header.
+	execJSON, err := json.Marshal(&deneb.ExecutionPayloadHeader{
+		BlockNumber: view.Uint64View(block.Number.Uint64()),
+		BlockHash:   zrntcommon.Hash32(block.Hash()),
+	})
+	require.NoError(t, err)
+	execHeader, err := btypes.ExecutionHeaderFromJSON("electra", execJSON)
+	require.NoError(t, err)
+	bodyRoot, execBranch := testMerkleProof(bparams.BodyIndexExecPayload, execHeader.PayloadRoot())
+
+	slot := btypes.SyncPeriodStart(period) + 300
+	header := btypes.Header{Slot: slot, ProposerIndex: 7, BodyRoot: bodyRoot}
+	signed := light.GenerateTestSignedHeader(header, testBeaconConfig, committee, slot+1, 400)
+	b.optimistic, err = json.Marshal(map[string]any{
+		"version": "electra",
+		"data": map[string]any{
+			"attested_header": map[string]any{
+				"beacon":           jsonHeader(header),
+				"execution":        json.RawMessage(execJSON),
+				"execution_branch": hexValues(execBranch),
+			},
+			"sync_aggregate": &signed.Signature,
+			"signature_slot": strconv.FormatUint(signed.SignatureSlot, 10),
+		},
+	})
+	require.NoError(t, err)
+
+	b.Server = httptest.NewServer(http.HandlerFunc(b.serve))
+	t.Cleanup(b.Close)
+	return b
+}
+
+func (b *testBeaconAPI) serve(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case fmt.Sprintf("/eth/v1/beacon/light_client/bootstrap/%#x", b.checkpoint):
+		w.Write(b.bootstrap)
+	case "/eth/v1/beacon/light_client/optimistic_update":
+		w.Write(b.optimistic)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// testMerkleProof returns the root of a tree holding leaf at the generalized
+// index and the proof of the leaf.
+func testMerkleProof(index uint64, leaf merkle.Value) (common.Hash, merkle.Values) {
+	var (
+		branch merkle.Values
+		node   = leaf
+	)
+	for i := byte(1); index > 1; i++ {
+		sibling := merkle.Value{i}
+		if index&1 == 0 {
+			node = sha256.Sum256(append(node[:], sibling[:]...))
+		} else {
+			node = sha256.Sum256(append(sibling[:], node[:]...))
+		}
+		branch = append(branch, sibling)
+		index >>= 1
+	}
+	return common.Hash(node), branch
+}
+
+// jsonHeader encodes a beacon header like the beacon API, with decimal strings
+// for numbers.
+func jsonHeader(h btypes.Header) map[string]any {
+	return map[string]any{
+		"slot":           strconv.FormatUint(h.Slot, 10),
+		"proposer_index": strconv.FormatUint(h.ProposerIndex, 10),
+		"parent_root":    h.ParentRoot,
+		"state_root":     h.StateRoot,
+		"body_root":      h.BodyRoot,
+	}
+}
+
+func hexValues(values merkle.Values) []common.Hash {
+	hashes := make([]common.Hash, len(values))
+	for i, v := range values {
+		hashes[i] = common.Hash(v)
+	}
+	return hashes
+}
+
+func TestLightClientVerifiedHead(t *testing.T) {
+	block := &types.Header{Number: big.NewInt(1000), Difficulty: new(big.Int), Extra: []byte("verified")}
+	beacon := newTestBeaconAPI(t, block)
+
+	r := newTestRelay()
+	api := NewAPI(r)
+	_, err := api.VerifiedHead()
+	assert.ErrorIs(t, err, errLightClientDisabled)
+
+	config := &LightClientConfig{
+		BeaconAPIs:  []string{beacon.URL},
+		Checkpoint:  beacon.checkpoint,
+		ChainConfig: testBeaconConfig,
+	}
+	chain := light.NewTestCommitteeChain(memorydb.New(), testBeaconConfig, config.threshold(), false, &mclock.Simulated{})
+	r.light = newLightClient(config, r.backend, chain)
+	r.backend.verifyHeaders = r.light.checkHeaders
+	_, err = api.VerifiedHead()
+	assert.ErrorIs(t, err, errNoVerifiedHead)
+
+	r.light.start()
+	defer r.light.stop()
+
+	var head *VerifiedHead
+	for i := 0; i < 500 && head == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+		head, _ = api.VerifiedHead()
+	}
+	require.NotNil(t, head, "no head verified")
+	assert.Equal(t, VerifiedBlock{Number: 1000, Hash: block.Hash()}, head.VerifiedBlock)
+	assert.Equal(t, btypes.SyncPeriodStart(10)+300, head.BeaconSlot)
+	assert.Equal(t, 400, head.Signers)
+	assert.Nil(t, head.Finalized)
+
+	// The verified head is announced to peers.
+	for i := 0; i < 100 && r.backend.GetBlockRange().LatestBlock != 1000; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, BlockRange{LatestBlock: 1000, LatestBlockHash: block.Hash()}, r.backend.GetBlockRange())
+
+	// The optimistic head may be reorged, other blocks of its number pass.
+	other := &types.Header{Number: big.NewInt(1000), Difficulty: new(big.Int), Extra: []byte("reorged")}
+	assert.NoError(t, r.light.checkHeaders([]*types.Header{block}))
+	assert.NoError(t, r.light.checkHeaders([]*types.Header{other}))
+}
+
+func TestLightClientFinalizedHeaders(t *testing.T) {
+	lc := newLightClient(&LightClientConfig{ChainConfig: testBeaconConfig}, newTestRelay().backend, newCommitteeChain(&LightClientConfig{ChainConfig: testBeaconConfig}))
+	finalized := &types.Header{Number: big.NewInt(990), Difficulty: new(big.Int), Extra: []byte("finalized")}
+	forged := &types.Header{Number: big.NewInt(990), Difficulty: new(big.Int), Extra: []byte("forged")}
+	lc.setHead(&VerifiedHead{
+		VerifiedBlock: VerifiedBlock{Number: 1000, Hash: common.Hash{1}},
+		BeaconRoot:    common.Hash{2},
+		Finalized:     &VerifiedBlock{Number: 990, Hash: finalized.Hash()},
+	})
+
+	// Headers are checked against the finalized block of the same number.
+	assert.NoError(t, lc.checkHeaders([]*types.Header{finalized}))
+	assert.NoError(t, lc.checkHeaders([]*types.Header{{Number: big.NewInt(1000), Difficulty: new(big.Int)}}))
+	assert.ErrorIs(t, lc.checkHeaders([]*types.Header{forged}), errHeaderMismatch)
+
+	// Finalized blocks stay checked after newer heads.
+	lc.setHead(&VerifiedHead{
+		VerifiedBlock: VerifiedBlock{Number: 1001, Hash: common.Hash{3}},
+		BeaconRoot:    common.Hash{4},
+		Finalized:     &VerifiedBlock{Number: 990, Hash: finalized.Hash()},
+	})
+	assert.ErrorIs(t, lc.checkHeaders([]*types.Header{forged}), errHeaderMismatch)
+}
+
+func TestProxyDropsConflictingHeaders(t *testing.T) {
+	r := newTestRelay()
+	r.backend.AddPeer(newTestRelayPeer(1, &testPeerConn{}))
+	r.proxy = NewRequestProxy(r.backend, NewRoundRobinSelector(r.backend), time.Minute)
+	defer r.proxy.Stop()
+
+	forged := &types.Header{Number: big.NewInt(5), Difficulty: new(big.Int)}
+	r.backend.verifyHeaders = func(headers []*types.Header) error {
+		for _, h := range headers {
+			if h.Hash() == forged.Hash() {
+				return errHeaderMismatch
+			}
+		}
+		return nil
+	}
+	request := func(response []byte) []byte {
+		done := make(chan []byte, 1)
+		go func() {
+			resp, err := r.Request(context.Background(), enode.ID{1}, 0x03, []byte{0xc0})
+			require.NoError(t, err)
+			done <- resp
+		}()
+		var pending []PendingRequestInfo
+		for i := 0; i < 100 && len(pending) == 0; i++ {
+			time.Sleep(10 * time.Millisecond)
+			pending = r.proxy.Pending()
+		}
+		require.Len(t, pending, 1)
+		require.NoError(t, r.proxy.HandleResponse(enode.ID{1}, 0x04, pending[0].RequestID, response))
+		return <-done
+	}
+
+	valid, _ := rlp.EncodeToBytes([]*types.Header{{Number: big.NewInt(6), Difficulty: new(big.Int)}})
+	assert.Equal(t, valid, request(valid))
+	assert.Zero(t, r.backend.reputation.Score(enode.ID{1}))
+
+	// Conflicting headers are replaced by an empty response and penalized.
+	conflicting, _ := rlp.EncodeToBytes([]*types.Header{forged})
+	assert.Equal(t, emptyHeaders, request(conflicting))
+	assert.Less(t, r.backend.reputation.Score(enode.ID{1}), 0.0)
+}
@@ -0,0 +1,275 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Relay overlay.
+//
+// Relays announce themselves with a relay entry in their node record, so other
+// relays find them in discovery, and with the relay capability in the devp2p
+// handshake, so both ends of a connection know that it links two relays. Every
+// relay keeps a number of such links by dialing relays found in discovery as
+// static peers. Messages received over a link are forwarded to all other
+// peers and links. Every link remembers the messages sent and received over
+// it, and messages are never sent over a link that has them already: not back
+// to the relay they came from, and not around a cycle of links once the
+// validator has forgotten them.
+
+const (
+	overlayVersion       = 1
+	overlayProtocolName  = "relay"
+	overlayCheckInterval = 30 * time.Second // how often lost links are replaced
+	overlayKnownMessages = 16384            // messages remembered per link
+)
+
+// OverlayConfig configures the links to other relays.
+type OverlayConfig struct {
+	Links    int           // Relay-to-relay links kept (0 = only accept links)
+	Operator hexutil.Bytes // Compressed public key of the relay operator, announced in the node record
+}
+
+// relayENREntry is the ENR entry announcing a relay.
+type relayENREntry struct {
+	Version  uint
+	Operator []byte         // compressed public key of the operator, empty if not announced
+	Rest     []rlp.RawValue `rlp:"tail"`
+}
+
+// ENRKey implements enr.Entry.
+func (e relayENREntry) ENRKey() string {
+	return "relay"
+}
+
+// ParseOperatorKey checks that key is a compressed secp256k1 public key.
+func ParseOperatorKey(key []byte) (*ecdsa.PublicKey, error) {
+	pub, err := crypto.DecompressPubkey(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid operator key: %v", err)
+	}
+	return pub, nil
+}
+
+// IsRelayNode reports whether a node record announces a relay.
+func IsRelayNode(n *enode.Node) bool {
+	var entry relayENREntry
+	return n.Load(&entry) == nil
+}
+
+// RelayOperator returns the operator key announced by a relay. It returns nil
+// if the node is not a relay or announces no valid operator key.
+func RelayOperator(n *enode.Node) *ecdsa.PublicKey {
+	var entry relayENREntry
+	if n.Load(&entry) != nil || len(entry.Operator) == 0 {
+		return nil
+	}
+	pub, err := ParseOperatorKey(entry.Operator)
+	if err != nil {
+		return nil
+	}
+	return pub
+}
+
+// isOverlayPeer reports whether a connected peer is a relay.
+func isOverlayPeer(p *p2p.Peer) bool {
+	return p.RunningCap(overlayProtocolName, []uint{overlayVersion})
+}
+
+// overlayProtocols returns the relay capability. It has no messages, it only
+// marks the peer as a relay in the devp2p handshake.
+func overlayProtocols() []p2p.Protocol {
+	return []p2p.Protocol{{
+		Name:    overlayProtocolName,
+		Version: overlayVersion,
+		Length:  0,
+		Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+			msg, err := rw.ReadMsg()
+			if err != nil {
+				return err
+			}
+			msg.Discard()
+			return fmt.Errorf("unexpected relay overlay message %d", msg.Code)
+		},
+	}}
+}
+
+// staticDialer keeps peers connected, implemented by p2p.Server.
+type staticDialer interface {
+	AddPeer(node *enode.Node)
+	RemovePeer(node *enode.Node)
+}
+
+// overlay keeps the links to other relays.
+type overlay struct {
+	links      int
+	backend    *Backend
+	dialer     staticDialer
+	candidates enode.Iterator // relays found in discovery
+	interval   time.Duration
+
+	lock   sync.Mutex
+	dialed map[enode.ID]*dialedRelay // relays kept as static peers
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// dialedRelay is a relay kept as static peer.
+type dialedRelay struct {
+	node  *enode.Node
+	added time.Time
+}
+
+func newOverlay(links int, backend *Backend, dialer staticDialer, candidates enode.Iterator) *overlay {
+	return &overlay{
+		links:      links,
+		backend:    backend,
+		dialer:     dialer,
+		candidates: candidates,
+		interval:   overlayCheckInterval,
+		dialed:     make(map[enode.ID]*dialedRelay),
+		quit:       make(chan struct{}),
+	}
+}
+
+func (o *overlay) start() {
+	o.wg.Add(1)
+	go o.loop()
+}
+
+// stop closes the candidate iterator, releasing a pending lookup, and drops the
+// static peers.
+func (o *overlay) stop() {
+	close(o.quit)
+	o.candidates.Close()
+	o.wg.Wait()
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	for id, d := range o.dialed {
+		o.dialer.RemovePeer(d.node)
+		delete(o.dialed, id)
+	}
+}
+
+func (o *overlay) loop() {
+	defer o.wg.Done()
+
+	ticker := time.NewTicker(o.interval)
+	defer ticker.Stop()
+	for {
+		o.prune()
+		o.fill()
+		select {
+		case <-ticker.C:
+		case <-o.quit:
+			return
+		}
+	}
+}
+
+// prune gives up on dialed relays that did not connect within one check
+// interval, or were banned since.
+func (o *overlay) prune() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	for id, d := range o.dialed {
+		connected := o.backend.peers.Get(id) != nil
+		if o.backend.IsBanned(id) || (!connected && time.Since(d.added) >= o.interval) {
+			log.Debug("Dropping relay overlay link", "peer", id, "connected", connected)
+			o.dialer.RemovePeer(d.node)
+			delete(o.dialed, id)
+		}
+	}
+}
+
+// fill dials relays from discovery until the overlay has enough links. Relays
+// that linked to us count as well.
+func (o *overlay) fill() {
+	for o.missing() > 0 {
+		if !o.candidates.Next() {
+			return
+		}
+		n := o.candidates.Node()
+		if o.backend.IsBanned(n.ID()) {
+			continue
+		}
+		o.lock.Lock()
+		_, dialed := o.dialed[n.ID()]
+		if !dialed && o.backend.peers.Get(n.ID()) == nil {
+			log.Debug("Adding relay overlay link", "peer", n.ID(), "addr", n.IPAddr())
+			o.dialed[n.ID()] = &dialedRelay{node: n, added: time.Now()}
+			o.dialer.AddPeer(n)
+		}
+		o.lock.Unlock()
+	}
+}
+
+// missing returns the number of links to add.
+func (o *overlay) missing() int {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	links := len(o.dialed)
+	for _, p := range o.backend.Peers() {
+		if _, dialed := o.dialed[p.ID]; p.Overlay && !dialed {
+			links++
+		}
+	}
+	return o.links - links
+}
+
+// overlayMessageHash identifies a message sent over overlay links.
+func overlayMessageHash(msgCode uint64, payload []byte) common.Hash {
+	return crypto.Keccak256Hash(rlp.AppendUint64(nil, msgCode), payload)
+}
+
+// markKnown records that the relay at the other end of an overlay link has a
+// message. It returns false if it had the message already. Peers that are not
+// linked over the overlay never have messages.
+func (p *RelayPeer) markKnown(hash common.Hash) bool {
+	if p.known == nil {
+		return true
+	}
+	p.knownLock.Lock()
+	defer p.knownLock.Unlock()
+	if p.known.Contains(hash) {
+		return false
+	}
+	p.known.Add(hash, struct{}{})
+	return true
+}
+
+// isOverlayPeer reports whether a registered peer is linked over the overlay.
+func (b *Backend) isOverlayPeer(id enode.ID) bool {
+	peer := b.peers.Get(id)
+	return peer != nil && peer.Overlay
+}
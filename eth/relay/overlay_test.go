@@ -0,0 +1,298 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestOverlayPeer creates a peer linked over the overlay.
+func newTestOverlayPeer(id byte) *RelayPeer {
+	p := p2p.NewPeer(enode.ID{id}, "test", []p2p.Cap{{Name: "eth", Version: 69}, {Name: overlayProtocolName, Version: overlayVersion}})
+	return NewRelayPeer(p, 69, nil)
+}
+
+// newTestRelayNode creates a node record, announcing a relay if config is set.
+func newTestRelayNode(t *testing.T, config *Config) *enode.Node {
+	t.Helper()
+	db, _ := enode.OpenDB("")
+	t.Cleanup(db.Close)
+	key, _ := crypto.GenerateKey()
+	ln := enode.NewLocalNode(db, key)
+	if config != nil {
+		StartRelayENRUpdater(ln, config)
+	}
+	return ln.Node()
+}
+
+func TestRelayENREntry(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	operator := crypto.CompressPubkey(&key.PublicKey)
+	config := &Config{ForkID: forkid.ID{Hash: [4]byte{1, 2, 3, 4}}}
+
+	plain := newTestRelayNode(t, config)
+	assert.True(t, IsRelayNode(plain))
+	assert.Nil(t, RelayOperator(plain))
+
+	config.Overlay.Operator = operator
+	announced := newTestRelayNode(t, config)
+	assert.True(t, IsRelayNode(announced))
+	assert.Equal(t, &key.PublicKey, RelayOperator(announced))
+
+	assert.False(t, IsRelayNode(newTestRelayNode(t, nil)))
+
+	_, err := ParseOperatorKey(operator[1:])
+	assert.Error(t, err)
+}
+
+func TestOverlayNodeFilter(t *testing.T) {
+	config := &Config{ForkID: forkid.ID{Hash: [4]byte{1, 2, 3, 4}}}
+	relayNode := newTestRelayNode(t, config)
+	self := newTestRelayNode(t, config)
+	other := newTestRelayNode(t, &Config{ForkID: forkid.ID{Hash: [4]byte{5, 6, 7, 8}, Next: 1}})
+
+	filter := newOverlayNodeFilter(&Config{ForkID: config.ForkID, Overlay: OverlayConfig{Links: 1}}, self.ID())
+	assert.True(t, filter(relayNode))
+	assert.False(t, filter(self), "local node accepted")
+	assert.False(t, filter(newTestRelayNode(t, nil)), "node without records accepted")
+	assert.True(t, filter(other), "relay of a compatible fork rejected")
+}
+
+func TestRouterOverlayForward(t *testing.T) {
+	r := newTestRelay()
+	defer r.router.Stop()
+	defer r.backend.Stop()
+	r.backend.AddPeer(newTestRelayPeer(1, nil))
+	r.backend.AddPeer(newTestOverlayPeer(2))
+	r.backend.AddPeer(newTestOverlayPeer(3))
+
+	// Messages from regular peers go to the overlay.
+	require.NoError(t, r.router.ForwardMessage(enode.ID{1}, 0x07, []byte{0xc1, 0x01}))
+	stats := r.router.QueueStats()
+	assert.Contains(t, stats, enode.ID{2})
+	assert.Contains(t, stats, enode.ID{3})
+
+	// Messages from the overlay go to all other peers and links, but not back
+	// over the link they arrived on.
+	r.router.removeQueue(enode.ID{1})
+	r.router.removeQueue(enode.ID{2})
+	r.router.removeQueue(enode.ID{3})
+	require.NoError(t, r.router.ForwardMessage(enode.ID{2}, 0x07, []byte{0xc1, 0x02}))
+	stats = r.router.QueueStats()
+	assert.Contains(t, stats, enode.ID{1})
+	assert.Contains(t, stats, enode.ID{3})
+	assert.NotContains(t, stats, enode.ID{2})
+
+	// The message coming back over another link is not forwarded again.
+	r.router.removeQueue(enode.ID{1})
+	r.router.removeQueue(enode.ID{3})
+	require.NoError(t, r.router.ForwardMessage(enode.ID{3}, 0x07, []byte{0xc1, 0x02}))
+	assert.Empty(t, r.router.QueueStats())
+}
+
+// testLinkConn is one direction of an overlay link between two test relays,
+// counting the messages it carries.
+type testLinkConn struct {
+	testPeerConn
+	from   enode.ID // ID of the sending relay at the other end
+	to     *MessageRouter
+	counts map[string]int
+}
+
+func (c *testLinkConn) Send(msgCode uint64, payload []byte) error {
+	c.lock.Lock()
+	c.counts[string(payload)]++
+	c.lock.Unlock()
+	return c.to.ForwardMessage(c.from, msgCode, payload)
+}
+
+func (c *testLinkConn) count(payload []byte) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.counts[string(payload)]
+}
+
+// countMsgs counts the messages a test peer received per payload.
+func (c *testPeerConn) countMsgs() map[string]int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	counts := make(map[string]int)
+	for i := range c.msgs {
+		payload := make([]byte, c.msgs[i].Size)
+		c.msgs[i].Payload.Read(payload)
+		c.msgs[i].Payload = bytes.NewReader(payload)
+		counts[string(payload)]++
+	}
+	return counts
+}
+
+func TestOverlayDeliverOnce(t *testing.T) {
+	t.Run("dedup", func(t *testing.T) { testOverlayDeliverOnce(t, false) })
+	t.Run("evicted", func(t *testing.T) { testOverlayDeliverOnce(t, true) })
+}
+
+// testOverlayDeliverOnce links three relays with one regular peer each and
+// checks that messages from each peer reach all others without circling. If
+// evict is set, the validators forget messages right away.
+func testOverlayDeliverOnce(t *testing.T, evict bool) {
+	const n = 3
+	var (
+		relays = make([]*Relay, n)
+		peers  = make([]*testPeerConn, n)
+		links  []*testLinkConn
+	)
+	for i := range relays {
+		relays[i] = newTestRelay()
+		defer relays[i].backend.Stop()
+		defer relays[i].router.Stop()
+		if evict {
+			v := relays[i].router.validator
+			v.lock.Lock()
+			v.msgs = lru.NewBasicLRU[common.Hash, struct{}](1)
+			v.lock.Unlock()
+		}
+		peers[i] = &testPeerConn{}
+		relays[i].backend.AddPeer(newTestRelayPeer(byte(i+1), peers[i]))
+	}
+	// Relay i reaches relay j over the link with ID 0x10+j.
+	for i := range relays {
+		for j := range relays {
+			if i == j {
+				continue
+			}
+			link := &testLinkConn{from: enode.ID{byte(0x10 + i)}, to: relays[j].router, counts: make(map[string]int)}
+			p := p2p.NewPeer(enode.ID{byte(0x10 + j)}, "test", []p2p.Cap{{Name: "eth", Version: 69}, {Name: overlayProtocolName, Version: overlayVersion}})
+			relays[i].backend.AddPeer(NewRelayPeer(p, 69, link))
+			links = append(links, link)
+		}
+	}
+
+	// Every regular peer sends a number of messages.
+	var payloads [][]byte
+	for i := range relays {
+		for k := 0; k < 4; k++ {
+			payload := []byte{0xc2, byte(i), byte(k)}
+			payloads = append(payloads, payload)
+			require.NoError(t, relays[i].router.ForwardMessage(enode.ID{byte(i + 1)}, 0x07, payload))
+		}
+	}
+	delivered := func() bool {
+		for i := range peers {
+			counts := peers[i].countMsgs()
+			for _, payload := range payloads {
+				if int(payload[1]) != i && counts[string(payload)] == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	require.Eventually(t, delivered, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond) // let circling messages show up
+
+	for _, link := range links {
+		for _, payload := range payloads {
+			assert.LessOrEqual(t, link.count(payload), 1, "message %x carried twice over a link", payload)
+		}
+	}
+	if evict {
+		return // relays may deliver a message once per link it arrives over
+	}
+	for i := range peers {
+		counts := peers[i].countMsgs()
+		for _, payload := range payloads {
+			want := 1
+			if int(payload[1]) == i {
+				want = 0
+			}
+			assert.Equal(t, want, counts[string(payload)], "peer %d, message %x", i+1, payload)
+		}
+	}
+}
+
+// testDialer records the static peers of the overlay.
+type testDialer struct {
+	mu     sync.Mutex
+	static map[enode.ID]bool
+}
+
+func (d *testDialer) AddPeer(n *enode.Node) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.static[n.ID()] = true
+}
+
+func (d *testDialer) RemovePeer(n *enode.Node) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.static, n.ID())
+}
+
+func (d *testDialer) peers() map[enode.ID]bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	peers := make(map[enode.ID]bool, len(d.static))
+	for id := range d.static {
+		peers[id] = true
+	}
+	return peers
+}
+
+func TestOverlayLinks(t *testing.T) {
+	config := &Config{}
+	nodes := []*enode.Node{
+		newTestRelayNode(t, config),
+		newTestRelayNode(t, config),
+		newTestRelayNode(t, config),
+		newTestRelayNode(t, config),
+	}
+	backend := NewBackend(config, nil)
+	dialer := &testDialer{static: make(map[enode.ID]bool)}
+	o := newOverlay(2, backend, dialer, enode.IterNodes(nodes))
+
+	// A relay linked to us counts towards the links.
+	backend.AddPeer(newTestOverlayPeer(1))
+	o.fill()
+	assert.Equal(t, map[enode.ID]bool{nodes[0].ID(): true}, dialer.peers())
+
+	// Dialed relays that do not connect are replaced.
+	o.interval = time.Millisecond
+	time.Sleep(2 * time.Millisecond)
+	o.prune()
+	o.fill()
+	assert.Equal(t, map[enode.ID]bool{nodes[1].ID(): true}, dialer.peers())
+
+	// Connected relays are kept, stopping drops the static peers.
+	o.dialed[nodes[1].ID()].added = time.Now().Add(-time.Hour)
+	backend.AddPeer(NewRelayPeer(p2p.NewPeer(nodes[1].ID(), "test", []p2p.Cap{{Name: overlayProtocolName, Version: overlayVersion}}), 69, nil))
+	o.prune()
+	assert.Len(t, dialer.peers(), 1)
+	o.stop()
+	assert.Empty(t, dialer.peers())
+}
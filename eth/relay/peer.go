@@ -18,20 +18,103 @@ package relay
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 )
 
 // RelayPeer represents a peer connection in relay mode.
 type RelayPeer struct {
-	ID       enode.ID
-	Peer     *p2p.Peer
-	Version  uint
-	Inbound  bool
-	AddedAt  time.Time
-	connLock sync.RWMutex
+	ID        enode.ID
+	Peer      *p2p.Peer
+	Version   uint
+	Inbound   bool
+	Transport string // onion, i2p or clearnet
+	Overlay   bool   // peer is a relay linked over the overlay
+	AddedAt   time.Time
+	connLock  sync.RWMutex
+
+	conn  PeerConn // protocol handler side of the connection, may be nil
+	stats peerStats
+
+	// Messages sent or received over an overlay link, nil for other peers
+	known     *lru.BasicLRU[common.Hash, struct{}]
+	knownLock sync.Mutex
+}
+
+// PeerConn is implemented by the protocol handler serving a relay peer.
+type PeerConn interface {
+	// BlockRange returns the block range last announced by the peer.
+	BlockRange() (BlockRange, bool)
+
+	// SendBlockRange announces our block range to the peer.
+	SendBlockRange(BlockRange) error
+
+	// Send writes a message with an RLP encoded payload to the peer.
+	Send(msgCode uint64, payload []byte) error
+}
+
+// PeerStats contains the relay counters of a single peer.
+type PeerStats struct {
+	Received   uint64 `json:"received"`   // messages received from the peer for relaying
+	Forwarded  uint64 `json:"forwarded"`  // messages forwarded to the peer
+	Requests   uint64 `json:"requests"`   // requests proxied to the peer
+	Timeouts   uint64 `json:"timeouts"`   // proxied requests the peer did not answer in time
+	Rejected   uint64 `json:"rejected"`   // messages from the peer failing validation
+	Duplicates uint64 `json:"duplicates"` // messages from the peer that were already relayed
+	Throttled  uint64 `json:"throttled"`  // messages to or from the peer dropped by bandwidth limits
+	Dropped    uint64 `json:"dropped"`    // messages to the peer dropped because its queue was full
+}
+
+type peerStats struct {
+	received, forwarded, requests, timeouts  atomic.Uint64
+	rejected, duplicates, throttled, dropped atomic.Uint64
+}
+
+// NewRelayPeer creates a relay peer for a connection that completed the
+// protocol handshake.
+func NewRelayPeer(p *p2p.Peer, version uint, conn PeerConn) *RelayPeer {
+	peer := &RelayPeer{
+		ID:        p.ID(),
+		Peer:      p,
+		Version:   version,
+		Inbound:   p.Inbound(),
+		Transport: p.Transport(),
+		Overlay:   isOverlayPeer(p),
+		AddedAt:   time.Now(),
+		conn:      conn,
+	}
+	if peer.Overlay {
+		known := lru.NewBasicLRU[common.Hash, struct{}](overlayKnownMessages)
+		peer.known = &known
+	}
+	return peer
+}
+
+// BlockRange returns the block range last announced by the peer.
+func (p *RelayPeer) BlockRange() (BlockRange, bool) {
+	if p.conn == nil {
+		return BlockRange{}, false
+	}
+	return p.conn.BlockRange()
+}
+
+// Stats returns a snapshot of the peer's relay counters.
+func (p *RelayPeer) Stats() PeerStats {
+	return PeerStats{
+		Received:   p.stats.received.Load(),
+		Forwarded:  p.stats.forwarded.Load(),
+		Requests:   p.stats.requests.Load(),
+		Timeouts:   p.stats.timeouts.Load(),
+		Rejected:   p.stats.rejected.Load(),
+		Duplicates: p.stats.duplicates.Load(),
+		Throttled:  p.stats.throttled.Load(),
+		Dropped:    p.stats.dropped.Load(),
+	}
 }
 
 // RelayPeerSet manages the set of relay peers.
@@ -0,0 +1,200 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// pendingTxCache is the number of recent transactions kept for RPC.
+const pendingTxCache = 8192
+
+var errSlowSubscriber = errors.New("pending transaction subscriber too slow")
+
+// pendingTxs keeps the valid transactions recently received from peers, so
+// they can be served over RPC without a transaction pool, and counts the
+// arrivals of every connected peer. New transactions are sent to subscribers
+// without blocking, the peer handlers must not wait for RPC clients.
+type pendingTxs struct {
+	lock  sync.Mutex
+	txs   lru.BasicLRU[common.Hash, *types.Transaction]
+	peers map[enode.ID]*PendingPeerStats
+	subs  map[*pendingSub]struct{} // subscribers to new transactions
+}
+
+// pendingSub is a subscription to new transactions. Subscribers whose channel
+// is full are dropped with errSlowSubscriber.
+type pendingSub struct {
+	pending *pendingTxs
+	ch      chan<- []*types.Transaction
+	err     chan error
+	once    sync.Once
+}
+
+// PendingPeerStats counts the transactions that arrived from a peer.
+type PendingPeerStats struct {
+	Received  uint64 `json:"received"`  // valid transactions received
+	First     uint64 `json:"first"`     // transactions received before any other peer sent them
+	Announced uint64 `json:"announced"` // transaction hashes announced
+}
+
+// PendingStats describes the recent transaction cache.
+type PendingStats struct {
+	Cached   int                           `json:"cached"`
+	Capacity int                           `json:"capacity"`
+	Peers    map[enode.ID]PendingPeerStats `json:"peers"` // connected peers only
+}
+
+func newPendingTxs() *pendingTxs {
+	return &pendingTxs{
+		txs:   lru.NewBasicLRU[common.Hash, *types.Transaction](pendingTxCache),
+		peers: make(map[enode.ID]*PendingPeerStats),
+		subs:  make(map[*pendingSub]struct{}),
+	}
+}
+
+// add records valid transactions received from a peer. Transactions not seen
+// before are sent to the subscribers.
+func (p *pendingTxs) add(from enode.ID, txs []*types.Transaction) {
+	var fresh []*types.Transaction
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	stats := p.stats(from)
+	for _, tx := range txs {
+		stats.Received++
+		if p.txs.Contains(tx.Hash()) {
+			continue
+		}
+		stats.First++
+		p.txs.Add(tx.Hash(), tx)
+		fresh = append(fresh, tx)
+	}
+	if len(fresh) == 0 {
+		return
+	}
+	for sub := range p.subs {
+		select {
+		case sub.ch <- fresh:
+		default:
+			delete(p.subs, sub)
+			sub.close(errSlowSubscriber)
+		}
+	}
+}
+
+// subscribe registers a channel for new transactions.
+func (p *pendingTxs) subscribe(ch chan<- []*types.Transaction) *pendingSub {
+	sub := &pendingSub{pending: p, ch: ch, err: make(chan error, 1)}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.subs[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe implements event.Subscription.
+func (s *pendingSub) Unsubscribe() {
+	s.pending.lock.Lock()
+	delete(s.pending.subs, s)
+	s.pending.lock.Unlock()
+	s.close(nil)
+}
+
+// Err implements event.Subscription.
+func (s *pendingSub) Err() <-chan error {
+	return s.err
+}
+
+func (s *pendingSub) close(err error) {
+	s.once.Do(func() {
+		if err != nil {
+			s.err <- err
+		}
+		close(s.err)
+	})
+}
+
+// announced records transaction hashes announced by a peer.
+func (p *pendingTxs) announced(from enode.ID, n int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.stats(from).Announced += uint64(n)
+}
+
+// stats returns the counters of a peer. It must be called with the lock held.
+func (p *pendingTxs) stats(id enode.ID) *PendingPeerStats {
+	stats := p.peers[id]
+	if stats == nil {
+		stats = new(PendingPeerStats)
+		p.peers[id] = stats
+	}
+	return stats
+}
+
+// get returns a recent transaction, or nil if it is not cached.
+func (p *pendingTxs) get(hash common.Hash) *types.Transaction {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	tx, _ := p.txs.Peek(hash)
+	return tx
+}
+
+// removePeer drops the counters of a disconnected peer.
+func (p *pendingTxs) removePeer(id enode.ID) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.peers, id)
+}
+
+func (p *pendingTxs) info() *PendingStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	info := &PendingStats{
+		Cached:   p.txs.Len(),
+		Capacity: pendingTxCache,
+		Peers:    make(map[enode.ID]PendingPeerStats, len(p.peers)),
+	}
+	for id, stats := range p.peers {
+		info.Peers[id] = *stats
+	}
+	return info
+}
+
+// PendingTransaction returns a transaction recently received from a peer, or
+// nil if it is not cached.
+func (b *Backend) PendingTransaction(hash common.Hash) *types.Transaction {
+	return b.pending.get(hash)
+}
+
+// SubscribePendingTransactions subscribes to the transactions received from
+// peers. Every transaction is sent once, when it first arrives. Sends do not
+// block: if ch is full, the subscription ends with an error.
+func (b *Backend) SubscribePendingTransactions(ch chan<- []*types.Transaction) event.Subscription {
+	return b.pending.subscribe(ch)
+}
+
+// PendingStats returns the state of the recent transaction cache and the
+// arrival counts of the connected peers.
+func (b *Backend) PendingStats() *PendingStats {
+	return b.pending.info()
+}
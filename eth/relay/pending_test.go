@@ -0,0 +1,87 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPendingTransactions(t *testing.T) {
+	r := newTestRelay()
+	api := NewAPI(r)
+	b := r.backend
+	require.True(t, b.AddPeer(newTestRelayPeer(1, nil)))
+	require.True(t, b.AddPeer(newTestRelayPeer(2, nil)))
+
+	ch := make(chan []*types.Transaction, 10)
+	sub := b.SubscribePendingTransactions(ch)
+	defer sub.Unsubscribe()
+
+	// Only transactions not seen before reach subscribers.
+	tx1, tx2, tx3 := newTestTx(1), newTestTx(2), newTestTx(3)
+	b.CheckTransactions(enode.ID{1}, []*types.Transaction{tx1, tx2})
+	b.CheckTransactions(enode.ID{2}, []*types.Transaction{tx2, tx3})
+	b.TransactionsAnnounced(enode.ID{2}, []common.Hash{tx1.Hash()})
+	assert.Equal(t, []*types.Transaction{tx1, tx2}, <-ch)
+	assert.Equal(t, []*types.Transaction{tx3}, <-ch)
+	assert.Empty(t, ch)
+
+	assert.Equal(t, tx2.Hash(), b.PendingTransaction(tx2.Hash()).Hash())
+	assert.Nil(t, b.PendingTransaction(common.Hash{1}))
+
+	stats := api.PendingStats()
+	assert.Equal(t, 3, stats.Cached)
+	assert.Equal(t, PendingPeerStats{Received: 2, First: 2}, stats.Peers[enode.ID{1}])
+	assert.Equal(t, PendingPeerStats{Received: 2, First: 1, Announced: 1}, stats.Peers[enode.ID{2}])
+
+	// Invalid transactions are not cached, disconnected peers not listed.
+	invalid := types.NewTx(&types.LegacyTx{Nonce: 4, Gas: 1, To: &common.Address{1}, GasPrice: common.Big1})
+	assert.Equal(t, 1, b.CheckTransactions(enode.ID{1}, []*types.Transaction{invalid}))
+	assert.Nil(t, b.PendingTransaction(invalid.Hash()))
+	b.RemovePeer(enode.ID{1})
+	assert.NotContains(t, api.PendingStats().Peers, enode.ID{1})
+}
+
+func TestPendingSlowSubscriber(t *testing.T) {
+	b := newTestRelay().backend
+	slow := make(chan []*types.Transaction, 1)
+	slowSub := b.SubscribePendingTransactions(slow)
+	defer slowSub.Unsubscribe()
+	ch := make(chan []*types.Transaction, 10)
+	sub := b.SubscribePendingTransactions(ch)
+	defer sub.Unsubscribe()
+
+	// A full subscriber does not hold up the peers, it is dropped instead.
+	for nonce := uint64(1); nonce <= 3; nonce++ {
+		b.CheckTransactions(enode.ID{1}, []*types.Transaction{newTestTx(nonce)})
+	}
+	assert.ErrorIs(t, <-slowSub.Err(), errSlowSubscriber)
+	assert.Len(t, slow, 1)
+	assert.Len(t, ch, 3)
+
+	// Unsubscribing twice is fine, the error channel is closed.
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+	_, open := <-sub.Err()
+	assert.False(t, open)
+}
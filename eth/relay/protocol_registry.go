@@ -17,6 +17,7 @@
 package relay
 
 import (
+	"github.com/ethereum/go-ethereum/eth/protocols/pex"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
@@ -36,8 +37,27 @@ func (r *Relay) RegisterProtocols(stack *node.Node) error {
 	if protocolRegistry == nil {
 		return nil // No registry set, skip protocol registration
 	}
-	discCandidates := MakeRelayDialCandidates(r.p2pServer, r.config)
+	// Banned peers would be refused after the handshake, don't dial them.
+	notBanned := func(n *enode.Node) bool { return !r.backend.IsBanned(n.ID()) }
+	discCandidates := enode.Filter(MakeRelayDialCandidates(r.p2pServer, r.config), notBanned)
 	protocols := protocolRegistry(r.backend, r.networkID, discCandidates)
+
+	// Discv4/v5 cannot run over Tor, so onion-only relays learn their peers
+	// from the records exchanged with connected nodes instead.
+	if r.config.PeerExchange {
+		relayFilter := newRelayNodeFilter(r.config)
+		r.pex = pex.NewExchange(pex.Config{
+			Self:   r.p2pServer.Self,
+			Filter: func(n *enode.Node) bool {
+				return notBanned(n) && relayFilter(n)
+			},
+		})
+		protocols = append(protocols, r.pex.Protocols()...)
+	}
+	if r.stem != nil {
+		protocols = append(protocols, r.stem.Protocols()...)
+	}
+	protocols = append(protocols, overlayProtocols()...)
 	stack.RegisterProtocols(protocols)
 	return nil
 }
@@ -17,12 +17,15 @@
 package relay
 
 import (
+	"context"
 	"errors"
+	"math/rand"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 var (
@@ -30,8 +33,12 @@ var (
 	ErrRequestTimeout       = errors.New("request timeout")
 	ErrUnknownRequest       = errors.New("unknown request ID")
 	ErrUnexpectedResponsePeer = errors.New("response from unexpected peer")
+	ErrResponseMismatch     = errors.New("response does not match request")
 )
 
+// emptyHeaders is the BlockHeaders response without headers.
+var emptyHeaders = []byte{0xc0}
+
 // PendingRequest represents a pending request-response pair.
 type PendingRequest struct {
 	RequestID    uint64
@@ -104,7 +111,8 @@ type RequestProxy struct {
 	wg             sync.WaitGroup
 }
 
-// NewRequestProxy creates a new request proxy.
+// NewRequestProxy creates a new request proxy. The backend delivers the
+// responses of peers to it.
 func NewRequestProxy(backend *Backend, selector PeerSelector, timeout time.Duration) *RequestProxy {
 	proxy := &RequestProxy{
 		backend:        backend,
@@ -113,6 +121,7 @@ func NewRequestProxy(backend *Backend, selector PeerSelector, timeout time.Durat
 		requestTimeout: timeout,
 		quit:          make(chan struct{}),
 	}
+	backend.proxy.Store(proxy)
 
 	// Start cleanup ticker to remove timed-out requests
 	proxy.cleanupTicker = time.NewTicker(5 * time.Second)
@@ -158,6 +167,7 @@ func (rp *RequestProxy) ProxyRequest(fromPeer enode.ID, msgCode uint64, requestI
 		log.Trace("No target peer available for request proxying",
 			"from", fromPeer.String()[:16]+"...",
 			"code", msgCodeToString(msgCode))
+		rp.replyEmpty(fromPeer, msgCode, requestID)
 		return ErrNoTargetPeer
 	}
 
@@ -168,58 +178,144 @@ func (rp *RequestProxy) ProxyRequest(fromPeer enode.ID, msgCode uint64, requestI
 		"requestID", requestID,
 		"size", len(payload))
 
-	// Store pending request
-	pending := &PendingRequest{
-		RequestID:     requestID,
-		FromPeer:      fromPeer,
-		ToPeer:        targetPeer,
-		MsgCode:       msgCode,
-		ResponseChan:  make(chan []byte, 1),
-		Timeout:       time.Now().Add(rp.requestTimeout),
+	response, err := rp.roundTrip(context.Background(), fromPeer, targetPeer, msgCode, payload)
+	if err != nil {
+		log.Debug("Proxied request failed",
+			"from", fromPeer.String()[:16]+"...",
+			"to", targetPeer.String()[:16]+"...",
+			"code", msgCodeToString(msgCode),
+			"requestID", requestID,
+			"err", err)
+		rp.replyEmpty(fromPeer, msgCode, requestID)
+		return err
 	}
+	// Forward response back to original requester, under its request ID
+	responseMsgCode := getResponseMsgCode(msgCode)
+	log.Trace("Proxying response back",
+		"to", fromPeer.String()[:16]+"...",
+		"code", msgCodeToString(responseMsgCode),
+		"requestID", requestID,
+		"size", len(response))
+	return rp.backend.sendPacket(fromPeer, responseMsgCode, requestID, response)
+}
+
+// replyEmpty answers a request that could not be proxied with an empty
+// response, so the requester does not wait for it to time out.
+func (rp *RequestProxy) replyEmpty(fromPeer enode.ID, msgCode uint64, requestID uint64) {
+	if err := rp.backend.sendPacket(fromPeer, getResponseMsgCode(msgCode), requestID, rlp.EmptyList); err != nil {
+		log.Trace("Failed to answer unproxied request", "to", fromPeer, "err", err)
+	}
+}
+
+// Request sends a request of the relay itself to a peer and returns the
+// response payload. Unlike proxied requests, the response is not forwarded to
+// another peer. Local requests are listed as pending with a zero origin.
+func (rp *RequestProxy) Request(ctx context.Context, peer enode.ID, msgCode uint64, payload []byte) ([]byte, error) {
+	return rp.roundTrip(ctx, enode.ID{}, peer, msgCode, payload)
+}
 
+// roundTrip sends a request to a peer under a fresh request ID and waits for
+// the response, which the backend delivers to HandleResponse. The payload is
+// the request without its request ID.
+func (rp *RequestProxy) roundTrip(ctx context.Context, fromPeer, toPeer enode.ID, msgCode uint64, payload []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	pending := &PendingRequest{
+		FromPeer:     fromPeer,
+		ToPeer:       toPeer,
+		MsgCode:      msgCode,
+		ResponseChan: make(chan []byte, 1),
+		Timeout:      time.Now().Add(rp.requestTimeout),
+	}
 	rp.requestLock.Lock()
-	rp.pendingRequests[requestID] = pending
+	for {
+		pending.RequestID = rand.Uint64()
+		if _, exists := rp.pendingRequests[pending.RequestID]; !exists {
+			break
+		}
+	}
+	rp.pendingRequests[pending.RequestID] = pending
 	rp.requestLock.Unlock()
+	rp.backend.totals.requests.Add(1)
+	stats := rp.backend.peerStats(toPeer)
+	if stats != nil {
+		stats.requests.Add(1)
+	}
 
-	// Forward request to target
-	err := rp.backend.sendToPeer(targetPeer, msgCode, payload)
-	if err != nil {
-		rp.removePendingRequest(requestID)
-		log.Debug("Failed to send proxied request", "err", err)
-		return err
+	if err := rp.backend.sendPacket(toPeer, msgCode, pending.RequestID, payload); err != nil {
+		rp.removePendingRequest(pending.RequestID)
+		rp.backend.totals.failed.Add(1)
+		return nil, err
 	}
+	timeout := time.NewTimer(rp.requestTimeout)
+	defer timeout.Stop()
 
-	// Wait for response (with timeout)
 	select {
-	case response := <-pending.ResponseChan:
-		if response == nil {
-			log.Debug("Proxied request timed out",
-				"from", fromPeer.String()[:16]+"...",
-				"to", targetPeer.String()[:16]+"...",
-				"code", msgCodeToString(msgCode),
-				"requestID", requestID)
-			return ErrRequestTimeout
+	case response, ok := <-pending.ResponseChan:
+		if !ok {
+			// Closed by the cleanup loop or by Stop
+			rp.requestTimedOut(stats, toPeer, pending.RequestID)
+			return nil, ErrRequestTimeout
 		}
-		// Forward response back to original requester
-		responseMsgCode := getResponseMsgCode(msgCode)
-		log.Trace("Proxying response back",
-			"to", fromPeer.String()[:16]+"...",
-			"code", msgCodeToString(responseMsgCode),
-			"requestID", requestID,
-			"size", len(response))
-		return rp.backend.sendToPeer(fromPeer, responseMsgCode, response)
-	case <-time.After(rp.requestTimeout):
-		rp.removePendingRequest(requestID)
-		log.Debug("Proxied request timed out waiting for response",
-			"from", fromPeer.String()[:16]+"...",
-			"to", targetPeer.String()[:16]+"...",
-			"code", msgCodeToString(msgCode),
-			"requestID", requestID)
-		return ErrRequestTimeout
+		rp.backend.totals.answered.Add(1)
+		return response, nil
+	case <-timeout.C:
+		rp.removePendingRequest(pending.RequestID)
+		rp.requestTimedOut(stats, toPeer, pending.RequestID)
+		return nil, ErrRequestTimeout
+	case <-ctx.Done():
+		rp.removePendingRequest(pending.RequestID)
+		return nil, ctx.Err()
 	}
 }
 
+// requestTimedOut accounts a proxied request the target peer did not answer.
+// Requests aborted by a drain or shutdown are not held against the peer.
+func (rp *RequestProxy) requestTimedOut(stats *peerStats, targetPeer enode.ID, requestID uint64) {
+	select {
+	case <-rp.quit:
+		return
+	default:
+	}
+	if rp.backend.Draining() {
+		return
+	}
+	rp.backend.totals.failed.Add(1)
+	if stats != nil {
+		stats.timeouts.Add(1)
+	}
+	rp.backend.events.Send(Event{Type: EventRequestTimeout, Time: time.Now(), Peer: &targetPeer, RequestID: requestID})
+	rp.backend.ReportPeer(targetPeer, MisbehaviourTimeout)
+}
+
+// PendingRequestInfo describes a request awaiting its response.
+type PendingRequestInfo struct {
+	RequestID uint64    `json:"requestId"`
+	From      enode.ID  `json:"from"`
+	To        enode.ID  `json:"to"`
+	Code      string    `json:"code"`
+	Deadline  time.Time `json:"deadline"`
+}
+
+// Pending returns the requests currently awaiting a response.
+func (rp *RequestProxy) Pending() []PendingRequestInfo {
+	rp.requestLock.RLock()
+	defer rp.requestLock.RUnlock()
+
+	pending := make([]PendingRequestInfo, 0, len(rp.pendingRequests))
+	for _, req := range rp.pendingRequests {
+		pending = append(pending, PendingRequestInfo{
+			RequestID: req.RequestID,
+			From:      req.FromPeer,
+			To:        req.ToPeer,
+			Code:      msgCodeToString(req.MsgCode),
+			Deadline:  req.Timeout,
+		})
+	}
+	return pending
+}
+
 // HandleResponse processes response from target peer.
 func (rp *RequestProxy) HandleResponse(fromPeer enode.ID, msgCode uint64, requestID uint64, payload []byte) error {
 	rp.requestLock.Lock()
@@ -240,8 +336,35 @@ func (rp *RequestProxy) HandleResponse(fromPeer enode.ID, msgCode uint64, reques
 			"expected", pending.ToPeer.String()[:16]+"...",
 			"got", fromPeer.String()[:16]+"...",
 			"requestID", requestID)
+		rp.backend.ReportPeer(fromPeer, MisbehaviourMismatch)
 		return ErrUnexpectedResponsePeer
 	}
+	if want := getResponseMsgCode(pending.MsgCode); msgCode != want {
+		log.Debug("Response with unexpected message code",
+			"from", fromPeer.String()[:16]+"...",
+			"code", msgCodeToString(msgCode),
+			"want", msgCodeToString(want),
+			"requestID", requestID)
+		rp.backend.ReportPeer(fromPeer, MisbehaviourMismatch)
+		return ErrResponseMismatch
+	}
+
+	// Headers conflicting with the finalized chain are not passed on, the
+	// requester gets an empty response instead.
+	if msgCode == blockHeadersMsg {
+		if err := rp.backend.checkHeaders(payload); err != nil {
+			log.Debug("Dropped invalid block headers",
+				"from", fromPeer.String()[:16]+"...",
+				"requestID", requestID,
+				"err", err)
+			if errors.Is(err, errDecode) {
+				rp.backend.ReportPeer(fromPeer, MisbehaviourDecode)
+			} else {
+				rp.backend.ReportPeer(fromPeer, MisbehaviourBadHeader)
+			}
+			payload = emptyHeaders
+		}
+	}
 
 	log.Trace("Received proxied response",
 		"from", fromPeer.String()[:16]+"...",
@@ -249,19 +372,21 @@ func (rp *RequestProxy) HandleResponse(fromPeer enode.ID, msgCode uint64, reques
 		"requestID", requestID,
 		"size", len(payload))
 
-	// Send response to original requester
-	select {
-	case pending.ResponseChan <- payload:
-		log.Trace("Delivered proxied response to requester",
-			"requestID", requestID,
-			"from", fromPeer.String()[:16]+"...",
-			"to", pending.FromPeer.String()[:16]+"...")
-	default:
-		// Channel already closed or full
-		log.Debug("Failed to deliver proxied response, channel closed or full", "requestID", requestID)
+	// Claim the request before answering it, it may have timed out in the
+	// meantime. Claimed requests are not closed by anyone else.
+	rp.requestLock.Lock()
+	if rp.pendingRequests[requestID] != pending {
+		rp.requestLock.Unlock()
+		return ErrUnknownRequest
 	}
+	delete(rp.pendingRequests, requestID)
+	rp.requestLock.Unlock()
 
-	rp.removePendingRequest(requestID)
+	pending.ResponseChan <- payload
+	log.Trace("Delivered proxied response to requester",
+		"requestID", requestID,
+		"from", fromPeer.String()[:16]+"...",
+		"to", pending.FromPeer.String()[:16]+"...")
 	return nil
 }
 
@@ -278,6 +403,7 @@ func (rp *RequestProxy) removePendingRequest(requestID uint64) {
 
 // Stop stops the proxy.
 func (rp *RequestProxy) Stop() {
+	rp.backend.proxy.CompareAndSwap(rp, nil)
 	close(rp.quit)
 	rp.cleanupTicker.Stop()
 	rp.wg.Wait()
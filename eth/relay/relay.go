@@ -17,15 +17,23 @@
 package relay
 
 import (
+	"context"
+	"errors"
 	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/protocols/pex"
+	"github.com/ethereum/go-ethereum/eth/protocols/stem"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
+var errRelayStopped = errors.New("relay is not running")
+
 // ProtocolRegistrar is an interface for registering protocols.
 // This helps avoid import cycles by allowing the relay package
 // to request protocol registration without directly importing eth/protocols/eth.
@@ -40,6 +48,11 @@ type Relay struct {
 	proxy      *RequestProxy
 	p2pServer  *p2p.Server
 	discmix    *enode.FairMix
+	pex        *pex.Exchange
+	stem       *stem.Router
+	dandelion  *dandelion
+	light      *lightClient
+	overlay    *overlay
 	stack      *node.Node
 	config     *Config
 	networkID  uint64
@@ -50,9 +63,18 @@ type Relay struct {
 
 // NewRelay creates a new relay service.
 func NewRelay(stack *node.Node, config *Config, networkID uint64, registrar ProtocolRegistrar) (*Relay, error) {
+	// Defaults are filled in on a copy, the caller's configuration is left as is
+	cfg := *config
+	config = &cfg
+	if config.BanList == "" {
+		config.BanList = stack.ResolvePath(datadirBanList)
+	}
 	backend := NewBackend(config, stack.Server())
-	
-	return &Relay{
+	if err := backend.reputation.Load(); err != nil {
+		return nil, err
+	}
+
+	r := &Relay{
 		backend:           backend,
 		p2pServer:         stack.Server(),
 		stack:             stack,
@@ -60,7 +82,30 @@ func NewRelay(stack *node.Node, config *Config, networkID uint64, registrar Prot
 		networkID:         networkID,
 		protocolRegistrar: registrar,
 		quit:              make(chan struct{}),
-	}, nil
+	}
+	if config.Dandelion.Enabled {
+		r.stem = stem.NewRouter(stem.Config{
+			Deliver: func(from enode.ID, txs []*types.Transaction) error {
+				return r.dandelion.handleStem(from, txs)
+			},
+		})
+		r.dandelion = newDandelion(config.Dandelion, backend, r.stem)
+		backend.onTransactionsSeen(r.dandelion.seen)
+	}
+	if config.Capture.File != "" {
+		// Relative paths are in the datadir, or the working directory without one
+		capture := config.Capture
+		if path := stack.ResolvePath(capture.File); path != "" {
+			capture.File = path
+		}
+		backend.capture = newCapture(capture)
+	}
+	if len(config.LightClient.BeaconAPIs) > 0 {
+		r.light = newLightClient(&config.LightClient, backend, newCommitteeChain(&config.LightClient))
+		backend.verifyHeaders = r.light.checkHeaders
+	}
+	stack.RegisterAPIs(r.APIs())
+	return r, nil
 }
 
 // Start implements node.Lifecycle.
@@ -79,9 +124,26 @@ func (r *Relay) Start() error {
 	selector := NewRoundRobinSelector(r.backend)
 	r.proxy = NewRequestProxy(r.backend, selector, 30*time.Second)
 
+	// Fluffed stem transactions are broadcast like relayed ones
+	if r.dandelion != nil {
+		r.dandelion.start(r.broadcastTransactions)
+	}
+
+	// Follow the beacon chain for a verified head
+	if r.light != nil {
+		r.light.start()
+	}
+
 	// Setup ENR updater now that LocalNode() is available
 	StartRelayENRUpdater(r.p2pServer.LocalNode(), r.config)
 
+	// Keep the links to other relays, found with their own discovery iterator
+	if r.config.Overlay.Links > 0 {
+		candidates := enode.Filter(MakeRelayDialCandidates(r.p2pServer, r.config), newOverlayNodeFilter(r.config, r.p2pServer.Self().ID()))
+		r.overlay = newOverlay(r.config.Overlay.Links, r.backend, r.p2pServer, candidates)
+		r.overlay.start()
+	}
+
 	// Start relay loop
 	r.wg.Add(1)
 	go r.relayLoop()
@@ -98,6 +160,16 @@ func (r *Relay) relayLoop() {
 			if msg == nil {
 				continue
 			}
+			stats := r.backend.peerStats(msg.From)
+			if stats != nil {
+				stats.received.Add(1)
+			}
+			if r.backend.Draining() {
+				log.Trace("Dropped message while draining",
+					"from", msg.From.String()[:16]+"...",
+					"code", msgCodeToString(msg.MsgCode))
+				continue
+			}
 			log.Trace("Received message for relaying",
 				"from", msg.From.String()[:16]+"...",
 				"code", msg.MsgCode,
@@ -167,23 +239,49 @@ func msgCodeToString(code uint64) string {
 func (r *Relay) Stop() error {
 	close(r.quit)
 	
+	// Cancel the embargo timers
+	if r.dandelion != nil {
+		r.dandelion.stop()
+	}
+
+	// Disconnect from the beacon nodes
+	if r.light != nil {
+		r.light.stop()
+	}
+
+	// Stop dialing other relays
+	if r.overlay != nil {
+		r.overlay.stop()
+	}
+
 	// Stop proxy
 	if r.proxy != nil {
 		r.proxy.Stop()
 	}
 	
+	// Stop backend first, so queued sends blocking on the relay queue are
+	// released
+	r.backend.Stop()
+	
 	// Stop router
 	if r.router != nil {
 		r.router.Stop()
 	}
 	
+	// Flush the capture file
+	if r.backend.capture != nil {
+		if err := r.backend.capture.close(); err != nil {
+			log.Warn("Failed to close message capture", "err", err)
+		}
+	}
+
 	// Close discovery
 	if r.discmix != nil {
 		r.discmix.Close()
 	}
-	
-	// Stop backend
-	r.backend.Stop()
+	if r.pex != nil {
+		r.pex.Close()
+	}
 	
 	// Wait for goroutines
 	r.wg.Wait()
@@ -191,6 +289,48 @@ func (r *Relay) Stop() error {
 	return nil
 }
 
+// StemTransaction propagates a locally submitted transaction with Dandelion++.
+func (r *Relay) StemTransaction(tx *types.Transaction) error {
+	if r.dandelion == nil {
+		return errDandelionDisabled
+	}
+	var signer types.Signer
+	if r.backend.chainConfig != nil {
+		signer = types.LatestSigner(r.backend.chainConfig)
+	}
+	if err := validateTransaction(signer, tx); err != nil {
+		return err
+	}
+	r.dandelion.submit(tx)
+	return nil
+}
+
+// Peers returns the connected relay peers.
+func (r *Relay) Peers() []*RelayPeer {
+	return r.backend.Peers()
+}
+
+// Request sends a request of the relay itself to a peer and waits for the
+// response payload. The payload is the request without its request ID.
+func (r *Relay) Request(ctx context.Context, peer enode.ID, msgCode uint64, payload []byte) ([]byte, error) {
+	if r.proxy == nil {
+		return nil, errRelayStopped
+	}
+	return r.proxy.Request(ctx, peer, msgCode, payload)
+}
+
+// broadcastTransactions sends transactions to all peers.
+func (r *Relay) broadcastTransactions(txs []*types.Transaction) {
+	payload, err := rlp.EncodeToBytes(txs)
+	if err != nil {
+		log.Error("Failed to encode transactions", "err", err)
+		return
+	}
+	if err := r.router.BroadcastLocal(transactionsMsg, payload); err != nil {
+		log.Debug("Failed to broadcast transactions", "err", err)
+	}
+}
+
 // Backend returns the relay backend.
 func (r *Relay) Backend() *Backend {
 	return r.backend
@@ -0,0 +1,298 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// Peer reputation.
+//
+// Every peer starts with a score of zero. Misbehaviour lowers the score by a
+// fixed penalty and the score recovers towards zero over time, so occasional
+// faults are forgiven. A peer whose score drops below the ban threshold is
+// disconnected and refused for the ban duration. Scores are kept across
+// reconnects, bans are persisted in the datadir.
+
+const (
+	defaultBanThreshold = -100
+	defaultBanDuration  = time.Hour
+
+	scoreRecoveryRate = 1.0 / 60 // points per second
+	maxTrackedScores  = 4096     // score entries kept before pruning recovered ones
+
+	datadirBanList = "banlist.json" // path within the datadir to the ban list
+)
+
+// Misbehaviour is a fault that lowers a peer's score.
+type Misbehaviour int
+
+const (
+	MisbehaviourDecode    Misbehaviour = iota // malformed message
+	MisbehaviourInvalidTx                     // transaction failing validation
+	MisbehaviourTimeout                       // proxied request not answered in time
+	MisbehaviourMismatch                      // response not matching any request
+	MisbehaviourBadHeader                     // header conflicting with the finalized chain
+)
+
+var misbehaviourPenalty = [...]float64{
+	MisbehaviourDecode:    50,
+	MisbehaviourInvalidTx: 10,
+	MisbehaviourTimeout:   5,
+	MisbehaviourMismatch:  20,
+	MisbehaviourBadHeader: 50,
+}
+
+var misbehaviourToString = [...]string{
+	MisbehaviourDecode:    "decode error",
+	MisbehaviourInvalidTx: "invalid transaction",
+	MisbehaviourTimeout:   "request timeout",
+	MisbehaviourMismatch:  "mismatched response",
+	MisbehaviourBadHeader: "conflicting header",
+}
+
+func (m Misbehaviour) String() string {
+	if m < 0 || int(m) >= len(misbehaviourToString) {
+		return fmt.Sprintf("unknown misbehaviour %d", int(m))
+	}
+	return misbehaviourToString[m]
+}
+
+// Ban is an entry of the ban list.
+type Ban struct {
+	ID     enode.ID  `json:"id"`
+	Reason string    `json:"reason"`
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until"`
+}
+
+type peerScore struct {
+	value   float64
+	updated time.Time
+}
+
+// Reputation tracks peer scores and the ban list.
+type Reputation struct {
+	threshold   float64
+	banDuration time.Duration
+	path        string // ban list file, not persisted if empty
+	now         func() time.Time
+
+	lock   sync.Mutex
+	scores map[enode.ID]*peerScore
+	bans   map[enode.ID]*Ban
+}
+
+// NewReputation creates the reputation tracker. Bans are persisted in the file
+// at path, which may be empty.
+func NewReputation(threshold int, banDuration time.Duration, path string) *Reputation {
+	if threshold == 0 {
+		threshold = defaultBanThreshold
+	}
+	if banDuration == 0 {
+		banDuration = defaultBanDuration
+	}
+	return &Reputation{
+		threshold:   float64(threshold),
+		banDuration: banDuration,
+		path:        path,
+		now:         time.Now,
+		scores:      make(map[enode.ID]*peerScore),
+		bans:        make(map[enode.ID]*Ban),
+	}
+}
+
+// Load reads the ban list from disk. Expired bans are dropped.
+func (r *Reputation) Load() error {
+	if r.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var bans []*Ban
+	if err := json.Unmarshal(data, &bans); err != nil {
+		return fmt.Errorf("invalid ban list %s: %w", r.path, err)
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := r.now()
+	for _, ban := range bans {
+		if ban.Until.After(now) {
+			r.bans[ban.ID] = ban
+		}
+	}
+	return nil
+}
+
+// save writes the ban list to disk. It must be called with the lock held.
+func (r *Reputation) save() error {
+	if r.path == "" {
+		return nil
+	}
+	bans := make([]*Ban, 0, len(r.bans))
+	for _, ban := range r.bans {
+		bans = append(bans, ban)
+	}
+	data, err := json.MarshalIndent(bans, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0700); err != nil {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
+
+// score returns the current score of a peer, applying recovery since the last
+// update. It must be called with the lock held.
+func (r *Reputation) score(id enode.ID, now time.Time) float64 {
+	s := r.scores[id]
+	if s == nil {
+		return 0
+	}
+	s.value = min(s.value+now.Sub(s.updated).Seconds()*scoreRecoveryRate, 0)
+	s.updated = now
+	return s.value
+}
+
+// Penalize lowers the score of a peer. It returns true if the peer got banned
+// as a result.
+func (r *Reputation) Penalize(id enode.ID, m Misbehaviour) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := r.now()
+	if ban := r.bans[id]; ban != nil && ban.Until.After(now) {
+		return false
+	}
+	value := r.score(id, now) - misbehaviourPenalty[m]
+	if value > r.threshold {
+		if len(r.scores) >= maxTrackedScores {
+			r.prune(now)
+		}
+		r.scores[id] = &peerScore{value: value, updated: now}
+		return false
+	}
+	r.ban(id, m.String(), r.banDuration, now)
+	return true
+}
+
+// prune drops the scores that recovered fully. It must be called with the lock
+// held.
+func (r *Reputation) prune(now time.Time) {
+	for id := range r.scores {
+		if r.score(id, now) == 0 {
+			delete(r.scores, id)
+		}
+	}
+}
+
+// ban adds a peer to the ban list. It must be called with the lock held.
+func (r *Reputation) ban(id enode.ID, reason string, duration time.Duration, now time.Time) *Ban {
+	ban := &Ban{ID: id, Reason: reason, Since: now, Until: now.Add(duration)}
+	r.bans[id] = ban
+	delete(r.scores, id)
+	if err := r.save(); err != nil {
+		log.Warn("Failed to save ban list", "path", r.path, "err", err)
+	}
+	return ban
+}
+
+// Ban bans a peer for the given duration, or the default ban duration if zero.
+func (r *Reputation) Ban(id enode.ID, reason string, duration time.Duration) *Ban {
+	if duration == 0 {
+		duration = r.banDuration
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.ban(id, reason, duration, r.now())
+}
+
+// Unban removes a peer from the ban list and resets its score. It returns false
+// if the peer was not banned.
+func (r *Reputation) Unban(id enode.ID) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.bans[id]; !ok {
+		return false
+	}
+	delete(r.bans, id)
+	delete(r.scores, id)
+	if err := r.save(); err != nil {
+		log.Warn("Failed to save ban list", "path", r.path, "err", err)
+	}
+	return true
+}
+
+// IsBanned reports whether a peer is currently banned.
+func (r *Reputation) IsBanned(id enode.ID) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	ban := r.bans[id]
+	if ban == nil {
+		return false
+	}
+	if !ban.Until.After(r.now()) {
+		delete(r.bans, id)
+		return false
+	}
+	return true
+}
+
+// Score returns the current score of a peer.
+func (r *Reputation) Score(id enode.ID) float64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.score(id, r.now())
+}
+
+// Bans returns the active bans.
+func (r *Reputation) Bans() []Ban {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := r.now()
+	bans := make([]Ban, 0, len(r.bans))
+	for id, ban := range r.bans {
+		if !ban.Until.After(now) {
+			delete(r.bans, id)
+			continue
+		}
+		bans = append(bans, *ban)
+	}
+	return bans
+}
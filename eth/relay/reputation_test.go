@@ -0,0 +1,136 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testClock is a settable time source.
+type testClock struct{ now time.Time }
+
+func (c *testClock) Now() time.Time          { return c.now }
+func (c *testClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestReputation(path string) (*Reputation, *testClock) {
+	clock := &testClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	r := NewReputation(-100, time.Hour, path)
+	r.now = clock.Now
+	return r, clock
+}
+
+func TestReputationBan(t *testing.T) {
+	r, clock := newTestReputation("")
+	id := enode.ID{1}
+
+	assert.False(t, r.Penalize(id, MisbehaviourDecode))
+	assert.Equal(t, -50.0, r.Score(id))
+	assert.True(t, r.Penalize(id, MisbehaviourDecode), "peer not banned at threshold")
+	assert.True(t, r.IsBanned(id))
+	assert.False(t, r.Penalize(id, MisbehaviourDecode), "banned peer banned again")
+
+	bans := r.Bans()
+	require.Len(t, bans, 1)
+	assert.Equal(t, "decode error", bans[0].Reason)
+	assert.Equal(t, clock.now.Add(time.Hour), bans[0].Until)
+
+	clock.Advance(time.Hour)
+	assert.False(t, r.IsBanned(id), "ban did not expire")
+	assert.Equal(t, 0.0, r.Score(id), "score not reset after ban")
+}
+
+func TestReputationRecovery(t *testing.T) {
+	r, clock := newTestReputation("")
+	id := enode.ID{1}
+
+	r.Penalize(id, MisbehaviourMismatch)
+	clock.Advance(10 * time.Minute)
+	assert.InDelta(t, -10.0, r.Score(id), 1e-9)
+	clock.Advance(time.Hour)
+	assert.Equal(t, 0.0, r.Score(id), "score recovered above zero")
+
+	// Occasional timeouts are forgiven.
+	for i := 0; i < 100; i++ {
+		assert.False(t, r.Penalize(id, MisbehaviourTimeout))
+		clock.Advance(5 * time.Minute)
+	}
+}
+
+func TestReputationPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gethrelay", datadirBanList)
+	r, clock := newTestReputation(path)
+	r.Ban(enode.ID{1}, "spam", 0)
+	r.Ban(enode.ID{2}, "spam", 2*time.Hour)
+	r.Ban(enode.ID{3}, "spam", time.Hour)
+	require.True(t, r.Unban(enode.ID{3}))
+	require.False(t, r.Unban(enode.ID{3}))
+
+	// Reload after the default ban duration: only the long ban is left.
+	r2, clock2 := newTestReputation(path)
+	clock2.now = clock.now.Add(90 * time.Minute)
+	require.NoError(t, r2.Load())
+	assert.False(t, r2.IsBanned(enode.ID{1}))
+	assert.True(t, r2.IsBanned(enode.ID{2}))
+	assert.False(t, r2.IsBanned(enode.ID{3}))
+}
+
+func TestCheckTransactions(t *testing.T) {
+	config := &Config{ChainConfig: params.MainnetChainConfig}
+	b := NewBackend(config, nil)
+	key, _ := crypto.GenerateKey()
+	signer := types.LatestSigner(params.MainnetChainConfig)
+	tx := types.NewTx(&types.DynamicFeeTx{ChainID: big.NewInt(1), Gas: 21000, To: &common.Address{}})
+
+	valid, err := types.SignTx(tx, signer, key)
+	require.NoError(t, err)
+	otherTx := types.NewTx(&types.DynamicFeeTx{ChainID: big.NewInt(5), Gas: 21000, To: &common.Address{}})
+	otherChain, err := types.SignTx(otherTx, types.LatestSignerForChainID(big.NewInt(5)), key)
+	require.NoError(t, err)
+
+	var seen []common.Hash
+	b.onTransactionsSeen(func(hashes []common.Hash) { seen = append(seen, hashes...) })
+
+	peer := enode.ID{1}
+	assert.Equal(t, 0, b.CheckTransactions(peer, []*types.Transaction{valid}))
+	assert.Equal(t, 0.0, b.reputation.Score(peer))
+	assert.Equal(t, 1, b.CheckTransactions(peer, []*types.Transaction{valid, otherChain}))
+	assert.InDelta(t, -misbehaviourPenalty[MisbehaviourInvalidTx], b.reputation.Score(peer), 0.1)
+	assert.Equal(t, uint64(3), b.Stats().Transactions)
+
+	// Invalid transactions are not reported as seen.
+	assert.Equal(t, []common.Hash{valid.Hash(), valid.Hash()}, seen)
+}
+
+func TestBackendBanList(t *testing.T) {
+	config := &Config{BanList: filepath.Join(t.TempDir(), datadirBanList)}
+	NewBackend(config, nil).BanPeer(enode.ID{1}, "spam", time.Hour)
+
+	backend := NewBackend(config, nil)
+	require.NoError(t, backend.Reputation().Load())
+	assert.True(t, backend.IsBanned(enode.ID{1}))
+}
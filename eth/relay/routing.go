@@ -18,17 +18,61 @@ package relay
 
 import (
 	"errors"
+	"fmt"
 	"sync"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 )
 
+// Outbound queues.
+//
+// Every peer has one outbound queue, filled by the router and drained by a
+// single goroutine, so messages reach a peer in the order they were relayed.
+// Queues are bounded by the payload bytes they hold and never block the
+// router. When a queue is full, messages are dropped according to the drop
+// policy. A peer whose queue stays full for longer than the slow peer timeout
+// is disconnected, so it cannot hold on to relay memory.
+
+const (
+	defaultQueueLimit      = 4 * 1024 * 1024 // payload bytes queued per peer
+	defaultSlowPeerTimeout = 30 * time.Second
+)
+
 var (
 	ErrPeerDisconnected = errors.New("peer disconnected")
 	ErrNoPeers          = errors.New("no peers available")
+	ErrInvalidPayload   = errors.New("invalid payload")
 )
 
+// DropPolicy selects the messages dropped when an outbound queue is full.
+type DropPolicy string
+
+const (
+	// DropOldest drops the oldest queued messages until the new one fits.
+	DropOldest DropPolicy = "drop-oldest"
+
+	// DropGossip drops announcements and gossip: new gossip is dropped, and
+	// requests and responses push out the oldest queued gossip. Requests and
+	// responses are only dropped if they do not fit otherwise.
+	DropGossip DropPolicy = "drop-gossip"
+)
+
+// ParseDropPolicy parses a drop policy name. The empty string selects the
+// default policy.
+func ParseDropPolicy(s string) (DropPolicy, error) {
+	switch p := DropPolicy(s); p {
+	case "":
+		return DropGossip, nil
+	case DropOldest, DropGossip:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown queue drop policy %q", s)
+	}
+}
+
 // QueuedMessage represents a message waiting to be forwarded.
 type QueuedMessage struct {
 	MsgCode   uint64
@@ -37,39 +81,130 @@ type QueuedMessage struct {
 	RequestID uint64
 }
 
-// OrderedQueue ensures single-threaded message ordering per peer.
+// OrderedQueue is the outbound queue of a single peer.
 type OrderedQueue struct {
-	peerID   enode.ID
-	messages chan *QueuedMessage
-	quit     chan struct{}
-	wg       sync.WaitGroup
-	backend  *Backend
+	peerID          enode.ID
+	limit           int // maximum queued payload bytes
+	policy          DropPolicy
+	slowPeerTimeout time.Duration
+	clock           mclock.Clock
+	backend         *Backend
+
+	lock         sync.Mutex
+	messages     []*QueuedMessage
+	size         int            // queued payload bytes
+	dropped      uint64         // messages dropped because the queue was full
+	backlogged   bool           // queue overflowed and has not drained since
+	backlogSince mclock.AbsTime // time of the first overflow
+	evicted      bool
+
+	wake chan struct{}
+	quit chan struct{}
+	wg   sync.WaitGroup
 }
 
 // MessageRouter handles ordered message forwarding.
 type MessageRouter struct {
-	relay      *Backend
+	relay           *Backend
+	validator       *payloadValidator
+	queueLimit      int
+	policy          DropPolicy
+	slowPeerTimeout time.Duration
+	clock           mclock.Clock
+	broadcasts      *broadcastScheduler
+
 	peerQueues map[enode.ID]*OrderedQueue
 	queuesLock sync.RWMutex
 }
 
 // NewMessageRouter creates a new message router.
 func NewMessageRouter(relay *Backend) *MessageRouter {
-	return &MessageRouter{
-		relay:      relay,
-		peerQueues: make(map[enode.ID]*OrderedQueue),
+	mr := &MessageRouter{
+		relay:           relay,
+		validator:       newPayloadValidator(relay.chainConfig),
+		queueLimit:      relay.config.QueueLimit,
+		policy:          relay.config.QueuePolicy,
+		slowPeerTimeout: relay.config.SlowPeerTimeout,
+		clock:           mclock.System{},
+		peerQueues:      make(map[enode.ID]*OrderedQueue),
+	}
+	if mr.queueLimit == 0 {
+		mr.queueLimit = defaultQueueLimit
+	}
+	if mr.policy == "" {
+		mr.policy = DropGossip
+	}
+	if mr.slowPeerTimeout == 0 {
+		mr.slowPeerTimeout = defaultSlowPeerTimeout
 	}
+	mr.broadcasts = newBroadcastScheduler(relay.config.BroadcastDelay, mr.clock, func(msg *QueuedMessage) {
+		if queue := mr.getQueue(msg.ToPeer); queue != nil {
+			queue.enqueue(msg)
+		}
+	})
+	relay.onPeerRemoved(mr.removeQueue)
+	relay.onPeerRemoved(mr.broadcasts.removePeer)
+	return mr
 }
 
-// ForwardMessage adds message to peer's ordered queue and forwards to all other peers.
+// ForwardMessage queues a message for all peers except the sender. Messages
+// received from a relay are passed on to the other overlay links as well, but
+// never over a link that carried them before, so they do not circle. It never
+// blocks on slow peers.
 func (mr *MessageRouter) ForwardMessage(from enode.ID, msgCode uint64, payload []byte) error {
-	mr.relay.peersLock.RLock()
-	defer mr.relay.peersLock.RUnlock()
+	return mr.forward(from, false, msgCode, payload)
+}
 
-	allPeers := mr.relay.peers.All()
-	targetCount := len(allPeers)
-	if targetCount == 0 {
-		return nil // No peers to forward to
+// BroadcastLocal queues a message originating from the relay itself for all
+// peers.
+func (mr *MessageRouter) BroadcastLocal(msgCode uint64, payload []byte) error {
+	return mr.forward(enode.ID{}, true, msgCode, payload)
+}
+
+func (mr *MessageRouter) forward(from enode.ID, local bool, msgCode uint64, payload []byte) error {
+	// Validate before fanning out, rejected messages are not forwarded
+	var rejected error
+	received := payload
+	payload, err := mr.validator.validate(msgCode, payload)
+	switch {
+	case err == nil:
+	case payload != nil:
+		// Invalid transactions are dropped, the valid ones are still forwarded.
+		if local {
+			rejected = fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+		} else {
+			rejected = mr.reject(from, msgCode, err)
+		}
+	case local && errors.Is(err, errDuplicate):
+		return nil
+	case local:
+		return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	default:
+		return mr.reject(from, msgCode, err)
+	}
+
+	allPeers := mr.relay.Peers()
+	if len(allPeers) == 0 {
+		return rejected // No peers to forward to
+	}
+	overlay := !local && mr.relay.isOverlayPeer(from)
+
+	// Overlay links never carry a message twice: the relay at the other end
+	// has it already if it sent or received it before.
+	hash := overlayMessageHash(msgCode, payload)
+	if overlay {
+		for _, peer := range allPeers {
+			if peer.ID == from {
+				peer.markKnown(overlayMessageHash(msgCode, received))
+				peer.markKnown(hash)
+			}
+		}
+	}
+	targets := make([]*RelayPeer, 0, len(allPeers))
+	for _, peer := range allPeers {
+		if peer.ID != from && peer.markKnown(hash) {
+			targets = append(targets, peer)
+		}
 	}
 
 	// Log message being relayed
@@ -78,42 +213,84 @@ func (mr *MessageRouter) ForwardMessage(from enode.ID, msgCode uint64, payload [
 		"code", msgCode,
 		"codeName", msgCodeToString(msgCode),
 		"size", len(payload),
-		"targets", targetCount)
+		"targets", len(targets),
+		"overlay", overlay)
 
-	// Get ordered queue for sender (ensures ordering)
-	queue := mr.getQueue(from)
+	// Transaction broadcasts go out in the rounds of the broadcast scheduler
+	if isTxBroadcast(msgCode) {
+		mr.broadcasts.schedule(msgCode, payload, targets, local)
+		return rejected
+	}
 
 	// Broadcast to all other peers
-	for _, peer := range allPeers {
-		if peer.ID != from {
-			select {
-			case queue.messages <- &QueuedMessage{
+	for _, peer := range targets {
+		// The peer may have disconnected since the snapshot was taken.
+		if queue := mr.getQueue(peer.ID); queue != nil {
+			queue.enqueue(&QueuedMessage{
 				MsgCode: msgCode,
 				Payload: payload,
 				ToPeer:  peer.ID,
-			}:
-			case <-queue.quit:
-				return ErrPeerDisconnected
-			}
+			})
 		}
 	}
-	return nil
+	return rejected
 }
 
-// getQueue returns or creates ordered queue for peer.
+// reject accounts a message that failed validation. Duplicates are dropped
+// silently, invalid messages count against the sender's reputation.
+func (mr *MessageRouter) reject(from enode.ID, msgCode uint64, err error) error {
+	stats := mr.relay.peerStats(from)
+	if errors.Is(err, errDuplicate) {
+		if stats != nil {
+			stats.duplicates.Add(1)
+		}
+		return nil
+	}
+	if stats != nil {
+		stats.rejected.Add(1)
+	}
+	log.Debug("Rejected relayed message",
+		"from", from.String()[:16]+"...",
+		"code", msgCodeToString(msgCode),
+		"err", err)
+	if errors.Is(err, errDecode) || errors.Is(err, errAnnounceFields) {
+		mr.relay.ReportPeer(from, MisbehaviourDecode)
+	} else {
+		mr.relay.ReportPeer(from, MisbehaviourInvalidTx)
+	}
+	return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+}
+
+// getQueue returns or creates the outbound queue of a peer. It returns nil if
+// the peer is not registered.
 func (mr *MessageRouter) getQueue(peerID enode.ID) *OrderedQueue {
+	mr.queuesLock.RLock()
+	queue := mr.peerQueues[peerID]
+	mr.queuesLock.RUnlock()
+	if queue != nil {
+		return queue
+	}
+
 	mr.queuesLock.Lock()
 	defer mr.queuesLock.Unlock()
 
 	if queue, exists := mr.peerQueues[peerID]; exists {
 		return queue
 	}
-
-	queue := &OrderedQueue{
-		peerID:   peerID,
-		messages: make(chan *QueuedMessage, 100), // Buffered channel
-		quit:     make(chan struct{}),
-		backend:  mr.relay,
+	// Queues are torn down in removeQueue after the peer is unregistered, so
+	// checking under the lock ensures no queue outlives its peer.
+	if mr.relay.peers.Get(peerID) == nil {
+		return nil
+	}
+	queue = &OrderedQueue{
+		peerID:          peerID,
+		limit:           mr.queueLimit,
+		policy:          mr.policy,
+		slowPeerTimeout: mr.slowPeerTimeout,
+		clock:           mr.clock,
+		backend:         mr.relay,
+		wake:            make(chan struct{}, 1),
+		quit:            make(chan struct{}),
 	}
 	mr.peerQueues[peerID] = queue
 
@@ -124,27 +301,195 @@ func (mr *MessageRouter) getQueue(peerID enode.ID) *OrderedQueue {
 	return queue
 }
 
+// removeQueue stops the outbound queue of a disconnected peer.
+func (mr *MessageRouter) removeQueue(peerID enode.ID) {
+	mr.queuesLock.Lock()
+	queue := mr.peerQueues[peerID]
+	delete(mr.peerQueues, peerID)
+	mr.queuesLock.Unlock()
+
+	if queue != nil {
+		queue.Stop()
+	}
+}
+
+// enqueue adds a message to the queue, dropping messages if it is full.
+func (oq *OrderedQueue) enqueue(msg *QueuedMessage) {
+	oq.lock.Lock()
+	queued, evict := true, false
+	if oq.size+len(msg.Payload) > oq.limit {
+		queued = oq.makeRoom(msg)
+		now := oq.clock.Now()
+		if !oq.backlogged {
+			oq.backlogged, oq.backlogSince = true, now
+		}
+		if !oq.evicted && time.Duration(now-oq.backlogSince) >= oq.slowPeerTimeout {
+			oq.evicted, evict = true, true
+		}
+	}
+	if queued {
+		oq.messages = append(oq.messages, msg)
+		oq.size += len(msg.Payload)
+	}
+	oq.lock.Unlock()
+
+	if queued {
+		select {
+		case oq.wake <- struct{}{}:
+		default:
+		}
+	}
+	if evict {
+		oq.backend.evictSlowPeer(oq.peerID, oq.slowPeerTimeout)
+	}
+}
+
+// makeRoom drops queued messages according to the drop policy so that msg
+// fits. It returns false if msg has to be dropped instead. It must be called
+// with the lock held.
+func (oq *OrderedQueue) makeRoom(msg *QueuedMessage) bool {
+	excess := oq.size + len(msg.Payload) - oq.limit
+	switch oq.policy {
+	case DropOldest:
+		n := 0
+		for ; n < len(oq.messages) && excess > 0; n++ {
+			excess -= len(oq.messages[n].Payload)
+		}
+		oq.dropMessages(oq.messages[:n])
+		oq.messages = oq.messages[n:]
+
+	default:
+		if classify(msg.MsgCode) != classRequest {
+			break
+		}
+		kept := make([]*QueuedMessage, 0, len(oq.messages))
+		for i, m := range oq.messages {
+			if excess > 0 && classify(m.MsgCode) != classRequest {
+				excess -= len(m.Payload)
+				oq.dropMessages(oq.messages[i : i+1])
+				continue
+			}
+			kept = append(kept, m)
+		}
+		oq.messages = kept
+	}
+	if excess > 0 {
+		oq.countDropped(1)
+		return false
+	}
+	return true
+}
+
+// dropMessages removes the size of dropped messages from the queue size and
+// counts them. It must be called with the lock held.
+func (oq *OrderedQueue) dropMessages(msgs []*QueuedMessage) {
+	for _, m := range msgs {
+		oq.size -= len(m.Payload)
+	}
+	oq.countDropped(len(msgs))
+}
+
+// countDropped counts dropped messages. It must be called with the lock held.
+func (oq *OrderedQueue) countDropped(n int) {
+	if n == 0 {
+		return
+	}
+	oq.dropped += uint64(n)
+	if stats := oq.backend.peerStats(oq.peerID); stats != nil {
+		stats.dropped.Add(uint64(n))
+	}
+}
+
+// pop removes the oldest message from the queue. It returns nil and clears the
+// backlog if the queue is empty.
+func (oq *OrderedQueue) pop() *QueuedMessage {
+	oq.lock.Lock()
+	defer oq.lock.Unlock()
+
+	if len(oq.messages) == 0 {
+		oq.backlogged = false
+		return nil
+	}
+	msg := oq.messages[0]
+	oq.messages[0] = nil
+	oq.messages = oq.messages[1:]
+	oq.size -= len(msg.Payload)
+	return msg
+}
+
 // processMessages runs in single goroutine per peer - ensures ordering.
 func (oq *OrderedQueue) processMessages() {
 	defer oq.wg.Done()
 
 	for {
+		msg := oq.pop()
+		if msg == nil {
+			select {
+			case <-oq.wake:
+				continue
+			case <-oq.quit:
+				return
+			}
+		}
+		// Send to target peer
+		log.Trace("Forwarding message to peer",
+			"to", msg.ToPeer.String()[:16]+"...",
+			"code", msg.MsgCode,
+			"codeName", msgCodeToString(msg.MsgCode),
+			"size", len(msg.Payload))
+		if oq.backend.sendToPeer(msg.ToPeer, msg.MsgCode, msg.Payload) == nil {
+			oq.backend.totals.relayed.Add(1)
+			if stats := oq.backend.peerStats(msg.ToPeer); stats != nil {
+				stats.forwarded.Add(1)
+			}
+		}
 		select {
-		case msg := <-oq.messages:
-			// Send to target peer
-			log.Trace("Forwarding message to peer",
-				"from", oq.peerID.String()[:16]+"...",
-				"to", msg.ToPeer.String()[:16]+"...",
-				"code", msg.MsgCode,
-				"codeName", msgCodeToString(msg.MsgCode),
-				"size", len(msg.Payload))
-			oq.backend.sendToPeer(msg.ToPeer, msg.MsgCode, msg.Payload)
 		case <-oq.quit:
 			return
+		default:
 		}
 	}
 }
 
+// QueueInfo describes the fill level of a message queue.
+type QueueInfo struct {
+	Len int `json:"len"`
+	Cap int `json:"cap"`
+}
+
+// PeerQueueInfo describes the state of a peer's outbound queue.
+type PeerQueueInfo struct {
+	Len        int    `json:"len"`
+	Bytes      int    `json:"bytes"`      // queued payload bytes
+	Limit      int    `json:"limit"`      // maximum queued payload bytes
+	Dropped    uint64 `json:"dropped"`    // messages dropped because the queue was full
+	Backlogged bool   `json:"backlogged"` // queue overflowed and has not drained since
+}
+
+func (oq *OrderedQueue) info() PeerQueueInfo {
+	oq.lock.Lock()
+	defer oq.lock.Unlock()
+
+	return PeerQueueInfo{
+		Len:        len(oq.messages),
+		Bytes:      oq.size,
+		Limit:      oq.limit,
+		Dropped:    oq.dropped,
+		Backlogged: oq.backlogged,
+	}
+}
+
+// QueueStats returns the state of the per-peer outbound queues.
+func (mr *MessageRouter) QueueStats() map[enode.ID]PeerQueueInfo {
+	mr.queuesLock.RLock()
+	defer mr.queuesLock.RUnlock()
+
+	stats := make(map[enode.ID]PeerQueueInfo, len(mr.peerQueues))
+	for id, queue := range mr.peerQueues {
+		stats[id] = queue.info()
+	}
+	return stats
+}
 
 // Stop stops the queue.
 func (oq *OrderedQueue) Stop() {
@@ -154,6 +499,8 @@ func (oq *OrderedQueue) Stop() {
 
 // Stop stops all queues.
 func (mr *MessageRouter) Stop() {
+	mr.broadcasts.stop()
+
 	mr.queuesLock.Lock()
 	defer mr.queuesLock.Unlock()
 
@@ -162,4 +509,3 @@ func (mr *MessageRouter) Stop() {
 	}
 	mr.peerQueues = make(map[enode.ID]*OrderedQueue)
 }
-
@@ -0,0 +1,234 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestQueue creates an outbound queue without a sender goroutine, so
+// messages stay queued.
+func newTestQueue(backend *Backend, policy DropPolicy, limit int, clock mclock.Clock) *OrderedQueue {
+	return &OrderedQueue{
+		peerID:          enode.ID{1},
+		limit:           limit,
+		policy:          policy,
+		slowPeerTimeout: time.Minute,
+		clock:           clock,
+		backend:         backend,
+		wake:            make(chan struct{}, 1),
+		quit:            make(chan struct{}),
+	}
+}
+
+func queuedCodes(q *OrderedQueue) []uint64 {
+	var codes []uint64
+	for _, m := range q.messages {
+		codes = append(codes, m.MsgCode)
+	}
+	return codes
+}
+
+func TestQueueDropOldest(t *testing.T) {
+	r := newTestRelay()
+	r.backend.AddPeer(newTestRelayPeer(1, nil))
+	q := newTestQueue(r.backend, DropOldest, 300, new(mclock.Simulated))
+
+	for code := uint64(1); code <= 3; code++ {
+		q.enqueue(&QueuedMessage{MsgCode: code, Payload: make([]byte, 100)})
+	}
+	q.enqueue(&QueuedMessage{MsgCode: 4, Payload: make([]byte, 150)})
+	assert.Equal(t, []uint64{3, 4}, queuedCodes(q))
+	assert.Equal(t, 250, q.size)
+
+	// Messages larger than the limit never fit.
+	q.enqueue(&QueuedMessage{MsgCode: 5, Payload: make([]byte, 301)})
+	assert.Empty(t, q.messages)
+	assert.Zero(t, q.size)
+
+	info := q.info()
+	assert.Equal(t, uint64(5), info.Dropped)
+	assert.True(t, info.Backlogged)
+	assert.Equal(t, uint64(5), r.backend.peers.Get(enode.ID{1}).Stats().Dropped)
+}
+
+func TestQueueDropGossip(t *testing.T) {
+	r := newTestRelay()
+	q := newTestQueue(r.backend, DropGossip, 300, new(mclock.Simulated))
+
+	q.enqueue(&QueuedMessage{MsgCode: testGossip, Payload: make([]byte, 100)})
+	q.enqueue(&QueuedMessage{MsgCode: testRequestMsg, Payload: make([]byte, 100)})
+	q.enqueue(&QueuedMessage{MsgCode: testAnnounce, Payload: make([]byte, 100)})
+
+	// New gossip is dropped when the queue is full.
+	q.enqueue(&QueuedMessage{MsgCode: testAnnounce, Payload: make([]byte, 10)})
+	assert.Equal(t, []uint64{testGossip, testRequestMsg, testAnnounce}, queuedCodes(q))
+
+	// Responses push out the oldest gossip.
+	q.enqueue(&QueuedMessage{MsgCode: testRequestMsg, Payload: make([]byte, 100)})
+	assert.Equal(t, []uint64{testRequestMsg, testAnnounce, testRequestMsg}, queuedCodes(q))
+	q.enqueue(&QueuedMessage{MsgCode: testRequestMsg, Payload: make([]byte, 50)})
+	assert.Equal(t, []uint64{testRequestMsg, testRequestMsg, testRequestMsg}, queuedCodes(q))
+
+	// Responses are dropped once no gossip is left to drop.
+	q.enqueue(&QueuedMessage{MsgCode: testRequestMsg, Payload: make([]byte, 100)})
+	assert.Len(t, q.messages, 3)
+	assert.Equal(t, 250, q.size)
+	assert.Equal(t, uint64(4), q.info().Dropped)
+
+	// The backlog clears once the queue drains.
+	for q.pop() != nil {
+	}
+	assert.False(t, q.info().Backlogged)
+	assert.Zero(t, q.size)
+}
+
+func TestQueueSlowPeerEviction(t *testing.T) {
+	r := newTestRelay()
+	r.backend.AddPeer(newTestRelayPeer(1, nil))
+	events := make(chan Event, 8)
+	sub := r.backend.SubscribeEvents(events)
+	defer sub.Unsubscribe()
+
+	clock := new(mclock.Simulated)
+	q := newTestQueue(r.backend, DropOldest, 100, clock)
+	q.enqueue(&QueuedMessage{MsgCode: testGossip, Payload: make([]byte, 100)})
+	q.enqueue(&QueuedMessage{MsgCode: testGossip, Payload: make([]byte, 100)})
+
+	// Draining the queue resets the backlog timer.
+	clock.Run(50 * time.Second)
+	for q.pop() != nil {
+	}
+	q.enqueue(&QueuedMessage{MsgCode: testGossip, Payload: make([]byte, 100)})
+	q.enqueue(&QueuedMessage{MsgCode: testGossip, Payload: make([]byte, 100)})
+	clock.Run(50 * time.Second)
+	q.enqueue(&QueuedMessage{MsgCode: testGossip, Payload: make([]byte, 100)})
+	assert.Empty(t, events, "peer evicted before the timeout")
+
+	clock.Run(10 * time.Second)
+	q.enqueue(&QueuedMessage{MsgCode: testGossip, Payload: make([]byte, 100)})
+	q.enqueue(&QueuedMessage{MsgCode: testGossip, Payload: make([]byte, 100)})
+	require.Len(t, events, 1, "peer not evicted exactly once")
+	ev := <-events
+	assert.Equal(t, EventPeerEvicted, ev.Type)
+	assert.Equal(t, enode.ID{1}, *ev.Peer)
+}
+
+func TestRouterQueueTeardown(t *testing.T) {
+	r := newTestRelay()
+	defer r.router.Stop()
+	defer r.backend.Stop()
+	r.backend.AddPeer(newTestRelayPeer(1, nil))
+	r.backend.AddPeer(newTestRelayPeer(2, nil))
+	r.backend.AddPeer(newTestRelayPeer(3, nil))
+
+	// Queues are created per recipient, not for the sender.
+	require.NoError(t, r.router.ForwardMessage(enode.ID{1}, 0x07, []byte{0xc1, 0x01}))
+	stats := r.router.QueueStats()
+	assert.Len(t, stats, 2)
+	assert.NotContains(t, stats, enode.ID{1})
+
+	r.backend.RemovePeer(enode.ID{2})
+	stats = r.router.QueueStats()
+	assert.Len(t, stats, 1)
+	assert.Contains(t, stats, enode.ID{3})
+
+	// Removed peers don't get a queue back.
+	require.NoError(t, r.router.ForwardMessage(enode.ID{1}, 0x07, []byte{0xc1, 0x02}))
+	assert.NotContains(t, r.router.QueueStats(), enode.ID{2})
+}
+
+func TestParseDropPolicy(t *testing.T) {
+	for _, s := range []string{"", "drop-gossip", "drop-oldest"} {
+		_, err := ParseDropPolicy(s)
+		assert.NoError(t, err, s)
+	}
+	_, err := ParseDropPolicy("drop-newest")
+	assert.Error(t, err)
+}
+
+// BenchmarkForwardMessage measures fanning out messages to many peers, which
+// read all messages.
+func BenchmarkForwardMessage(b *testing.B) {
+	for _, peers := range []int{100, 300, 500} {
+		b.Run(fmt.Sprintf("peers=%d", peers), func(b *testing.B) {
+			benchmarkForwardMessage(b, peers, false)
+		})
+	}
+}
+
+// BenchmarkForwardMessageStalled measures fanning out messages when no peer
+// reads messages, so every queue overflows.
+func BenchmarkForwardMessageStalled(b *testing.B) {
+	for _, peers := range []int{100, 300, 500} {
+		b.Run(fmt.Sprintf("peers=%d", peers), func(b *testing.B) {
+			benchmarkForwardMessage(b, peers, true)
+		})
+	}
+}
+
+// benchPeerConn is a PeerConn discarding messages, or blocking writes until
+// stall is closed.
+type benchPeerConn struct{ stall chan struct{} }
+
+func (c *benchPeerConn) BlockRange() (BlockRange, bool)  { return BlockRange{}, false }
+func (c *benchPeerConn) SendBlockRange(BlockRange) error { return nil }
+
+func (c *benchPeerConn) Send(uint64, []byte) error {
+	if c.stall != nil {
+		<-c.stall
+	}
+	return nil
+}
+
+func benchmarkForwardMessage(b *testing.B, peers int, stalled bool) {
+	config := &Config{NetworkID: 1, QueueLimit: 64 * 1024, SlowPeerTimeout: time.Hour}
+	backend := NewBackend(config, nil)
+	router := NewMessageRouter(backend)
+	conn := new(benchPeerConn)
+	if stalled {
+		conn.stall = make(chan struct{})
+	}
+	for i := 0; i < peers; i++ {
+		var id enode.ID
+		id[0], id[1] = byte(i>>8), byte(i)
+		backend.AddPeer(NewRelayPeer(p2p.NewPeer(id, "bench", nil), 69, conn))
+	}
+	// Stalled writes block the queues until the connections are released.
+	defer router.Stop()
+	if stalled {
+		defer close(conn.stall)
+	}
+
+	// NewBlock payloads are forwarded verbatim, vary them to defeat deduplication.
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		payload := make([]byte, 512)
+		payload[0], payload[1], payload[2] = 0xf9, 0x01, 0xfd
+		payload[3], payload[4], payload[5] = byte(i>>16), byte(i>>8), byte(i)
+		router.ForwardMessage(enode.ID{0xff, 0xff}, 0x07, payload)
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Relayed eth message codes that are validated beyond their RLP structure.
+const (
+	transactionsMsg               = 0x02
+	blockHeadersMsg               = 0x04
+	newPooledTransactionHashesMsg = 0x08
+)
+
+const (
+	// txMaxSize is the maximum size of a relayed transaction, matching the
+	// limit of the legacy pool.
+	txMaxSize = 128 * 1024
+
+	seenTxsCache      = 32768 // transaction hashes remembered for deduplication
+	seenAnnounceCache = 32768 // announced hashes remembered for deduplication
+	seenMsgCache      = 4096  // other message hashes remembered for deduplication
+)
+
+var (
+	errDecode         = errors.New("invalid message")
+	errDuplicate      = errors.New("duplicate message")
+	errAnnounceFields = errors.New("announcement field lengths differ")
+	errBlobBroadcast  = errors.New("blob transaction broadcast")
+	errOversizedTx    = errors.New("oversized transaction")
+	errEmptyAuthList  = errors.New("set code transaction without authorizations")
+)
+
+// newPooledTransactionHashesPacket is the eth/68+ transaction announcement.
+type newPooledTransactionHashesPacket struct {
+	Types  []byte
+	Sizes  []uint32
+	Hashes []common.Hash
+}
+
+// payloadValidator checks relayed messages before they are forwarded, so the
+// relay does not amplify malformed, invalid or repeated traffic.
+type payloadValidator struct {
+	signer types.Signer // nil if the chain config is unknown
+
+	lock      sync.Mutex
+	txs       lru.BasicLRU[common.Hash, struct{}]
+	announced lru.BasicLRU[common.Hash, struct{}]
+	msgs      lru.BasicLRU[common.Hash, struct{}]
+}
+
+func newPayloadValidator(config *params.ChainConfig) *payloadValidator {
+	v := &payloadValidator{
+		txs:       lru.NewBasicLRU[common.Hash, struct{}](seenTxsCache),
+		announced: lru.NewBasicLRU[common.Hash, struct{}](seenAnnounceCache),
+		msgs:      lru.NewBasicLRU[common.Hash, struct{}](seenMsgCache),
+	}
+	if config != nil {
+		v.signer = types.LatestSigner(config)
+	}
+	return v
+}
+
+// validate checks a relayed message. It returns the payload to forward, which
+// has the already seen items removed, or an error if nothing is left to
+// forward. Invalid transactions are removed as well and reported in the error,
+// along with the payload of the valid ones if there are any. Decode failures
+// wrap errDecode.
+func (v *payloadValidator) validate(msgCode uint64, payload []byte) ([]byte, error) {
+	switch msgCode {
+	case transactionsMsg:
+		return v.validateTransactions(payload)
+	case newPooledTransactionHashesMsg:
+		return v.validateAnnouncement(payload)
+	default:
+		// Other messages are forwarded verbatim, they only need to be well-formed
+		// RLP and not repeated.
+		if _, _, err := rlp.SplitList(payload); err != nil {
+			return nil, fmt.Errorf("%w: %v", errDecode, err)
+		}
+		v.lock.Lock()
+		defer v.lock.Unlock()
+		if !markSeen(&v.msgs, crypto.Keccak256Hash(payload)) {
+			return nil, errDuplicate
+		}
+		return payload, nil
+	}
+}
+
+func (v *payloadValidator) validateTransactions(payload []byte) ([]byte, error) {
+	var txs []*types.Transaction
+	if err := rlp.DecodeBytes(payload, &txs); err != nil {
+		return nil, fmt.Errorf("%w: %v", errDecode, err)
+	}
+	var (
+		invalid error
+		valid   = make([]*types.Transaction, 0, len(txs))
+	)
+	for i, tx := range txs {
+		if err := validateTransaction(v.signer, tx); err != nil {
+			if invalid == nil {
+				invalid = fmt.Errorf("transaction %d (%x): %w", i, tx.Hash(), err)
+			}
+			continue
+		}
+		valid = append(valid, tx)
+	}
+	v.lock.Lock()
+	fresh := valid[:0]
+	for _, tx := range valid {
+		if markSeen(&v.txs, tx.Hash()) {
+			fresh = append(fresh, tx)
+		}
+	}
+	v.lock.Unlock()
+
+	switch {
+	case len(fresh) == 0 && invalid != nil:
+		return nil, invalid
+	case len(fresh) == 0:
+		return nil, errDuplicate
+	case len(fresh) == len(txs):
+		return payload, nil
+	}
+	enc, err := rlp.EncodeToBytes(fresh)
+	if err != nil {
+		return nil, err
+	}
+	return enc, invalid
+}
+
+func (v *payloadValidator) validateAnnouncement(payload []byte) ([]byte, error) {
+	var ann newPooledTransactionHashesPacket
+	if err := rlp.DecodeBytes(payload, &ann); err != nil {
+		return nil, fmt.Errorf("%w: %v", errDecode, err)
+	}
+	if len(ann.Hashes) != len(ann.Types) || len(ann.Hashes) != len(ann.Sizes) {
+		return nil, fmt.Errorf("%w: %d hashes, %d types, %d sizes", errAnnounceFields, len(ann.Hashes), len(ann.Types), len(ann.Sizes))
+	}
+	var fresh newPooledTransactionHashesPacket
+	v.lock.Lock()
+	for i, hash := range ann.Hashes {
+		if markSeen(&v.announced, hash) {
+			fresh.Types = append(fresh.Types, ann.Types[i])
+			fresh.Sizes = append(fresh.Sizes, ann.Sizes[i])
+			fresh.Hashes = append(fresh.Hashes, hash)
+		}
+	}
+	v.lock.Unlock()
+
+	switch {
+	case len(fresh.Hashes) == 0:
+		return nil, errDuplicate
+	case len(fresh.Hashes) == len(ann.Hashes):
+		return payload, nil
+	default:
+		return rlp.EncodeToBytes(&fresh)
+	}
+}
+
+// markSeen adds hash to the cache. It returns false if it was already present.
+func markSeen(cache *lru.BasicLRU[common.Hash, struct{}], hash common.Hash) bool {
+	if cache.Contains(hash) {
+		return false
+	}
+	cache.Add(hash, struct{}{})
+	return true
+}
+
+// validateTransaction performs the checks of the transaction pool that do not
+// depend on the chain head or state. The signature is only checked if signer
+// is non-nil.
+func validateTransaction(signer types.Signer, tx *types.Transaction) error {
+	if tx.Type() == types.BlobTxType {
+		// Blob transactions are announced only, never broadcast in full.
+		return errBlobBroadcast
+	}
+	if tx.Size() > txMaxSize {
+		return fmt.Errorf("%w: size %d, limit %d", errOversizedTx, tx.Size(), txMaxSize)
+	}
+	if tx.To() == nil && len(tx.Data()) > params.MaxInitCodeSize {
+		return fmt.Errorf("%w: code size %v, limit %v", core.ErrMaxInitCodeSizeExceeded, len(tx.Data()), params.MaxInitCodeSize)
+	}
+	if tx.GasFeeCap().BitLen() > 256 {
+		return core.ErrFeeCapVeryHigh
+	}
+	if tx.GasTipCap().BitLen() > 256 {
+		return core.ErrTipVeryHigh
+	}
+	if tx.GasFeeCapIntCmp(tx.GasTipCap()) < 0 {
+		return core.ErrTipAboveFeeCap
+	}
+	if tx.Nonce()+1 < tx.Nonce() {
+		return core.ErrNonceMax
+	}
+	intrGas, err := core.IntrinsicGas(tx.Data(), tx.AccessList(), tx.SetCodeAuthorizations(), tx.To() == nil, true, true, true)
+	if err != nil {
+		return err
+	}
+	if tx.Gas() < intrGas {
+		return fmt.Errorf("%w: gas %v, minimum needed %v", core.ErrIntrinsicGas, tx.Gas(), intrGas)
+	}
+	if tx.Type() == types.SetCodeTxType && len(tx.SetCodeAuthorizations()) == 0 {
+		return errEmptyAuthList
+	}
+	if signer != nil {
+		if _, err := types.Sender(signer, tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
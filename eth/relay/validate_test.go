@@ -0,0 +1,167 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+
+func signedTestTx(t *testing.T, nonce uint64, gas uint64) *types.Transaction {
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   big.NewInt(1),
+		Nonce:     nonce,
+		Gas:       gas,
+		GasFeeCap: big.NewInt(2),
+		GasTipCap: big.NewInt(1),
+		To:        &common.Address{1},
+	})
+	signed, err := types.SignTx(tx, types.LatestSigner(params.MainnetChainConfig), testKey)
+	require.NoError(t, err)
+	return signed
+}
+
+func encodeTxs(t *testing.T, txs ...*types.Transaction) []byte {
+	enc, err := rlp.EncodeToBytes(txs)
+	require.NoError(t, err)
+	return enc
+}
+
+func TestValidateTransactions(t *testing.T) {
+	v := newPayloadValidator(params.MainnetChainConfig)
+	tx1, tx2 := signedTestTx(t, 0, 21000), signedTestTx(t, 1, 21000)
+
+	payload := encodeTxs(t, tx1)
+	out, err := v.validate(transactionsMsg, payload)
+	require.NoError(t, err)
+	assert.Equal(t, payload, out)
+
+	// Repeated transactions are dropped from the forwarded message.
+	_, err = v.validate(transactionsMsg, payload)
+	assert.ErrorIs(t, err, errDuplicate)
+	out, err = v.validate(transactionsMsg, encodeTxs(t, tx1, tx2))
+	require.NoError(t, err)
+	assert.Equal(t, encodeTxs(t, tx2), out)
+
+	// Messages without valid transactions are rejected.
+	unsigned := types.NewTx(&types.DynamicFeeTx{ChainID: big.NewInt(1), Gas: 21000, To: &common.Address{1}})
+	blob := types.NewTx(&types.BlobTx{Gas: 21000})
+	tests := []struct {
+		name    string
+		payload []byte
+		want    error
+	}{
+		{"garbage", []byte{0xc3, 0x01}, errDecode},
+		{"intrinsic gas", encodeTxs(t, signedTestTx(t, 2, 20999)), core.ErrIntrinsicGas},
+		{"blob broadcast", encodeTxs(t, blob), errBlobBroadcast},
+		{"bad signature", encodeTxs(t, unsigned), nil},
+	}
+	for _, tt := range tests {
+		_, err := v.validate(transactionsMsg, tt.payload)
+		if err == nil {
+			t.Errorf("%s: message accepted", tt.name)
+		} else if tt.want != nil {
+			assert.ErrorIs(t, err, tt.want, tt.name)
+		}
+	}
+
+	// Invalid transactions are dropped from mixed messages, the valid ones are
+	// forwarded and reported along with the error.
+	tx3 := signedTestTx(t, 3, 21000)
+	out, err = v.validate(transactionsMsg, encodeTxs(t, unsigned, tx3, signedTestTx(t, 4, 20999)))
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, errDecode)
+	assert.Equal(t, encodeTxs(t, tx3), out)
+
+	// Without fresh valid transactions left, the message is rejected.
+	out, err = v.validate(transactionsMsg, encodeTxs(t, tx3, unsigned))
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, errDuplicate)
+	assert.Nil(t, out)
+}
+
+func TestValidateAnnouncement(t *testing.T) {
+	v := newPayloadValidator(nil)
+	h1, h2 := common.Hash{1}, common.Hash{2}
+
+	bad, _ := rlp.EncodeToBytes(&newPooledTransactionHashesPacket{Types: []byte{2}, Sizes: []uint32{100, 200}, Hashes: []common.Hash{h1, h2}})
+	_, err := v.validate(newPooledTransactionHashesMsg, bad)
+	assert.ErrorIs(t, err, errAnnounceFields)
+
+	ann, _ := rlp.EncodeToBytes(&newPooledTransactionHashesPacket{Types: []byte{2}, Sizes: []uint32{100}, Hashes: []common.Hash{h1}})
+	out, err := v.validate(newPooledTransactionHashesMsg, ann)
+	require.NoError(t, err)
+	assert.Equal(t, ann, out)
+	_, err = v.validate(newPooledTransactionHashesMsg, ann)
+	assert.ErrorIs(t, err, errDuplicate)
+
+	both, _ := rlp.EncodeToBytes(&newPooledTransactionHashesPacket{Types: []byte{2, 3}, Sizes: []uint32{100, 200}, Hashes: []common.Hash{h1, h2}})
+	out, err = v.validate(newPooledTransactionHashesMsg, both)
+	require.NoError(t, err)
+	want, _ := rlp.EncodeToBytes(&newPooledTransactionHashesPacket{Types: []byte{3}, Sizes: []uint32{200}, Hashes: []common.Hash{h2}})
+	assert.Equal(t, want, out)
+}
+
+func TestForwardMessageRejects(t *testing.T) {
+	config := &Config{ChainConfig: params.MainnetChainConfig}
+	b := NewBackend(config, nil)
+	router := NewMessageRouter(b)
+	defer router.Stop()
+	b.AddPeer(newTestRelayPeer(1, nil))
+	b.AddPeer(newTestRelayPeer(2, nil))
+
+	payload := encodeTxs(t, signedTestTx(t, 0, 21000))
+	require.NoError(t, router.ForwardMessage(enode.ID{1}, transactionsMsg, payload))
+	require.NoError(t, router.ForwardMessage(enode.ID{1}, transactionsMsg, payload))
+	err := router.ForwardMessage(enode.ID{1}, transactionsMsg, encodeTxs(t, signedTestTx(t, 1, 100)))
+	assert.ErrorIs(t, err, ErrInvalidPayload)
+	err = router.ForwardMessage(enode.ID{1}, newPooledTransactionHashesMsg, []byte{0x01})
+	assert.ErrorIs(t, err, ErrInvalidPayload)
+
+	// The valid transactions of a mixed message are still forwarded.
+	router.removeQueue(enode.ID{2})
+	err = router.ForwardMessage(enode.ID{1}, transactionsMsg, encodeTxs(t, signedTestTx(t, 2, 100), signedTestTx(t, 3, 21000)))
+	assert.ErrorIs(t, err, ErrInvalidPayload)
+	assert.Eventually(t, func() bool {
+		_, ok := router.QueueStats()[enode.ID{2}]
+		return ok
+	}, time.Second, 5*time.Millisecond)
+
+	var stats PeerStats
+	for _, p := range b.Peers() {
+		if p.ID == (enode.ID{1}) {
+			stats = p.Stats()
+		}
+	}
+	assert.Equal(t, uint64(1), stats.Duplicates)
+	assert.Equal(t, uint64(3), stats.Rejected)
+	want := -2*misbehaviourPenalty[MisbehaviourInvalidTx] - misbehaviourPenalty[MisbehaviourDecode]
+	assert.InDelta(t, want, b.reputation.Score(enode.ID{1}), 0.1)
+}
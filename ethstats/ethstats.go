@@ -256,15 +256,6 @@ func (s *Service) loop(chainHeadCh chan core.ChainHeadEvent, txEventCh chan core
 		close(quitCh)
 	}()
 
-	// Resolve the URL, defaulting to TLS, but falling back to none too
-	path := fmt.Sprintf("%s/api", s.host)
-	urls := []string{path}
-
-	// url.Parse and url.IsAbs is unsuitable (https://github.com/golang/go/issues/19779)
-	if !strings.Contains(path, "://") {
-		urls = []string{"wss://" + path, "ws://" + path}
-	}
-
 	errTimer := time.NewTimer(0)
 	defer errTimer.Stop()
 	// Loop reporting until termination
@@ -274,21 +265,7 @@ func (s *Service) loop(chainHeadCh chan core.ChainHeadEvent, txEventCh chan core
 			return
 		case <-errTimer.C:
 			// Establish a websocket connection to the server on any supported URL
-			var (
-				conn *connWrapper
-				err  error
-			)
-			dialer := websocket.Dialer{HandshakeTimeout: 5 * time.Second}
-			header := make(http.Header)
-			header.Set("origin", "http://localhost")
-			for _, url := range urls {
-				c, _, e := dialer.Dial(url, header)
-				err = e
-				if err == nil {
-					conn = newConnectionWrapper(c)
-					break
-				}
-			}
+			conn, err := s.dial()
 			if err != nil {
 				log.Warn("Stats server unreachable", "err", err)
 				errTimer.Reset(10 * time.Second)
@@ -351,6 +328,31 @@ func (s *Service) loop(chainHeadCh chan core.ChainHeadEvent, txEventCh chan core
 	}
 }
 
+// dial establishes a websocket connection to the stats server.
+func (s *Service) dial() (*connWrapper, error) {
+	// Resolve the URL, defaulting to TLS, but falling back to none too
+	path := fmt.Sprintf("%s/api", s.host)
+	urls := []string{path}
+
+	// url.Parse and url.IsAbs is unsuitable (https://github.com/golang/go/issues/19779)
+	if !strings.Contains(path, "://") {
+		urls = []string{"wss://" + path, "ws://" + path}
+	}
+
+	var err error
+	dialer := websocket.Dialer{HandshakeTimeout: 5 * time.Second}
+	header := make(http.Header)
+	header.Set("origin", "http://localhost")
+	for _, url := range urls {
+		c, _, e := dialer.Dial(url, header)
+		err = e
+		if err == nil {
+			return newConnectionWrapper(c), nil
+		}
+	}
+	return nil, err
+}
+
 // readLoop loops as long as the connection is alive and retrieves data packets
 // from the network socket. If any of them match an active request, it forwards
 // it, if they themselves are requests it initiates a reply, and lastly it drops
@@ -494,6 +496,12 @@ func (s *Service) login(conn *connWrapper) error {
 		},
 		Secret: s.pass,
 	}
+	return s.sendLogin(conn, auth)
+}
+
+// sendLogin sends the authentication message and waits for the server to
+// accept it.
+func (s *Service) sendLogin(conn *connWrapper, auth *authMsg) error {
 	login := map[string][]interface{}{
 		"emit": {"hello", auth},
 	}
@@ -0,0 +1,328 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethstats
+
+import (
+	"fmt"
+	"math/big"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/eth/relay"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+)
+
+// relayEventChanSize is the size of channel listening to relay events.
+const relayEventChanSize = 64
+
+// relayBackend is the relay functionality needed for ethstats reporting.
+type relayBackend interface {
+	GetNetworkID() uint64
+	GetBlockRange() relay.BlockRange
+	Peers() []*relay.RelayPeer
+	Stats() relay.RelayStats
+	SubscribeEvents(ch chan<- relay.Event) event.Subscription
+}
+
+// RelayService reports the stats of a relay node to a netstats server. Relays
+// have no chain and no transaction pool: the head reported is the one the
+// relay advertises to its peers, and the node stats carry relay counters.
+type RelayService struct {
+	base    *Service // connection handling shared with full nodes
+	backend relayBackend
+
+	// Counters of the previous report, for the transaction rate
+	lastStats relay.RelayStats
+	lastTime  time.Time
+
+	eventSub event.Subscription
+	quit     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewRelay returns a monitoring service for a relay node ready for stats
+// reporting.
+func NewRelay(node *node.Node, backend relayBackend, url string) error {
+	parts, err := parseEthstatsURL(url)
+	if err != nil {
+		return err
+	}
+	node.RegisterLifecycle(newRelayService(node, backend, parts))
+	return nil
+}
+
+func newRelayService(node *node.Node, backend relayBackend, parts []string) *RelayService {
+	return &RelayService{
+		base: &Service{
+			server: node.Server(),
+			node:   parts[0],
+			pass:   parts[1],
+			host:   parts[2],
+			pongCh: make(chan struct{}),
+			histCh: make(chan []uint64, 1),
+		},
+		backend: backend,
+		quit:    make(chan struct{}),
+	}
+}
+
+// Start implements node.Lifecycle, starting up the monitoring and reporting daemon.
+func (s *RelayService) Start() error {
+	eventCh := make(chan relay.Event, relayEventChanSize)
+	s.eventSub = s.backend.SubscribeEvents(eventCh)
+	s.wg.Add(1)
+	go s.loop(eventCh)
+
+	log.Info("Relay stats daemon started")
+	return nil
+}
+
+// Stop implements node.Lifecycle, terminating the monitoring and reporting daemon.
+func (s *RelayService) Stop() error {
+	s.eventSub.Unsubscribe()
+	close(s.quit)
+	s.wg.Wait()
+	log.Info("Relay stats daemon stopped")
+	return nil
+}
+
+// loop keeps trying to connect to the netstats server, reporting block range
+// changes until termination.
+func (s *RelayService) loop(eventCh chan relay.Event) {
+	defer s.wg.Done()
+
+	// Exhaust the event subscription, the relay blocks on slow subscribers
+	headCh := make(chan struct{}, 1)
+	go func() {
+		for {
+			select {
+			case ev := <-eventCh:
+				if ev.Type == relay.EventBlockRange {
+					select {
+					case headCh <- struct{}{}:
+					default:
+					}
+				}
+			case <-s.eventSub.Err():
+				return
+			}
+		}
+	}()
+
+	errTimer := time.NewTimer(0)
+	defer errTimer.Stop()
+	// Loop reporting until termination
+	for {
+		select {
+		case <-s.quit:
+			return
+		case <-errTimer.C:
+			conn, err := s.base.dial()
+			if err != nil {
+				log.Warn("Stats server unreachable", "err", err)
+				errTimer.Reset(10 * time.Second)
+				continue
+			}
+			// Authenticate the client with the server
+			if err = s.login(conn); err != nil {
+				log.Warn("Stats login failed", "err", err)
+				conn.Close()
+				errTimer.Reset(10 * time.Second)
+				continue
+			}
+			go s.base.readLoop(conn)
+
+			// Send the initial stats so our node looks decent from the get go
+			if err = s.report(conn); err != nil {
+				log.Warn("Initial stats report failed", "err", err)
+				conn.Close()
+				errTimer.Reset(0)
+				continue
+			}
+			// Keep sending status updates until the connection breaks
+			fullReport := time.NewTicker(15 * time.Second)
+
+			for err == nil {
+				select {
+				case <-s.quit:
+					fullReport.Stop()
+					conn.Close()
+					return
+
+				case <-fullReport.C:
+					if err = s.report(conn); err != nil {
+						log.Warn("Full stats report failed", "err", err)
+					}
+				case <-s.base.histCh:
+					// Relays keep no blocks, answer with an empty history
+					if err = s.reportHistory(conn); err != nil {
+						log.Warn("Requested history report failed", "err", err)
+					}
+				case <-headCh:
+					if err = s.reportBlock(conn); err != nil {
+						log.Warn("Block stats report failed", "err", err)
+					}
+				}
+			}
+			fullReport.Stop()
+
+			// Close the current connection and establish a new one
+			conn.Close()
+			errTimer.Reset(0)
+		}
+	}
+}
+
+// login tries to authorize the client at the remote server.
+func (s *RelayService) login(conn *connWrapper) error {
+	infos := s.base.server.NodeInfo()
+
+	var protocols []string
+	for _, proto := range s.base.server.Protocols {
+		protocols = append(protocols, fmt.Sprintf("%s/%d", proto.Name, proto.Version))
+	}
+	auth := &authMsg{
+		ID: s.base.node,
+		Info: nodeInfo{
+			Name:     s.base.node,
+			Node:     infos.Name,
+			Port:     infos.Ports.Listener,
+			Network:  fmt.Sprintf("%d", s.backend.GetNetworkID()),
+			Protocol: strings.Join(protocols, ", "),
+			API:      "No",
+			Os:       runtime.GOOS,
+			OsVer:    runtime.GOARCH,
+			Client:   "0.1.1",
+			History:  false,
+		},
+		Secret: s.base.pass,
+	}
+	return s.base.sendLogin(conn, auth)
+}
+
+// report sends the latency, the advertised head and the node stats to the
+// stats server.
+func (s *RelayService) report(conn *connWrapper) error {
+	if err := s.base.reportLatency(conn); err != nil {
+		return err
+	}
+	if err := s.reportBlock(conn); err != nil {
+		return err
+	}
+	return s.reportStats(conn)
+}
+
+// reportBlock reports the head of the block range advertised by the relay. Only
+// the number and hash are known.
+func (s *RelayService) reportBlock(conn *connWrapper) error {
+	r := s.backend.GetBlockRange()
+	details := &blockStats{
+		Number:    new(big.Int).SetUint64(r.LatestBlock),
+		Hash:      r.LatestBlockHash,
+		Timestamp: new(big.Int),
+		Diff:      "0",
+		TotalDiff: "0",
+		Txs:       []txStats{},
+	}
+	log.Trace("Sending advertised head to ethstats", "number", details.Number, "hash", details.Hash)
+
+	stats := map[string]interface{}{
+		"id":    s.base.node,
+		"block": details,
+	}
+	report := map[string][]interface{}{
+		"emit": {"block", stats},
+	}
+	return conn.WriteJSON(report)
+}
+
+// reportHistory answers a history request with an empty history.
+func (s *RelayService) reportHistory(conn *connWrapper) error {
+	stats := map[string]interface{}{
+		"id":      s.base.node,
+		"history": []*blockStats{},
+	}
+	report := map[string][]interface{}{
+		"emit": {"history", stats},
+	}
+	return conn.WriteJSON(report)
+}
+
+// relayNodeStats is the information to report about the local relay node.
+type relayNodeStats struct {
+	nodeStats
+	Relay relayStats `json:"relay"`
+}
+
+// relayStats is the relay specific part of the node stats.
+type relayStats struct {
+	EarliestBlock    uint64  `json:"earliestBlock"`
+	LatestBlock      uint64  `json:"latestBlock"`
+	EthPeers         int     `json:"ethPeers"`
+	Relayed          uint64  `json:"relayed"`          // messages forwarded to peers since startup
+	TxsPerSecond     float64 `json:"txsPerSecond"`     // transactions received since the previous report
+	ProxyRequests    uint64  `json:"proxyRequests"`    // requests proxied since startup
+	ProxySuccessRate float64 `json:"proxySuccessRate"` // answered share of the finished proxied requests, 1 if none finished
+}
+
+// reportStats reports the peer count and the relay counters to the stats
+// server.
+func (s *RelayService) reportStats(conn *connWrapper) error {
+	var (
+		now      = time.Now()
+		counters = s.backend.Stats()
+		r        = s.backend.GetBlockRange()
+		rs       = relayStats{
+			EarliestBlock:    r.EarliestBlock,
+			LatestBlock:      r.LatestBlock,
+			EthPeers:         len(s.backend.Peers()),
+			Relayed:          counters.Relayed,
+			ProxyRequests:    counters.Requests,
+			ProxySuccessRate: 1,
+		}
+	)
+	if !s.lastTime.IsZero() {
+		if elapsed := now.Sub(s.lastTime).Seconds(); elapsed > 0 {
+			rs.TxsPerSecond = float64(counters.Transactions-s.lastStats.Transactions) / elapsed
+		}
+	}
+	if finished := counters.Answered + counters.Failed; finished > 0 {
+		rs.ProxySuccessRate = float64(counters.Answered) / float64(finished)
+	}
+	s.lastStats, s.lastTime = counters, now
+
+	log.Trace("Sending relay details to ethstats")
+	stats := map[string]interface{}{
+		"id": s.base.node,
+		"stats": &relayNodeStats{
+			nodeStats: nodeStats{
+				Active: true,
+				Peers:  s.base.server.PeerCount(),
+				Uptime: 100,
+			},
+			Relay: rs,
+		},
+	}
+	report := map[string][]interface{}{
+		"emit": {"stats", stats},
+	}
+	return conn.WriteJSON(report)
+}
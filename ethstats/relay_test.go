@@ -0,0 +1,264 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethstats
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/relay"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/gorilla/websocket"
+)
+
+// testRelayBackend is a relayBackend with settable values.
+type testRelayBackend struct {
+	mu     sync.Mutex
+	br     relay.BlockRange
+	stats  relay.RelayStats
+	events event.Feed
+}
+
+func (b *testRelayBackend) GetNetworkID() uint64      { return 1 }
+func (b *testRelayBackend) Peers() []*relay.RelayPeer { return nil }
+
+func (b *testRelayBackend) GetBlockRange() relay.BlockRange {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.br
+}
+
+func (b *testRelayBackend) Stats() relay.RelayStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
+
+func (b *testRelayBackend) SubscribeEvents(ch chan<- relay.Event) event.Subscription {
+	return b.events.Subscribe(ch)
+}
+
+// testStatsServer is a websocket stand-in for a netstats server. It accepts the
+// login, answers pings and records the messages received.
+type testStatsServer struct {
+	t        *testing.T
+	msgs     chan []json.RawMessage // emit arguments, by message
+	hello    chan authMsg
+	connLock sync.Mutex
+	conn     *websocket.Conn
+}
+
+func newTestStatsServer(t *testing.T) (*testStatsServer, string) {
+	srv := &testStatsServer{t: t, msgs: make(chan []json.RawMessage, 100), hello: make(chan authMsg, 1)}
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	httpsrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api" {
+			http.NotFound(w, r)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		srv.connLock.Lock()
+		srv.conn = conn
+		srv.connLock.Unlock()
+		srv.serve(conn)
+	}))
+	t.Cleanup(httpsrv.Close)
+	return srv, "ws://" + strings.TrimPrefix(httpsrv.URL, "http://")
+}
+
+func (srv *testStatsServer) serve(conn *websocket.Conn) {
+	defer conn.Close()
+	for {
+		var msg struct {
+			Emit []json.RawMessage `json:"emit"`
+		}
+		if err := conn.ReadJSON(&msg); err != nil || len(msg.Emit) == 0 {
+			return
+		}
+		var kind string
+		json.Unmarshal(msg.Emit[0], &kind)
+		switch kind {
+		case "hello":
+			var auth authMsg
+			json.Unmarshal(msg.Emit[1], &auth)
+			srv.hello <- auth
+			srv.send(map[string][]string{"emit": {"ready"}})
+		case "node-ping":
+			srv.send(map[string][]interface{}{"emit": {"node-pong", map[string]string{}}})
+		default:
+			srv.msgs <- msg.Emit
+		}
+	}
+}
+
+func (srv *testStatsServer) send(v interface{}) {
+	srv.connLock.Lock()
+	defer srv.connLock.Unlock()
+	if err := srv.conn.WriteJSON(v); err != nil {
+		srv.t.Log("write failed:", err)
+	}
+}
+
+// next returns the payload of the next message of the given kind.
+func (srv *testStatsServer) next(kind string, v interface{}) {
+	srv.t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg := <-srv.msgs:
+			var k string
+			json.Unmarshal(msg[0], &k)
+			if k != kind {
+				continue
+			}
+			if err := json.Unmarshal(msg[1], v); err != nil {
+				srv.t.Fatalf("invalid %s message: %v", kind, err)
+			}
+			return
+		case <-timeout:
+			srv.t.Fatalf("no %s message received", kind)
+		}
+	}
+}
+
+func TestRelayReporting(t *testing.T) {
+	srv, url := newTestStatsServer(t)
+
+	stack, err := node.New(&node.Config{
+		Name: "gethrelay",
+		P2P:  p2p.Config{ListenAddr: "127.0.0.1:0", NoDiscovery: true, MaxPeers: 10},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stack.Close()
+
+	backend := &testRelayBackend{
+		br:    relay.BlockRange{EarliestBlock: 10, LatestBlock: 100, LatestBlockHash: common.Hash{0x64}},
+		stats: relay.RelayStats{Relayed: 42, Transactions: 7, Requests: 4, Answered: 3, Failed: 1},
+	}
+	if err := NewRelay(stack, backend, "relay1:secret@"+url); err != nil {
+		t.Fatal(err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Login carries the relay's network.
+	select {
+	case auth := <-srv.hello:
+		if auth.ID != "relay1" || auth.Secret != "secret" || auth.Info.Network != "1" || auth.Info.History {
+			t.Errorf("wrong login: %+v", auth)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no login received")
+	}
+
+	var latency struct {
+		ID      string `json:"id"`
+		Latency string `json:"latency"`
+	}
+	srv.next("latency", &latency)
+	if latency.ID != "relay1" || latency.Latency == "" {
+		t.Errorf("wrong latency report: %+v", latency)
+	}
+
+	var block struct {
+		Block struct {
+			Number uint64      `json:"number"`
+			Hash   common.Hash `json:"hash"`
+		} `json:"block"`
+	}
+	srv.next("block", &block)
+	if block.Block.Number != 100 || block.Block.Hash != (common.Hash{0x64}) {
+		t.Errorf("wrong head reported: %+v", block.Block)
+	}
+
+	var stats struct {
+		Stats relayNodeStats `json:"stats"`
+	}
+	srv.next("stats", &stats)
+	rs := stats.Stats.Relay
+	if !stats.Stats.Active || rs.Relayed != 42 || rs.ProxyRequests != 4 || rs.ProxySuccessRate != 0.75 || rs.LatestBlock != 100 || rs.EarliestBlock != 10 {
+		t.Errorf("wrong stats reported: %+v", stats.Stats)
+	}
+
+	// Block range updates are reported as new heads.
+	backend.mu.Lock()
+	backend.br.LatestBlock = 101
+	backend.mu.Unlock()
+	backend.events.Send(relay.Event{Type: relay.EventBlockRange})
+	srv.next("block", &block)
+	if block.Block.Number != 101 {
+		t.Errorf("head update reported block %d, want 101", block.Block.Number)
+	}
+
+	// History requests get an empty history.
+	srv.send(map[string][]interface{}{"emit": {"history", map[string]interface{}{"list": []int{1, 2}}}})
+	var history struct {
+		History []json.RawMessage `json:"history"`
+	}
+	srv.next("history", &history)
+	if len(history.History) != 0 {
+		t.Errorf("history of %d blocks reported, want none", len(history.History))
+	}
+}
+
+func TestRelayTxRate(t *testing.T) {
+	backend := &testRelayBackend{stats: relay.RelayStats{Transactions: 100}}
+	s := &RelayService{backend: backend, lastStats: relay.RelayStats{Transactions: 40}, lastTime: time.Now().Add(-2 * time.Second)}
+	key, _ := crypto.GenerateKey()
+	server := &p2p.Server{Config: p2p.Config{PrivateKey: key, NoDiscovery: true, MaxPeers: 1}}
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+	s.base = &Service{server: server, node: "relay"}
+
+	srv, url := newTestStatsServer(t)
+	s.base.host = url
+	conn, err := s.base.dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := s.reportStats(conn); err != nil {
+		t.Fatal(err)
+	}
+	var stats struct {
+		Stats relayNodeStats `json:"stats"`
+	}
+	srv.next("stats", &stats)
+	if rate := stats.Stats.Relay.TxsPerSecond; rate < 25 || rate > 31 {
+		t.Errorf("transaction rate %v, want about 30", rate)
+	}
+	if rate := stats.Stats.Relay.ProxySuccessRate; rate != 1 {
+		t.Errorf("proxy success rate %v without finished requests, want 1", rate)
+	}
+}
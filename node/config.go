@@ -39,6 +39,7 @@ const (
 	datadirStaticNodes     = "static-nodes.json"  // Path within the datadir to the static node list
 	datadirTrustedNodes    = "trusted-nodes.json" // Path within the datadir to the trusted node list
 	datadirNodeDatabase    = "nodes"              // Path within the datadir to store the node infos
+	datadirI2PKey          = "i2pkey"             // Path within the datadir to the I2P session destination
 )
 
 // Config represents a small collection of configuration values to fine tune the
@@ -239,6 +240,22 @@ type TorConfig struct {
 	// WSPort overrides the virtual port advertised for the WS endpoint. Zero falls
 	// back to the actual listener port.
 	WSPort int `toml:"ws_port,omitempty"`
+
+	// P2POnly publishes only the P2P hidden service and keeps the RPC endpoints
+	// off Tor.
+	P2POnly bool `toml:"p2p_only,omitempty"`
+
+	// AuthorizedClientsDir holds one <name>.auth file per client allowed to
+	// reach our hidden services (v3 client authorization). If it contains any
+	// keys, the services are invisible to everyone else. It may be relative to
+	// the node data directory.
+	AuthorizedClientsDir string `toml:"authorized_clients_dir,omitempty"`
+
+	// ClientAuthDir holds <name>.auth_private files with the keys we use to
+	// reach other nodes' restricted hidden services. The keys are handed to
+	// Tor before the p2p server starts dialing. It may be relative to the node
+	// data directory.
+	ClientAuthDir string `toml:"client_auth_dir,omitempty"`
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
@@ -37,6 +37,8 @@ const (
 	DefaultTorControlAddress = "127.0.0.1:9051"          // Default Tor control port endpoint
 	DefaultTorCookiePath     = "tor/control_auth_cookie" // Default Tor cookie path relative to datadir
 	DefaultTorServiceDir     = "tor/hidden_service"      // Default hidden service dir relative to datadir
+	DefaultTorAuthorizedDir  = "tor/authorized_clients"  // Default authorized client keys dir relative to datadir
+	DefaultTorClientAuthDir  = "tor/client_auth"         // Default client auth private keys dir relative to datadir
 )
 
 const (
@@ -71,9 +73,11 @@ var DefaultConfig = Config{
 	BatchResponseMaxSize: 25 * 1000 * 1000,
 	GraphQLVirtualHosts:  []string{"localhost"},
 	Tor: TorConfig{
-		ControlAddress:   DefaultTorControlAddress,
-		CookiePath:       DefaultTorCookiePath,
-		HiddenServiceDir: DefaultTorServiceDir,
+		ControlAddress:       DefaultTorControlAddress,
+		CookiePath:           DefaultTorCookiePath,
+		HiddenServiceDir:     DefaultTorServiceDir,
+		AuthorizedClientsDir: DefaultTorAuthorizedDir,
+		ClientAuthDir:        DefaultTorClientAuthDir,
 	},
 	P2P: p2p.Config{
 		ListenAddr: ":30303",
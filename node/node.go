@@ -142,6 +142,9 @@ func New(conf *Config) (*Node, error) {
 	if node.server.Config.NodeDatabase == "" {
 		node.server.Config.NodeDatabase = node.config.NodeDB()
 	}
+	if node.server.Config.I2PSAMAddress != "" && node.server.Config.I2PKeyFile == "" {
+		node.server.Config.I2PKeyFile = node.config.ResolvePath(datadirI2PKey)
+	}
 
 	// Check HTTP/WS prefixes are valid.
 	if err := validatePrefix("HTTP", conf.HTTPPathPrefix); err != nil {
@@ -268,6 +271,17 @@ func (n *Node) doClose(errs []error) error {
 
 // openEndpoints starts all network and RPC endpoints.
 func (n *Node) openEndpoints() error {
+	// Onion client authorization keys must be known to Tor before dialing
+	if err := n.loadTorClientAuth(); err != nil {
+		return fmt.Errorf("failed to load Tor client authorization keys: %w", err)
+	}
+
+	// The P2P hidden service forwards to a listener of its own, which tells
+	// onion peers from local connections.
+	if n.config.Tor.Enabled && n.server.ListenAddr != "" && n.server.OnionListenAddr == "" {
+		n.server.OnionListenAddr = "127.0.0.1:0"
+	}
+
 	// start networking endpoints
 	n.log.Info("Starting peer-to-peer node", "instance", n.server.Name)
 	if err := n.server.Start(); err != nil {
@@ -281,7 +295,7 @@ func (n *Node) openEndpoints() error {
 			// ListenAddr format: "host:port" or ":port"
 			if _, portStr, err := net.SplitHostPort(addr); err == nil {
 				if port, err := strconv.Atoi(portStr); err == nil && port > 0 {
-					if err := n.enableP2PTorHiddenService(n.server.LocalNode(), port); err != nil {
+					if err := n.enableP2PTorHiddenService(n.server.LocalNode(), port, n.server.OnionListenAddr); err != nil {
 						n.log.Warn("Failed to enable P2P Tor hidden service", "err", err)
 						// Don't fail startup, just log warning
 					}
@@ -20,7 +20,8 @@ import (
 )
 
 const (
-	torOKResponse = 250
+	torOKResponse       = 250
+	torReplacedResponse = 251 // ONION_CLIENT_AUTH_ADD replaced an existing key
 
 	torKeyFilename      = "hs_ed25519_secret_key"
 	torHostnameFilename = "hostname"
@@ -32,7 +33,7 @@ const (
 // hidden service backed by the configured RPC endpoints.
 func (n *Node) enableTorHiddenService() error {
 	cfg := n.config.Tor
-	if !cfg.Enabled {
+	if !cfg.Enabled || cfg.P2POnly {
 		return nil
 	}
 
@@ -96,7 +97,11 @@ func (n *Node) enableTorHiddenService() error {
 		return err
 	}
 
-	serviceID, newKey, err := controller.addOnion(keySpec, mappings)
+	clientAuth, err := loadTorAuthorizedClients(n.resolveTorPath(cfg.AuthorizedClientsDir))
+	if err != nil {
+		return err
+	}
+	serviceID, newKey, err := controller.addOnion(keySpec, mappings, clientAuth)
 	if err != nil {
 		return err
 	}
@@ -219,9 +224,17 @@ func (c *torController) authenticate(cookie []byte) error {
 	return err
 }
 
-func (c *torController) addOnion(keySpec string, mappings []string) (serviceID string, privateKey string, err error) {
+// addOnion creates a hidden service. If clientAuth holds any x25519 public
+// keys, the service descriptor is only readable by those clients.
+func (c *torController) addOnion(keySpec string, mappings []string, clientAuth []string) (serviceID string, privateKey string, err error) {
 	parts := []string{"ADD_ONION", keySpec, "Flags=Detach"}
+	if len(clientAuth) > 0 {
+		parts[2] = "Flags=Detach,V3Auth"
+	}
 	parts = append(parts, mappings...)
+	for _, key := range clientAuth {
+		parts = append(parts, "ClientAuthV3="+key)
+	}
 	lines, err := c.command(strings.Join(parts, " "))
 	if err != nil {
 		return "", "", err
@@ -239,14 +252,16 @@ func (c *torController) addOnion(keySpec string, mappings []string) (serviceID s
 	return serviceID, privateKey, nil
 }
 
-func (c *torController) command(cmd string) ([]string, error) {
+// command sends a control command and reads its reply. Replies other than
+// 250 OK fail the command, unless their code is listed in accept.
+func (c *torController) command(cmd string, accept ...int) ([]string, error) {
 	if _, err := fmt.Fprintf(c.conn, "%s\r\n", cmd); err != nil {
 		return nil, err
 	}
-	return c.readReply()
+	return c.readReply(accept)
 }
 
-func (c *torController) readReply() ([]string, error) {
+func (c *torController) readReply(accept []int) ([]string, error) {
 	var lines []string
 	deadline := time.Now().Add(torCommandTimeout)
 	_ = c.conn.SetDeadline(deadline)
@@ -265,7 +280,7 @@ func (c *torController) readReply() ([]string, error) {
 		if err != nil {
 			return nil, fmt.Errorf("invalid tor status code: %q", codePart)
 		}
-		if code != torOKResponse {
+		if code != torOKResponse && !slices.Contains(accept, code) {
 			return nil, fmt.Errorf("tor control error %d: %s", code, text)
 		}
 		lines = append(lines, text)
@@ -276,19 +291,21 @@ func (c *torController) readReply() ([]string, error) {
 	return lines, nil
 }
 
-// enableP2PTorHiddenService creates a Tor hidden service for the P2P port
-// and updates the local node's ENR with the .onion address.
+// enableP2PTorHiddenService creates a Tor hidden service for the P2P port,
+// forwarding to the onion listener at target, and updates the local node's ENR
+// with the .onion address.
 //
 // This method:
 //   - Connects to the Tor control port
-//   - Creates an ephemeral hidden service for the P2P port
+//   - Creates a hidden service for the P2P port, restricted to the authorized
+//     clients if any are configured
 //   - Retrieves the .onion address from the Tor controller
 //   - Updates the local node's ENR with the .onion address
 //
 // The method gracefully handles cases where Tor is disabled or unavailable.
 // If localNode is nil, an error is returned.
 // If Tor is not enabled in the config, the method returns nil without error.
-func (n *Node) enableP2PTorHiddenService(localNode *enode.LocalNode, p2pPort int) error {
+func (n *Node) enableP2PTorHiddenService(localNode *enode.LocalNode, p2pPort int, target string) error {
 	if localNode == nil {
 		return errors.New("local node is nil")
 	}
@@ -303,45 +320,47 @@ func (n *Node) enableP2PTorHiddenService(localNode *enode.LocalNode, p2pPort int
 		return fmt.Errorf("invalid P2P port: %d", p2pPort)
 	}
 
-	// Use default control address if not configured
-	controlAddr := cfg.ControlAddress
-	if controlAddr == "" {
-		controlAddr = DefaultTorControlAddress
-	}
-
-	// Connect to Tor control port
-	controller, err := dialTorController(controlAddr)
+	controller, err := n.openTorController()
 	if err != nil {
-		return fmt.Errorf("failed to connect to Tor controller: %w", err)
+		return err
 	}
 	defer controller.Close()
 
-	// Authenticate with Tor
-	if err := controller.protocolInfo(); err != nil {
-		return fmt.Errorf("tor protocol info failed: %w", err)
-	}
-
-	cookiePath := cfg.CookiePath
-	if cookiePath == "" {
-		cookiePath = DefaultTorCookiePath
-	}
-	cookiePath = n.resolveTorPath(cookiePath)
-	cookie, err := os.ReadFile(cookiePath)
+	// Restricted services only publish descriptors for the authorized clients.
+	// Those clients pin our address in their key files, so the service key has
+	// to survive restarts instead of being ephemeral.
+	clientAuth, err := loadTorAuthorizedClients(n.resolveTorPath(cfg.AuthorizedClientsDir))
 	if err != nil {
-		return fmt.Errorf("failed to read Tor cookie: %w", err)
+		return err
 	}
-
-	if err := controller.authenticate(cookie); err != nil {
-		return fmt.Errorf("tor authentication failed: %w", err)
+	keySpec, keyPath := "NEW:ED25519-V3", ""
+	if len(clientAuth) > 0 {
+		hsDir := cfg.HiddenServiceDir
+		if hsDir == "" {
+			hsDir = DefaultTorServiceDir
+		}
+		hsDir = n.resolveTorPath(hsDir)
+		if err := os.MkdirAll(hsDir, 0o700); err != nil {
+			return fmt.Errorf("tor hidden service dir create failed: %w", err)
+		}
+		keyPath = filepath.Join(hsDir, torP2PKeyFilename)
+		if keySpec, err = loadTorKey(keyPath); err != nil {
+			return err
+		}
 	}
 
-	// Create ephemeral hidden service for P2P port
+	// Create hidden service for P2P port
 	// Format: Port=<virtual_port>,<target_address>:<target_port>
-	mapping := fmt.Sprintf("Port=%d,127.0.0.1:%d", p2pPort, p2pPort)
-	serviceID, _, err := controller.addOnion("NEW:ED25519-V3", []string{mapping})
+	mapping := fmt.Sprintf("Port=%d,%s", p2pPort, target)
+	serviceID, newKey, err := controller.addOnion(keySpec, []string{mapping}, clientAuth)
 	if err != nil {
 		return fmt.Errorf("failed to create P2P hidden service: %w", err)
 	}
+	if keyPath != "" && newKey != "" {
+		if err := os.WriteFile(keyPath, []byte(newKey+"\n"), 0o600); err != nil {
+			return fmt.Errorf("write tor key failed: %w", err)
+		}
+	}
 
 	// Construct .onion address (56 base32 chars + ".onion")
 	onionAddress := serviceID + ".onion"
@@ -356,7 +375,7 @@ func (n *Node) enableP2PTorHiddenService(localNode *enode.LocalNode, p2pPort int
 	// Set in ENR (validation passed)
 	localNode.Set(onion)
 
-	n.log.Info("P2P Tor hidden service ready", "onion", onionAddress, "port", p2pPort)
+	n.log.Info("P2P Tor hidden service ready", "onion", onionAddress, "port", p2pPort, "authorizedClients", len(clientAuth))
 	return nil
 }
 
@@ -0,0 +1,234 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Onion client authorization (v3) uses x25519 key pairs. The files follow the
+// layout used by Tor itself, so keys can be shared with a stock tor daemon:
+//
+//	<AuthorizedClientsDir>/<name>.auth          descriptor:x25519:<base32 public key>
+//	<ClientAuthDir>/<name>.auth_private         <service id>:descriptor:x25519:<base32 private key>
+//
+// The server side passes every authorized public key to ADD_ONION as
+// ClientAuthV3, the dialing side registers every private key through
+// ONION_CLIENT_AUTH_ADD before any connection is attempted.
+const (
+	torAuthFileExt        = ".auth"
+	torAuthPrivateFileExt = ".auth_private"
+	torAuthKeyType        = "x25519"
+
+	torP2PKeyFilename = "p2p_ed25519_secret_key"
+)
+
+var torBase32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// torClientAuth is a client authorization key for a single onion service.
+type torClientAuth struct {
+	ServiceID  string // onion address without the .onion suffix
+	PrivateKey []byte // raw x25519 private key
+}
+
+// GenerateTorClientAuth creates a new x25519 key pair for onion client
+// authorization. It returns the contents of the .auth file to be placed in the
+// serving node's authorized clients directory and the contents of the
+// .auth_private file for the dialing node's client auth directory.
+func GenerateTorClientAuth(onionAddress string) (authFile, authPrivateFile string, err error) {
+	serviceID := strings.TrimSuffix(strings.ToLower(onionAddress), ".onion")
+	if len(serviceID) != 56 {
+		return "", "", fmt.Errorf("invalid onion address %q", onionAddress)
+	}
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	pub := torBase32.EncodeToString(key.PublicKey().Bytes())
+	priv := torBase32.EncodeToString(key.Bytes())
+	authFile = fmt.Sprintf("descriptor:%s:%s", torAuthKeyType, pub)
+	authPrivateFile = fmt.Sprintf("%s:descriptor:%s:%s", serviceID, torAuthKeyType, priv)
+	return authFile, authPrivateFile, nil
+}
+
+// loadTorAuthorizedClients reads the base32 encoded public keys of all clients
+// allowed to reach our onion services. A missing directory yields no keys.
+func loadTorAuthorizedClients(dir string) ([]string, error) {
+	files, err := readTorAuthDir(dir, torAuthFileExt)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(files))
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read tor client auth failed: %w", err)
+		}
+		fields := strings.Split(strings.TrimSpace(string(content)), ":")
+		if len(fields) != 3 || fields[0] != "descriptor" || fields[1] != torAuthKeyType {
+			return nil, fmt.Errorf("invalid tor client auth file %s", file)
+		}
+		if _, err := decodeTorAuthKey(fields[2]); err != nil {
+			return nil, fmt.Errorf("invalid tor client auth file %s: %w", file, err)
+		}
+		keys = append(keys, strings.ToUpper(fields[2]))
+	}
+	return keys, nil
+}
+
+// loadTorClientAuthKeys reads the client authorization private keys used to
+// reach other nodes' restricted onion services. A missing directory yields no
+// keys.
+func loadTorClientAuthKeys(dir string) ([]torClientAuth, error) {
+	files, err := readTorAuthDir(dir, torAuthPrivateFileExt)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]torClientAuth, 0, len(files))
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read tor client key failed: %w", err)
+		}
+		fields := strings.Split(strings.TrimSpace(string(content)), ":")
+		if len(fields) != 4 || fields[1] != "descriptor" || fields[2] != torAuthKeyType {
+			return nil, fmt.Errorf("invalid tor client key file %s", file)
+		}
+		serviceID := strings.TrimSuffix(strings.ToLower(fields[0]), ".onion")
+		if len(serviceID) != 56 {
+			return nil, fmt.Errorf("invalid tor client key file %s: bad onion address", file)
+		}
+		priv, err := decodeTorAuthKey(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("invalid tor client key file %s: %w", file, err)
+		}
+		keys = append(keys, torClientAuth{ServiceID: serviceID, PrivateKey: priv})
+	}
+	return keys, nil
+}
+
+// readTorAuthDir lists the files with the given extension in dir, sorted by name.
+func readTorAuthDir(dir, ext string) ([]string, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read tor auth dir failed: %w", err)
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ext {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+func decodeTorAuthKey(key string) ([]byte, error) {
+	raw, err := torBase32.DecodeString(strings.ToUpper(key))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("invalid x25519 key length %d", len(raw))
+	}
+	return raw, nil
+}
+
+// onionClientAuthAdd registers a client authorization key with the Tor daemon,
+// allowing it to fetch the descriptor of the restricted onion service.
+func (c *torController) onionClientAuthAdd(auth torClientAuth) error {
+	blob := base64.StdEncoding.EncodeToString(auth.PrivateKey)
+	_, err := c.command(fmt.Sprintf("ONION_CLIENT_AUTH_ADD %s %s:%s", auth.ServiceID, torAuthKeyType, blob), torReplacedResponse)
+	return err
+}
+
+// openTorController dials and authenticates against the configured Tor control
+// port.
+func (n *Node) openTorController() (*torController, error) {
+	cfg := n.config.Tor
+	controlAddr := cfg.ControlAddress
+	if controlAddr == "" {
+		controlAddr = DefaultTorControlAddress
+	}
+	controller, err := dialTorController(controlAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Tor controller: %w", err)
+	}
+	if err := controller.protocolInfo(); err != nil {
+		controller.Close()
+		return nil, fmt.Errorf("tor protocol info failed: %w", err)
+	}
+	cookiePath := cfg.CookiePath
+	if cookiePath == "" {
+		cookiePath = DefaultTorCookiePath
+	}
+	cookie, err := os.ReadFile(n.resolveTorPath(cookiePath))
+	if err != nil {
+		controller.Close()
+		return nil, fmt.Errorf("failed to read Tor cookie: %w", err)
+	}
+	if err := controller.authenticate(cookie); err != nil {
+		controller.Close()
+		return nil, fmt.Errorf("tor authentication failed: %w", err)
+	}
+	return controller, nil
+}
+
+// loadTorClientAuth hands all configured client authorization keys to Tor.
+// It must run before the p2p server starts dialing, otherwise connections to
+// restricted onion services fail until the descriptor fetch is retried.
+func (n *Node) loadTorClientAuth() error {
+	cfg := n.config.Tor
+	if !cfg.Enabled || cfg.ClientAuthDir == "" {
+		return nil
+	}
+	keys, err := loadTorClientAuthKeys(n.resolveTorPath(cfg.ClientAuthDir))
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	controller, err := n.openTorController()
+	if err != nil {
+		return err
+	}
+	defer controller.Close()
+
+	for _, key := range keys {
+		if err := controller.onionClientAuthAdd(key); err != nil {
+			return fmt.Errorf("failed to add client auth for %s.onion: %w", key.ServiceID, err)
+		}
+	}
+	n.log.Info("Loaded Tor onion client authorization keys", "count", len(keys))
+	return nil
+}
@@ -0,0 +1,242 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"bufio"
+	"encoding/base64"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+const testServiceID = "vww6ybal4bd7szmgncyruucpgfkqahzddi37ktceo3ah7ngmcopnpyyd"
+
+// fakeTorControl is a minimal Tor control port that records all commands.
+type fakeTorControl struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	commands []string
+}
+
+func newFakeTorControl(t *testing.T) *fakeTorControl {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc := &fakeTorControl{ln: ln}
+	go fc.serve()
+	t.Cleanup(func() { ln.Close() })
+	return fc
+}
+
+func (fc *fakeTorControl) serve() {
+	for {
+		conn, err := fc.ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				cmd := strings.TrimRight(line, "\r\n")
+				fc.mu.Lock()
+				fc.commands = append(fc.commands, cmd)
+				fc.mu.Unlock()
+				if strings.HasPrefix(cmd, "ADD_ONION") {
+					conn.Write([]byte("250-ServiceID=" + testServiceID + "\r\n250-PrivateKey=ED25519-V3:KEY\r\n250 OK\r\n"))
+				} else if strings.HasPrefix(cmd, "ONION_CLIENT_AUTH_ADD") {
+					conn.Write([]byte("251 Client for onion existed and replaced\r\n"))
+				} else {
+					conn.Write([]byte("250 OK\r\n"))
+				}
+			}
+		}()
+	}
+}
+
+func (fc *fakeTorControl) sent(prefix string) []string {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	var out []string
+	for _, cmd := range fc.commands {
+		if strings.HasPrefix(cmd, prefix) {
+			out = append(out, cmd)
+		}
+	}
+	return out
+}
+
+func newTorAuthTestNode(t *testing.T, control string) (*Node, string) {
+	dir := t.TempDir()
+	cfg := &Config{
+		Tor: TorConfig{
+			Enabled:              true,
+			ControlAddress:       control,
+			CookiePath:           createTempTorCookie(t),
+			HiddenServiceDir:     filepath.Join(dir, "hs"),
+			AuthorizedClientsDir: filepath.Join(dir, "authorized_clients"),
+			ClientAuthDir:        filepath.Join(dir, "client_auth"),
+		},
+	}
+	return &Node{config: cfg, log: log.New()}, dir
+}
+
+func writeTorAuthFile(t *testing.T, dir, name, content string) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenerateTorClientAuth(t *testing.T) {
+	auth, authPrivate, err := GenerateTorClientAuth(testServiceID + ".onion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	writeTorAuthFile(t, filepath.Join(dir, "a"), "peer.auth", auth)
+	writeTorAuthFile(t, filepath.Join(dir, "b"), "peer.auth_private", authPrivate)
+
+	pubs, err := loadTorAuthorizedClients(filepath.Join(dir, "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pubs) != 1 || !strings.HasSuffix(auth, pubs[0]) {
+		t.Fatalf("wrong authorized clients: %v", pubs)
+	}
+	privs, err := loadTorClientAuthKeys(filepath.Join(dir, "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(privs) != 1 || privs[0].ServiceID != testServiceID || len(privs[0].PrivateKey) != 32 {
+		t.Fatalf("wrong client keys: %+v", privs)
+	}
+
+	if _, _, err := GenerateTorClientAuth("short.onion"); err == nil {
+		t.Fatal("expected error for invalid onion address")
+	}
+}
+
+func TestLoadTorAuthInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTorAuthFile(t, dir, "bad.auth", "descriptor:ed25519:AAAA")
+	if _, err := loadTorAuthorizedClients(dir); err == nil {
+		t.Fatal("expected error for wrong key type")
+	}
+	writeTorAuthFile(t, dir, "bad.auth_private", testServiceID+":descriptor:x25519:NOTBASE32!")
+	if _, err := loadTorClientAuthKeys(dir); err == nil {
+		t.Fatal("expected error for malformed key")
+	}
+	// Missing directories are not an error.
+	if keys, err := loadTorClientAuthKeys(filepath.Join(dir, "missing")); err != nil || len(keys) != 0 {
+		t.Fatalf("unexpected result for missing dir: %v %v", keys, err)
+	}
+}
+
+func TestLoadTorClientAuth(t *testing.T) {
+	control := newFakeTorControl(t)
+	n, _ := newTorAuthTestNode(t, control.ln.Addr().String())
+
+	_, authPrivate, err := GenerateTorClientAuth(testServiceID + ".onion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTorAuthFile(t, n.config.Tor.ClientAuthDir, "peer.auth_private", authPrivate)
+	keys, _ := loadTorClientAuthKeys(n.config.Tor.ClientAuthDir)
+
+	if err := n.loadTorClientAuth(); err != nil {
+		t.Fatal(err)
+	}
+	cmds := control.sent("ONION_CLIENT_AUTH_ADD")
+	want := "ONION_CLIENT_AUTH_ADD " + testServiceID + " x25519:" + base64.StdEncoding.EncodeToString(keys[0].PrivateKey)
+	if len(cmds) != 1 || cmds[0] != want {
+		t.Fatalf("wrong commands sent:\n got %v\nwant %s", cmds, want)
+	}
+}
+
+// TestTorReplacedReply checks that the 251 reply of ONION_CLIENT_AUTH_ADD does
+// not pass for other commands.
+func TestTorReplacedReply(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+	c := &torController{conn: local, reader: bufio.NewReader(local)}
+	defer c.Close()
+
+	go func() {
+		r := bufio.NewReader(remote)
+		for {
+			if _, err := r.ReadString('\n'); err != nil {
+				return
+			}
+			remote.Write([]byte("251 Replaced\r\n"))
+		}
+	}()
+	if err := c.onionClientAuthAdd(torClientAuth{ServiceID: testServiceID, PrivateKey: make([]byte, 32)}); err != nil {
+		t.Fatalf("ONION_CLIENT_AUTH_ADD failed: %v", err)
+	}
+	if err := c.protocolInfo(); err == nil {
+		t.Fatal("PROTOCOLINFO accepted reply 251")
+	}
+}
+
+func TestP2PHiddenServiceClientAuth(t *testing.T) {
+	control := newFakeTorControl(t)
+	n, _ := newTorAuthTestNode(t, control.ln.Addr().String())
+
+	auth, _, err := GenerateTorClientAuth(testServiceID + ".onion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTorAuthFile(t, n.config.Tor.AuthorizedClientsDir, "peer.auth", auth)
+
+	db, _ := enode.OpenDB("")
+	defer db.Close()
+	localNode := enode.NewLocalNode(db, testNodeKey)
+	if err := n.enableP2PTorHiddenService(localNode, 30303, "127.0.0.1:30304"); err != nil {
+		t.Fatal(err)
+	}
+	cmds := control.sent("ADD_ONION")
+	if len(cmds) != 1 {
+		t.Fatalf("expected one ADD_ONION, got %v", cmds)
+	}
+	pub := auth[strings.LastIndex(auth, ":")+1:]
+	if !strings.Contains(cmds[0], "Flags=Detach,V3Auth") || !strings.Contains(cmds[0], "ClientAuthV3="+pub) {
+		t.Fatalf("ADD_ONION lacks client auth: %s", cmds[0])
+	}
+	if !strings.Contains(cmds[0], " Port=30303,127.0.0.1:30304") {
+		t.Fatalf("ADD_ONION does not forward to the onion listener: %s", cmds[0])
+	}
+	// The service key must be persisted so authorized peers can pin the address.
+	key, err := os.ReadFile(filepath.Join(n.config.Tor.HiddenServiceDir, torP2PKeyFilename))
+	if err != nil || strings.TrimSpace(string(key)) != "ED25519-V3:KEY" {
+		t.Fatalf("service key not persisted: %q %v", key, err)
+	}
+}
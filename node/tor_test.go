@@ -109,7 +109,7 @@ func TestEnableP2PTorHiddenService_Success(t *testing.T) {
 
 	// Test with mock controller
 	p2pPort := 30303
-	err := node.enableP2PTorHiddenService(localNode, p2pPort)
+	err := node.enableP2PTorHiddenService(localNode, p2pPort, "127.0.0.1:30304")
 	if err != nil {
 		t.Fatalf("enableP2PTorHiddenService failed: %v", err)
 	}
@@ -131,7 +131,7 @@ func TestEnableP2PTorHiddenService_NilLocalNode(t *testing.T) {
 		log:    log.New(),
 	}
 
-	err := node.enableP2PTorHiddenService(nil, 30303)
+	err := node.enableP2PTorHiddenService(nil, 30303, "127.0.0.1:30304")
 	if err == nil {
 		t.Fatal("expected error for nil LocalNode, got nil")
 	}
@@ -158,7 +158,7 @@ func TestEnableP2PTorHiddenService_TorDisabled(t *testing.T) {
 	localNode := enode.NewLocalNode(db, testNodeKey)
 
 	// Should return nil when Tor is disabled (no-op)
-	err := node.enableP2PTorHiddenService(localNode, 30303)
+	err := node.enableP2PTorHiddenService(localNode, 30303, "127.0.0.1:30304")
 	if err != nil {
 		t.Fatalf("expected nil error when Tor disabled, got: %v", err)
 	}
@@ -182,7 +182,7 @@ func TestEnableP2PTorHiddenService_InvalidPort(t *testing.T) {
 	localNode := enode.NewLocalNode(db, testNodeKey)
 
 	// Test with invalid port (0)
-	err := node.enableP2PTorHiddenService(localNode, 0)
+	err := node.enableP2PTorHiddenService(localNode, 0, "127.0.0.1:30304")
 	if err == nil {
 		t.Fatal("expected error for port 0, got nil")
 	}
@@ -191,7 +191,7 @@ func TestEnableP2PTorHiddenService_InvalidPort(t *testing.T) {
 	}
 
 	// Test with negative port
-	err = node.enableP2PTorHiddenService(localNode, -1)
+	err = node.enableP2PTorHiddenService(localNode, -1, "127.0.0.1:30304")
 	if err == nil {
 		t.Fatal("expected error for negative port, got nil")
 	}
@@ -200,7 +200,7 @@ func TestEnableP2PTorHiddenService_InvalidPort(t *testing.T) {
 	}
 
 	// Test with port > 65535
-	err = node.enableP2PTorHiddenService(localNode, 99999)
+	err = node.enableP2PTorHiddenService(localNode, 99999, "127.0.0.1:30304")
 	if err == nil {
 		t.Fatal("expected error for port > 65535, got nil")
 	}
@@ -229,7 +229,7 @@ func TestEnableP2PTorHiddenService_ControllerFailure(t *testing.T) {
 	defer db.Close()
 	localNode := enode.NewLocalNode(db, testNodeKey)
 
-	err := node.enableP2PTorHiddenService(localNode, 30303)
+	err := node.enableP2PTorHiddenService(localNode, 30303, "127.0.0.1:30304")
 	if err == nil {
 		t.Fatal("expected error for invalid control address, got nil")
 	}
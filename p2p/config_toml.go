@@ -16,28 +16,42 @@ var _ = (*configMarshaling)(nil)
 // MarshalTOML marshals as TOML.
 func (c Config) MarshalTOML() (interface{}, error) {
 	type Config struct {
-		PrivateKey       *ecdsa.PrivateKey `toml:"-"`
-		MaxPeers         int
-		MaxPendingPeers  int `toml:",omitempty"`
-		DialRatio        int `toml:",omitempty"`
-		NoDiscovery      bool
-		DiscoveryV4      bool   `toml:",omitempty"`
-		DiscoveryV5      bool   `toml:",omitempty"`
-		Name             string `toml:"-"`
-		BootstrapNodes   []*enode.Node
-		BootstrapNodesV5 []*enode.Node `toml:",omitempty"`
-		StaticNodes      []*enode.Node
-		TrustedNodes     []*enode.Node
-		NetRestrict      *netutil.Netlist `toml:",omitempty"`
-		NodeDatabase     string           `toml:",omitempty"`
-		Protocols        []Protocol       `toml:"-" json:"-"`
-		ListenAddr       string
-		DiscAddr         string
-		NAT              nat.Interface `toml:",omitempty"`
-		Dialer           NodeDialer    `toml:"-"`
-		NoDial           bool          `toml:",omitempty"`
-		EnableMsgEvents  bool
-		Logger           log.Logger `toml:"-"`
+		PrivateKey         *ecdsa.PrivateKey `toml:"-"`
+		MaxPeers           int
+		MaxPendingPeers    int `toml:",omitempty"`
+		DialRatio          int `toml:",omitempty"`
+		NoDiscovery        bool
+		DiscoveryV4        bool   `toml:",omitempty"`
+		DiscoveryV5        bool   `toml:",omitempty"`
+		Name               string `toml:"-"`
+		BootstrapNodes     []*enode.Node
+		BootstrapNodesV5   []*enode.Node `toml:",omitempty"`
+		StaticNodes        []*enode.Node
+		TrustedNodes       []*enode.Node
+		NetRestrict        *netutil.Netlist `toml:",omitempty"`
+		NodeDatabase       string           `toml:",omitempty"`
+		Protocols          []Protocol       `toml:"-" json:"-"`
+		ListenAddr         string
+		DiscAddr           string
+		NAT                nat.Interface `toml:",omitempty"`
+		Dialer             NodeDialer    `toml:"-"`
+		NoDial             bool          `toml:",omitempty"`
+		EnableMsgEvents    bool
+		Logger             log.Logger `toml:"-"`
+		TorSOCKSProxy      string     `toml:",omitempty"`
+		PreferTor          bool       `toml:",omitempty"`
+		OnlyOnion          bool       `toml:",omitempty"`
+		OnionListenAddr    string     `toml:",omitempty"`
+		MinOnionPeers      int        `toml:",omitempty"`
+		MaxOnionPeers      int        `toml:",omitempty"`
+		MaxClearnetInbound int        `toml:",omitempty"`
+		ReservedOnionSlots int        `toml:",omitempty"`
+		ClearnetProxy      string     `toml:",omitempty"`
+		ClearnetViaTor     bool       `toml:",omitempty"`
+		I2PSAMAddress      string     `toml:",omitempty"`
+		I2PKeyFile         string     `toml:",omitempty"`
+		PreferI2P          bool       `toml:",omitempty"`
+		OnlyI2P            bool       `toml:",omitempty"`
 	}
 	var enc Config
 	enc.PrivateKey = c.PrivateKey
@@ -62,34 +76,62 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.NoDial = c.NoDial
 	enc.EnableMsgEvents = c.EnableMsgEvents
 	enc.Logger = c.Logger
+	enc.TorSOCKSProxy = c.TorSOCKSProxy
+	enc.PreferTor = c.PreferTor
+	enc.OnlyOnion = c.OnlyOnion
+	enc.OnionListenAddr = c.OnionListenAddr
+	enc.MinOnionPeers = c.MinOnionPeers
+	enc.MaxOnionPeers = c.MaxOnionPeers
+	enc.MaxClearnetInbound = c.MaxClearnetInbound
+	enc.ReservedOnionSlots = c.ReservedOnionSlots
+	enc.ClearnetProxy = c.ClearnetProxy
+	enc.ClearnetViaTor = c.ClearnetViaTor
+	enc.I2PSAMAddress = c.I2PSAMAddress
+	enc.I2PKeyFile = c.I2PKeyFile
+	enc.PreferI2P = c.PreferI2P
+	enc.OnlyI2P = c.OnlyI2P
 	return &enc, nil
 }
 
 // UnmarshalTOML unmarshals from TOML.
 func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	type Config struct {
-		PrivateKey       *ecdsa.PrivateKey `toml:"-"`
-		MaxPeers         *int
-		MaxPendingPeers  *int `toml:",omitempty"`
-		DialRatio        *int `toml:",omitempty"`
-		NoDiscovery      *bool
-		DiscoveryV4      *bool   `toml:",omitempty"`
-		DiscoveryV5      *bool   `toml:",omitempty"`
-		Name             *string `toml:"-"`
-		BootstrapNodes   []*enode.Node
-		BootstrapNodesV5 []*enode.Node `toml:",omitempty"`
-		StaticNodes      []*enode.Node
-		TrustedNodes     []*enode.Node
-		NetRestrict      *netutil.Netlist `toml:",omitempty"`
-		NodeDatabase     *string          `toml:",omitempty"`
-		Protocols        []Protocol       `toml:"-" json:"-"`
-		ListenAddr       *string
-		DiscAddr         *string
-		NAT              *configNAT `toml:",omitempty"`
-		Dialer           NodeDialer `toml:"-"`
-		NoDial           *bool      `toml:",omitempty"`
-		EnableMsgEvents  *bool
-		Logger           log.Logger `toml:"-"`
+		PrivateKey         *ecdsa.PrivateKey `toml:"-"`
+		MaxPeers           *int
+		MaxPendingPeers    *int `toml:",omitempty"`
+		DialRatio          *int `toml:",omitempty"`
+		NoDiscovery        *bool
+		DiscoveryV4        *bool   `toml:",omitempty"`
+		DiscoveryV5        *bool   `toml:",omitempty"`
+		Name               *string `toml:"-"`
+		BootstrapNodes     []*enode.Node
+		BootstrapNodesV5   []*enode.Node `toml:",omitempty"`
+		StaticNodes        []*enode.Node
+		TrustedNodes       []*enode.Node
+		NetRestrict        *netutil.Netlist `toml:",omitempty"`
+		NodeDatabase       *string          `toml:",omitempty"`
+		Protocols          []Protocol       `toml:"-" json:"-"`
+		ListenAddr         *string
+		DiscAddr           *string
+		NAT                *configNAT `toml:",omitempty"`
+		Dialer             NodeDialer `toml:"-"`
+		NoDial             *bool      `toml:",omitempty"`
+		EnableMsgEvents    *bool
+		Logger             log.Logger `toml:"-"`
+		TorSOCKSProxy      *string    `toml:",omitempty"`
+		PreferTor          *bool      `toml:",omitempty"`
+		OnlyOnion          *bool      `toml:",omitempty"`
+		OnionListenAddr    *string    `toml:",omitempty"`
+		MinOnionPeers      *int       `toml:",omitempty"`
+		MaxOnionPeers      *int       `toml:",omitempty"`
+		MaxClearnetInbound *int       `toml:",omitempty"`
+		ReservedOnionSlots *int       `toml:",omitempty"`
+		ClearnetProxy      *string    `toml:",omitempty"`
+		ClearnetViaTor     *bool      `toml:",omitempty"`
+		I2PSAMAddress      *string    `toml:",omitempty"`
+		I2PKeyFile         *string    `toml:",omitempty"`
+		PreferI2P          *bool      `toml:",omitempty"`
+		OnlyI2P            *bool      `toml:",omitempty"`
 	}
 	var dec Config
 	if err := unmarshal(&dec); err != nil {
@@ -161,5 +203,47 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.Logger != nil {
 		c.Logger = dec.Logger
 	}
+	if dec.TorSOCKSProxy != nil {
+		c.TorSOCKSProxy = *dec.TorSOCKSProxy
+	}
+	if dec.PreferTor != nil {
+		c.PreferTor = *dec.PreferTor
+	}
+	if dec.OnlyOnion != nil {
+		c.OnlyOnion = *dec.OnlyOnion
+	}
+	if dec.OnionListenAddr != nil {
+		c.OnionListenAddr = *dec.OnionListenAddr
+	}
+	if dec.MinOnionPeers != nil {
+		c.MinOnionPeers = *dec.MinOnionPeers
+	}
+	if dec.MaxOnionPeers != nil {
+		c.MaxOnionPeers = *dec.MaxOnionPeers
+	}
+	if dec.MaxClearnetInbound != nil {
+		c.MaxClearnetInbound = *dec.MaxClearnetInbound
+	}
+	if dec.ReservedOnionSlots != nil {
+		c.ReservedOnionSlots = *dec.ReservedOnionSlots
+	}
+	if dec.ClearnetProxy != nil {
+		c.ClearnetProxy = *dec.ClearnetProxy
+	}
+	if dec.ClearnetViaTor != nil {
+		c.ClearnetViaTor = *dec.ClearnetViaTor
+	}
+	if dec.I2PSAMAddress != nil {
+		c.I2PSAMAddress = *dec.I2PSAMAddress
+	}
+	if dec.I2PKeyFile != nil {
+		c.I2PKeyFile = *dec.I2PKeyFile
+	}
+	if dec.PreferI2P != nil {
+		c.PreferI2P = *dec.PreferI2P
+	}
+	if dec.OnlyI2P != nil {
+		c.OnlyI2P = *dec.OnlyI2P
+	}
 	return nil
 }
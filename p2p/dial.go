@@ -108,9 +108,10 @@ type dialScheduler struct {
 
 	// Everything below here belongs to loop and
 	// should only be accessed by code on the loop goroutine.
-	dialing   map[enode.ID]*dialTask // active tasks
-	peers     map[enode.ID]struct{}  // all connected peers
-	dialPeers int                    // current number of dialed peers
+	dialing    map[enode.ID]*dialTask // active tasks
+	peers      map[enode.ID]struct{}  // all connected peers
+	dialPeers  int                    // current number of dialed peers
+	onionPeers int                    // current number of peers connected through Tor
 
 	// The static map tracks all static dial tasks. The subset of usable static dial tasks
 	// (i.e. those passing checkDial) is kept in staticPool. The scheduler prefers
@@ -134,6 +135,8 @@ type dialConfig struct {
 	self           enode.ID         // our own ID
 	maxDialPeers   int              // maximum number of dialed peers
 	maxActiveDials int              // maximum number of active dials
+	minOnionPeers  int              // onion peer quota, see Config.MinOnionPeers
+	maxOnionPeers  int              // onion peer limit, see Config.MaxOnionPeers
 	netRestrict    *netutil.Netlist // IP netrestrict list, disabled if nil
 	resolver       nodeResolver
 	dialer         NodeDialer
@@ -247,6 +250,8 @@ loop:
 		case node := <-nodesCh:
 			if err := d.checkDial(node); err != nil {
 				d.log.Trace("Discarding dial candidate", "id", node.ID(), "ip", node.IPAddr(), "reason", err)
+			} else if err := d.checkQuota(node); err != nil {
+				d.log.Trace("Dropping dial candidate over quota", "id", node.ID(), "ip", node.IPAddr(), "reason", err)
 			} else {
 				d.startDial(newDialTask(node, dynDialedConn))
 			}
@@ -261,6 +266,9 @@ loop:
 			if c.is(dynDialedConn) || c.is(staticDialedConn) {
 				d.dialPeers++
 			}
+			if c.is(onionConn) {
+				d.onionPeers++
+			}
 			id := c.node.ID()
 			d.peers[id] = struct{}{}
 			// Remove from static pool because the node is now connected.
@@ -274,6 +282,9 @@ loop:
 			if c.is(dynDialedConn) || c.is(staticDialedConn) {
 				d.dialPeers--
 			}
+			if c.is(onionConn) {
+				d.onionPeers--
+			}
 			delete(d.peers, c.node.ID())
 			d.updateStaticPool(c.node.ID())
 
@@ -403,7 +414,7 @@ func (d *dialScheduler) checkDial(n *enode.Node) error {
 // startStaticDials starts n static dial tasks.
 func (d *dialScheduler) startStaticDials(n int) (started int) {
 	for started = 0; started < n && len(d.staticPool) > 0; started++ {
-		idx := d.rand.Intn(len(d.staticPool))
+		idx := d.pickStatic()
 		task := d.staticPool[idx]
 		d.startDial(task)
 		d.removeFromStaticPool(idx)
@@ -447,7 +458,8 @@ func isOnionAddress(hostname string) bool {
 
 // dnsResolveHostname updates the given node from its DNS hostname.
 // This is used to resolve static dial targets.
-// .onion addresses are skipped as they must be resolved through Tor SOCKS5, not DNS.
+// .onion and .i2p addresses are skipped as they must be resolved through Tor
+// SOCKS5 or the I2P router, not DNS.
 func (d *dialScheduler) dnsResolveHostname(n *enode.Node) (*enode.Node, error) {
 	hostname := n.Hostname()
 	if hostname == "" {
@@ -459,6 +471,11 @@ func (d *dialScheduler) dnsResolveHostname(n *enode.Node) (*enode.Node, error) {
 		d.log.Trace("Skipping DNS resolution for .onion address", "hostname", hostname)
 		return n, nil
 	}
+	// Likewise, I2P destinations are resolved by the router behind the SAM bridge
+	if isI2PAddress(hostname) {
+		d.log.Trace("Skipping DNS resolution for I2P address", "hostname", hostname)
+		return n, nil
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -563,10 +580,12 @@ func (t *dialTask) run(d *dialScheduler) {
 		}
 		// Try resolving node ID through the DHT if there is no IP address.
 		// Skip DHT resolution for .onion addresses - they don't need IP addresses
-		// and will be resolved by TorDialer through SOCKS5 proxy.
+		// and will be resolved by TorDialer through SOCKS5 proxy. The same holds
+		// for I2P destinations, which I2PDialer reaches through the SAM bridge.
 		dest := t.dest()
 		isOnion := dest.Hostname() != "" && isOnionAddress(dest.Hostname())
-		if !dest.IPAddr().IsValid() && !isOnion {
+		_, isI2P := peerI2PAddress(dest)
+		if !dest.IPAddr().IsValid() && !isOnion && !isI2P {
 			if !t.resolve(d) {
 				return // DHT resolve failed, skip dial.
 			}
@@ -635,7 +654,11 @@ func (t *dialTask) dial(d *dialScheduler, dest *enode.Node) error {
 		dialConnectionError.Mark(1)
 		return &dialError{err}
 	}
-	return d.setupFunc(newMeteredConn(fd), t.flags, dest)
+	flags := t.flags
+	if _, ok := fd.(*torConn); ok {
+		flags |= onionConn
+	}
+	return d.setupFunc(newMeteredConn(fd), flags, dest)
 }
 
 func (t *dialTask) String() string {
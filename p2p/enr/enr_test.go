@@ -542,3 +542,28 @@ func TestOnion3WithOtherENREntries(t *testing.T) {
 		t.Fatalf("failed to load UDP: %v", err)
 	}
 }
+
+// TestI2PRoundTrip tests that RLP encoding and decoding preserves the I2P address.
+func TestI2PRoundTrip(t *testing.T) {
+	addr := I2P("ukeu3k5oycgaauneqgtnvselmt4yemvoilkln7jpvamvfx7dnkdq.b32.i2p")
+	assert.Equal(t, "i2p", addr.ENRKey())
+
+	var r Record
+	r.Set(addr)
+	var addr2 I2P
+	require.NoError(t, r.Load(&addr2))
+	assert.Equal(t, addr, addr2)
+}
+
+// TestI2PInvalid tests that malformed I2P addresses are rejected on encoding.
+func TestI2PInvalid(t *testing.T) {
+	for _, addr := range []string{
+		"",
+		"ukeu3k5oycgaauneqgtnvselmt4yemvoilkln7jpvamvfx7dnkdq.i2p",
+		"ukeu3k5oycgaauneqgtnvselmt4yemvoilkln7jpvamvfx7dnkd1.b32.i2p",
+		"UKEU3K5OYCGAAUNEQGTNVSELMT4YEMVOILKLN7JPVAMVFX7DNKDQ.b32.i2p",
+	} {
+		_, err := rlp.EncodeToBytes(I2P(addr))
+		assert.Error(t, err, "address %q", addr)
+	}
+}
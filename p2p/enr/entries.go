@@ -152,6 +152,54 @@ func validateOnion3(addr string) error {
 	return nil
 }
 
+// I2P is the "i2p" key, which holds an I2P destination in its b32 form.
+// A valid b32 address consists of 52 base32 characters followed by ".b32.i2p".
+type I2P string
+
+func (v I2P) ENRKey() string { return "i2p" }
+
+// EncodeRLP implements rlp.Encoder.
+func (v I2P) EncodeRLP(w io.Writer) error {
+	if err := validateI2P(string(v)); err != nil {
+		return err
+	}
+	return rlp.Encode(w, string(v))
+}
+
+// DecodeRLP implements rlp.Decoder.
+func (v *I2P) DecodeRLP(s *rlp.Stream) error {
+	var addr string
+	if err := s.Decode(&addr); err != nil {
+		return err
+	}
+	if err := validateI2P(addr); err != nil {
+		return err
+	}
+	*v = I2P(addr)
+	return nil
+}
+
+// validateI2P checks if the given string is a valid I2P b32 address.
+// Valid format: 52 base32 characters (a-z, 2-7) + ".b32.i2p" suffix (60 chars total).
+func validateI2P(addr string) error {
+	const (
+		i2pSuffix = ".b32.i2p"
+		base32Len = 52 // sha256 hash of the destination, base32 without padding
+	)
+	if len(addr) != base32Len+len(i2pSuffix) {
+		return fmt.Errorf("invalid I2P address length: got %d, want %d", len(addr), base32Len+len(i2pSuffix))
+	}
+	if addr[base32Len:] != i2pSuffix {
+		return fmt.Errorf("invalid I2P address: missing .b32.i2p suffix")
+	}
+	for i, c := range addr[:base32Len] {
+		if !((c >= 'a' && c <= 'z') || (c >= '2' && c <= '7')) {
+			return fmt.Errorf("invalid I2P address: character at position %d is not valid base32 (got %q)", i, c)
+		}
+	}
+	return nil
+}
+
 // IP is either the "ip" or "ip6" key, depending on the value.
 // Use this value to encode IP addresses that can be either v4 or v6.
 // To load an address from a record use the IPv4 or IPv6 types.
@@ -0,0 +1,549 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+const (
+	samVersion        = "3.1"
+	samCommandTimeout = 30 * time.Second
+
+	// samSessionTimeout bounds SESSION CREATE, which waits for the router to
+	// build the session's tunnels.
+	samSessionTimeout = 3 * time.Minute
+
+	// i2pRetryInterval is the delay between attempts to (re)create the SAM
+	// session when the bridge is unreachable.
+	i2pRetryInterval = 30 * time.Second
+
+	// i2pAcceptRetryDelay is the delay before a failed STREAM ACCEPT is
+	// issued again on a live session.
+	i2pAcceptRetryDelay = time.Second
+)
+
+var (
+	errI2PSessionClosed = errors.New("i2p session closed")
+
+	// I2P uses a modified base64 alphabet for destinations.
+	i2pBase64 = base64.NewEncoding("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-~")
+	i2pBase32 = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+)
+
+// isI2PAddress checks if a hostname is an I2P address. Like .onion addresses,
+// these are resolved by the router and never through DNS.
+func isI2PAddress(hostname string) bool {
+	return strings.HasSuffix(strings.ToLower(hostname), ".i2p")
+}
+
+// i2pB32Address derives the b32 address of a base64 encoded destination.
+func i2pB32Address(dest string) (string, error) {
+	raw, err := i2pBase64.DecodeString(dest)
+	if err != nil {
+		return "", fmt.Errorf("invalid i2p destination: %w", err)
+	}
+	hash := sha256.Sum256(raw)
+	return i2pBase32.EncodeToString(hash[:]) + ".b32.i2p", nil
+}
+
+// i2pAddr is the net.Addr of a SAM stream.
+type i2pAddr string
+
+func (a i2pAddr) Network() string { return "i2p" }
+func (a i2pAddr) String() string  { return string(a) }
+
+// i2pConn is a SAM data socket after the stream handshake. Reads go through
+// the buffered reader used for the handshake, so no stream data is lost.
+type i2pConn struct {
+	net.Conn
+	r      *bufio.Reader
+	local  i2pAddr
+	remote i2pAddr
+}
+
+func (c *i2pConn) Read(b []byte) (int, error) { return c.r.Read(b) }
+func (c *i2pConn) LocalAddr() net.Addr        { return c.local }
+func (c *i2pConn) RemoteAddr() net.Addr       { return c.remote }
+
+// samConn is a connection to the SAM bridge that has completed HELLO.
+type samConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialSAM(ctx context.Context, addr string) (*samConn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &samConn{conn: conn, r: bufio.NewReader(conn)}
+	if _, err := c.command("HELLO VERSION MIN="+samVersion+" MAX="+samVersion, "HELLO", samCommandTimeout); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// command sends cmd and waits for a reply of the expected kind. It returns the
+// reply's key/value pairs or an error if RESULT is not OK.
+func (c *samConn) command(cmd, kind string, timeout time.Duration) (map[string]string, error) {
+	c.conn.SetDeadline(time.Now().Add(timeout))
+	defer c.conn.SetDeadline(time.Time{})
+
+	if _, err := fmt.Fprintf(c.conn, "%s\n", cmd); err != nil {
+		return nil, err
+	}
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	words, args := parseSAMReply(line)
+	if len(words) == 0 || words[0] != kind {
+		return nil, fmt.Errorf("unexpected SAM reply: %q", strings.TrimSpace(line))
+	}
+	if result, ok := args["RESULT"]; ok && result != "OK" {
+		if msg := args["MESSAGE"]; msg != "" {
+			return nil, fmt.Errorf("SAM %s failed: %s (%s)", kind, result, msg)
+		}
+		return nil, fmt.Errorf("SAM %s failed: %s", kind, result)
+	}
+	return args, nil
+}
+
+// parseSAMReply splits a SAM reply line into its leading words and KEY=VALUE
+// arguments. Values may be double-quoted.
+func parseSAMReply(line string) (words []string, args map[string]string) {
+	args = make(map[string]string)
+	line = strings.TrimSpace(line)
+	for len(line) > 0 {
+		var token string
+		if i := strings.IndexByte(line, '='); i >= 0 && !strings.ContainsRune(line[:i], ' ') {
+			key := line[:i]
+			rest := line[i+1:]
+			var value string
+			if strings.HasPrefix(rest, "\"") {
+				if end := strings.IndexByte(rest[1:], '"'); end >= 0 {
+					value, rest = rest[1:end+1], rest[end+2:]
+				} else {
+					value, rest = rest[1:], ""
+				}
+			} else if end := strings.IndexByte(rest, ' '); end >= 0 {
+				value, rest = rest[:end], rest[end:]
+			} else {
+				value, rest = rest, ""
+			}
+			args[key] = value
+			line = strings.TrimLeft(rest, " ")
+			continue
+		}
+		if end := strings.IndexByte(line, ' '); end >= 0 {
+			token, line = line[:end], strings.TrimLeft(line[end:], " ")
+		} else {
+			token, line = line, ""
+		}
+		words = append(words, token)
+	}
+	return words, args
+}
+
+// samSession is a SAM v3 stream session. The session exists for as long as
+// its control connection stays open.
+type samSession struct {
+	addr string // SAM bridge address
+	id   string
+	ctrl *samConn
+
+	privateKey string // base64 private destination, persisted to keep the address
+	b32        string // our b32 address
+
+	mu      sync.Mutex
+	accepts map[*samConn]struct{}
+	closed  bool
+}
+
+// newSAMSession creates a stream session on the SAM bridge at addr. If
+// privateKey is empty, the router generates a new transient destination.
+func newSAMSession(ctx context.Context, addr, privateKey string) (*samSession, error) {
+	ctrl, err := dialSAM(ctx, addr)
+	if err != nil {
+		return nil, fmt.Errorf("SAM bridge connect failed: %w", err)
+	}
+	var idBytes [8]byte
+	rand.Read(idBytes[:])
+	id := "geth-" + hex.EncodeToString(idBytes[:])
+
+	dest := privateKey
+	if dest == "" {
+		dest = "TRANSIENT SIGNATURE_TYPE=7" // Ed25519
+	}
+	reply, err := ctrl.command("SESSION CREATE STYLE=STREAM ID="+id+" DESTINATION="+dest, "SESSION", samSessionTimeout)
+	if err != nil {
+		ctrl.conn.Close()
+		return nil, err
+	}
+	if privateKey == "" {
+		privateKey = reply["DESTINATION"]
+	}
+	// The private destination starts with the public one, but its length
+	// depends on the certificate. Ask the router instead of parsing it.
+	lookup, err := ctrl.command("NAMING LOOKUP NAME=ME", "NAMING", samCommandTimeout)
+	if err != nil {
+		ctrl.conn.Close()
+		return nil, err
+	}
+	b32, err := i2pB32Address(lookup["VALUE"])
+	if err != nil {
+		ctrl.conn.Close()
+		return nil, err
+	}
+	s := &samSession{
+		addr:       addr,
+		id:         id,
+		ctrl:       ctrl,
+		privateKey: privateKey,
+		b32:        b32,
+		accepts:    make(map[*samConn]struct{}),
+	}
+	go s.watch()
+	return s, nil
+}
+
+// watch keeps the control connection of the session. The bridge destroys the
+// session when that connection closes, so the session is closed when reading
+// from it fails. Keepalive pings of the bridge are answered.
+func (s *samSession) watch() {
+	for {
+		line, err := s.ctrl.r.ReadString('\n')
+		if err != nil {
+			s.Close()
+			return
+		}
+		if words, _ := parseSAMReply(line); len(words) > 0 && words[0] == "PING" {
+			fmt.Fprintf(s.ctrl.conn, "PONG%s\n", strings.TrimPrefix(strings.TrimSpace(line), "PING"))
+		}
+	}
+}
+
+// Dial opens a stream to the given destination, which may be a b32 address or
+// a full base64 destination.
+func (s *samSession) Dial(ctx context.Context, dest string) (net.Conn, error) {
+	c, err := dialSAM(ctx, s.addr)
+	if err != nil {
+		return nil, err
+	}
+	timeout := samSessionTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	if _, err := c.command("STREAM CONNECT ID="+s.id+" DESTINATION="+dest+" SILENT=false", "STREAM", timeout); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	return &i2pConn{Conn: c.conn, r: c.r, local: i2pAddr(s.b32), remote: i2pAddr(dest)}, nil
+}
+
+// Accept waits for the next inbound stream.
+func (s *samSession) Accept() (net.Conn, error) {
+	c, err := dialSAM(context.Background(), s.addr)
+	if err != nil {
+		return nil, err
+	}
+	if !s.trackAccept(c, true) {
+		c.conn.Close()
+		return nil, errI2PSessionClosed
+	}
+	defer s.trackAccept(c, false)
+
+	if _, err := c.command("STREAM ACCEPT ID="+s.id+" SILENT=false", "STREAM", samCommandTimeout); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	// Once a peer connects, the bridge sends its destination on a line of
+	// its own, followed by the stream data.
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		c.conn.Close()
+		if s.isClosed() {
+			return nil, errI2PSessionClosed
+		}
+		return nil, err
+	}
+	words, _ := parseSAMReply(line)
+	if len(words) == 0 {
+		c.conn.Close()
+		return nil, errors.New("SAM accept: missing peer destination")
+	}
+	remote := words[0]
+	if b32, err := i2pB32Address(remote); err == nil {
+		remote = b32
+	}
+	return &i2pConn{Conn: c.conn, r: c.r, local: i2pAddr(s.b32), remote: i2pAddr(remote)}, nil
+}
+
+func (s *samSession) trackAccept(c *samConn, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if add {
+		if s.closed {
+			return false
+		}
+		s.accepts[c] = struct{}{}
+	} else {
+		delete(s.accepts, c)
+	}
+	return true
+}
+
+func (s *samSession) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// Close tears down the session and unblocks pending Accept calls.
+func (s *samSession) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	for c := range s.accepts {
+		c.conn.Close()
+	}
+	return s.ctrl.conn.Close()
+}
+
+// i2pTransport owns the SAM session shared by the I2P dialer and the inbound
+// listener. The session is created lazily and re-created if it fails.
+type i2pTransport struct {
+	samAddr string
+	keyFile string // persisted private destination, empty for transient
+
+	mu      sync.Mutex
+	session *samSession
+	closed  bool
+}
+
+func newI2PTransport(samAddr, keyFile string) *i2pTransport {
+	return &i2pTransport{samAddr: samAddr, keyFile: keyFile}
+}
+
+// getSession returns the current session, creating it if needed.
+func (t *i2pTransport) getSession(ctx context.Context) (*samSession, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, errI2PSessionClosed
+	}
+	if t.session != nil && !t.session.isClosed() {
+		return t.session, nil
+	}
+	var key string
+	if t.keyFile != "" {
+		data, err := os.ReadFile(t.keyFile)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("read i2p key failed: %w", err)
+		}
+		key = strings.TrimSpace(string(data))
+	}
+	session, err := newSAMSession(ctx, t.samAddr, key)
+	if err != nil {
+		return nil, err
+	}
+	if t.keyFile != "" && key == "" {
+		if err := os.WriteFile(t.keyFile, []byte(session.privateKey+"\n"), 0o600); err != nil {
+			session.Close()
+			return nil, fmt.Errorf("write i2p key failed: %w", err)
+		}
+	}
+	t.session = session
+	return session, nil
+}
+
+func (t *i2pTransport) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	if t.session != nil {
+		t.session.Close()
+		t.session = nil
+	}
+}
+
+// I2PDialer wraps a NodeDialer and routes connections to I2P destinations
+// through a SAM v3 bridge. Its policies mirror TorDialer:
+//
+//  1. Default mode: peers with only an I2P destination are dialed over I2P,
+//     everything else goes to the wrapped dialer.
+//  2. Prefer I2P mode (preferI2P=true): I2P is used whenever the peer has an
+//     I2P destination, falling back to the wrapped dialer on failure.
+//  3. I2P only mode (onlyI2P=true): peers without an I2P destination are
+//     rejected and there is no fallback.
+type I2PDialer struct {
+	transport *i2pTransport
+	fallback  NodeDialer // Dialer for clearnet and .onion peers
+	preferI2P bool
+	onlyI2P   bool
+}
+
+// Dial implements the NodeDialer interface.
+func (d *I2PDialer) Dial(ctx context.Context, dest *enode.Node) (net.Conn, error) {
+	if dest == nil {
+		return nil, fmt.Errorf("cannot dial nil peer")
+	}
+	dest32, hasI2P := peerI2PAddress(dest)
+
+	// Anything the fallback can reach: a TCP endpoint or an onion address.
+	_, hasTCP := dest.TCPEndpoint()
+	var onion enr.Onion3
+	hasOther := hasTCP || dest.Load(&onion) == nil || isOnionAddress(dest.Hostname())
+
+	if d.onlyI2P && !hasI2P {
+		return nil, fmt.Errorf("only-i2p mode: peer %s has no I2P destination", dest.ID())
+	}
+	if !hasI2P || (!d.preferI2P && !d.onlyI2P && hasOther) {
+		if d.fallback == nil {
+			return nil, fmt.Errorf("peer %s has no usable addresses", dest.ID())
+		}
+		return d.fallback.Dial(ctx, dest)
+	}
+
+	conn, err := d.dialI2P(ctx, dest32)
+	if err == nil {
+		return conn, nil
+	}
+	if d.onlyI2P {
+		return nil, fmt.Errorf("failed to connect via I2P in only-i2p mode: %w", err)
+	}
+	if hasOther && d.fallback != nil {
+		return d.fallback.Dial(ctx, dest)
+	}
+	return nil, fmt.Errorf("I2P connection failed and no fallback available: %w", err)
+}
+
+func (d *I2PDialer) dialI2P(ctx context.Context, dest string) (net.Conn, error) {
+	session, err := d.transport.getSession(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("i2p session unavailable: %w", err)
+	}
+	return session.Dial(ctx, dest)
+}
+
+// peerI2PAddress returns the I2P destination of a node from its ENR or, for
+// static nodes, from its hostname.
+func peerI2PAddress(n *enode.Node) (string, bool) {
+	var addr enr.I2P
+	if n.Load(&addr) == nil && addr != "" {
+		return string(addr), true
+	}
+	if hostname := n.Hostname(); isI2PAddress(hostname) {
+		return strings.ToLower(hostname), true
+	}
+	return "", false
+}
+
+// i2pListenLoop accepts inbound streams from the SAM bridge and hands them to
+// SetupConn. It also publishes our b32 address in the local ENR once the
+// session is up.
+func (srv *Server) i2pListenLoop(t *i2pTransport) {
+	defer srv.loopWG.Done()
+
+	tokens := defaultMaxPendingPeers
+	if srv.MaxPendingPeers > 0 {
+		tokens = srv.MaxPendingPeers
+	}
+	slots := make(chan struct{}, tokens)
+	for i := 0; i < tokens; i++ {
+		slots <- struct{}{}
+	}
+	defer func() {
+		for i := 0; i < cap(slots); i++ {
+			<-slots
+		}
+	}()
+
+	var published string
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), samSessionTimeout)
+		session, err := t.getSession(ctx)
+		cancel()
+		if err != nil {
+			if errors.Is(err, errI2PSessionClosed) {
+				return
+			}
+			srv.log.Warn("I2P session setup failed", "sam", t.samAddr, "err", err)
+			select {
+			case <-time.After(i2pRetryInterval):
+				continue
+			case <-srv.quit:
+				return
+			}
+		}
+		if published != session.b32 {
+			srv.localnode.Set(enr.I2P(session.b32))
+			published = session.b32
+			srv.log.Info("I2P session ready", "address", session.b32)
+		}
+
+		<-slots
+		fd, err := session.Accept()
+		if err != nil {
+			slots <- struct{}{}
+			select {
+			case <-srv.quit:
+				return
+			default:
+			}
+			srv.log.Debug("I2P accept failed", "err", err)
+			if errors.Is(err, errI2PSessionClosed) || session.isClosed() {
+				continue
+			}
+			// The session is shared with outbound dials and stays up, only
+			// the accepting stream is opened again.
+			select {
+			case <-time.After(i2pAcceptRetryDelay):
+			case <-srv.quit:
+				return
+			}
+			continue
+		}
+		srv.log.Trace("Accepted I2P connection", "addr", fd.RemoteAddr())
+		go func() {
+			srv.SetupConn(fd, inboundConn, nil)
+			slots <- struct{}{}
+		}()
+	}
+}
@@ -0,0 +1,429 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/internal/testlog"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+// fakeSAMBridge is a minimal SAM v3 bridge. The bridge side of outbound
+// streams is handed out on the connects channel, inbound streams are injected
+// with connectInbound.
+type fakeSAMBridge struct {
+	ln       net.Listener
+	pubDest  string
+	privDest string
+
+	connects chan samTestStream
+	accepts  chan net.Conn
+
+	mu          sync.Mutex
+	sessions    int
+	commands    []string
+	failAccepts int // STREAM ACCEPT commands to fail
+}
+
+type samTestStream struct {
+	dest string
+	conn net.Conn
+}
+
+func newFakeSAMBridge(t *testing.T) *fakeSAMBridge {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := make([]byte, 391)
+	for i := range pub {
+		pub[i] = byte(i)
+	}
+	b := &fakeSAMBridge{
+		ln:       ln,
+		pubDest:  i2pBase64.EncodeToString(pub),
+		privDest: i2pBase64.EncodeToString(append(pub, 0xff, 0xfe)),
+		connects: make(chan samTestStream, 8),
+		accepts:  make(chan net.Conn, 8),
+	}
+	go b.serve()
+	t.Cleanup(func() { ln.Close() })
+	return b
+}
+
+func (b *fakeSAMBridge) addr() string { return b.ln.Addr().String() }
+
+func (b *fakeSAMBridge) b32() string {
+	addr, _ := i2pB32Address(b.pubDest)
+	return addr
+}
+
+func (b *fakeSAMBridge) serve() {
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		go b.handle(conn)
+	}
+}
+
+func (b *fakeSAMBridge) handle(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			conn.Close()
+			return
+		}
+		cmd := strings.TrimSpace(line)
+		b.mu.Lock()
+		b.commands = append(b.commands, cmd)
+		b.mu.Unlock()
+		words, args := parseSAMReply(cmd)
+		switch {
+		case words[0] == "HELLO":
+			fmt.Fprintf(conn, "HELLO REPLY RESULT=OK VERSION=3.1\n")
+		case words[0] == "SESSION":
+			b.mu.Lock()
+			b.sessions++
+			b.mu.Unlock()
+			fmt.Fprintf(conn, "SESSION STATUS RESULT=OK DESTINATION=%s\n", b.privDest)
+		case words[0] == "NAMING":
+			fmt.Fprintf(conn, "NAMING REPLY RESULT=OK NAME=ME VALUE=%s\n", b.pubDest)
+		case words[0] == "STREAM" && words[1] == "CONNECT":
+			if strings.HasPrefix(args["DESTINATION"], "unreachable") {
+				fmt.Fprintf(conn, "STREAM STATUS RESULT=CANT_REACH_PEER MESSAGE=\"no route\"\n")
+				continue
+			}
+			fmt.Fprintf(conn, "STREAM STATUS RESULT=OK\n")
+			b.connects <- samTestStream{dest: args["DESTINATION"], conn: conn}
+			return
+		case words[0] == "STREAM" && words[1] == "ACCEPT":
+			b.mu.Lock()
+			fail := b.failAccepts > 0
+			if fail {
+				b.failAccepts--
+			}
+			b.mu.Unlock()
+			if fail {
+				fmt.Fprintf(conn, "STREAM STATUS RESULT=I2P_ERROR\n")
+				continue
+			}
+			fmt.Fprintf(conn, "STREAM STATUS RESULT=OK\n")
+			b.accepts <- conn
+			return
+		default:
+			fmt.Fprintf(conn, "%s REPLY RESULT=I2P_ERROR\n", words[0])
+		}
+	}
+}
+
+// connectInbound simulates a remote destination opening a stream to us. It
+// returns the remote end of the stream.
+func (b *fakeSAMBridge) connectInbound(t *testing.T, from string) net.Conn {
+	select {
+	case conn := <-b.accepts:
+		fmt.Fprintf(conn, "%s FROM_PORT=0 TO_PORT=0\n", from)
+		return conn
+	case <-time.After(5 * time.Second):
+		t.Fatal("no pending STREAM ACCEPT")
+		return nil
+	}
+}
+
+func (b *fakeSAMBridge) sessionCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sessions
+}
+
+// recordingDialer is a NodeDialer that records dials and never connects.
+type recordingDialer struct {
+	dialed []enode.ID
+}
+
+func (d *recordingDialer) Dial(_ context.Context, n *enode.Node) (net.Conn, error) {
+	d.dialed = append(d.dialed, n.ID())
+	return nil, errors.New("recording dialer")
+}
+
+const testI2PAddr = "ukeu3k5oycgaauneqgtnvselmt4yemvoilkln7jpvamvfx7dnkdq.b32.i2p"
+
+func i2pTestNode(t *testing.T, i2p string, withTCP bool) *enode.Node {
+	var r enr.Record
+	if i2p != "" {
+		r.Set(enr.I2P(i2p))
+	}
+	if withTCP {
+		r.Set(enr.IPv4{127, 0, 0, 1})
+		r.Set(enr.TCP(30303))
+	}
+	if err := enode.SignV4(&r, newkey()); err != nil {
+		t.Fatal(err)
+	}
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestI2PB32Address(t *testing.T) {
+	b32, err := i2pB32Address(i2pBase64.EncodeToString([]byte("destination")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b32) != 60 || !strings.HasSuffix(b32, ".b32.i2p") {
+		t.Fatalf("wrong b32 address %q", b32)
+	}
+	if _, err := i2pB32Address("not+valid/i2p"); err == nil {
+		t.Fatal("expected error for standard base64 alphabet")
+	}
+}
+
+func TestParseSAMReply(t *testing.T) {
+	words, args := parseSAMReply(`STREAM STATUS RESULT=CANT_REACH_PEER MESSAGE="no route to peer" ID=x` + "\n")
+	if len(words) != 2 || words[0] != "STREAM" || words[1] != "STATUS" {
+		t.Fatalf("wrong words: %v", words)
+	}
+	if args["RESULT"] != "CANT_REACH_PEER" || args["MESSAGE"] != "no route to peer" || args["ID"] != "x" {
+		t.Fatalf("wrong args: %v", args)
+	}
+}
+
+func TestI2PDialerPolicies(t *testing.T) {
+	sam := newFakeSAMBridge(t)
+	var (
+		i2pOnly = i2pTestNode(t, testI2PAddr, false)
+		both    = i2pTestNode(t, testI2PAddr, true)
+		tcpOnly = i2pTestNode(t, "", true)
+		tests   = []struct {
+			name               string
+			prefer, only       bool
+			node               *enode.Node
+			wantI2P, wantFallb bool
+			wantErr            bool
+		}{
+			{name: "default/i2p-only", node: i2pOnly, wantI2P: true},
+			{name: "default/both", node: both, wantFallb: true, wantErr: true},
+			{name: "default/tcp", node: tcpOnly, wantFallb: true, wantErr: true},
+			{name: "prefer/both", prefer: true, node: both, wantI2P: true},
+			{name: "prefer/tcp", prefer: true, node: tcpOnly, wantFallb: true, wantErr: true},
+			{name: "only/both", only: true, node: both, wantI2P: true},
+			{name: "only/tcp", only: true, node: tcpOnly, wantErr: true},
+		}
+	)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fallback := new(recordingDialer)
+			d := &I2PDialer{transport: newI2PTransport(sam.addr(), ""), fallback: fallback, preferI2P: tt.prefer, onlyI2P: tt.only}
+			defer d.transport.Close()
+
+			conn, err := d.Dial(context.Background(), tt.node)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("wrong error: %v", err)
+			}
+			if got := len(fallback.dialed) > 0; got != tt.wantFallb {
+				t.Fatalf("fallback used: %v, want %v", got, tt.wantFallb)
+			}
+			if tt.wantI2P {
+				stream := <-sam.connects
+				defer stream.conn.Close()
+				defer conn.Close()
+				if stream.dest != testI2PAddr {
+					t.Fatalf("wrong stream destination %q", stream.dest)
+				}
+				if conn.RemoteAddr().Network() != "i2p" || conn.LocalAddr().String() != sam.b32() {
+					t.Fatalf("wrong conn addresses: %v -> %v", conn.LocalAddr(), conn.RemoteAddr())
+				}
+				// Data written after the stream handshake must arrive unchanged.
+				go stream.conn.Write([]byte("ping"))
+				buf := make([]byte, 4)
+				if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+					t.Fatalf("wrong stream data %q: %v", buf, err)
+				}
+			}
+		})
+	}
+}
+
+func TestI2PDialerFallbackOnFailure(t *testing.T) {
+	sam := newFakeSAMBridge(t)
+	fallback := new(recordingDialer)
+	d := &I2PDialer{transport: newI2PTransport(sam.addr(), ""), fallback: fallback, preferI2P: true}
+	defer d.transport.Close()
+
+	// A peer the router cannot reach falls back to the wrapped dialer.
+	node := i2pTestNode(t, "", true).WithHostname("unreachable.i2p")
+	if _, err := d.Dial(context.Background(), node); err == nil {
+		t.Fatal("expected error")
+	}
+	if len(fallback.dialed) != 1 {
+		t.Fatalf("fallback not used after I2P failure")
+	}
+}
+
+func TestI2PKeyPersistence(t *testing.T) {
+	sam := newFakeSAMBridge(t)
+	keyFile := filepath.Join(t.TempDir(), "i2pkey")
+
+	tr := newI2PTransport(sam.addr(), keyFile)
+	if _, err := tr.getSession(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	tr.Close()
+	key, err := os.ReadFile(keyFile)
+	if err != nil || strings.TrimSpace(string(key)) != sam.privDest {
+		t.Fatalf("destination not persisted: %v", err)
+	}
+
+	tr = newI2PTransport(sam.addr(), keyFile)
+	defer tr.Close()
+	if _, err := tr.getSession(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	sam.mu.Lock()
+	defer sam.mu.Unlock()
+	var found bool
+	for _, cmd := range sam.commands {
+		if strings.HasPrefix(cmd, "SESSION CREATE") && strings.Contains(cmd, "DESTINATION="+sam.privDest) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("persisted destination not used for new session")
+	}
+}
+
+func TestServerI2PInbound(t *testing.T) {
+	sam := newFakeSAMBridge(t)
+	remid := &newkey().PublicKey
+	connected := make(chan *Peer, 1)
+	srv := &Server{
+		Config: Config{
+			Name:          "test",
+			MaxPeers:      10,
+			ListenAddr:    "127.0.0.1:0",
+			NoDiscovery:   true,
+			PrivateKey:    newkey(),
+			I2PSAMAddress: sam.addr(),
+			Logger:        testlog.Logger(t, log.LvlTrace),
+		},
+		newPeerHook: func(p *Peer) { connected <- p },
+		newTransport: func(fd net.Conn, dialDest *ecdsa.PublicKey) transport {
+			return newTestTransport(remid, fd, dialDest)
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+
+	remote := sam.connectInbound(t, testI2PAddr)
+	defer remote.Close()
+
+	select {
+	case p := <-connected:
+		if p.ID() != enode.PubkeyToIDV4(remid) {
+			t.Fatal("wrong peer id")
+		}
+		if p.RemoteAddr().String() != testI2PAddr || !p.Inbound() {
+			t.Fatalf("wrong peer: addr %v inbound %v", p.RemoteAddr(), p.Inbound())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("I2P peer not accepted")
+	}
+	var addr enr.I2P
+	if err := srv.Self().Load(&addr); err != nil || string(addr) != sam.b32() {
+		t.Fatalf("ENR lacks I2P address: %q %v", addr, err)
+	}
+	if sam.sessionCount() != 1 {
+		t.Fatalf("expected one SAM session, got %d", sam.sessionCount())
+	}
+}
+
+// TestServerI2PAcceptFailure checks that a failed STREAM ACCEPT does not
+// replace the SAM session that outbound dials share.
+func TestServerI2PAcceptFailure(t *testing.T) {
+	sam := newFakeSAMBridge(t)
+	sam.failAccepts = 1
+	connected := make(chan *Peer, 1)
+	srv := &Server{
+		Config: Config{
+			Name:          "test",
+			MaxPeers:      10,
+			ListenAddr:    "127.0.0.1:0",
+			NoDiscovery:   true,
+			PrivateKey:    newkey(),
+			I2PSAMAddress: sam.addr(),
+			Logger:        testlog.Logger(t, log.LvlTrace),
+		},
+		newPeerHook: func(p *Peer) { connected <- p },
+		newTransport: func(fd net.Conn, dialDest *ecdsa.PublicKey) transport {
+			return newTestTransport(&newkey().PublicKey, fd, dialDest)
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+
+	remote := sam.connectInbound(t, testI2PAddr)
+	defer remote.Close()
+	select {
+	case <-connected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("I2P peer not accepted after accept failure")
+	}
+	if sam.sessionCount() != 1 {
+		t.Fatalf("expected one SAM session, got %d", sam.sessionCount())
+	}
+}
+
+func TestConfigValidation_I2P(t *testing.T) {
+	tests := []struct {
+		cfg     Config
+		wantErr bool
+	}{
+		{Config{I2PSAMAddress: "127.0.0.1:7656"}, false},
+		{Config{I2PSAMAddress: "127.0.0.1:7656", OnlyI2P: true}, false},
+		{Config{OnlyI2P: true}, true},
+		{Config{I2PSAMAddress: "noport"}, true},
+		{Config{I2PSAMAddress: "127.0.0.1:7656", TorSOCKSProxy: "127.0.0.1:9050", OnlyI2P: true, OnlyOnion: true}, true},
+	}
+	for i, tt := range tests {
+		if err := tt.cfg.checkValid(); (err != nil) != tt.wantErr {
+			t.Errorf("test %d: wrong error %v", i, err)
+		}
+	}
+}
@@ -224,6 +224,19 @@ func (p *Peer) Inbound() bool {
 	return p.rw.is(inboundConn)
 }
 
+// Transport returns the network the connection was established over: "onion"
+// for Tor, "i2p" or "clearnet".
+func (p *Peer) Transport() string {
+	switch {
+	case p.rw.is(onionConn):
+		return "onion"
+	case !p.rw.isClearnet():
+		return "i2p"
+	default:
+		return "clearnet"
+	}
+}
+
 // Trusted returns true if the peer is configured as trusted.
 // Trusted peers are accepted in above the MaxInboundConns limit.
 // The peer can be either inbound or dialed.
@@ -553,6 +566,7 @@ type PeerInfo struct {
 		Inbound       bool   `json:"inbound"`
 		Trusted       bool   `json:"trusted"`
 		Static        bool   `json:"static"`
+		Transport     string `json:"transport"` // onion, i2p or clearnet
 	} `json:"network"`
 	Protocols map[string]interface{} `json:"protocols"` // Sub-protocol specific metadata fields
 }
@@ -580,6 +594,7 @@ func (p *Peer) Info() *PeerInfo {
 	info.Network.Inbound = p.rw.is(inboundConn)
 	info.Network.Trusted = p.rw.is(trustedConn)
 	info.Network.Static = p.rw.is(staticDialedConn)
+	info.Network.Transport = p.Transport()
 
 	// Gather all the running protocol infos
 	for _, proto := range p.running {
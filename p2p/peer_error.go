@@ -52,6 +52,13 @@ func (pe *peerError) Error() string {
 	return pe.message
 }
 
+// IsDecodeError reports whether err was returned by Msg.Decode for a message
+// that could not be decoded.
+func IsDecodeError(err error) bool {
+	var pe *peerError
+	return errors.As(err, &pe) && pe.code == errInvalidMsg
+}
+
 var errProtocolReturned = errors.New("protocol returned")
 
 type DiscReason uint8
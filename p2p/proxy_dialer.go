@@ -0,0 +1,166 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"golang.org/x/net/proxy"
+)
+
+// ProxyDialer is a NodeDialer that connects to clearnet peers through an
+// outbound proxy. It is used as the clearnet dialer of TorDialer and I2PDialer,
+// so onion and I2P destinations are unaffected.
+//
+// Supported proxy URLs:
+//
+//	socks5://[user:password@]host:port   SOCKS5, optionally with username/password auth
+//	http://[user:password@]host:port     HTTP CONNECT, optionally with basic auth
+type ProxyDialer struct {
+	proxy proxy.ContextDialer
+	addr  string // proxy address, for error messages
+}
+
+// NewProxyDialer creates a dialer routing all connections through the proxy
+// at the given URL.
+func NewProxyDialer(rawurl string) (*ProxyDialer, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	if u.Host == "" || u.Port() == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: host:port required", rawurl)
+	}
+	forward := &net.Dialer{Timeout: defaultDialTimeout}
+
+	var d proxy.ContextDialer
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if u.User != nil {
+			password, _ := u.User.Password()
+			auth = &proxy.Auth{User: u.User.Username(), Password: password}
+		}
+		socks, err := proxy.SOCKS5("tcp", u.Host, auth, forward)
+		if err != nil {
+			return nil, err
+		}
+		d = socks.(proxy.ContextDialer)
+	case "http":
+		hd := &httpConnectDialer{addr: u.Host, forward: forward}
+		if u.User != nil {
+			password, _ := u.User.Password()
+			creds := u.User.Username() + ":" + password
+			hd.auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(creds))
+		}
+		d = hd
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	return &ProxyDialer{proxy: d, addr: u.Host}, nil
+}
+
+// newTorExitDialer creates a dialer sending clearnet connections through the
+// Tor SOCKS5 port, i.e. as exit traffic.
+func newTorExitDialer(socksAddr string) *ProxyDialer {
+	forward := &net.Dialer{Timeout: defaultDialTimeout}
+	socks, _ := proxy.SOCKS5("tcp", socksAddr, nil, forward) // never fails without auth
+	return &ProxyDialer{proxy: socks.(proxy.ContextDialer), addr: socksAddr}
+}
+
+// Dial implements NodeDialer.
+func (d *ProxyDialer) Dial(ctx context.Context, dest *enode.Node) (net.Conn, error) {
+	addr, ok := dest.TCPEndpoint()
+	if !ok {
+		return nil, errNoPort
+	}
+	conn, err := d.proxy.DialContext(ctx, "tcp", addr.String())
+	if err != nil {
+		return nil, fmt.Errorf("proxy %s: %w", d.addr, err)
+	}
+	return conn, nil
+}
+
+// httpConnectDialer tunnels connections through an HTTP proxy using CONNECT.
+type httpConnectDialer struct {
+	addr    string
+	auth    string // Proxy-Authorization header value, if any
+	forward *net.Dialer
+}
+
+func (d *httpConnectDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := d.forward.DialContext(ctx, "tcp", d.addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(defaultDialTimeout))
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if d.auth != "" {
+		req.Header.Set("Proxy-Authorization", d.auth)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, errors.New("CONNECT failed: " + resp.Status)
+	}
+	conn.SetDeadline(time.Time{})
+
+	// The proxy may have sent tunnelled data along with the response.
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a net.Conn whose reads are served from a buffered reader.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
@@ -0,0 +1,159 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+// Transport-aware slot quotas.
+//
+// Connections are classified by transport: onion peers are dialed through the
+// Tor SOCKS5 proxy or reach us through our hidden service, which the Tor
+// daemon forwards to the onion listener. I2P peers are neither onion nor
+// clearnet. Everything else is clearnet.
+//
+// Trusted peers are exempt from all quotas, static onion peers may use the
+// reserved onion slots and are exempt from MaxOnionPeers.
+
+var (
+	errOnionQuotaFull  = errors.New("onion peer limit reached")
+	errOnionQuotaShort = errors.New("remaining slots reserved for onion peers")
+)
+
+// hasOnionAddress reports whether n can be reached as a Tor onion service.
+func hasOnionAddress(n *enode.Node) bool {
+	var onion enr.Onion3
+	if n.Load(&onion) == nil && onion != "" {
+		return true
+	}
+	return n.Hostname() != "" && isOnionAddress(n.Hostname())
+}
+
+// isClearnet reports whether the connection uses neither Tor nor I2P.
+func (c *conn) isClearnet() bool {
+	if c.is(onionConn) {
+		return false
+	}
+	_, isI2P := c.fd.RemoteAddr().(i2pAddr)
+	return !isI2P
+}
+
+// hasSlotQuotas reports whether any transport quota is configured.
+func (cfg *Config) hasSlotQuotas() bool {
+	return cfg.MinOnionPeers > 0 || cfg.MaxOnionPeers > 0 || cfg.MaxClearnetInbound > 0 || cfg.ReservedOnionSlots > 0
+}
+
+// checkSlotQuotas checks whether the non-trusted connection c may be added to
+// the peer set without violating the transport quotas.
+func (srv *Server) checkSlotQuotas(peers map[enode.ID]*Peer, c *conn) error {
+	if !srv.hasSlotQuotas() {
+		return nil
+	}
+	var onion, reservedOnion, clearnetInbound int
+	for _, p := range peers {
+		switch {
+		case p.rw.is(onionConn):
+			onion++
+			if p.rw.is(staticDialedConn | trustedConn) {
+				reservedOnion++
+			}
+		case p.rw.is(inboundConn) && p.rw.isClearnet():
+			clearnetInbound++
+		}
+	}
+
+	isOnion := c.is(onionConn)
+	switch {
+	case isOnion && c.is(staticDialedConn):
+		return nil
+	case isOnion && srv.MaxOnionPeers > 0 && onion >= srv.MaxOnionPeers:
+		return DiscTooManyPeers
+	case c.is(inboundConn) && c.isClearnet() && srv.MaxClearnetInbound > 0 && clearnetInbound >= srv.MaxClearnetInbound:
+		return DiscTooManyPeers
+	}
+	// Keep the reserved slots free for static and trusted onion nodes. Clearnet
+	// peers additionally have to leave room for the missing onion peers.
+	reserve := max(srv.ReservedOnionSlots-reservedOnion, 0)
+	if !isOnion {
+		reserve = max(reserve, srv.MinOnionPeers-onion)
+	}
+	if free := srv.MaxPeers - len(peers) - 1; free < reserve {
+		return DiscTooManyPeers
+	}
+	return nil
+}
+
+// onionDeficit returns the number of onion peers missing from the
+// minimum quota, counting dials in progress.
+func (d *dialScheduler) onionDeficit() int {
+	if d.minOnionPeers == 0 {
+		return 0
+	}
+	return d.minOnionPeers - d.onionPeers - d.onionDials()
+}
+
+// onionDials returns the number of active dials to onion nodes.
+func (d *dialScheduler) onionDials() int {
+	n := 0
+	for _, t := range d.dialing {
+		if hasOnionAddress(t.dest()) {
+			n++
+		}
+	}
+	return n
+}
+
+// checkQuota returns an error if the dynamic dial candidate n does not fit
+// the transport quotas. While onion peers are missing, clearnet candidates are
+// only dialed if there are more free dial slots than missing onion peers.
+// Candidates that do not fit are dropped, discovery offers them again later.
+func (d *dialScheduler) checkQuota(n *enode.Node) error {
+	if d.minOnionPeers == 0 && d.maxOnionPeers == 0 {
+		return nil
+	}
+	if hasOnionAddress(n) {
+		if d.maxOnionPeers > 0 && d.onionPeers+d.onionDials() >= d.maxOnionPeers {
+			return errOnionQuotaFull
+		}
+		return nil
+	}
+	if deficit := d.onionDeficit(); deficit > 0 && d.maxDialPeers-d.dialPeers-len(d.dialing) <= deficit {
+		return errOnionQuotaShort
+	}
+	return nil
+}
+
+// pickStatic returns the index of the next static dial task to launch. Onion
+// nodes go first while the onion quota is short.
+func (d *dialScheduler) pickStatic() int {
+	if d.onionDeficit() > 0 {
+		var onion []int
+		for i, t := range d.staticPool {
+			if hasOnionAddress(t.dest()) {
+				onion = append(onion, i)
+			}
+		}
+		if len(onion) > 0 {
+			return onion[d.rand.Intn(len(onion))]
+		}
+	}
+	return d.rand.Intn(len(d.staticPool))
+}
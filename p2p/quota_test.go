@@ -0,0 +1,247 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"crypto/ecdsa"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/internal/testlog"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// quotaTestConn creates a connection with the given flags to a random node.
+func quotaTestConn(t *testing.T, flags connFlag) *conn {
+	fd, other := net.Pipe()
+	t.Cleanup(func() { fd.Close(); other.Close() })
+	return &conn{fd: fd, flags: flags, node: createTestNode(t, "", nil, 0)}
+}
+
+func quotaTestPeers(t *testing.T, flags ...connFlag) map[enode.ID]*Peer {
+	peers := make(map[enode.ID]*Peer)
+	for _, f := range flags {
+		c := quotaTestConn(t, f)
+		peers[c.node.ID()] = &Peer{rw: c}
+	}
+	return peers
+}
+
+func TestCheckSlotQuotas(t *testing.T) {
+	const (
+		dynOnion    = dynDialedConn | onionConn
+		staticOnion = staticDialedConn | onionConn
+		inOnion     = inboundConn | onionConn
+	)
+	tests := []struct {
+		name  string
+		cfg   Config
+		peers []connFlag
+		conn  connFlag
+		want  error
+	}{
+		{
+			name:  "no quotas",
+			cfg:   Config{MaxPeers: 3},
+			peers: []connFlag{dynDialedConn, dynDialedConn},
+			conn:  inboundConn,
+		},
+		{
+			name:  "clearnet leaves room for min onion peers",
+			cfg:   Config{MaxPeers: 4, MinOnionPeers: 2},
+			peers: []connFlag{dynDialedConn, dynOnion},
+			conn:  dynDialedConn,
+		},
+		{
+			name:  "clearnet would take a slot kept for onion peers",
+			cfg:   Config{MaxPeers: 4, MinOnionPeers: 2},
+			peers: []connFlag{dynDialedConn, dynDialedConn},
+			conn:  inboundConn,
+			want:  DiscTooManyPeers,
+		},
+		{
+			name:  "onion peer fills the min quota",
+			cfg:   Config{MaxPeers: 4, MinOnionPeers: 2},
+			peers: []connFlag{dynDialedConn, dynDialedConn, dynOnion},
+			conn:  inOnion,
+		},
+		{
+			name:  "max onion peers reached",
+			cfg:   Config{MaxPeers: 10, MaxOnionPeers: 2},
+			peers: []connFlag{dynOnion, inOnion},
+			conn:  dynOnion,
+			want:  DiscTooManyPeers,
+		},
+		{
+			name:  "static onion nodes exempt from max onion peers",
+			cfg:   Config{MaxPeers: 10, MaxOnionPeers: 2},
+			peers: []connFlag{dynOnion, inOnion},
+			conn:  staticOnion,
+		},
+		{
+			name:  "max clearnet inbound reached",
+			cfg:   Config{MaxPeers: 10, MaxClearnetInbound: 1},
+			peers: []connFlag{inboundConn, inOnion},
+			conn:  inboundConn,
+			want:  DiscTooManyPeers,
+		},
+		{
+			name:  "onion inbound not limited by clearnet quota",
+			cfg:   Config{MaxPeers: 10, MaxClearnetInbound: 1},
+			peers: []connFlag{inboundConn},
+			conn:  inOnion,
+		},
+		{
+			name:  "reserved slot not available to dynamic onion peer",
+			cfg:   Config{MaxPeers: 3, ReservedOnionSlots: 1},
+			peers: []connFlag{dynDialedConn, dynOnion},
+			conn:  dynOnion,
+			want:  DiscTooManyPeers,
+		},
+		{
+			name:  "reserved slot taken by static onion node",
+			cfg:   Config{MaxPeers: 3, ReservedOnionSlots: 1},
+			peers: []connFlag{dynDialedConn, dynOnion},
+			conn:  staticOnion,
+		},
+		{
+			name:  "reserved slot already used",
+			cfg:   Config{MaxPeers: 3, ReservedOnionSlots: 1},
+			peers: []connFlag{staticOnion, dynOnion},
+			conn:  dynDialedConn,
+		},
+	}
+	for _, tt := range tests {
+		srv := &Server{Config: tt.cfg}
+		err := srv.checkSlotQuotas(quotaTestPeers(t, tt.peers...), quotaTestConn(t, tt.conn))
+		if err != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestDialSchedulerQuota(t *testing.T) {
+	onion := createTestNode(t, generateValidOnion3(), nil, 30303)
+	clearnet := createTestNode(t, "", net.IP{1, 2, 3, 4}, 30303)
+
+	d := &dialScheduler{
+		dialConfig: dialConfig{maxDialPeers: 4, minOnionPeers: 2, maxOnionPeers: 3, rand: rand.New(rand.NewSource(1))},
+		dialing:    make(map[enode.ID]*dialTask),
+	}
+	if err := d.checkQuota(clearnet); err != nil {
+		t.Fatalf("clearnet candidate rejected with free slots: %v", err)
+	}
+	// Only two dial slots left, both are needed for onion peers.
+	d.dialPeers = 2
+	if err := d.checkQuota(clearnet); err != errOnionQuotaShort {
+		t.Fatalf("clearnet candidate not deferred: %v", err)
+	}
+	if err := d.checkQuota(onion); err != nil {
+		t.Fatalf("onion candidate rejected: %v", err)
+	}
+	// Static onion nodes are dialed first while the quota is short.
+	d.staticPool = []*dialTask{
+		newDialTask(clearnet, staticDialedConn),
+		newDialTask(onion, staticDialedConn),
+		newDialTask(createTestNode(t, "", net.IP{1, 2, 3, 5}, 30303), staticDialedConn),
+	}
+	for i := 0; i < 10; i++ {
+		if idx := d.pickStatic(); idx != 1 {
+			t.Fatalf("picked static clearnet node %d while onion quota short", idx)
+		}
+	}
+	// Quota satisfied: clearnet allowed again, onion limited by the maximum.
+	d.onionPeers = 3
+	if err := d.checkQuota(clearnet); err != nil {
+		t.Fatalf("clearnet candidate rejected after quota filled: %v", err)
+	}
+	if err := d.checkQuota(onion); err != errOnionQuotaFull {
+		t.Fatalf("onion candidate not rejected at maximum: %v", err)
+	}
+}
+
+// TestOnionListener checks that only connections accepted on the onion listener
+// are onion peers, local connections to the main listener are not.
+func TestOnionListener(t *testing.T) {
+	connected := make(chan *Peer, 2)
+	srv := &Server{
+		Config: Config{
+			Name:            "test",
+			MaxPeers:        10,
+			ListenAddr:      "127.0.0.1:0",
+			OnionListenAddr: "127.0.0.1:0",
+			NoDiscovery:     true,
+			PrivateKey:      newkey(),
+			Logger:          testlog.Logger(t, log.LvlTrace),
+		},
+		newPeerHook: func(p *Peer) { connected <- p },
+		newTransport: func(fd net.Conn, dialDest *ecdsa.PublicKey) transport {
+			return newTestTransport(&newkey().PublicKey, fd, dialDest)
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+
+	for _, tt := range []struct {
+		addr  string
+		onion bool
+	}{
+		{srv.ListenAddr, false},
+		{srv.OnionListenAddr, true},
+	} {
+		conn, err := net.DialTimeout("tcp", tt.addr, 5*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		select {
+		case p := <-connected:
+			if got := p.rw.is(onionConn); got != tt.onion {
+				t.Errorf("connection to %s: onion %v, want %v", tt.addr, got, tt.onion)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("connection to %s not accepted", tt.addr)
+		}
+	}
+}
+
+func TestConfigQuotaValidation(t *testing.T) {
+	tor := "127.0.0.1:9050"
+	tests := []struct {
+		cfg     Config
+		wantErr bool
+	}{
+		{Config{MaxPeers: 10, MaxClearnetInbound: 5}, false},
+		{Config{MaxPeers: 10, MinOnionPeers: 3}, true},
+		{Config{MaxPeers: 10, MinOnionPeers: 3, TorSOCKSProxy: tor}, false},
+		{Config{MaxPeers: 10, MinOnionPeers: 4, MaxOnionPeers: 3, TorSOCKSProxy: tor}, true},
+		{Config{MaxPeers: 10, ReservedOnionSlots: 11, TorSOCKSProxy: tor}, true},
+		{Config{MaxPeers: 10, MaxClearnetInbound: -1}, true},
+		{Config{MaxPeers: 10, OnionListenAddr: "127.0.0.1:0"}, false},
+		{Config{MaxPeers: 10, OnionListenAddr: "0.0.0.0:30304"}, true},
+	}
+	for i, tt := range tests {
+		if err := tt.cfg.checkValid(); (err != nil) != tt.wantErr {
+			t.Errorf("test %d: unexpected result %v", i, err)
+		}
+	}
+}
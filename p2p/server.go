@@ -84,11 +84,12 @@ type Server struct {
 	lock    sync.Mutex // protects running
 	running bool
 
-	listener     net.Listener
-	ourHandshake *protoHandshake
-	loopWG       sync.WaitGroup // loop, listenLoop
-	peerFeed     event.Feed
-	log          log.Logger
+	listener      net.Listener
+	onionListener net.Listener // inbound connections of the hidden service
+	ourHandshake  *protoHandshake
+	loopWG        sync.WaitGroup // loop, listenLoop
+	peerFeed      event.Feed
+	log           log.Logger
 
 	nodedb    *enode.DB
 	localnode *enode.LocalNode
@@ -96,6 +97,7 @@ type Server struct {
 	discv5    *discover.UDPv5
 	discmix   *enode.FairMix
 	dialsched *dialScheduler
+	i2p       *i2pTransport
 
 	// This is read by the NAT port mapping loop.
 	portMappingRegister chan *portMapping
@@ -129,6 +131,7 @@ const (
 	staticDialedConn
 	inboundConn
 	trustedConn
+	onionConn // established through Tor, see slot quotas in Config
 )
 
 // conn wraps a network connection with information gathered
@@ -180,6 +183,9 @@ func (f connFlag) String() string {
 	if f&inboundConn != 0 {
 		s += "-inbound"
 	}
+	if f&onionConn != 0 {
+		s += "-onion"
+	}
 	if s != "" {
 		s = s[1:]
 	}
@@ -222,6 +228,13 @@ func (srv *Server) Peers() []*Peer {
 	return ps
 }
 
+// Running reports whether the server is started and has not been stopped.
+func (srv *Server) Running() bool {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	return srv.running
+}
+
 // PeerCount returns the number of connected peers.
 func (srv *Server) PeerCount() int {
 	var count int
@@ -325,7 +338,14 @@ func (srv *Server) Stop() {
 		// this unblocks listener Accept
 		srv.listener.Close()
 	}
+	if srv.onionListener != nil {
+		srv.onionListener.Close()
+	}
 	close(srv.quit)
+	if srv.i2p != nil {
+		// this unblocks pending SAM accepts
+		srv.i2p.Close()
+	}
 	srv.lock.Unlock()
 	srv.loopWG.Wait()
 }
@@ -413,6 +433,13 @@ func (srv *Server) Start() (err error) {
 	if err := srv.setupDiscovery(); err != nil {
 		return err
 	}
+	if srv.I2PSAMAddress != "" {
+		srv.i2p = newI2PTransport(srv.I2PSAMAddress, srv.I2PKeyFile)
+		if srv.ListenAddr != "" {
+			srv.loopWG.Add(1)
+			go srv.i2pListenLoop(srv.i2p)
+		}
+	}
 	srv.setupDialScheduler()
 
 	srv.loopWG.Add(1)
@@ -528,6 +555,8 @@ func (srv *Server) setupDialScheduler() {
 		self:           srv.localnode.ID(),
 		maxDialPeers:   srv.MaxDialedConns(),
 		maxActiveDials: srv.MaxPendingPeers,
+		minOnionPeers:  srv.MinOnionPeers,
+		maxOnionPeers:  srv.MaxOnionPeers,
 		log:            srv.Logger,
 		netRestrict:    srv.NetRestrict,
 		dialer:         srv.Dialer,
@@ -537,8 +566,17 @@ func (srv *Server) setupDialScheduler() {
 		config.resolver = srv.discv4
 	}
 	if config.dialer == nil {
-		// Create base TCP dialer
-		baseDial := tcpDialer{&net.Dialer{Timeout: defaultDialTimeout}}
+		// Create base dialer for clearnet peers, optionally behind a proxy
+		var baseDial NodeDialer = tcpDialer{&net.Dialer{Timeout: defaultDialTimeout}}
+		switch {
+		case srv.Config.ClearnetViaTor:
+			baseDial = newTorExitDialer(srv.Config.TorSOCKSProxy)
+		case srv.Config.ClearnetProxy != "":
+			// The URL was validated in checkValid.
+			if pd, err := NewProxyDialer(srv.Config.ClearnetProxy); err == nil {
+				baseDial = pd
+			}
+		}
 
 		// Inject TorDialer if configured
 		if srv.Config.TorSOCKSProxy != "" {
@@ -551,6 +589,16 @@ func (srv *Server) setupDialScheduler() {
 		} else {
 			config.dialer = baseDial
 		}
+
+		// Route I2P destinations through the SAM bridge
+		if srv.i2p != nil {
+			config.dialer = &I2PDialer{
+				transport: srv.i2p,
+				fallback:  config.dialer,
+				preferI2P: srv.Config.PreferI2P,
+				onlyI2P:   srv.Config.OnlyI2P,
+			}
+		}
 	}
 	srv.dialsched = newDialScheduler(config, srv.discmix, srv.SetupConn)
 	for _, n := range srv.StaticNodes {
@@ -600,7 +648,20 @@ func (srv *Server) setupListening() error {
 	}
 
 	srv.loopWG.Add(1)
-	go srv.listenLoop()
+	go srv.listenLoop(listener, inboundConn)
+
+	// Connections forwarded by the hidden service get a listener of their own,
+	// which marks them as onion peers.
+	if srv.OnionListenAddr != "" {
+		onion, err := srv.listenFunc("tcp", srv.OnionListenAddr)
+		if err != nil {
+			return err
+		}
+		srv.onionListener = onion
+		srv.OnionListenAddr = onion.Addr().String()
+		srv.loopWG.Add(1)
+		go srv.listenLoop(onion, inboundConn|onionConn)
+	}
 	return nil
 }
 
@@ -773,6 +834,8 @@ func (srv *Server) postHandshakeChecks(peers map[enode.ID]*Peer, inboundCount in
 		return DiscAlreadyConnected
 	case c.node.ID() == srv.localnode.ID():
 		return DiscSelf
+	case !c.is(trustedConn):
+		return srv.checkSlotQuotas(peers, c)
 	default:
 		return nil
 	}
@@ -789,9 +852,9 @@ func (srv *Server) addPeerChecks(peers map[enode.ID]*Peer, inboundCount int, c *
 }
 
 // listenLoop runs in its own goroutine and accepts
-// inbound connections.
-func (srv *Server) listenLoop() {
-	srv.log.Debug("TCP listener up", "addr", srv.listener.Addr())
+// inbound connections, which are set up with the given flags.
+func (srv *Server) listenLoop(listener net.Listener, flags connFlag) {
+	srv.log.Debug("TCP listener up", "addr", listener.Addr(), "onion", flags&onionConn != 0)
 
 	// The slots channel limits accepts of new connections.
 	tokens := defaultMaxPendingPeers
@@ -822,7 +885,7 @@ func (srv *Server) listenLoop() {
 			lastLog time.Time
 		)
 		for {
-			fd, err = srv.listener.Accept()
+			fd, err = listener.Accept()
 			if netutil.IsTemporaryError(err) {
 				if time.Since(lastLog) > 1*time.Second {
 					srv.log.Debug("Temporary read error", "err", err)
@@ -851,7 +914,7 @@ func (srv *Server) listenLoop() {
 			srv.log.Trace("Accepted connection", "addr", fd.RemoteAddr())
 		}
 		go func() {
-			srv.SetupConn(fd, inboundConn, nil)
+			srv.SetupConn(fd, flags, nil)
 			slots <- struct{}{}
 		}()
 	}
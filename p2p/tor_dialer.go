@@ -203,5 +203,11 @@ func (t *TorDialer) dialViaTor(ctx context.Context, dest *enode.Node, onionAddr
 	}
 	log.Info("TorDialer SOCKS5 dial successful", "target", target)
 
-	return conn, nil
+	return &torConn{conn}, nil
+}
+
+// torConn marks connections established through the Tor SOCKS5 proxy, so the
+// server can account them as onion peers.
+type torConn struct {
+	net.Conn
 }